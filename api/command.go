@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/importer"
+	"github.com/Massad/gin-boilerplate/models"
+)
+
+// runCommand runs a command line tool instead of the server and returns the exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "migrate":
+		return migrateCommand()
+	case "validate":
+		return validateCommand(args)
+	case "import":
+		return importCommand(args)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q, available: migrate, validate, import\n", name)
+	return 2
+}
+
+// migrateCommand runs the pending migrations without starting the server
+func migrateCommand() int {
+	db.Init()
+	if err := models.RunMigrations(); err != nil {
+		fmt.Fprintf(os.Stderr, "migrations failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// validateCommand runs the data-quality checks and prints the report as JSON. It exits with 1 when
+// a check of error severity fails and blocking is on (-block or VALIDATION_BLOCK_INGESTION), so an
+// ingestion pipeline can stop on it.
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	tahun := flags.Int("tahun", time.Now().Year(), "year to check")
+	bulan := flags.Int("bulan", 0, "month to check, 0 for the whole year")
+	checks := flags.String("checks", "", "comma separated check names, empty for every check")
+	tolerance := flags.Float64("tolerance", 0.01, "largest difference accepted when comparing sums")
+	limit := flags.Int("limit", 100, "issues listed per check, 0 for all")
+	block := flags.Bool("block", models.ValidationBlocksIngestion(), "exit with 1 when a check of error severity fails")
+	list := flags.Bool("list", false, "list the checks and exit")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if *list {
+		for _, check := range models.ValidationChecks() {
+			fmt.Printf("%-28s %-8s %s\n", check.Name, check.Severity, check.Description)
+		}
+		return 0
+	}
+
+	db.Init()
+
+	var names []string
+	for _, name := range strings.Split(*checks, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	scope := models.ValidationScope{Tahun: *tahun, Bulan: *bulan, Tolerance: *tolerance, Limit: *limit}
+	report, err := models.ValidationModel{}.Run(scope, names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validation failed: %v\n", err)
+		return 2
+	}
+
+	report.Blocked = *block && !report.Passed
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write the report: %v\n", err)
+		return 2
+	}
+	if report.Blocked {
+		return 1
+	}
+	return 0
+}
+
+// importCommand syncs the upstream procurement feeds and prints the run with its diff report as JSON.
+// It exits with 1 when the validation gate blocks the import and 2 on any other failure.
+func importCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	feeds := flags.String("feeds", "", "comma separated feeds ("+strings.Join(importer.Feeds, ", ")+"), empty for every feed")
+	dryRun := flags.Bool("dry-run", false, "report the diff without writing")
+	full := flags.Bool("full", false, "ignore the cursors and compare every record")
+	limit := flags.Int("limit", 100, "changes and issues listed per feed, 0 for all")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	importer.Init()
+	source, err := importer.GetSource()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 2
+	}
+
+	db.Init()
+
+	var names []string
+	for _, name := range strings.Split(*feeds, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	options := models.ImportOptions{Feeds: names, DryRun: *dryRun, Full: *full, Limit: *limit}
+	run, err := models.ImportModel{}.Sync(context.Background(), source, options)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(run); encodeErr != nil {
+		fmt.Fprintf(os.Stderr, "could not write the report: %v\n", encodeErr)
+		return 2
+	}
+	if errors.Is(err, models.ErrIngestionBlocked) {
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 2
+	}
+	return 0
+}
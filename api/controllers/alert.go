@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertController ...
+type AlertController struct{}
+
+var alertRuleModel = new(models.AlertRuleModel)
+var alertModel = new(models.AlertModel)
+
+var alertForm = new(forms.AlertForm)
+
+// alertIDParam ...
+func alertIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return 0, false
+	}
+	return id, true
+}
+
+// alertScope is the satker whose alerts the user may see, 0 for managers who see all of them
+func alertScope(c *gin.Context) (int64, bool) {
+	if new(AuthController).userCan(c, "manage_alerts") {
+		return 0, true
+	}
+
+	user, err := userModel.One(getUserID(c))
+	if err != nil || user.Idsatker == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"Message": "Your account is not linked to a satker"})
+		return 0, false
+	}
+	return user.Idsatker, true
+}
+
+// alertError maps alert lookups to a response
+func alertError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Alert not found"})
+	case errors.Is(err, models.ErrAlertForbidden):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"Message": err.Error()})
+	default:
+		transitionError(c, err, message)
+	}
+}
+
+// Rules godoc
+// @Summary List alert rules
+// @Schemes
+// @Description All rules with the metrics they can be defined on
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Success 	 200  {array}  models.AlertRule
+// @Security BearerAuth
+// @Router /alert-rules [GET]
+func (ctrl AlertController) Rules(c *gin.Context) {
+	rules, err := alertRuleModel.All()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules, "metrics": models.AlertMetrics()})
+}
+
+// CreateRule godoc
+// @Summary Create an alert rule
+// @Schemes
+// @Description Rules are evaluated against the latest month of every satker
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param rule body forms.AlertRuleForm true "Rule"
+// @Success 	 200  {object}  models.AlertRule
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alert-rules [POST]
+func (ctrl AlertController) CreateRule(c *gin.Context) {
+	var form forms.AlertRuleForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": alertForm.Rule(validationErr)})
+		return
+	}
+
+	rule, err := alertRuleModel.Create(getUserID(c), form)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownMetric) {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Alert rule could not be created"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule created", "data": rule})
+}
+
+// UpdateRule godoc
+// @Summary Update an alert rule
+// @Schemes
+// @Description Replaces the rule definition, existing alerts are re-checked on the next evaluation
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param id path int true "Rule ID"
+// @Param rule body forms.AlertRuleForm true "Rule"
+// @Success 	 200  {object}  models.AlertRule
+// @Failure      404  {object}  models.MessageResponse
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alert-rules/{id} [PUT]
+func (ctrl AlertController) UpdateRule(c *gin.Context) {
+	id, ok := alertIDParam(c)
+	if !ok {
+		return
+	}
+
+	var form forms.AlertRuleForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": alertForm.Rule(validationErr)})
+		return
+	}
+
+	rule, err := alertRuleModel.Update(id, form)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Alert rule not found"})
+		case errors.Is(err, models.ErrUnknownMetric):
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Alert rule could not be updated"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule updated", "data": rule})
+}
+
+// DeleteRule godoc
+// @Summary Delete an alert rule
+// @Schemes
+// @Description The rule's alerts are deleted with it
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param id path int true "Rule ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alert-rules/{id} [DELETE]
+func (ctrl AlertController) DeleteRule(c *gin.Context) {
+	id, ok := alertIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := alertRuleModel.Delete(id); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Alert rule could not be deleted"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted"})
+}
+
+// Evaluate godoc
+// @Summary Evaluate the alert rules now
+// @Schemes
+// @Description Runs every enabled rule, meant to be called by ingestion jobs once new data is loaded
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: latest loaded)"
+// @Param bulan query int false "Last month considered (default: latest loaded)"
+// @Success 	 200  {object}  models.AlertEvaluation
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alert-rules/evaluate [POST]
+func (ctrl AlertController) Evaluate(c *gin.Context) {
+	var form forms.EvaluateAlertsForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": alertForm.Evaluate(validationErr)})
+		return
+	}
+
+	summary, err := alertModel.Evaluate(form.Tahun, form.Bulan)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Alert rules could not be evaluated", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rules evaluated", "data": summary})
+}
+
+// All godoc
+// @Summary List alerts
+// @Schemes
+// @Description Alerts of the caller's satker, or of every satker with manage_alerts
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param state query string false "open|acknowledged|resolved"
+// @Param tahun query int false "Year"
+// @Param idsatker query int false "Satker ID (manage_alerts only)"
+// @Param rule_id query int false "Rule ID"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 	 200  {object}  models.AlertPage
+// @Failure      403  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alerts [GET]
+func (ctrl AlertController) All(c *gin.Context) {
+	var form forms.AlertListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": alertForm.List(validationErr)})
+		return
+	}
+
+	idsatker, ok := alertScope(c)
+	if !ok {
+		return
+	}
+
+	page, err := alertModel.All(idsatker, form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Acknowledge godoc
+// @Summary Acknowledge an alert
+// @Schemes
+// @Description Marks an open alert of the caller's satker as seen
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Param note body forms.AlertNoteForm false "Note"
+// @Success 	 200  {object}  models.Alert
+// @Failure      403  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alerts/{id}/acknowledge [POST]
+func (ctrl AlertController) Acknowledge(c *gin.Context) {
+	ctrl.changeState(c, alertModel.Acknowledge, "Alert acknowledged", "Alert could not be acknowledged")
+}
+
+// Resolve godoc
+// @Summary Resolve an alert
+// @Schemes
+// @Description Closes an open or acknowledged alert of the caller's satker
+// @Tags Alert
+// @Accept json
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Param note body forms.AlertNoteForm false "Note"
+// @Success 	 200  {object}  models.Alert
+// @Failure      403  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /alerts/{id}/resolve [POST]
+func (ctrl AlertController) Resolve(c *gin.Context) {
+	ctrl.changeState(c, alertModel.Resolve, "Alert resolved", "Alert could not be resolved")
+}
+
+// changeState ...
+func (ctrl AlertController) changeState(c *gin.Context, change func(userID, idsatker, id int64, note string) (models.Alert, error), done, failed string) {
+	id, ok := alertIDParam(c)
+	if !ok {
+		return
+	}
+
+	// The note is optional, an empty body is fine
+	var form forms.AlertNoteForm
+	if c.Request.ContentLength > 0 {
+		if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": alertForm.Note(validationErr)})
+			return
+		}
+	}
+
+	idsatker, ok := alertScope(c)
+	if !ok {
+		return
+	}
+
+	alert, err := change(getUserID(c), idsatker, id, form.Note)
+	if err != nil {
+		alertError(c, err, failed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": done, "data": alert})
+}
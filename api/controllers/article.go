@@ -55,13 +55,26 @@ func (ctrl ArticleController) Create(c *gin.Context) {
 // @Tags Article
 // @Accept json
 // @Produce json
+// @Param page query int false "Page number (default 1), ignored when cursor is set"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Opaque cursor from meta.next_cursor or meta.prev_cursor"
+// @Param sort query string false "newest|oldest|updated|title|relevance (default newest, relevance when q is set)"
+// @Param q query string false "Full-text search over title and content"
+// @Param format query string false "html|markdown|text (default markdown)"
 // @Success 	 200  {object}  models.AllArticleResponse
 // @Failure      406  {object}  forms.ArticleResponse
 // @Router /articles [GET]
 func (ctrl ArticleController) All(c *gin.Context) {
 	userID := getUserID(c)
 
-	results, err := articleModel.All(userID)
+	var form forms.ArticleListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		message := articleForm.List(validationErr)
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": message})
+		return
+	}
+
+	results, err := articleModel.All(userID, form)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get articles"})
 		return
@@ -77,6 +90,7 @@ func (ctrl ArticleController) All(c *gin.Context) {
 // @Tags Article
 // @Accept json
 // @Produce json
+// @Param format query string false "html|markdown|text (default markdown)"
 // @Success 	 200  {object}  models.OneArticleResponse
 // @Failure      406  {object}  forms.ArticleResponse
 // @Router /article/{id} [GET]
@@ -91,11 +105,18 @@ func (ctrl ArticleController) One(c *gin.Context) {
 		return
 	}
 
+	var form forms.ArticleFormatForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Format should be one of html, markdown or text"})
+		return
+	}
+
 	data, err := articleModel.One(userID, getID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
 		return
 	}
+	data.ApplyFormat(form.Format)
 
 	c.JSON(http.StatusOK, gin.H{"data": data})
 }
@@ -132,7 +153,7 @@ func (ctrl ArticleController) Update(c *gin.Context) {
 
 	err = articleModel.Update(userID, getID, form)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Article could not be updated"})
+		articleAccessError(c, err, "Article could not be updated")
 		return
 	}
 
@@ -163,7 +184,7 @@ func (ctrl ArticleController) Delete(c *gin.Context) {
 
 	err = articleModel.Delete(userID, getID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Article could not be deleted"})
+		articleAccessError(c, err, "Article could not be deleted")
 		return
 	}
 
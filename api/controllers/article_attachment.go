@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/Massad/gin-boilerplate/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+var attachmentModel = new(models.AttachmentModel)
+
+// attachmentIDParam parses the :attachment route parameter, aborting with 404 when invalid
+func attachmentIDParam(c *gin.Context) (int64, bool) {
+	getID, err := strconv.ParseInt(c.Param("attachment"), 10, 64)
+	if getID == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return 0, false
+	}
+	return getID, true
+}
+
+// Upload Attachment godoc
+// @Summary Upload an article attachment
+// @Schemes
+// @Description Multipart upload of one file in the "file" field. The type is sniffed from the content and must match the extension (pdf, png, jpg, gif, webp, csv, xls, xlsx, doc, docx). Size is limited by ATTACHMENT_MAX_MB
+// @Tags Article
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param file formData file true "Attachment"
+// @Success 	 200  {object}  models.ArticleAttachment
+// @Failure      413  {object}  models.MessageResponse
+// @Failure      415  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/attachments [POST]
+func (ctrl ArticleController) Upload(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	maxBytes := models.AttachmentMaxBytes()
+	// Leave room for the multipart envelope, the file itself is checked below
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"Message": fmt.Sprintf("File must be at most %d MB", maxBytes>>20)})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "A file is required in the file field"})
+		return
+	}
+	if header.Size > maxBytes {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"Message": fmt.Sprintf("File must be at most %d MB", maxBytes>>20)})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "File could not be read"})
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "File could not be read"})
+		return
+	}
+	head = head[:n]
+
+	filename := filepath.Base(header.Filename)
+	contentType, err := models.DetectAttachmentType(filename, head)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"Message": err.Error()})
+		return
+	}
+
+	attachment, err := attachmentModel.Create(userID, id, filename, contentType, header.Size, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		articleAccessError(c, err, "Attachment could not be saved")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment uploaded", "data": attachment})
+}
+
+// Article Attachments godoc
+// @Summary List article attachments
+// @Schemes
+// @Description Attachments of an article, oldest first
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {array}  models.ArticleAttachment
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/attachments [GET]
+func (ctrl ArticleController) Attachments(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := attachmentModel.All(userID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+// Attachment URL godoc
+// @Summary Signed download URL for an attachment
+// @Schemes
+// @Description Returns a URL that downloads the attachment without authentication until expires_at (ATTACHMENT_URL_TTL_MINUTES)
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param attachment path int true "Attachment ID"
+// @Success 	 200  {object}  models.AttachmentURL
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/attachments/{attachment}/url [GET]
+func (ctrl ArticleController) AttachmentURL(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := attachmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	link, err := attachmentModel.URL(userID, id, attachmentID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Attachment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": link})
+}
+
+// Delete Attachment godoc
+// @Summary Delete an article attachment
+// @Schemes
+// @Description Remove the attachment record and its stored file
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param attachment path int true "Attachment ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/attachments/{attachment} [DELETE]
+func (ctrl ArticleController) DeleteAttachment(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := attachmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := attachmentModel.Delete(userID, id, attachmentID); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Attachment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// Download Attachment godoc
+// @Summary Download an attachment through a local signed URL
+// @Schemes
+// @Description Target of the signed URLs issued by the local storage driver. The signature is the authorization, no token is needed
+// @Tags Article
+// @Produce octet-stream
+// @Param key query string true "Object key"
+// @Param filename query string true "Download filename"
+// @Param expires query int true "Expiry (unix seconds)"
+// @Param signature query string true "HMAC signature"
+// @Success 	 200  {file}  file
+// @Failure      403  {object}  models.MessageResponse
+// @Router /attachments/download [GET]
+func (ctrl ArticleController) Download(c *gin.Context) {
+	local, ok := storage.GetStorage().(*storage.LocalStorage)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Not found"})
+		return
+	}
+
+	reader, filename, err := local.Open(c.Request.Context(), c.Request.URL.Query())
+	if errors.Is(err, storage.ErrInvalidSignature) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"Message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Attachment not found"})
+		return
+	}
+	defer reader.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// articleAccessError maps ownership errors to 404/403, anything else to 406 with message
+func articleAccessError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
+	case errors.Is(err, models.ErrArticleForbidden):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"Message": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": message})
+	}
+}
+
+// Article Editors godoc
+// @Summary List article co-editors
+// @Schemes
+// @Description Users who can edit the article next to its owner
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {array}  models.ArticleEditor
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/editors [GET]
+func (ctrl ArticleController) Editors(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	editors, err := articleModel.Editors(userID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": editors})
+}
+
+// Add Article Editor godoc
+// @Summary Add an article co-editor
+// @Schemes
+// @Description Only the owner and edit_any_article holders can add co-editors
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param editor body forms.ArticleEditorForm true "Editor"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      403  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/editors [POST]
+func (ctrl ArticleController) AddEditor(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	var form forms.ArticleEditorForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "user_id is required"})
+		return
+	}
+
+	if err := articleModel.AddEditor(userID, id, form.UserID); err != nil {
+		articleAccessError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Editor added"})
+}
+
+// Remove Article Editor godoc
+// @Summary Remove an article co-editor
+// @Schemes
+// @Description The owner and edit_any_article holders can remove any co-editor, co-editors can remove themselves
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param user path int true "Editor user ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      403  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/editors/{user} [DELETE]
+func (ctrl ArticleController) RemoveEditor(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	editorID, err := strconv.ParseInt(c.Param("user"), 10, 64)
+	if editorID == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	if err := articleModel.RemoveEditor(userID, id, editorID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Editor not found"})
+			return
+		}
+		articleAccessError(c, err, "Editor could not be removed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Editor removed"})
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Article Revisions godoc
+// @Summary List article revisions
+// @Schemes
+// @Description Every create, update and restore stores an immutable revision with author and timestamp, newest first
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {array}  models.ArticleRevision
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/revisions [GET]
+func (ctrl ArticleController) Revisions(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := articleModel.Revisions(userID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+// Article Revision Diff godoc
+// @Summary Diff two article revisions
+// @Schemes
+// @Description Line-level diff of title and content between two revisions
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param from query int true "Base revision"
+// @Param to query int true "Compared revision"
+// @Success 	 200  {object}  models.RevisionDiff
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/revisions/diff [GET]
+func (ctrl ArticleController) Diff(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	from, fromErr := strconv.ParseInt(c.Query("from"), 10, 64)
+	to, toErr := strconv.ParseInt(c.Query("to"), 10, 64)
+	if fromErr != nil || toErr != nil || from <= 0 || to <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"Message": "from and to revisions are required"})
+		return
+	}
+
+	diff, err := articleModel.Diff(userID, id, from, to)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": diff})
+}
+
+// Restore Article Revision godoc
+// @Summary Restore an article revision
+// @Schemes
+// @Description Copy an old revision back into the article, recorded as a new revision
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param revision path int true "Revision number"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/revisions/{revision}/restore [POST]
+func (ctrl ArticleController) Restore(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	revision, err := strconv.ParseInt(c.Param("revision"), 10, 64)
+	if err != nil || revision <= 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	if err := articleModel.Restore(userID, id, revision); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Article restored"})
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var categoryModel = new(models.CategoryModel)
+
+// Article Categories godoc
+// @Summary List article categories
+// @Schemes
+// @Description All categories ordered by name
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Success 	 200  {array}  models.ArticleCategory
+// @Security BearerAuth
+// @Router /article-categories [GET]
+func (ctrl ArticleController) Categories(c *gin.Context) {
+	categories, err := categoryModel.All()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": categories})
+}
+
+// Create Article Category godoc
+// @Summary Create an article category
+// @Schemes
+// @Description Category names are unique
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param category body forms.CategoryForm true "Category"
+// @Success 	 200  {object}  models.ArticleCategory
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article-categories [POST]
+func (ctrl ArticleController) CreateCategory(c *gin.Context) {
+	var form forms.CategoryForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": articleForm.Category(validationErr)})
+		return
+	}
+
+	category, err := categoryModel.Create(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Category could not be created, the name may already exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category created", "data": category})
+}
+
+// Delete Article Category godoc
+// @Summary Delete an article category
+// @Schemes
+// @Description Articles of the category become uncategorized
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article-categories/{id} [DELETE]
+func (ctrl ArticleController) DeleteCategory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	if err := categoryModel.Delete(id); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// Mark Article Read godoc
+// @Summary Mark an article as read
+// @Schemes
+// @Description Store a read receipt for a published article addressed to the caller. Reading again keeps the first read time
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/read [POST]
+func (ctrl ArticleController) MarkRead(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := articleModel.MarkRead(userID, id); err != nil {
+		if errors.Is(err, models.ErrNotTargeted) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Read receipt could not be stored"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Article marked as read"})
+}
+
+// Article Reads godoc
+// @Summary Read receipts of an article
+// @Schemes
+// @Description Who read the article, and per OPD how many of the targeted users did. unread_opds lists the OPDs where nobody has read it yet
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {object}  models.ReadReport
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/reads [GET]
+func (ctrl ArticleController) Reads(c *gin.Context) {
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := articleModel.Reads(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Article not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
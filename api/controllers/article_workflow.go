@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// articleIDParam parses the :id route parameter, aborting with 404 when invalid
+func articleIDParam(c *gin.Context) (int64, bool) {
+	getID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if getID == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return 0, false
+	}
+	return getID, true
+}
+
+// transitionError maps workflow errors to a response
+func transitionError(c *gin.Context, err error, message string) {
+	if errors.Is(err, models.ErrInvalidTransition) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"Message": err.Error()})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": message})
+}
+
+// Submit Article godoc
+// @Summary Submit an article for review
+// @Schemes
+// @Description Move the caller's draft article to in_review
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/submit [POST]
+func (ctrl ArticleController) Submit(c *gin.Context) {
+	userID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := articleModel.Submit(userID, id); err != nil {
+		transitionError(c, err, "Article could not be submitted")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Article submitted for review"})
+}
+
+// Approve Article godoc
+// @Summary Approve an article in review
+// @Schemes
+// @Description Publish an article in review, or schedule it when publish_at is in the future. Reviewers can not approve their own articles
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param review body forms.ReviewArticleForm false "Review note"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/approve [POST]
+func (ctrl ArticleController) Approve(c *gin.Context) {
+	ctrl.review(c, true)
+}
+
+// Reject Article godoc
+// @Summary Reject an article in review
+// @Schemes
+// @Description Send an article in review back to draft with a note
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param review body forms.ReviewArticleForm false "Review note"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/reject [POST]
+func (ctrl ArticleController) Reject(c *gin.Context) {
+	ctrl.review(c, false)
+}
+
+// review handles both approve and reject
+func (ctrl ArticleController) review(c *gin.Context, approve bool) {
+	reviewerID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	var form forms.ReviewArticleForm
+	if c.Request.ContentLength > 0 {
+		if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Review note should be at most 500 characters"})
+			return
+		}
+	}
+
+	if approve {
+		if err := articleModel.Approve(reviewerID, id, form.Note); err != nil {
+			transitionError(c, err, "Article could not be approved")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Article approved"})
+		return
+	}
+
+	if err := articleModel.Reject(reviewerID, id, form.Note); err != nil {
+		transitionError(c, err, "Article could not be rejected")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Article rejected"})
+}
+
+// Archive Article godoc
+// @Summary Archive an article
+// @Schemes
+// @Description Withdraw a scheduled or published article. Owners can archive their own articles, reviewers any article
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /article/{id}/archive [POST]
+func (ctrl ArticleController) Archive(c *gin.Context) {
+	ownerID := getUserID(c)
+
+	id, ok := articleIDParam(c)
+	if !ok {
+		return
+	}
+
+	if new(AuthController).userCan(c, "review_article") {
+		ownerID = 0
+	}
+
+	if err := articleModel.Archive(ownerID, id); err != nil {
+		transitionError(c, err, "Article could not be archived")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Article archived"})
+}
+
+// Article Feed godoc
+// @Summary Published articles feed
+// @Schemes
+// @Description Published, unexpired articles addressed to the caller (by role, jenis_opd or idsatker) and the caller's own. Supports the same paging, sorting, search, tag and category options as /articles
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Param cursor query string false "Cursor"
+// @Param sort query string false "newest|oldest|updated|title|relevance"
+// @Param q query string false "Full-text search"
+// @Success 	 200  {object}  models.AllArticleResponse
+// @Failure      406  {object}  forms.ArticleResponse
+// @Security BearerAuth
+// @Router /articles/feed [GET]
+func (ctrl ArticleController) Feed(c *gin.Context) {
+	var form forms.ArticleListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": articleForm.List(validationErr)})
+		return
+	}
+
+	results, err := articleModel.Feed(getUserID(c), form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get articles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// Article Review Queue godoc
+// @Summary Articles waiting for review
+// @Schemes
+// @Description Articles in review of all users
+// @Tags Article
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Param cursor query string false "Cursor"
+// @Param sort query string false "newest|oldest|updated|title|relevance"
+// @Param q query string false "Full-text search"
+// @Success 	 200  {object}  models.AllArticleResponse
+// @Failure      406  {object}  forms.ArticleResponse
+// @Security BearerAuth
+// @Router /articles/review [GET]
+func (ctrl ArticleController) ReviewQueue(c *gin.Context) {
+	var form forms.ArticleListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": articleForm.List(validationErr)})
+		return
+	}
+
+	results, err := articleModel.ReviewQueue(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get articles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -1,15 +1,11 @@
 package controllers
 
 import (
-	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 
 	"github.com/Massad/gin-boilerplate/forms"
 	"github.com/Massad/gin-boilerplate/models"
 	"github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v4"
 )
 
 // AuthController ...
@@ -26,6 +22,12 @@ func (ctl AuthController) TokenValid(c *gin.Context) {
 		return
 	}
 
+	// Cookies are sent automatically by the browser, so state-changing requests need the double-submit CSRF token
+	if authModel.UsesCookie(c.Request) && !isReadOnlyMethod(c.Request.Method) && !authModel.ValidCSRF(c.Request) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Invalid CSRF token"})
+		return
+	}
+
 	userID, err := authModel.FetchAuth(tokenAuth)
 	if err != nil {
 		//Token does not exists in Redis (User logged out or expired)
@@ -34,10 +36,13 @@ func (ctl AuthController) TokenValid(c *gin.Context) {
 	}
 
 	// Refresh token expiration on every action (sliding expiration)
-	err = authModel.RefreshAuth(tokenAuth.AccessUUID)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Please login first"})
-		return
+	// Impersonation tokens keep their fixed lifetime
+	if !tokenAuth.IsImpersonated() {
+		err = authModel.RefreshAuth(tokenAuth.AccessUUID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Please login first"})
+			return
+		}
 	}
 
 	// Fetch user and roles
@@ -56,6 +61,41 @@ func (ctl AuthController) TokenValid(c *gin.Context) {
 	c.Set("userID", userID)
 	c.Set("user", user)
 	c.Set("roles", roles)
+
+	if tokenAuth.IsImpersonated() {
+		c.Set("actorID", tokenAuth.ActorID)
+		c.Set("accessUUID", tokenAuth.AccessUUID)
+
+		// Write operations are blocked unless the token was explicitly issued with write access
+		if tokenAuth.ReadOnly && !isReadOnlyMethod(c.Request.Method) {
+			c.Set("impersonationBlocked", true)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Write operations are not allowed while impersonating"})
+			return
+		}
+	}
+}
+
+// isReadOnlyMethod ...
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// userCan reports whether the authenticated user is an admin or has the given permission
+func (ctl AuthController) userCan(c *gin.Context, permission string) bool {
+	rolesInterface, rolesOk := c.Get("roles")
+	if rolesOk {
+		roles, typeOk := rolesInterface.([]models.Role)
+		if typeOk {
+			for _, role := range roles {
+				if role.Name == "admin" {
+					return true
+				}
+			}
+		}
+	}
+
+	hasPerm, err := userModel.HasPermission(c.GetInt64("userID"), permission)
+	return err == nil && hasPerm
 }
 
 // HasPermission ...
@@ -66,23 +106,8 @@ func (ctl AuthController) HasPermission(permission string) gin.HandlerFunc {
 			return
 		}
 
-		// Bypass permission check for admin role
-		rolesInterface, rolesOk := c.Get("roles")
-		if rolesOk {
-			roles, typeOk := rolesInterface.([]models.Role)
-			if typeOk {
-				for _, role := range roles {
-					if role.Name == "admin" {
-						c.Next()
-						return
-					}
-				}
-			}
-		}
-
-		userID := c.GetInt64("userID")
-		hasPerm, err := userModel.HasPermission(userID, permission)
-		if err != nil || !hasPerm {
+		// Admin role bypasses the permission check
+		if !ctl.userCan(c, permission) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Insufficient permissions"})
 			return
 		}
@@ -111,62 +136,55 @@ func (ctl AuthController) Refresh(c *gin.Context) {
 		return
 	}
 
-	//verify the token
-	token, err := jwt.Parse(tokenForm.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		//Make sure that the token method conform to "SigningMethodHMAC"
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("REFRESH_SECRET")), nil
-	})
-	//if there is an error, the token must have expired
+	ts, err := authModel.RotateRefreshToken(tokenForm.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
 		return
 	}
-	//is token valid?
-	if _, ok := token.Claims.(jwt.Claims); !ok && !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
+
+	tokens := map[string]string{
+		"access_token":  ts.AccessToken,
+		"refresh_token": ts.RefreshToken,
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh Cookie Token godoc
+// @Summary Refresh cookie session
+// @Schemes
+// @Description Rotate the access/refresh cookies of a cookie-based session. Requires the X-CSRF-Token header to match the csrf_token cookie
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param X-CSRF-Token header string true "CSRF token"
+// @Success 	 200  {object}  models.CSRFResponse
+// @Failure      401  {object}  models.MessageResponse
+// @Failure      403  {object}  models.MessageResponse
+// @Router /token/refresh-cookie [POST]
+func (ctl AuthController) RefreshCookie(c *gin.Context) {
+	if !authModel.ValidCSRF(c.Request) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Invalid CSRF token"})
 		return
 	}
-	//Since token is valid, get the uuid:
-	claims, ok := token.Claims.(jwt.MapClaims) //the token claims should conform to MapClaims
-	if ok && token.Valid {
-		refreshUUID, ok := claims["refresh_uuid"].(string) //convert the interface to string
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
-			return
-		}
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["user_id"]), 10, 64)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
-			return
-		}
-		//Delete the previous Refresh Token
-		deleted, delErr := authModel.DeleteAuth(refreshUUID)
-		if delErr != nil || deleted == 0 { //if any goes wrong
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
-			return
-		}
 
-		//Create new pairs of refresh and access tokens
-		ts, createErr := authModel.CreateToken(userID)
-		if createErr != nil {
-			c.JSON(http.StatusForbidden, gin.H{"message": "Invalid authorization, please login again"})
-			return
-		}
-		//save the tokens metadata to redis
-		saveErr := authModel.CreateAuth(userID, ts)
-		if saveErr != nil {
-			c.JSON(http.StatusForbidden, gin.H{"message": "Invalid authorization, please login again"})
-			return
-		}
-		tokens := map[string]string{
-			"access_token":  ts.AccessToken,
-			"refresh_token": ts.RefreshToken,
-		}
-		c.JSON(http.StatusOK, tokens)
-	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
+	refreshCookie, err := c.Request.Cookie(models.RefreshTokenCookie)
+	if err != nil || refreshCookie.Value == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
+		return
 	}
+
+	ts, err := authModel.RotateRefreshToken(refreshCookie.Value)
+	if err != nil {
+		clearSessionCookies(c)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid authorization, please login again"})
+		return
+	}
+
+	csrfToken, err := setSessionCookies(c, ts.AccessToken, ts.RefreshToken)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session refreshed", "csrf_token": csrfToken})
 }
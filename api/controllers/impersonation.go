@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationController ...
+type ImpersonationController struct{}
+
+var impersonationModel = new(models.ImpersonationModel)
+
+// Start Impersonation godoc
+// @Summary Login as another user
+// @Schemes
+// @Description Issue a time-limited access token for another user. Write operations are blocked unless allow_write is set and the caller has impersonate_users_write
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param impersonate body forms.ImpersonateForm true "Target user"
+// @Success 200 {object} models.ImpersonationResponse
+// @Failure 403 {object} models.MessageResponse
+// @Failure 406 {object} models.MessageResponse
+// @Security BearerAuth
+// @Router /user/impersonate [post]
+func (ctrl ImpersonationController) Start(c *gin.Context) {
+	actorID := getUserID(c)
+
+	// Nested impersonation would hide the real actor
+	if c.GetInt64("actorID") != 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Already impersonating a user"})
+		return
+	}
+
+	var form forms.ImpersonateForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Invalid form"})
+		return
+	}
+
+	if form.AllowWrite && !new(AuthController).userCan(c, "impersonate_users_write") {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Insufficient permissions for write impersonation"})
+		return
+	}
+
+	resp, err := impersonationModel.Start(actorID, form.UserID, !form.AllowWrite, c.Writer.Header().Get("X-Request-Id"), c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Impersonation Logs godoc
+// @Summary Impersonation audit trail
+// @Schemes
+// @Description List impersonation events, newest first
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param actor_id query int false "Filter by the real user"
+// @Param subject_id query int false "Filter by the impersonated user"
+// @Param limit query int false "Max rows (default 100, max 500)"
+// @Success 200 {array} models.ImpersonationLog
+// @Failure 406 {object} models.MessageResponse
+// @Security BearerAuth
+// @Router /user/impersonation-logs [get]
+func (ctrl ImpersonationController) Logs(c *gin.Context) {
+	actorID, _ := strconv.ParseInt(c.DefaultQuery("actor_id", "0"), 10, 64)
+	subjectID, _ := strconv.ParseInt(c.DefaultQuery("subject_id", "0"), 10, 64)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	logs, err := impersonationModel.All(actorID, subjectID, limit)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Could not get impersonation logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": logs})
+}
+
+// Audit records every request made with an impersonation token.
+// It must run before the auth middleware so it sees the final status.
+func (ctrl ImpersonationController) Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		actorID := c.GetInt64("actorID")
+		if actorID == 0 {
+			return
+		}
+
+		event := "request"
+		if c.GetBool("impersonationBlocked") {
+			event = "blocked"
+		}
+
+		impersonationModel.Log(models.ImpersonationLog{
+			ActorID:    actorID,
+			SubjectID:  c.GetInt64("userID"),
+			AccessUUID: c.GetString("accessUUID"),
+			Event:      event,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Status:     c.Writer.Status(),
+			RequestID:  c.Writer.Header().Get("X-Request-Id"),
+			ClientIP:   c.ClientIP(),
+		})
+	}
+}
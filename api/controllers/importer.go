@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/importer"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportController ...
+type ImportController struct{}
+
+var importModel = new(models.ImportModel)
+
+var importForm = new(forms.ImportForm)
+
+// Run godoc
+// @Summary Import the upstream procurement feeds
+// @Schemes
+// @Description Loads the records of status_paket, peta_detail and detail_barjas modified since the last run. A dry run reports the same diff without writing. Answers 409 with the run when the validation gate blocks ingestion.
+// @Tags Import
+// @Accept json
+// @Produce json
+// @Param feeds query string false "Comma separated feeds, empty for every feed"
+// @Param dry_run query bool false "Report the diff without writing"
+// @Param full query bool false "Ignore the cursors and compare every record"
+// @Param limit query int false "Changes and issues listed per feed (default: all)"
+// @Success 	 200  {object}  models.ImportRunDetail
+// @Failure      406  {object}  models.MessageResponse
+// @Failure      409  {object}  models.ImportRunDetail
+// @Failure      503  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /imports/run [POST]
+func (ctrl ImportController) Run(c *gin.Context) {
+	var form forms.RunImportForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": importForm.Run(validationErr)})
+		return
+	}
+
+	source, err := importer.GetSource()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+		return
+	}
+
+	run, err := importModel.Sync(c.Request.Context(), source, models.ImportOptions{
+		Feeds:  form.FeedNames(),
+		DryRun: form.DryRun,
+		Full:   form.Full,
+		Limit:  form.Limit,
+		RunBy:  getUserID(c),
+	})
+	switch {
+	case errors.Is(err, models.ErrUnknownFeed):
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+	case errors.Is(err, models.ErrIngestionBlocked):
+		c.AbortWithStatusJSON(http.StatusConflict, run)
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Import failed", "error": err.Error(), "data": run})
+	default:
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+// All godoc
+// @Summary List import runs
+// @Schemes
+// @Description Runs newest first, with the cursor of every feed
+// @Tags Import
+// @Accept json
+// @Produce json
+// @Param page query int false "Page (default 1)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 	 200  {object}  models.ImportRunPage
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /imports [GET]
+func (ctrl ImportController) All(c *gin.Context) {
+	var form forms.ImportListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": importForm.List(validationErr)})
+		return
+	}
+
+	page, err := importModel.All(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get import runs"})
+		return
+	}
+	cursors, err := importModel.Cursors()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get import cursors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": page.Data, "meta": page.Meta, "cursors": cursors})
+}
+
+// One godoc
+// @Summary Get an import run
+// @Schemes
+// @Description The run with the diff report of every feed
+// @Tags Import
+// @Accept json
+// @Produce json
+// @Param id path int true "Run ID"
+// @Success 	 200  {object}  models.ImportRunDetail
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /imports/{id} [GET]
+func (ctrl ImportController) One(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	run, err := importModel.One(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Import run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": run})
+}
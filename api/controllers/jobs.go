@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/Massad/gin-boilerplate/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobController ...
+type JobController struct{}
+
+var jobModel = new(models.JobModel)
+
+var jobRunModel = new(models.JobRunModel)
+
+var jobForm = new(forms.JobForm)
+
+// All godoc
+// @Summary List the background jobs
+// @Schemes
+// @Description Every job with its schedule, next run, whether a replica is running it and its last run
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Success 	 200  {array}   models.JobInfo
+// @Failure      503  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /jobs [GET]
+func (ctrl JobController) All(c *gin.Context) {
+	jobs, err := jobModel.Status()
+	if errors.Is(err, models.ErrSchedulerNotStarted) {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// Run godoc
+// @Summary Run a job now
+// @Schemes
+// @Description Starts the job in the background and answers 202 with the run, poll GET /jobs/runs/{id} for its outcome. Answers 409 while the job runs on any replica.
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 	 202  {object}  models.JobRun
+// @Failure      404  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Failure      503  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /jobs/{name}/run [POST]
+func (ctrl JobController) Run(c *gin.Context) {
+	run, err := jobModel.Trigger(c.Param("name"), getUserID(c))
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Job not found"})
+	case errors.Is(err, scheduler.ErrJobRunning):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": err.Error()})
+	case errors.Is(err, models.ErrSchedulerNotStarted):
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not start the job", "error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Job started", "data": run})
+	}
+}
+
+// Runs godoc
+// @Summary List job runs
+// @Schemes
+// @Description Run history newest first, scheduled and manual
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param job query string false "Job name"
+// @Param status query string false "running, success or failed"
+// @Param page query int false "Page (default 1)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 	 200  {object}  models.JobRunPage
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /jobs/runs [GET]
+func (ctrl JobController) Runs(c *gin.Context) {
+	var form forms.JobRunListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": jobForm.List(validationErr)})
+		return
+	}
+
+	page, err := jobRunModel.All(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get job runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// OneRun godoc
+// @Summary Get a job run
+// @Schemes
+// @Description The run with its output
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param id path int true "Run ID"
+// @Success 	 200  {object}  models.JobRun
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /jobs/runs/{id} [GET]
+func (ctrl JobController) OneRun(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	run, err := jobRunModel.One(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Job run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": run})
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationController ...
+type NotificationController struct{}
+
+var notificationModel = new(models.NotificationModel)
+
+var notificationForm = new(forms.NotificationForm)
+
+// All godoc
+// @Summary List the caller's notifications
+// @Schemes
+// @Description In-app inbox, newest first, with the unread count
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 	 200  {object}  models.NotificationPage
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /notifications [GET]
+func (ctrl NotificationController) All(c *gin.Context) {
+	var form forms.NotificationListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": notificationForm.List(validationErr)})
+		return
+	}
+
+	page, err := notificationModel.Inbox(getUserID(c), form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// UnreadCount godoc
+// @Summary Count the caller's unread notifications
+// @Schemes
+// @Description Meant for polling the badge of the inbox
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Success 	 200  {object}  gin.H
+// @Security BearerAuth
+// @Router /notifications/unread-count [GET]
+func (ctrl NotificationController) UnreadCount(c *gin.Context) {
+	count, err := notificationModel.UnreadCount(getUserID(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+// MarkRead godoc
+// @Summary Mark a notification as read
+// @Schemes
+// @Description Marking a read notification again keeps the first read time
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Param id path int true "Notification ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /notifications/{id}/read [POST]
+func (ctrl NotificationController) MarkRead(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	if err := notificationModel.MarkRead(getUserID(c), id); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllRead godoc
+// @Summary Mark every notification as read
+// @Schemes
+// @Description Returns how many notifications were unread
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Success 	 200  {object}  gin.H
+// @Security BearerAuth
+// @Router /notifications/read-all [POST]
+func (ctrl NotificationController) MarkAllRead(c *gin.Context) {
+	count, err := notificationModel.MarkAllRead(getUserID(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not mark notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "data": gin.H{"marked": count}})
+}
+
+// Preferences godoc
+// @Summary Get the caller's notification preferences
+// @Schemes
+// @Description Channels and muted events, with the webhook signing secret
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Success 	 200  {object}  models.NotificationPreferences
+// @Security BearerAuth
+// @Router /notifications/preferences [GET]
+func (ctrl NotificationController) Preferences(c *gin.Context) {
+	prefs, err := notificationModel.Preferences(getUserID(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": prefs})
+}
+
+// SavePreferences godoc
+// @Summary Update the caller's notification preferences
+// @Schemes
+// @Description Omitted fields keep their value. Enabling the webhook generates its signing secret.
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Param preferences body forms.NotificationPreferencesForm true "Preferences"
+// @Success 	 200  {object}  models.NotificationPreferences
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /notifications/preferences [PUT]
+func (ctrl NotificationController) SavePreferences(c *gin.Context) {
+	var form forms.NotificationPreferencesForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": notificationForm.Preferences(validationErr)})
+		return
+	}
+
+	prefs, err := notificationModel.SavePreferences(getUserID(c), form)
+	if err != nil {
+		if errors.Is(err, models.ErrWebhookURL) {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Notification preferences could not be saved"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification preferences saved", "data": prefs})
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RankingSnapshotController ...
+type RankingSnapshotController struct{}
+
+var rankingSnapshotModel = new(models.RankingSnapshotModel)
+
+// Publish godoc
+// @Summary Publish the ranking of a period
+// @Schemes
+// @Description Freezes the live ranking of a month, triwulan or semester into a new immutable version. /sijagur/peringkat-kinerja serves the latest version from then on. The changelog lists the OPDs whose scores or ranks changed since the previous version.
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param body body forms.PublishRankingForm true "Period to publish"
+// @Success 200 {object} models.RankingSnapshotDetail
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Security BearerAuth
+// @Router /sijagur/peringkat-kinerja/publish [POST]
+func (ctrl RankingSnapshotController) Publish(c *gin.Context) {
+	var form forms.PublishRankingForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateRankingSnapshot(err), "error": err.Error()})
+		return
+	}
+
+	period, err := models.NewPeriod(form.Period, form.Periode, form.Month)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Please provide the month or periode to publish", "error": err.Error()})
+		return
+	}
+
+	snapshot, err := rankingSnapshotModel.Publish(form.Year, period, form.Note, getUserID(c))
+	if errors.Is(err, models.ErrSnapshotUnchanged) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Ranking could not be published", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ranking published", "data": snapshot})
+}
+
+// All godoc
+// @Summary List published ranking versions
+// @Schemes
+// @Description Published versions of a year, optionally of one period, newest version first
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param year query int true "Year"
+// @Param period query string false "bulan|triwulan|semester"
+// @Param periode query int false "Month, triwulan or semester number"
+// @Success 200 {array} models.RankingSnapshot
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/peringkat-kinerja/snapshots [GET]
+func (ctrl RankingSnapshotController) All(c *gin.Context) {
+	var form forms.SnapshotListForm
+	if err := c.ShouldBindQuery(&form); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateRankingSnapshot(err), "error": err.Error()})
+		return
+	}
+
+	snapshots, err := rankingSnapshotModel.All(form.Year, form.Period, form.Periode)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get ranking snapshots", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": snapshots})
+}
+
+// One godoc
+// @Summary Get a published ranking version
+// @Schemes
+// @Description A published version with the changelog from the version before it
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param id path int true "Snapshot ID"
+// @Success 200 {object} models.RankingSnapshotDetail
+// @Failure 404 {object} gin.H
+// @Router /sijagur/peringkat-kinerja/snapshots/{id} [GET]
+func (ctrl RankingSnapshotController) One(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return
+	}
+
+	snapshot, err := rankingSnapshotModel.One(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Ranking snapshot not found"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get the ranking snapshot", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": snapshot})
+}
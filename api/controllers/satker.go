@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SatkerController ...
+type SatkerController struct{}
+
+var satkerModel = new(models.SatkerModel)
+
+var satkerForm = new(forms.SatkerForm)
+
+// satkerIDParam ...
+func satkerIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return 0, false
+	}
+	return id, true
+}
+
+// satkerError maps registry writes to a response
+func satkerError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Satker not found"})
+	case errors.Is(err, models.ErrSatkerExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": err.Error()})
+	case errors.Is(err, models.ErrSatkerIDRequired), errors.Is(err, models.ErrSatkerParent), errors.Is(err, models.ErrSatkerPeriod):
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": message})
+	}
+}
+
+// All godoc
+// @Summary List satkers
+// @Schemes
+// @Description The satker master registry, filtered by search, jenis_opd, parent and the year a satker was active in
+// @Tags Satker
+// @Accept json
+// @Produce json
+// @Param q query string false "Search in name, short name or code"
+// @Param jenis_opd query string false "skpd|kecamatan"
+// @Param parent_idsatker query int false "Parent satker"
+// @Param tahun query int false "Only satkers active during the year"
+// @Param page query int false "Page (default 1)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 	 200  {object}  models.SatkerPage
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /satkers [GET]
+func (ctrl SatkerController) All(c *gin.Context) {
+	var form forms.SatkerListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": satkerForm.List(validationErr)})
+		return
+	}
+
+	page, err := satkerModel.All(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get satkers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Lookup godoc
+// @Summary Satker autocomplete
+// @Schemes
+// @Description Compact options for the frontend filters, codes starting with the search are listed first
+// @Tags Satker
+// @Accept json
+// @Produce json
+// @Param q query string false "Search in name, short name or code"
+// @Param jenis_opd query string false "skpd|kecamatan"
+// @Param parent_idsatker query int false "Parent satker"
+// @Param tahun query int false "Only satkers active during the year"
+// @Param limit query int false "Number of options (default 20, max 100)"
+// @Success 	 200  {array}  models.SatkerOption
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /satkers/lookup [GET]
+func (ctrl SatkerController) Lookup(c *gin.Context) {
+	var form forms.SatkerListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": satkerForm.List(validationErr)})
+		return
+	}
+
+	options, err := satkerModel.Lookup(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get satkers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": options})
+}
+
+// One godoc
+// @Summary Get a satker
+// @Schemes
+// @Description The satker with its parent name and the satkers directly below it
+// @Tags Satker
+// @Accept json
+// @Produce json
+// @Param id path int true "Satker ID (idsatker)"
+// @Success 	 200  {object}  models.SatkerDetail
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /satkers/{id} [GET]
+func (ctrl SatkerController) One(c *gin.Context) {
+	id, ok := satkerIDParam(c)
+	if !ok {
+		return
+	}
+
+	satker, err := satkerModel.One(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Satker not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": satker})
+}
+
+// Create godoc
+// @Summary Register a satker
+// @Schemes
+// @Description idsatker is the id used by the de_* tables
+// @Tags Satker
+// @Accept json
+// @Produce json
+// @Param satker body forms.SatkerDataForm true "Satker"
+// @Success 	 200  {object}  models.SatkerDetail
+// @Failure      406  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /satkers [POST]
+func (ctrl SatkerController) Create(c *gin.Context) {
+	var form forms.SatkerDataForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": satkerForm.Data(validationErr)})
+		return
+	}
+
+	satker, err := satkerModel.Create(form)
+	if err != nil {
+		satkerError(c, err, "Satker could not be created")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Satker created", "data": satker})
+}
+
+// Update godoc
+// @Summary Update a satker
+// @Schemes
+// @Description Replaces the record, idsatker cannot be changed
+// @Tags Satker
+// @Accept json
+// @Produce json
+// @Param id path int true "Satker ID (idsatker)"
+// @Param satker body forms.SatkerDataForm true "Satker"
+// @Success 	 200  {object}  models.SatkerDetail
+// @Failure      404  {object}  models.MessageResponse
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /satkers/{id} [PUT]
+func (ctrl SatkerController) Update(c *gin.Context) {
+	id, ok := satkerIDParam(c)
+	if !ok {
+		return
+	}
+
+	var form forms.SatkerDataForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": satkerForm.Data(validationErr)})
+		return
+	}
+
+	satker, err := satkerModel.Update(id, form)
+	if err != nil {
+		satkerError(c, err, "Satker could not be updated")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Satker updated", "data": satker})
+}
+
+// Delete godoc
+// @Summary Delete a satker
+// @Schemes
+// @Description Its children become top-level satkers, the de_* rows are kept
+// @Tags Satker
+// @Accept json
+// @Produce json
+// @Param id path int true "Satker ID (idsatker)"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /satkers/{id} [DELETE]
+func (ctrl SatkerController) Delete(c *gin.Context) {
+	id, ok := satkerIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := satkerModel.Delete(id); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Satker could not be deleted"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Satker deleted"})
+}
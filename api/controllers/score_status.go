@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScoreStatusController ...
+type ScoreStatusController struct{}
+
+var scoreThresholdModel = new(models.ScoreThresholdModel)
+
+var scoreStatusForm = new(forms.ScoreStatusForm)
+
+// scoreThresholdIDParam ...
+func scoreThresholdIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Invalid parameter"})
+		return 0, false
+	}
+	return id, true
+}
+
+// scoreThresholdError maps threshold writes to a response
+func scoreThresholdError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Score threshold not found"})
+	case errors.Is(err, models.ErrScoreThresholdExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": message})
+	}
+}
+
+// All godoc
+// @Summary List score-status thresholds
+// @Schemes
+// @Description Every band of every set, newest tahun_from first
+// @Tags ScoreStatus
+// @Accept json
+// @Produce json
+// @Param tahun_from query int false "Sets effective from this year"
+// @Param category query string false "all|barjas|fisik|anggaran|kinerja"
+// @Param jenis_opd query string false "skpd|kecamatan"
+// @Success 	 200  {array}   models.ScoreThreshold
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /score-thresholds [GET]
+func (ctrl ScoreStatusController) All(c *gin.Context) {
+	var form forms.ScoreThresholdListForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": scoreStatusForm.List(validationErr)})
+		return
+	}
+
+	thresholds, err := scoreThresholdModel.All(form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"Message": "Could not get score thresholds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": thresholds})
+}
+
+// Resolve godoc
+// @Summary Score-status bands of a ranking
+// @Schemes
+// @Description The bands applying to a ranking of the year, category and jenis_opd, lowest first, for legends
+// @Tags ScoreStatus
+// @Accept json
+// @Produce json
+// @Param tahun query int true "Year"
+// @Param category query string false "all|barjas|fisik|anggaran|kinerja"
+// @Param jenis_opd query string false "skpd|kecamatan"
+// @Success 	 200  {array}   models.ScoreThreshold
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /score-thresholds/resolve [GET]
+func (ctrl ScoreStatusController) Resolve(c *gin.Context) {
+	var form forms.ScoreThresholdResolveForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": scoreStatusForm.List(validationErr)})
+		return
+	}
+	if form.Category == "" {
+		form.Category = "all"
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": scoreThresholdModel.Resolve(form)})
+}
+
+// Create godoc
+// @Summary Add a score-status band
+// @Schemes
+// @Description Adds a band to the set of tahun_from, category and jenis_opd, creating the set if needed
+// @Tags ScoreStatus
+// @Accept json
+// @Produce json
+// @Param threshold body forms.ScoreThresholdForm true "Band"
+// @Success 	 200  {object}  models.ScoreThreshold
+// @Failure      406  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /score-thresholds [POST]
+func (ctrl ScoreStatusController) Create(c *gin.Context) {
+	var form forms.ScoreThresholdForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": scoreStatusForm.Threshold(validationErr)})
+		return
+	}
+
+	threshold, err := scoreThresholdModel.Create(form)
+	if err != nil {
+		scoreThresholdError(c, err, "Score threshold could not be created")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Score threshold created", "data": threshold})
+}
+
+// Update godoc
+// @Summary Update a score-status band
+// @Schemes
+// @Description Replaces the band, it can move to another set
+// @Tags ScoreStatus
+// @Accept json
+// @Produce json
+// @Param id path int true "Threshold ID"
+// @Param threshold body forms.ScoreThresholdForm true "Band"
+// @Success 	 200  {object}  models.ScoreThreshold
+// @Failure      404  {object}  models.MessageResponse
+// @Failure      406  {object}  models.MessageResponse
+// @Failure      409  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /score-thresholds/{id} [PUT]
+func (ctrl ScoreStatusController) Update(c *gin.Context) {
+	id, ok := scoreThresholdIDParam(c)
+	if !ok {
+		return
+	}
+
+	var form forms.ScoreThresholdForm
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": scoreStatusForm.Threshold(validationErr)})
+		return
+	}
+
+	threshold, err := scoreThresholdModel.Update(id, form)
+	if err != nil {
+		scoreThresholdError(c, err, "Score threshold could not be updated")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Score threshold updated", "data": threshold})
+}
+
+// Delete godoc
+// @Summary Delete a score-status band
+// @Schemes
+// @Description Deleting the last band of a set drops the set, rankings fall back to a less specific or older set
+// @Tags ScoreStatus
+// @Accept json
+// @Produce json
+// @Param id path int true "Threshold ID"
+// @Success 	 200  {object}  models.MessageResponse
+// @Failure      404  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /score-thresholds/{id} [DELETE]
+func (ctrl ScoreStatusController) Delete(c *gin.Context) {
+	id, ok := scoreThresholdIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := scoreThresholdModel.Delete(id); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"Message": "Score threshold could not be deleted"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Score threshold deleted"})
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/gin-gonic/gin"
+)
+
+// sessionCookieTTL matches the refresh token lifetime, the access token itself is still validated against Redis
+const sessionCookieTTL = time.Hour * 24 * 7
+
+// cookieSecure defaults to true, set COOKIE_SECURE=FALSE only for plain http development setups
+func cookieSecure() bool {
+	return os.Getenv("COOKIE_SECURE") != "FALSE"
+}
+
+// isCookieMode reports whether the client asked for cookie-based session transport
+func isCookieMode(c *gin.Context) bool {
+	return c.Query("mode") == "cookie"
+}
+
+// setSessionCookies stores the token pair in httpOnly cookies and issues a fresh CSRF token,
+// which is returned so the SPA can echo it in the X-CSRF-Token header
+func setSessionCookies(c *gin.Context, accessToken, refreshToken string) (string, error) {
+	csrfToken, err := authModel.CreateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := int(sessionCookieTTL.Seconds())
+	domain := os.Getenv("COOKIE_DOMAIN")
+	secure := cookieSecure()
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     models.AccessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		Domain:   domain,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// The refresh token is never sent outside the API (refresh and logout need it)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     models.RefreshTokenCookie,
+		Value:    refreshToken,
+		Path:     "/v1",
+		Domain:   domain,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// Readable by JS on purpose: double-submit CSRF
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     models.CSRFTokenCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		Domain:   domain,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: false,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return csrfToken, nil
+}
+
+// clearSessionCookies expires all session cookies
+func clearSessionCookies(c *gin.Context) {
+	domain := os.Getenv("COOKIE_DOMAIN")
+	secure := cookieSecure()
+
+	for name, path := range map[string]string{
+		models.AccessTokenCookie:  "/",
+		models.RefreshTokenCookie: "/v1",
+		models.CSRFTokenCookie:    "/",
+	} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     path,
+			Domain:   domain,
+			MaxAge:   -1,
+			Secure:   secure,
+			HttpOnly: name != models.CSRFTokenCookie,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -15,45 +16,49 @@ type SijagurController struct{}
 
 var sijagurModel = new(models.SijagurData)
 
-// getRealisasiData is a helper function to handle common logic for both bulan and tahun endpoints
-func (ctrl SijagurController) getRealisasiData(c *gin.Context, dataType string, getDataFunc func(int, int, int) ([]models.RealisasiData, error)) {
+// bindRealisasiQuery reads the tahun, idsatker and period of a realisasi request, ok is false once
+// the request was answered with an error
+func (ctrl SijagurController) bindRealisasiQuery(c *gin.Context) (tahun, idsatker int, period models.Period, ok bool) {
 	var queryForm forms.RealisasiQueryForm
 
 	// Bind query parameters
 	if err := c.ShouldBindQuery(&queryForm); err != nil {
 		sijagurForm := forms.SijagurForm{}
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateRealisasiQuery(err), "error": err.Error()})
-		return
+		return 0, 0, period, false
 	}
 
 	// Convert to integers with defaults
-	tahunInt, bulanInt, idsatkerInt, err := queryForm.ToInts()
+	tahun, bulan, idsatker, err := queryForm.ToInts()
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Invalid query parameters", "error": err.Error()})
-		return
+		return 0, 0, period, false
 	}
 
-	data, err := getDataFunc(tahunInt, bulanInt, idsatkerInt)
+	period, err = models.NewPeriod(queryForm.Period, queryForm.Periode, bulan)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get realisasi " + dataType + " data", "error": err.Error()})
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return 0, 0, period, false
+	}
+	return tahun, idsatker, period, true
+}
+
+// getRealisasiData is a helper function to handle common logic for both bulan and tahun endpoints.
+// The cards and their meta come from one query.
+func (ctrl SijagurController) getRealisasiData(c *gin.Context, dataType string) {
+	tahunInt, idsatkerInt, period, ok := ctrl.bindRealisasiQuery(c)
+	if !ok {
 		return
 	}
 
-	results := []models.SijagurResult{
-		{
-			Data: data,
-			Meta: models.RealisasiMeta{
-				Year:      tahunInt,
-				Month:     bulanInt,
-				MonthName: models.GetMonthName(bulanInt),
-				Idsatker:  idsatkerInt,
-				Type:      dataType,
-			},
-		},
+	result, err := sijagurModel.GetRealisasiResult(tahunInt, period, idsatkerInt, dataType)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get realisasi " + dataType + " data", "error": err.Error()})
+		return
 	}
 
 	response := models.SijagurResponse{
-		Results: results,
+		Results: []models.SijagurResult{result},
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -69,6 +74,8 @@ func (ctrl SijagurController) getRealisasiData(c *gin.Context, dataType string,
 // @Param tahun query int false "Year"
 // @Param bulan query int false "Month"
 // @Param idsatker query int false "Satker ID"
+// @Param period query string false "bulan|triwulan|semester" default(bulan)
+// @Param periode query int false "Triwulan (1-4) or semester (1-2), default: the one containing bulan"
 // @Success 	 200  {object}  models.RealisasiBulanResponse
 // @Failure      400  {object}  gin.H
 // @Failure      500  {object}  gin.H
@@ -77,7 +84,7 @@ func (ctrl SijagurController) GetRealisasiBulan(c *gin.Context) {
 	userID := getUserID(c)
 	_ = userID // Mark as used to avoid compiler warning
 
-	ctrl.getRealisasiData(c, "bulan", sijagurModel.GetRealisasiBulanWithParams)
+	ctrl.getRealisasiData(c, "bulan")
 }
 
 // GetRealisasiTahun godoc
@@ -90,6 +97,8 @@ func (ctrl SijagurController) GetRealisasiBulan(c *gin.Context) {
 // @Param tahun query int false "Year"
 // @Param bulan query int false "Month"
 // @Param idsatker query int false "Satker ID"
+// @Param period query string false "bulan|triwulan|semester" default(bulan)
+// @Param periode query int false "Triwulan (1-4) or semester (1-2), default: the one containing bulan"
 // @Success 	 200  {object}  models.RealisasiTahunResponse
 // @Failure      400  {object}  gin.H
 // @Failure      500  {object}  gin.H
@@ -98,7 +107,7 @@ func (ctrl SijagurController) GetRealisasiTahun(c *gin.Context) {
 	userID := getUserID(c)
 	_ = userID // Mark as used to avoid compiler warning
 
-	ctrl.getRealisasiData(c, "tahun", sijagurModel.GetRealisasiTahunWithParams)
+	ctrl.getRealisasiData(c, "tahun")
 }
 
 // GetRealisasiPerbulan godoc
@@ -118,9 +127,40 @@ func (ctrl SijagurController) GetRealisasiPerbulan(c *gin.Context) {
 	userID := getUserID(c)
 	_ = userID // Mark as used to avoid compiler warning
 
-	ctrl.getRealisasiData(c, "perbulan", func(year, month, idsatker int) ([]models.RealisasiData, error) {
-		return sijagurModel.GetRealisasiPerbulan(year, idsatker)
-	})
+	tahunInt, idsatkerInt, period, ok := ctrl.bindRealisasiQuery(c)
+	if !ok {
+		return
+	}
+	if !period.IsMonth() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Period is not supported on realisasi perbulan"})
+		return
+	}
+
+	data, err := sijagurModel.GetRealisasiPerbulan(tahunInt, idsatkerInt)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get realisasi perbulan data", "error": err.Error()})
+		return
+	}
+
+	meta := models.RealisasiMeta{
+		Year:       tahunInt,
+		Month:      period.Number,
+		MonthName:  models.GetMonthName(period.Number),
+		Idsatker:   idsatkerInt,
+		Type:       "perbulan",
+		LastUpdate: sijagurModel.DataLastUpdate(tahunInt, 1, 12, idsatkerInt),
+	}
+	if idsatkerInt > 0 {
+		if name, ok := models.SatkerNames([]int64{int64(idsatkerInt)})[int64(idsatkerInt)]; ok {
+			meta.NamaOpd, meta.ShortName = name.Name, name.ShortName
+		}
+	}
+
+	response := models.SijagurResponse{
+		Results: []models.SijagurResult{{Data: data, Meta: meta}},
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetPeringkatKinerja godoc
@@ -132,6 +172,10 @@ func (ctrl SijagurController) GetRealisasiPerbulan(c *gin.Context) {
 // @Produce json
 // @Param year query int true "Year"
 // @Param month query int false "Month"
+// @Param period query string false "bulan|triwulan|semester" default(bulan)
+// @Param periode query int false "Triwulan (1-4) or semester (1-2), default: the one containing month"
+// @Param live query bool false "Rank the live data instead of the published snapshot" default(false)
+// @Param version query int false "Published version to serve (default: the latest)"
 // @Param idsatker query int false "Satker ID"
 // @Param category query string false "Category filter: all|barjas|fisik|anggaran|kinerja" default(all)
 // @Param dimension query string false "Score dimension: kumulatif|capaian|periodik" default(kumulatif)
@@ -140,6 +184,7 @@ func (ctrl SijagurController) GetRealisasiPerbulan(c *gin.Context) {
 // @Param sortDir query string false "Sort direction: asc|desc" default(desc)
 // @Success 200 {object} models.RankingResponse
 // @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
 // @Failure 500 {object} gin.H
 // @Router /sijagur/peringkat-kinerja [GET]
 func (ctrl SijagurController) GetPeringkatKinerja(c *gin.Context) {
@@ -151,6 +196,10 @@ func (ctrl SijagurController) GetPeringkatKinerja(c *gin.Context) {
 	scope := c.DefaultQuery("scope", "skpd")
 	sortBy := c.DefaultQuery("sortBy", "")
 	sortDir := c.DefaultQuery("sortDir", "desc")
+	periodType := c.DefaultQuery("period", "bulan")
+	periode, _ := strconv.Atoi(c.DefaultQuery("periode", "0"))
+	live, _ := strconv.ParseBool(c.DefaultQuery("live", "false"))
+	version, _ := strconv.Atoi(c.DefaultQuery("version", "0"))
 
 	if year <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -160,9 +209,25 @@ func (ctrl SijagurController) GetPeringkatKinerja(c *gin.Context) {
 		return
 	}
 
+	// A month keeps the zero period, month=0 still ranks every month of the year
+	var period models.Period
+	if periodType != models.PeriodBulan {
+		var err error
+		if period, err = models.NewPeriod(periodType, periode, month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
 	resp, err := sijagurModel.GetPeringkatKinerja(
 		year,
 		month,
+		period,
+		live,
+		version,
 		idsatker,
 		category,
 		dimension,
@@ -170,6 +235,13 @@ func (ctrl SijagurController) GetPeringkatKinerja(c *gin.Context) {
 		sortBy,
 		sortDir,
 	)
+	if errors.Is(err, models.ErrSnapshotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
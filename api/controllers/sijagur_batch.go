@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRealisasiBatch godoc
+// @Summary Get the realisasi cards of several satkers and months
+// @Schemes
+// @Description Returns the realisasi-bulan or realisasi-tahun cards of up to 20 satkers over several months of a year, loaded with one query instead of one request per card
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param idsatker query string true "Comma separated satker IDs, 0 for the region, e.g. 0,12,15"
+// @Param bulan query string false "Comma separated months, e.g. 3,4,5 (default: current month)"
+// @Param tahun query int false "Year (default: current year)"
+// @Param type query string false "bulan|tahun" default(bulan)
+// @Success 200 {object} models.RealisasiBatchResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /realisasi-batch [GET]
+func (ctrl SijagurController) GetRealisasiBatch(c *gin.Context) {
+	var queryForm forms.RealisasiBatchQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateBatchQuery(err), "error": err.Error()})
+		return
+	}
+	if queryForm.Tahun == 0 {
+		queryForm.Tahun = time.Now().Year()
+	}
+	if queryForm.Type == "" {
+		queryForm.Type = "bulan"
+	}
+
+	response, err := sijagurModel.GetRealisasiBatch(queryForm.Tahun, queryForm.Months(), queryForm.Satkers(), queryForm.Type)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get realisasi " + queryForm.Type + " data", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
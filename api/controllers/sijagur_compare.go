@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCompare godoc
+// @Summary Compare the realisasi of several satkers
+// @Schemes
+// @Description Returns the realisasi cards of up to 20 satkers side by side with each category's rank within the selection and difference from the group average
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param idsatker query string true "Comma separated satker IDs, e.g. 1,2,3"
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Month (default: current month)"
+// @Param type query string false "bulan|tahun" default(bulan)
+// @Success 200 {object} models.CompareResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/compare [GET]
+func (ctrl SijagurController) GetCompare(c *gin.Context) {
+	var queryForm forms.CompareQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateCompareQuery(err), "error": err.Error()})
+		return
+	}
+	if queryForm.Tahun == 0 {
+		queryForm.Tahun = time.Now().Year()
+	}
+	if queryForm.Bulan == 0 {
+		queryForm.Bulan = int(time.Now().Month())
+	}
+	if queryForm.Type == "" {
+		queryForm.Type = "bulan"
+	}
+
+	response, err := sijagurModel.CompareSatkers(queryForm.Tahun, queryForm.Bulan, queryForm.Satkers(), queryForm.Type)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not compare satkers", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
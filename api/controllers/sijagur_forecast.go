@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindForecastQuery binds the forecast parameters and fills the defaults
+func bindForecastQuery(c *gin.Context) (forms.ForecastQueryForm, bool) {
+	var queryForm forms.ForecastQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateForecastQuery(err), "error": err.Error()})
+		return queryForm, false
+	}
+	if queryForm.Tahun == 0 {
+		queryForm.Tahun = time.Now().Year()
+	}
+	if queryForm.Model == "" {
+		queryForm.Model = models.ForecastModelAuto
+	}
+	if queryForm.History == 0 {
+		queryForm.History = 3
+	}
+	if queryForm.Threshold == 0 {
+		queryForm.Threshold = models.ForecastThreshold()
+	}
+	return queryForm, true
+}
+
+// forecastResponse ...
+func forecastResponse(queryForm forms.ForecastQueryForm, data []models.SatkerForecast) models.ForecastResponse {
+	flagged := 0
+	for _, forecast := range data {
+		if forecast.BelowThreshold {
+			flagged++
+		}
+	}
+	return models.ForecastResponse{
+		Status:    "success",
+		Year:      queryForm.Tahun,
+		Model:     queryForm.Model,
+		Threshold: queryForm.Threshold,
+		Total:     len(data),
+		Flagged:   flagged,
+		Data:      data,
+	}
+}
+
+// GetForecast godoc
+// @Summary Project year-end realisasi of a satker
+// @Schemes
+// @Description Projects December cumulative realisasi of barjas, fisik, anggaran and kinerja with a linear or seasonal model and a 95% band
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Last observed month (default: latest loaded)"
+// @Param idsatker query int false "Satker ID (default: 0 for the region)"
+// @Param model query string false "auto|linear|seasonal" default(auto)
+// @Param history query int false "Previous years used by the seasonal model" default(3)
+// @Param threshold query number false "Year-end percentage under which a category is flagged (default: FORECAST_THRESHOLD)"
+// @Success 200 {object} models.ForecastResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/forecast [GET]
+func (ctrl SijagurController) GetForecast(c *gin.Context) {
+	queryForm, ok := bindForecastQuery(c)
+	if !ok {
+		return
+	}
+
+	forecast, err := sijagurModel.ForecastSatker(queryForm.Tahun, queryForm.Bulan, queryForm.Idsatker, queryForm.Model, queryForm.History, queryForm.Threshold)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not forecast realisasi", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, forecastResponse(queryForm, []models.SatkerForecast{forecast}))
+}
+
+// GetForecastSatkers godoc
+// @Summary Project year-end realisasi of every satker
+// @Schemes
+// @Description Runs the forecast for each satker of the year, flagging the ones projected below the threshold
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Last observed month (default: latest loaded)"
+// @Param jenis_opd query string false "skpd|kecamatan"
+// @Param model query string false "auto|linear|seasonal" default(auto)
+// @Param history query int false "Previous years used by the seasonal model" default(3)
+// @Param threshold query number false "Year-end percentage under which a category is flagged (default: FORECAST_THRESHOLD)"
+// @Param flagged query bool false "Only return flagged satkers"
+// @Success 200 {object} models.ForecastResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/forecast/satkers [GET]
+func (ctrl SijagurController) GetForecastSatkers(c *gin.Context) {
+	queryForm, ok := bindForecastQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := sijagurModel.ForecastAll(queryForm.Tahun, queryForm.Bulan, queryForm.JenisOpd, queryForm.Model, queryForm.History, queryForm.Threshold, queryForm.Flagged)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not forecast realisasi", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, forecastResponse(queryForm, data))
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFreshness godoc
+// @Summary Data freshness per table and satker
+// @Schemes
+// @Description Latest last_update of every de_* table overall and per satker, with the months past the reporting deadline that a satker, or every satker, has not reported
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param deadline_day query int false "Day of the following month a month must be reported by (default: SIJAGUR_REPORT_DEADLINE_DAY)"
+// @Success 200 {object} models.FreshnessResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/freshness [GET]
+func (ctrl SijagurController) GetFreshness(c *gin.Context) {
+	var queryForm forms.FreshnessQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateFreshnessQuery(err), "error": err.Error()})
+		return
+	}
+	if queryForm.Tahun == 0 {
+		queryForm.Tahun = time.Now().Year()
+	}
+	if queryForm.DeadlineDay == 0 {
+		queryForm.DeadlineDay = models.ReportDeadlineDay()
+	}
+
+	response, err := sijagurModel.GetFreshness(queryForm.Tahun, queryForm.DeadlineDay)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not get data freshness", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
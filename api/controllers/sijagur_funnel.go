@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFunnel godoc
+// @Summary Barjas procurement funnel
+// @Schemes
+// @Description Stage-to-stage conversion, lateness and backlog of the four procurement stages for a satker, or for the whole region summed from every satker when idsatker is 0
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Month (default: current month)"
+// @Param idsatker query int false "Satker ID, 0 for the region" default(0)
+// @Param view query string false "bulan|kumulatif|periodik" default(bulan)
+// @Success 200 {object} models.FunnelResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/funnel [GET]
+func (ctrl SijagurController) GetFunnel(c *gin.Context) {
+	var queryForm forms.FunnelQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateFunnelQuery(err), "error": err.Error()})
+		return
+	}
+	if queryForm.Tahun == 0 {
+		queryForm.Tahun = time.Now().Year()
+	}
+	if queryForm.Bulan == 0 {
+		queryForm.Bulan = int(time.Now().Month())
+	}
+	if queryForm.View == "" {
+		queryForm.View = models.FunnelViewBulan
+	}
+
+	response, err := sijagurModel.GetBarjasFunnel(queryForm.Tahun, queryForm.Bulan, queryForm.Idsatker, queryForm.View)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": "No realisasi for the satker in this month"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not build the barjas funnel", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
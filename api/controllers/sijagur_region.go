@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRegion godoc
+// @Summary Region-wide realisasi computed from every satker
+// @Schemes
+// @Description Sums targets and realisasi of the individual satker rows, averages the percentages weighted by target, breaks the result down by jenis_opd and reconciles it with the stored idsatker 0 row
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Month (default: current month)"
+// @Param type query string false "bulan|tahun" default(bulan)
+// @Param tolerance query number false "Largest difference with the stored row still reported as consistent" default(0.01)
+// @Success 200 {object} models.RegionResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /sijagur/region [GET]
+func (ctrl SijagurController) GetRegion(c *gin.Context) {
+	var queryForm forms.RegionQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateRegionQuery(err), "error": err.Error()})
+		return
+	}
+	if queryForm.Tahun == 0 {
+		queryForm.Tahun = time.Now().Year()
+	}
+	if queryForm.Bulan == 0 {
+		queryForm.Bulan = int(time.Now().Month())
+	}
+	if queryForm.Type == "" {
+		queryForm.Type = "bulan"
+	}
+	if queryForm.Tolerance == 0 {
+		queryForm.Tolerance = 0.01
+	}
+
+	response, err := sijagurModel.AggregateRegion(queryForm.Tahun, queryForm.Bulan, queryForm.Type, queryForm.Tolerance)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not aggregate the region", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
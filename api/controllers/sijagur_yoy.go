@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Massad/gin-boilerplate/forms"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRealisasiPerbulanYoY godoc
+// @Summary Compare the monthly realisasi series of several years
+// @Schemes
+// @Description Returns the realisasi perbulan series of each year side by side and compares the first year month by month with the others: periodik value, monthly and cumulative realisasi with deltas and growth
+// @Tags Sijagur
+// @Accept json
+// @Produce json
+// @Param tahun query string false "Comma separated years, the first one is compared with the others (default: current and previous year)"
+// @Param idsatker query int false "Satker ID (default: 0 for all)"
+// @Success 200 {object} models.YoYResponse
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /realisasi-perbulan/yoy [GET]
+func (ctrl SijagurController) GetRealisasiPerbulanYoY(c *gin.Context) {
+	var queryForm forms.YoYQueryForm
+	if err := c.ShouldBindQuery(&queryForm); err != nil {
+		sijagurForm := forms.SijagurForm{}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": sijagurForm.ValidateYoYQuery(err), "error": err.Error()})
+		return
+	}
+
+	response, err := sijagurModel.GetRealisasiPerbulanYears(queryForm.Years(), queryForm.Idsatker)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not compare realisasi perbulan", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
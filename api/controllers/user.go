@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Massad/gin-boilerplate/db"
@@ -31,6 +32,7 @@ func getUserID(c *gin.Context) (userID int64) {
 // @Accept json
 // @Produce json
 // @Param login body forms.LoginForm true "User"
+// @Param mode query string false "Set to cookie to receive httpOnly session cookies instead of raw tokens"
 // @Success 	 200  {object}  models.UserLoginResponse
 // @Failure      406  {object}  models.MessageResponse
 // @Router /user/login [post]
@@ -55,6 +57,17 @@ func (ctrl UserController) Login(c *gin.Context) {
 		return
 	}
 
+	// Cookie mode keeps the raw tokens out of JS, only the CSRF token is returned
+	if isCookieMode(c) {
+		csrfToken, err := setSessionCookies(c, token.AccessToken, token.RefreshToken)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Successfully logged in", "user": user, "csrf_token": csrfToken})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged in", "user": user, "token": token})
 }
 
@@ -106,12 +119,24 @@ func (ctrl UserController) Logout(c *gin.Context) {
 		return
 	}
 
+	usesCookie := authModel.UsesCookie(c.Request)
+
 	deleted, delErr := authModel.DeleteAuth(au.AccessUUID)
 	if delErr != nil || deleted == 0 { //if any goes wrong
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid request"})
 		return
 	}
 
+	if usesCookie {
+		// Also revoke the refresh token, it is not reachable from JS to be discarded client-side
+		if refreshCookie, err := c.Request.Cookie(models.RefreshTokenCookie); err == nil {
+			if refreshUUID, _, err := authModel.ParseRefreshToken(refreshCookie.Value); err == nil {
+				authModel.DeleteAuth(refreshUUID)
+			}
+		}
+		clearSessionCookies(c)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 }
 
@@ -168,11 +193,45 @@ func (ctrl UserController) ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	// Stub for sending email
-	// In production, generate token, save to db, send email with link
+	if err := userModel.RequestPasswordReset(form.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset link sent to your email"})
 }
 
+// ResetPassword godoc
+// @Summary Reset Password
+// @Schemes
+// @Description Set a new password with the token of the reset link, the token works once
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param reset body forms.ResetPasswordForm true "Token and new password"
+// @Success 200 {object} models.MessageResponse
+// @Failure 406 {object} models.MessageResponse
+// @Router /user/reset-password [post]
+func (ctrl UserController) ResetPassword(c *gin.Context) {
+	var form forms.ResetPasswordForm
+
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "Please provide the reset token and a password of 3 to 50 characters"})
+		return
+	}
+
+	if err := userModel.ResetPassword(form); err != nil {
+		if errors.Is(err, models.ErrResetToken) {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed, you can log in now"})
+}
+
 // AssignRole godoc
 // @Summary Assign Role to User
 // @Schemes
@@ -223,6 +282,34 @@ func (ctrl UserController) AssignRole(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
 }
 
+// AssignSatker godoc
+// @Summary Assign a user to an OPD
+// @Schemes
+// @Description Link a user to the idsatker and jenis_opd they work for. Used to address articles and to report read receipts per OPD
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param assign body forms.AssignSatkerForm true "User ID, idsatker and jenis_opd"
+// @Success 200 {object} models.MessageResponse
+// @Failure 404 {object} models.MessageResponse
+// @Security BearerAuth
+// @Router /user/assign-satker [post]
+func (ctrl UserController) AssignSatker(c *gin.Context) {
+	var form forms.AssignSatkerForm
+
+	if validationErr := c.ShouldBindJSON(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": "user_id, idsatker and jenis_opd (skpd or kecamatan) are required"})
+		return
+	}
+
+	if err := userModel.AssignSatker(form); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Satker assigned successfully"})
+}
+
 // CreatePermission godoc
 // @Summary Create Permission
 // @Schemes
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidationController ...
+type ValidationController struct{}
+
+var validationModel = new(models.ValidationModel)
+
+var validationForm = new(forms.ValidationForm)
+
+// validationScope binds the run parameters, defaulting to the current year, a 0.01 tolerance and
+// 100 issues per check
+func validationScope(c *gin.Context) (forms.RunValidationForm, models.ValidationScope, bool) {
+	var form forms.RunValidationForm
+	if validationErr := c.ShouldBindQuery(&form); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": validationForm.Run(validationErr)})
+		return form, models.ValidationScope{}, false
+	}
+
+	scope := models.ValidationScope{Tahun: form.Tahun, Bulan: form.Bulan, Tolerance: form.Tolerance, Limit: form.Limit}
+	if scope.Tahun == 0 {
+		scope.Tahun = time.Now().Year()
+	}
+	if scope.Tolerance == 0 {
+		scope.Tolerance = 0.01
+	}
+	if scope.Limit == 0 {
+		scope.Limit = 100
+	}
+	return form, scope, true
+}
+
+// Checks godoc
+// @Summary List the data validation checks
+// @Schemes
+// @Description The catalogue of named checks over the de_* tables with their severity
+// @Tags Validation
+// @Accept json
+// @Produce json
+// @Success 	 200  {array}  models.ValidationCheck
+// @Security BearerAuth
+// @Router /validation/checks [GET]
+func (ctrl ValidationController) Checks(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.ValidationChecks()})
+}
+
+// Run godoc
+// @Summary Validate the sijagur data
+// @Schemes
+// @Description Runs the named checks, or all of them, over a year or month and reports the issues by severity
+// @Tags Validation
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Month, empty for the whole year"
+// @Param checks query string false "Comma separated check names, empty for every check"
+// @Param tolerance query number false "Largest difference accepted when comparing sums" default(0.01)
+// @Param limit query int false "Issues listed per check" default(100)
+// @Success 	 200  {object}  models.ValidationReport
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /validation/run [POST]
+func (ctrl ValidationController) Run(c *gin.Context) {
+	form, scope, ok := validationScope(c)
+	if !ok {
+		return
+	}
+
+	report, err := validationModel.Run(scope, form.CheckNames())
+	if errors.Is(err, models.ErrUnknownCheck) {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Data could not be validated", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Gate godoc
+// @Summary Validate the data before ingestion is committed
+// @Schemes
+// @Description Runs every check, meant to be called by ingestion jobs. Answers 409 with the report when VALIDATION_BLOCK_INGESTION is set and a check of error severity fails.
+// @Tags Validation
+// @Accept json
+// @Produce json
+// @Param tahun query int false "Year (default: current year)"
+// @Param bulan query int false "Month, empty for the whole year"
+// @Param tolerance query number false "Largest difference accepted when comparing sums" default(0.01)
+// @Param limit query int false "Issues listed per check" default(100)
+// @Success 	 200  {object}  models.ValidationReport
+// @Failure      409  {object}  models.ValidationReport
+// @Failure      406  {object}  models.MessageResponse
+// @Security BearerAuth
+// @Router /validation/gate [POST]
+func (ctrl ValidationController) Gate(c *gin.Context) {
+	_, scope, ok := validationScope(c)
+	if !ok {
+		return
+	}
+
+	report, err := validationModel.Gate(scope)
+	if errors.Is(err, models.ErrIngestionBlocked) {
+		c.AbortWithStatusJSON(http.StatusConflict, report)
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Data could not be validated", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
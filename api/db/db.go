@@ -51,6 +51,11 @@ func GetDB() *gorp.DbMap {
 	return db
 }
 
+// SetDB replaces the connection, tests and benchmarks use it to run the models on another driver
+func SetDB(dbmap *gorp.DbMap) {
+	db = dbmap
+}
+
 // RedisClient ...
 var RedisClient *_redis.Client
 
@@ -0,0 +1,135 @@
+package forms
+
+import (
+	"encoding/json"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// AlertForm ...
+type AlertForm struct{}
+
+// AlertRuleForm defines a condition evaluated against the latest month of every satker.
+// Compare operators test the metric against Threshold, "any" matches a metric above zero,
+// "increase" and "decrease" match a change of at least Threshold since the previous month.
+type AlertRuleForm struct {
+	Name        string  `form:"name" json:"name" binding:"required,min=3,max=100"`
+	Description string  `form:"description" json:"description" binding:"max=500"`
+	Metric      string  `form:"metric" json:"metric" binding:"required,max=50"`
+	Operator    string  `form:"operator" json:"operator" binding:"required,oneof=lt lte gt gte eq any increase decrease"`
+	Threshold   float64 `form:"threshold" json:"threshold"`
+	MinBulan    int     `form:"min_bulan" json:"min_bulan" binding:"omitempty,min=1,max=12"`
+	JenisOpd    string  `form:"jenis_opd" json:"jenis_opd" binding:"omitempty,oneof=skpd kecamatan"`
+	Severity    string  `form:"severity" json:"severity" binding:"omitempty,oneof=info warning critical"`
+	Enabled     *bool   `form:"enabled" json:"enabled"`
+}
+
+// EvaluateAlertsForm ...
+type EvaluateAlertsForm struct {
+	Tahun int `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Bulan int `form:"bulan" json:"bulan" binding:"omitempty,min=1,max=12"`
+}
+
+// AlertListForm ...
+type AlertListForm struct {
+	State    string `form:"state" json:"state" binding:"omitempty,oneof=open acknowledged resolved"`
+	Tahun    int    `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Idsatker int64  `form:"idsatker" json:"idsatker" binding:"omitempty,min=1"`
+	RuleID   int64  `form:"rule_id" json:"rule_id" binding:"omitempty,min=1"`
+	Page     int    `form:"page" json:"page" binding:"omitempty,min=1"`
+	Limit    int    `form:"limit" json:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// AlertNoteForm ...
+type AlertNoteForm struct {
+	Note string `form:"note" json:"note" binding:"max=500"`
+}
+
+// Rule ...
+func (f AlertForm) Rule(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return "Something went wrong, please try again later"
+		}
+
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Name":
+				return "Rule name should be between 3 to 100 characters"
+			case "Description":
+				return "Description should be at most 500 characters"
+			case "Metric":
+				return "Please provide a metric"
+			case "Operator":
+				return "Operator should be one of lt, lte, gt, gte, eq, any, increase or decrease"
+			case "MinBulan":
+				return "min_bulan should be between 1 and 12"
+			case "JenisOpd":
+				return "jenis_opd should be skpd or kecamatan"
+			case "Severity":
+				return "Severity should be one of info, warning or critical"
+			}
+		}
+
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// Evaluate ...
+func (f AlertForm) Evaluate(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			if err.Field() == "Tahun" {
+				return "Year must be between 1900 and 2100"
+			}
+			return "Month must be between 1 and 12"
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// List ...
+func (f AlertForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "State":
+				return "State should be one of open, acknowledged or resolved"
+			case "Tahun":
+				return "Year must be between 1900 and 2100"
+			case "Idsatker":
+				return "Satker ID must be 1 or greater"
+			case "RuleID":
+				return "Rule ID must be 1 or greater"
+			case "Page":
+				return "Page should be 1 or greater"
+			case "Limit":
+				return "Limit should be between 1 and 100"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// Note ...
+func (f AlertForm) Note(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		return "Note should be at most 500 characters"
+	default:
+		return "Invalid request"
+	}
+}
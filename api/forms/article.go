@@ -2,6 +2,10 @@ package forms
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -10,8 +14,68 @@ import (
 type ArticleForm struct{}
 
 type CreateArticleForm struct {
-	Title   string `form:"title" json:"title" binding:"required,min=3,max=100"`
-	Content string `form:"content" json:"content" binding:"required,min=3,max=1000"`
+	Title      string         `form:"title" json:"title" binding:"required,min=3,max=100"`
+	Content    string         `form:"content" json:"content" binding:"required,articleContent"`
+	PublishAt  int64          `form:"publish_at" json:"publish_at" binding:"omitempty,min=0"`
+	ExpireAt   int64          `form:"expire_at" json:"expire_at" binding:"omitempty,min=0"`
+	CategoryID int64          `form:"category_id" json:"category_id" binding:"omitempty,min=1"`
+	Tags       []string       `form:"tags" json:"tags" binding:"omitempty,max=10,dive,min=1,max=50"`
+	Audiences  []AudienceForm `form:"audiences" json:"audiences" binding:"omitempty,max=50,dive"`
+}
+
+// AudienceForm addresses an article to all users, a role, a jenis_opd (skpd/kecamatan) or one idsatker.
+// No audiences means all users.
+type AudienceForm struct {
+	Type  string `form:"type" json:"type" binding:"required,oneof=all role jenis_opd idsatker"`
+	Value string `form:"value" json:"value" binding:"omitempty,max=100"`
+}
+
+// ArticleEditorForm ...
+type ArticleEditorForm struct {
+	UserID int64 `form:"user_id" json:"user_id" binding:"required,min=1"`
+}
+
+// CategoryForm ...
+type CategoryForm struct {
+	Name string `form:"name" json:"name" binding:"required,min=2,max=50"`
+}
+
+// ArticleContentMax is the content length limit in characters, ARTICLE_CONTENT_MAX (default 20000)
+func ArticleContentMax() int {
+	max, err := strconv.Atoi(os.Getenv("ARTICLE_CONTENT_MAX"))
+	if err != nil || max < 3 {
+		return 20000
+	}
+	return max
+}
+
+// ValidateArticleContent implements validator.Func, the Markdown source must be 3 to ArticleContentMax characters
+func ValidateArticleContent(fl validator.FieldLevel) bool {
+	length := utf8.RuneCountInString(fl.Field().String())
+	return length >= 3 && length <= ArticleContentMax()
+}
+
+// ReviewArticleForm ...
+type ReviewArticleForm struct {
+	Note string `form:"note" json:"note" binding:"omitempty,max=500"`
+}
+
+// ArticleListForm holds the query parameters of the article listing
+type ArticleListForm struct {
+	Page     int    `form:"page" json:"page" binding:"omitempty,min=1"`
+	Limit    int    `form:"limit" json:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor   string `form:"cursor" json:"cursor" binding:"omitempty,max=500"`
+	Sort     string `form:"sort" json:"sort" binding:"omitempty,oneof=newest oldest updated title relevance"`
+	Q        string `form:"q" json:"q" binding:"omitempty,max=200"`
+	Status   string `form:"status" json:"status" binding:"omitempty,oneof=draft in_review scheduled published archived"`
+	Tag      string `form:"tag" json:"tag" binding:"omitempty,max=50"`
+	Category int64  `form:"category" json:"category" binding:"omitempty,min=1"`
+	Format   string `form:"format" json:"format" binding:"omitempty,oneof=html markdown text"`
+}
+
+// ArticleFormatForm selects the content format of a single article
+type ArticleFormatForm struct {
+	Format string `form:"format" json:"format" binding:"omitempty,oneof=html markdown text"`
 }
 
 type ArticleResponse struct {
@@ -43,7 +107,9 @@ func (f ArticleForm) Content(tag string, errMsg ...string) (message string) {
 		}
 		return errMsg[0]
 	case "min", "max":
-		return "Content should be between 3 to 1000 characters"
+		return fmt.Sprintf("Content should be between 3 to %d characters", ArticleContentMax())
+	case "articleContent":
+		return fmt.Sprintf("Content should be between 3 to %d characters", ArticleContentMax())
 	default:
 		return "Something went wrong, please try again later"
 	}
@@ -65,6 +131,12 @@ func (f ArticleForm) Create(err error) string {
 			if err.Field() == "Content" {
 				return f.Content(err.Tag())
 			}
+			if err.Field() == "PublishAt" || err.Field() == "ExpireAt" {
+				return "Publish and expire dates should be unix timestamps"
+			}
+			if message := f.target(err.Field()); message != "" {
+				return message
+			}
 		}
 
 	default:
@@ -90,6 +162,12 @@ func (f ArticleForm) Update(err error) string {
 			if err.Field() == "Content" {
 				return f.Content(err.Tag())
 			}
+			if err.Field() == "PublishAt" || err.Field() == "ExpireAt" {
+				return "Publish and expire dates should be unix timestamps"
+			}
+			if message := f.target(err.Field()); message != "" {
+				return message
+			}
 		}
 
 	default:
@@ -98,3 +176,65 @@ func (f ArticleForm) Update(err error) string {
 
 	return "Something went wrong, please try again later"
 }
+
+// List ...
+func (f ArticleForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Page":
+				return "Page should be 1 or greater"
+			case "Limit":
+				return "Limit should be between 1 and 100"
+			case "Cursor":
+				return "Invalid cursor"
+			case "Sort":
+				return "Sort should be one of newest, oldest, updated, title or relevance"
+			case "Q":
+				return "Search query should be at most 200 characters"
+			case "Status":
+				return "Status should be one of draft, in_review, scheduled, published or archived"
+			case "Tag":
+				return "Tag should be at most 50 characters"
+			case "Category":
+				return "Category should be a category ID"
+			case "Format":
+				return "Format should be one of html, markdown or text"
+			}
+		}
+
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// target returns the message for the category, tags and audiences fields
+func (f ArticleForm) target(field string) string {
+	switch field {
+	case "CategoryID":
+		return "Category should be a category ID"
+	case "Tags":
+		return "At most 10 tags of 1 to 50 characters are allowed"
+	case "Audiences":
+		return "At most 50 audiences are allowed"
+	case "Type":
+		return "Audience type should be one of all, role, jenis_opd or idsatker"
+	case "Value":
+		return "Audience value should be at most 100 characters"
+	}
+	return ""
+}
+
+// Category ...
+func (f ArticleForm) Category(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		return "Category name should be between 2 to 50 characters"
+	default:
+		return "Invalid request"
+	}
+}
@@ -0,0 +1,69 @@
+package forms
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ImportForm ...
+type ImportForm struct{}
+
+// RunImportForm ...
+type RunImportForm struct {
+	Feeds  string `form:"feeds" json:"feeds" binding:"max=200"`
+	DryRun bool   `form:"dry_run" json:"dry_run"`
+	Full   bool   `form:"full" json:"full"`
+	Limit  int    `form:"limit" json:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// FeedNames splits the comma separated feeds, dropping blanks
+func (f RunImportForm) FeedNames() []string {
+	var names []string
+	for _, name := range strings.Split(f.Feeds, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ImportListForm ...
+type ImportListForm struct {
+	Page  int `form:"page" json:"page" binding:"omitempty,min=1"`
+	Limit int `form:"limit" json:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// Run ...
+func (f ImportForm) Run(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			if err.Field() == "Limit" {
+				return "Limit should be between 1 and 1000"
+			}
+			return "Feeds should be at most 200 characters"
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// List ...
+func (f ImportForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			if err.Field() == "Page" {
+				return "Page should be 1 or greater"
+			}
+			return "Limit should be between 1 and 100"
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
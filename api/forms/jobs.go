@@ -0,0 +1,38 @@
+package forms
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+// JobForm ...
+type JobForm struct{}
+
+// JobRunListForm ...
+type JobRunListForm struct {
+	Job    string `form:"job" json:"job" binding:"max=100"`
+	Status string `form:"status" json:"status" binding:"omitempty,oneof=running success failed"`
+	Page   int    `form:"page" json:"page" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" json:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// List ...
+func (f JobForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Job":
+				return "Job should be at most 100 characters"
+			case "Status":
+				return "Status should be running, success or failed"
+			case "Page":
+				return "Page should be 1 or greater"
+			}
+			return "Limit should be between 1 and 100"
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
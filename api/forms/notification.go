@@ -0,0 +1,76 @@
+package forms
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// NotificationForm ...
+type NotificationForm struct{}
+
+// NotificationListForm ...
+type NotificationListForm struct {
+	Unread bool `form:"unread" json:"unread"`
+	Page   int  `form:"page" json:"page" binding:"omitempty,min=1"`
+	Limit  int  `form:"limit" json:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// NotificationPreferencesForm sets the channels of the caller. Omitted channels keep their value.
+type NotificationPreferencesForm struct {
+	Inbox        *bool    `form:"inbox" json:"inbox"`
+	Email        *bool    `form:"email" json:"email"`
+	Webhook      *bool    `form:"webhook" json:"webhook"`
+	WebhookURL   *string  `form:"webhook_url" json:"webhook_url" binding:"omitempty,max=500"`
+	MutedEvents  []string `form:"muted_events" json:"muted_events" binding:"omitempty,max=10,dive,oneof=alert announcement"`
+	RotateSecret bool     `form:"rotate_secret" json:"rotate_secret"`
+}
+
+// ResetPasswordForm ...
+type ResetPasswordForm struct {
+	Token    string `form:"token" json:"token" binding:"required,max=100"`
+	Password string `form:"password" json:"password" binding:"required,min=3,max=50"`
+}
+
+// List ...
+func (f NotificationForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			if err.Field() == "Page" {
+				return "Page should be 1 or greater"
+			}
+			return "Limit should be between 1 and 100"
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// Preferences ...
+func (f NotificationForm) Preferences(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return "Something went wrong, please try again later"
+		}
+
+		for _, err := range err.(validator.ValidationErrors) {
+			if err.Field() == "WebhookURL" {
+				return "Webhook URL should be at most 500 characters"
+			}
+			if strings.HasPrefix(err.Field(), "MutedEvents") {
+				return "At most 10 muted events of alert or announcement are allowed"
+			}
+		}
+
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
@@ -0,0 +1,108 @@
+package forms
+
+import (
+	"encoding/json"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// SatkerForm ...
+type SatkerForm struct{}
+
+// SatkerDataForm is the master record of a satker. Idsatker is the id used by the de_* tables
+// and can only be set on create. Dates are YYYY-MM-DD, an empty active_until means still active.
+type SatkerDataForm struct {
+	Idsatker       int64  `form:"idsatker" json:"idsatker" binding:"omitempty,min=1"`
+	IdSkpd         int64  `form:"id_skpd" json:"id_skpd" binding:"omitempty,min=1"`
+	Code           string `form:"code" json:"code" binding:"max=50"`
+	Name           string `form:"name" json:"name" binding:"required,min=3,max=200"`
+	ShortName      string `form:"short_name" json:"short_name" binding:"max=100"`
+	JenisOpd       string `form:"jenis_opd" json:"jenis_opd" binding:"required,oneof=skpd kecamatan"`
+	ParentIdsatker int64  `form:"parent_idsatker" json:"parent_idsatker" binding:"omitempty,min=1"`
+	KodeGadm       string `form:"kode_gadm" json:"kode_gadm" binding:"max=50"`
+	HeadName       string `form:"head_name" json:"head_name" binding:"max=200"`
+	HeadNip        string `form:"head_nip" json:"head_nip" binding:"max=30"`
+	HeadTitle      string `form:"head_title" json:"head_title" binding:"max=200"`
+	ActiveFrom     string `form:"active_from" json:"active_from" binding:"omitempty,datetime=2006-01-02"`
+	ActiveUntil    string `form:"active_until" json:"active_until" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// SatkerListForm filters the registry. Tahun keeps the satkers active at some point of the year.
+type SatkerListForm struct {
+	Q              string `form:"q" json:"q" binding:"max=100"`
+	JenisOpd       string `form:"jenis_opd" json:"jenis_opd" binding:"omitempty,oneof=skpd kecamatan"`
+	ParentIdsatker int64  `form:"parent_idsatker" json:"parent_idsatker" binding:"omitempty,min=1"`
+	Tahun          int    `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Page           int    `form:"page" json:"page" binding:"omitempty,min=1"`
+	Limit          int    `form:"limit" json:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// Data ...
+func (f SatkerForm) Data(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return "Something went wrong, please try again later"
+		}
+
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Idsatker":
+				return "Satker ID must be 1 or greater"
+			case "IdSkpd":
+				return "SKPD ID must be 1 or greater"
+			case "Code":
+				return "Code should be at most 50 characters"
+			case "Name":
+				return "Name should be between 3 to 200 characters"
+			case "ShortName":
+				return "Short name should be at most 100 characters"
+			case "JenisOpd":
+				return "jenis_opd should be skpd or kecamatan"
+			case "ParentIdsatker":
+				return "Parent satker ID must be 1 or greater"
+			case "KodeGadm":
+				return "kode_gadm should be at most 50 characters"
+			case "HeadName", "HeadTitle":
+				return "Head name and title should be at most 200 characters"
+			case "HeadNip":
+				return "Head NIP should be at most 30 characters"
+			case "ActiveFrom", "ActiveUntil":
+				return "Active dates should be formatted as YYYY-MM-DD"
+			}
+		}
+
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// List ...
+func (f SatkerForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Q":
+				return "Search should be at most 100 characters"
+			case "JenisOpd":
+				return "jenis_opd should be skpd or kecamatan"
+			case "ParentIdsatker":
+				return "Parent satker ID must be 1 or greater"
+			case "Tahun":
+				return "Year must be between 1900 and 2100"
+			case "Page":
+				return "Page should be 1 or greater"
+			case "Limit":
+				return "Limit should be between 1 and 100"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
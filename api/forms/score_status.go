@@ -0,0 +1,88 @@
+package forms
+
+import (
+	"encoding/json"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ScoreStatusForm ...
+type ScoreStatusForm struct{}
+
+// ScoreThresholdForm is one band of a set, an empty category or jenis_opd applies to all of them
+type ScoreThresholdForm struct {
+	TahunFrom int      `form:"tahun_from" json:"tahun_from" binding:"required,min=2000,max=2100"`
+	Category  string   `form:"category" json:"category" binding:"omitempty,oneof=all barjas fisik anggaran kinerja"`
+	JenisOpd  string   `form:"jenis_opd" json:"jenis_opd" binding:"omitempty,oneof=skpd kecamatan"`
+	MinScore  *float64 `form:"min_score" json:"min_score" binding:"required,min=0,max=1000"`
+	Label     string   `form:"label" json:"label" binding:"required,min=1,max=50"`
+	Color     string   `form:"color" json:"color" binding:"omitempty,hexcolor"`
+}
+
+// ScoreThresholdListForm ...
+type ScoreThresholdListForm struct {
+	TahunFrom int    `form:"tahun_from" json:"tahun_from" binding:"omitempty,min=2000,max=2100"`
+	Category  string `form:"category" json:"category" binding:"omitempty,oneof=all barjas fisik anggaran kinerja"`
+	JenisOpd  string `form:"jenis_opd" json:"jenis_opd" binding:"omitempty,oneof=skpd kecamatan"`
+}
+
+// ScoreThresholdResolveForm ...
+type ScoreThresholdResolveForm struct {
+	Tahun    int    `form:"tahun" json:"tahun" binding:"required,min=2000,max=2100"`
+	Category string `form:"category" json:"category" binding:"omitempty,oneof=all barjas fisik anggaran kinerja"`
+	JenisOpd string `form:"jenis_opd" json:"jenis_opd" binding:"omitempty,oneof=skpd kecamatan"`
+}
+
+// Threshold ...
+func (f ScoreStatusForm) Threshold(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return "Something went wrong, please try again later"
+		}
+
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "TahunFrom":
+				return "tahun_from must be between 2000 and 2100"
+			case "Category":
+				return "Category should be all, barjas, fisik, anggaran or kinerja"
+			case "JenisOpd":
+				return "jenis_opd should be skpd or kecamatan"
+			case "MinScore":
+				return "min_score is required and should be between 0 and 1000"
+			case "Label":
+				return "Label should be between 1 to 50 characters"
+			case "Color":
+				return "Color should be a hex color like #16a34a"
+			}
+		}
+
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// List ...
+func (f ScoreStatusForm) List(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "TahunFrom", "Tahun":
+				return "Year must be between 2000 and 2100"
+			case "Category":
+				return "Category should be all, barjas, fisik, anggaran or kinerja"
+			case "JenisOpd":
+				return "jenis_opd should be skpd or kecamatan"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
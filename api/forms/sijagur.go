@@ -3,6 +3,7 @@ package forms
 import (
 	"encoding/json"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -16,6 +17,8 @@ type RealisasiQueryForm struct {
 	Tahun    string `form:"tahun" json:"tahun" binding:"omitempty"`
 	Bulan    string `form:"bulan" json:"bulan" binding:"omitempty"`
 	Idsatker string `form:"idsatker" json:"idsatker" binding:"omitempty,numeric,min=0"`
+	Period   string `form:"period" json:"period" binding:"omitempty,oneof=bulan triwulan semester"`
+	Periode  int    `form:"periode" json:"periode" binding:"omitempty,min=1,max=12"`
 }
 
 // GetDefaultValues returns default values for the form fields
@@ -132,6 +135,10 @@ func (f SijagurForm) ValidateRealisasiQuery(err error) string {
 				return f.Bulan(e.Tag())
 			case "Idsatker":
 				return f.Idsatker(e.Tag())
+			case "Period":
+				return f.Period(e.Tag())
+			case "Periode":
+				return f.Periode(e.Tag())
 			}
 		}
 
@@ -141,3 +148,394 @@ func (f SijagurForm) ValidateRealisasiQuery(err error) string {
 
 	return "Something went wrong, please try again later"
 }
+
+// Period ...
+func (f SijagurForm) Period(tag string) (message string) {
+	return "Period must be bulan, triwulan or semester"
+}
+
+// Periode ...
+func (f SijagurForm) Periode(tag string) (message string) {
+	return "Periode must be 1-12 for bulan, 1-4 for triwulan and 1-2 for semester"
+}
+
+// ForecastQueryForm represents the query parameters of the forecast endpoints
+type ForecastQueryForm struct {
+	Tahun     int     `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Bulan     int     `form:"bulan" json:"bulan" binding:"omitempty,min=1,max=12"`
+	Idsatker  int     `form:"idsatker" json:"idsatker" binding:"omitempty,min=0"`
+	JenisOpd  string  `form:"jenis_opd" json:"jenis_opd" binding:"omitempty,oneof=skpd kecamatan"`
+	Model     string  `form:"model" json:"model" binding:"omitempty,oneof=auto linear seasonal"`
+	History   int     `form:"history" json:"history" binding:"omitempty,min=1,max=10"`
+	Threshold float64 `form:"threshold" json:"threshold" binding:"omitempty,gt=0,max=100"`
+	Flagged   bool    `form:"flagged" json:"flagged"`
+}
+
+// Forecast ...
+func (f SijagurForm) Forecast(field, tag string) (message string) {
+	switch field {
+	case "Tahun":
+		return f.Tahun(tag)
+	case "Bulan":
+		return f.Bulan(tag)
+	case "Idsatker":
+		return f.Idsatker(tag)
+	case "JenisOpd":
+		return "jenis_opd must be skpd or kecamatan"
+	case "Model":
+		return "Model must be auto, linear or seasonal"
+	case "History":
+		return "History must be between 1 and 10 years"
+	case "Threshold":
+		return "Threshold must be a percentage between 0 and 100"
+	default:
+		return "Something went wrong, please try again later"
+	}
+}
+
+// ValidateForecastQuery ...
+func (f SijagurForm) ValidateForecastQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			return f.Forecast(e.Field(), e.Tag())
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// CompareMaxSatkers is the largest selection the compare endpoint accepts
+const CompareMaxSatkers = 20
+
+// CompareQueryForm represents the query parameters of the compare endpoint
+type CompareQueryForm struct {
+	Idsatker string `form:"idsatker" json:"idsatker" binding:"required,satkerList"`
+	Tahun    int    `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Bulan    int    `form:"bulan" json:"bulan" binding:"omitempty,min=1,max=12"`
+	Type     string `form:"type" json:"type" binding:"omitempty,oneof=bulan tahun"`
+}
+
+// Satkers parses the comma separated idsatker list, keeping the first occurrence of duplicates
+func (f CompareQueryForm) Satkers() []int {
+	return intList(f.Idsatker)
+}
+
+// intList parses a comma separated list of integers, skipping invalid entries and keeping the
+// first occurrence of duplicates
+func intList(list string) []int {
+	var values []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || seen[value] {
+			continue
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+	return values
+}
+
+// ValidateSatkerList implements validator.Func, a comma separated list of 2 to CompareMaxSatkers satker IDs
+func ValidateSatkerList(fl validator.FieldLevel) bool {
+	parts := strings.Split(fl.Field().String(), ",")
+	if len(parts) < 2 || len(parts) > CompareMaxSatkers {
+		return false
+	}
+	for _, part := range parts {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err != nil || id < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare ...
+func (f SijagurForm) Compare(field, tag string) (message string) {
+	switch field {
+	case "Idsatker":
+		if tag == "required" {
+			return "Please provide the satker IDs to compare"
+		}
+		return "idsatker should be a comma separated list of 2 to " + strconv.Itoa(CompareMaxSatkers) + " satker IDs"
+	case "Tahun":
+		return f.Tahun(tag)
+	case "Bulan":
+		return f.Bulan(tag)
+	case "Type":
+		return "Type must be bulan or tahun"
+	default:
+		return "Something went wrong, please try again later"
+	}
+}
+
+// ValidateCompareQuery ...
+func (f SijagurForm) ValidateCompareQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			return f.Compare(e.Field(), e.Tag())
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// RegionQueryForm represents the query parameters of the region endpoint
+type RegionQueryForm struct {
+	Tahun     int     `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Bulan     int     `form:"bulan" json:"bulan" binding:"omitempty,min=1,max=12"`
+	Type      string  `form:"type" json:"type" binding:"omitempty,oneof=bulan tahun"`
+	Tolerance float64 `form:"tolerance" json:"tolerance" binding:"omitempty,min=0"`
+}
+
+// ValidateRegionQuery ...
+func (f SijagurForm) ValidateRegionQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			switch e.Field() {
+			case "Tahun":
+				return f.Tahun(e.Tag())
+			case "Bulan":
+				return f.Bulan(e.Tag())
+			case "Type":
+				return "Type must be bulan or tahun"
+			case "Tolerance":
+				return "Tolerance must be 0 or greater"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// FunnelQueryForm represents the query parameters of the barjas funnel endpoint
+type FunnelQueryForm struct {
+	Tahun    int    `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Bulan    int    `form:"bulan" json:"bulan" binding:"omitempty,min=1,max=12"`
+	Idsatker int    `form:"idsatker" json:"idsatker" binding:"omitempty,min=0"`
+	View     string `form:"view" json:"view" binding:"omitempty,oneof=bulan kumulatif periodik"`
+}
+
+// ValidateFunnelQuery ...
+func (f SijagurForm) ValidateFunnelQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			switch e.Field() {
+			case "Tahun":
+				return f.Tahun(e.Tag())
+			case "Bulan":
+				return f.Bulan(e.Tag())
+			case "Idsatker":
+				return f.Idsatker(e.Tag())
+			case "View":
+				return "View must be bulan, kumulatif or periodik"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// FreshnessQueryForm represents the query parameters of the freshness endpoint
+type FreshnessQueryForm struct {
+	Tahun       int `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	DeadlineDay int `form:"deadline_day" json:"deadline_day" binding:"omitempty,min=1,max=28"`
+}
+
+// ValidateFreshnessQuery ...
+func (f SijagurForm) ValidateFreshnessQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			switch e.Field() {
+			case "Tahun":
+				return f.Tahun(e.Tag())
+			case "DeadlineDay":
+				return "Deadline day must be between 1 and 28"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// PublishRankingForm selects the ranking period frozen by a publish
+type PublishRankingForm struct {
+	Year    int    `form:"year" json:"year" binding:"required,min=1900,max=2100"`
+	Month   int    `form:"month" json:"month" binding:"omitempty,min=1,max=12"`
+	Period  string `form:"period" json:"period" binding:"omitempty,oneof=bulan triwulan semester"`
+	Periode int    `form:"periode" json:"periode" binding:"omitempty,min=1,max=12"`
+	Note    string `form:"note" json:"note" binding:"max=500"`
+}
+
+// SnapshotListForm ...
+type SnapshotListForm struct {
+	Year    int    `form:"year" json:"year" binding:"required,min=1900,max=2100"`
+	Period  string `form:"period" json:"period" binding:"omitempty,oneof=bulan triwulan semester"`
+	Periode int    `form:"periode" json:"periode" binding:"omitempty,min=1,max=12"`
+}
+
+// ValidateRankingSnapshot ...
+func (f SijagurForm) ValidateRankingSnapshot(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			switch e.Field() {
+			case "Year":
+				return f.Tahun(e.Tag())
+			case "Month":
+				return f.Bulan(e.Tag())
+			case "Period":
+				return f.Period(e.Tag())
+			case "Periode":
+				return f.Periode(e.Tag())
+			case "Note":
+				return "Note should be at most 500 characters"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// YoYMaxYears is the largest number of years the year-over-year endpoint accepts
+const YoYMaxYears = 5
+
+// YoYQueryForm represents the query parameters of the year-over-year endpoint
+type YoYQueryForm struct {
+	Tahun    string `form:"tahun" json:"tahun" binding:"omitempty,yearList"`
+	Idsatker int    `form:"idsatker" json:"idsatker" binding:"omitempty,min=0"`
+}
+
+// Years parses the comma separated tahun list, the current and previous year by default.
+// The first year is the one compared with the others.
+func (f YoYQueryForm) Years() []int {
+	if f.Tahun == "" {
+		year := time.Now().Year()
+		return []int{year, year - 1}
+	}
+
+	return intList(f.Tahun)
+}
+
+// ValidateYearList implements validator.Func, a comma separated list of 2 to YoYMaxYears years
+func ValidateYearList(fl validator.FieldLevel) bool {
+	parts := strings.Split(fl.Field().String(), ",")
+	if len(parts) < 2 || len(parts) > YoYMaxYears {
+		return false
+	}
+	for _, part := range parts {
+		if year, err := strconv.Atoi(strings.TrimSpace(part)); err != nil || year < 1900 || year > 2100 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateYoYQuery ...
+func (f SijagurForm) ValidateYoYQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			if e.Field() == "Idsatker" {
+				return f.Idsatker(e.Tag())
+			}
+			return "tahun should be a comma separated list of 2 to " + strconv.Itoa(YoYMaxYears) + " years between 1900 and 2100"
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
+
+// BatchMaxSatkers is the largest number of satkers the batch endpoint accepts
+const BatchMaxSatkers = 20
+
+// RealisasiBatchQueryForm represents the query parameters of the batch endpoint
+type RealisasiBatchQueryForm struct {
+	Idsatker string `form:"idsatker" json:"idsatker" binding:"required,batchSatkerList"`
+	Bulan    string `form:"bulan" json:"bulan" binding:"omitempty,monthList"`
+	Tahun    int    `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Type     string `form:"type" json:"type" binding:"omitempty,oneof=bulan tahun"`
+}
+
+// Satkers parses the comma separated idsatker list, 0 is the region
+func (f RealisasiBatchQueryForm) Satkers() []int {
+	return intList(f.Idsatker)
+}
+
+// Months parses the comma separated bulan list, the current month by default
+func (f RealisasiBatchQueryForm) Months() []int {
+	if f.Bulan == "" {
+		return []int{int(time.Now().Month())}
+	}
+	return intList(f.Bulan)
+}
+
+// ValidateBatchSatkerList implements validator.Func, a comma separated list of 1 to BatchMaxSatkers
+// satker IDs, 0 included
+func ValidateBatchSatkerList(fl validator.FieldLevel) bool {
+	parts := strings.Split(fl.Field().String(), ",")
+	if len(parts) > BatchMaxSatkers {
+		return false
+	}
+	for _, part := range parts {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err != nil || id < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateMonthList implements validator.Func, a comma separated list of months between 1 and 12
+func ValidateMonthList(fl validator.FieldLevel) bool {
+	for _, part := range strings.Split(fl.Field().String(), ",") {
+		if month, err := strconv.Atoi(strings.TrimSpace(part)); err != nil || month < 1 || month > 12 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateBatchQuery ...
+func (f SijagurForm) ValidateBatchQuery(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			switch e.Field() {
+			case "Idsatker":
+				if e.Tag() == "required" {
+					return "Please provide the satker IDs"
+				}
+				return "idsatker should be a comma separated list of up to " + strconv.Itoa(BatchMaxSatkers) + " satker IDs, 0 for the region"
+			case "Bulan":
+				return "bulan should be a comma separated list of months between 1 and 12"
+			case "Tahun":
+				return f.Tahun(e.Tag())
+			case "Type":
+				return "Type must be bulan or tahun"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
@@ -19,6 +19,19 @@ type AssignRoleForm struct {
 	RoleName string `form:"role_name" json:"role_name" binding:"required"`
 }
 
+// AssignSatkerForm links a user to the OPD they work for, used for audience targeting
+type AssignSatkerForm struct {
+	UserID   int64  `form:"user_id" json:"user_id" binding:"required"`
+	Idsatker int64  `form:"idsatker" json:"idsatker" binding:"required,min=1"`
+	JenisOpd string `form:"jenis_opd" json:"jenis_opd" binding:"required,oneof=skpd kecamatan"`
+}
+
+// ImpersonateForm ...
+type ImpersonateForm struct {
+	UserID     int64 `form:"user_id" json:"user_id" binding:"required"`
+	AllowWrite bool  `form:"allow_write" json:"allow_write"`
+}
+
 // LoginForm ...
 type LoginForm struct {
 	Email    string `form:"email" json:"email" binding:"omitempty,email"`
@@ -0,0 +1,55 @@
+package forms
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationForm ...
+type ValidationForm struct{}
+
+// RunValidationForm selects the data and checks of a validation run
+type RunValidationForm struct {
+	Tahun     int     `form:"tahun" json:"tahun" binding:"omitempty,min=1900,max=2100"`
+	Bulan     int     `form:"bulan" json:"bulan" binding:"omitempty,min=1,max=12"`
+	Checks    string  `form:"checks" json:"checks" binding:"max=500"` // comma separated, empty for every check
+	Tolerance float64 `form:"tolerance" json:"tolerance" binding:"omitempty,min=0"`
+	Limit     int     `form:"limit" json:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// CheckNames splits the comma separated checks, dropping blanks
+func (f RunValidationForm) CheckNames() []string {
+	var names []string
+	for _, name := range strings.Split(f.Checks, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Run ...
+func (f ValidationForm) Run(err error) string {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Tahun":
+				return "Year must be between 1900 and 2100"
+			case "Bulan":
+				return "Month must be between 1 and 12"
+			case "Checks":
+				return "Checks should be at most 500 characters"
+			case "Tolerance":
+				return "Tolerance must be 0 or greater"
+			case "Limit":
+				return "Limit should be between 1 and 1000"
+			}
+		}
+	default:
+		return "Invalid request"
+	}
+
+	return "Something went wrong, please try again later"
+}
@@ -49,6 +49,19 @@ func (v *DefaultValidator) lazyinit() {
 
 		//Custom rule for user full name
 		v.validate.RegisterValidation("fullName", ValidateFullName)
+
+		//Article content length, configurable through ARTICLE_CONTENT_MAX
+		v.validate.RegisterValidation("articleContent", ValidateArticleContent)
+
+		//Comma separated satker IDs of the compare endpoint
+		v.validate.RegisterValidation("satkerList", ValidateSatkerList)
+
+		//Comma separated years of the year-over-year endpoint
+		v.validate.RegisterValidation("yearList", ValidateYearList)
+
+		//Comma separated satker IDs and months of the realisasi batch endpoint
+		v.validate.RegisterValidation("batchSatkerList", ValidateBatchSatkerList)
+		v.validate.RegisterValidation("monthList", ValidateMonthList)
 	})
 }
 
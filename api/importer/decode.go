@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeJSON reads an array of objects, or an object with the array under "data"
+func DecodeJSON(r io.Reader) ([]Record, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&items); err != nil {
+		var wrapped struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		decoder = json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if wrappedErr := decoder.Decode(&wrapped); wrappedErr != nil {
+			return nil, fmt.Errorf("decode json: %v", err)
+		}
+		items = wrapped.Data
+	}
+
+	records := make([]Record, 0, len(items))
+	for _, item := range items {
+		record := Record{}
+		for key, value := range item {
+			switch v := value.(type) {
+			case nil:
+				record[key] = ""
+			case string:
+				record[key] = v
+			case json.Number:
+				record[key] = v.String()
+			case bool:
+				record[key] = fmt.Sprint(v)
+			default:
+				// Nested values are kept as JSON, no feed maps them today
+				encoded, _ := json.Marshal(v)
+				record[key] = string(encoded)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// DecodeCSV reads a CSV with a header row, "," or ";" separated
+func DecodeCSV(r io.Reader) ([]Record, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(body))
+	firstLine := string(body)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("decode csv: %v", err)
+	}
+	if len(rows) == 0 {
+		return []Record{}, nil
+	}
+
+	header := rows[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	records := make([]Record, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := Record{}
+		for i, key := range header {
+			if i < len(row) {
+				record[key] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// decode picks the decoder from the format, "csv" or anything else for JSON
+func decode(format string, r io.Reader) ([]Record, error) {
+	if format == "csv" {
+		return DecodeCSV(r)
+	}
+	return DecodeJSON(r)
+}
@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HTTPSource reads a feed from <BaseURL>/<feed>, asking for the records changed after the
+// cursor with ?updated_since=<RFC3339>
+type HTTPSource struct {
+	BaseURL string
+	Token   string // sent as a bearer token when set
+	Format  string // "json" or "csv", empty to go by the Content-Type
+	Client  *http.Client
+}
+
+// Name ...
+func (s HTTPSource) Name() string {
+	return "http"
+}
+
+// Fetch ...
+func (s HTTPSource) Fetch(ctx context.Context, feed string, since time.Time) ([]Record, error) {
+	if s.BaseURL == "" {
+		return nil, ErrNoSource
+	}
+
+	address, err := url.Parse(strings.TrimRight(s.BaseURL, "/") + "/" + feed)
+	if err != nil {
+		return nil, err
+	}
+	if !since.IsZero() {
+		query := address.Query()
+		query.Set("updated_since", since.UTC().Format(time.RFC3339))
+		address.RawQuery = query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, address.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json, text/csv")
+	if s.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+		return nil, fmt.Errorf("%s answered %d", feed, response.StatusCode)
+	}
+
+	format := s.Format
+	if format == "" && strings.Contains(response.Header.Get("Content-Type"), "csv") {
+		format = "csv"
+	}
+	return decode(format, response.Body)
+}
+
+// FileSource reads a feed from <Dir>/<feed>.json, or <Dir>/<feed>.csv when there is no JSON file.
+// Files hold the whole feed, the cursor is applied by the importer.
+type FileSource struct {
+	Dir string
+}
+
+// Name ...
+func (s FileSource) Name() string {
+	return "file"
+}
+
+// Fetch ...
+func (s FileSource) Fetch(ctx context.Context, feed string, since time.Time) ([]Record, error) {
+	for _, format := range []string{"json", "csv"} {
+		file, err := os.Open(filepath.Join(s.Dir, feed+"."+format))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return decode(format, file)
+	}
+	return nil, fmt.Errorf("no %s.json or %s.csv in %s", feed, feed, s.Dir)
+}
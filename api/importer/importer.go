@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feeds an upstream procurement system can provide, named after the table they load
+const (
+	FeedStatusPaket  = "status_paket"
+	FeedPetaDetail   = "peta_detail"
+	FeedDetailBarjas = "detail_barjas"
+)
+
+// Feeds ...
+var Feeds = []string{FeedStatusPaket, FeedPetaDetail, FeedDetailBarjas}
+
+// ErrNoSource is returned when no upstream source is configured
+var ErrNoSource = errors.New("no import source configured")
+
+// Record is one upstream record, values keyed by the upstream field name. JSON numbers and
+// booleans are kept in their text form so JSON and CSV feeds map the same way.
+type Record map[string]string
+
+// modifiedKeys are the fields upstream systems use for the last modification time
+var modifiedKeys = []string{"last_modified", "updated_at", "last_update", "tgl_update"}
+
+// modifiedLayouts are the text formats accepted besides epoch seconds
+var modifiedLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// Modified returns the last modification time of the record, ok is false when it has none
+func (r Record) Modified() (modified time.Time, ok bool, err error) {
+	for _, key := range modifiedKeys {
+		value := strings.TrimSpace(r[key])
+		if value == "" {
+			continue
+		}
+		if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(epoch, 0), true, nil
+		}
+		for _, layout := range modifiedLayouts {
+			if modified, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return modified, true, nil
+			}
+		}
+		return modified, false, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return modified, false, nil
+}
+
+// Source fetches the records of a feed. Records modified at or before since may be returned
+// too, the importer drops them. A zero since asks for every record.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context, feed string, since time.Time) ([]Record, error)
+}
+
+var source Source
+
+// Init selects the source from IMPORT_SOURCE: "http" (default when IMPORT_SOURCE_URL is set) reads
+// IMPORT_SOURCE_URL/<feed>, "file" reads IMPORT_SOURCE_DIR/<feed>.json or .csv. Without either the
+// importer stays disabled.
+func Init() {
+	driver := os.Getenv("IMPORT_SOURCE")
+	if driver == "" && os.Getenv("IMPORT_SOURCE_URL") != "" {
+		driver = "http"
+	}
+
+	timeout, err := strconv.Atoi(os.Getenv("IMPORT_TIMEOUT_SECONDS"))
+	if err != nil || timeout <= 0 {
+		timeout = 60
+	}
+
+	switch driver {
+	case "http":
+		source = HTTPSource{
+			BaseURL: os.Getenv("IMPORT_SOURCE_URL"),
+			Token:   os.Getenv("IMPORT_SOURCE_TOKEN"),
+			Format:  os.Getenv("IMPORT_SOURCE_FORMAT"),
+			Client:  &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		}
+	case "file":
+		source = FileSource{Dir: os.Getenv("IMPORT_SOURCE_DIR")}
+	case "":
+		source = nil
+	default:
+		log.Fatal("Failed to init importer: ", fmt.Errorf("unknown IMPORT_SOURCE %q", driver))
+	}
+}
+
+// GetSource ...
+func GetSource() (Source, error) {
+	if source == nil {
+		return nil, ErrNoSource
+	}
+	return source, nil
+}
+
+// SetSource replaces the source, used by tests
+func SetSource(s Source) {
+	source = s
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/Massad/gin-boilerplate/db"
 	_ "github.com/Massad/gin-boilerplate/docs"
 	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/importer"
 	"github.com/Massad/gin-boilerplate/models"
+	"github.com/Massad/gin-boilerplate/notify"
+	"github.com/Massad/gin-boilerplate/storage"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -74,7 +77,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		// Set other CORS headers
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "X-Requested-With, Content-Type, Origin, Authorization, Accept, Client-Security-Token, Accept-Encoding, x-access-token")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "X-Requested-With, Content-Type, Origin, Authorization, Accept, Client-Security-Token, Accept-Encoding, x-access-token, X-CSRF-Token")
 		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
 
 		// Only allow credentials for same-origin or explicitly allowed origins
@@ -165,6 +168,11 @@ func main() {
 		log.Fatal("error: failed to load the env file")
 	}
 
+	//Command line tools ("migrate", "validate") run and exit instead of starting the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	if os.Getenv("ENV") == "PRODUCTION" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -179,6 +187,10 @@ func main() {
 	r.Use(RequestIDMiddleware())
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 
+	//Audit every request made with an impersonation token
+	impersonation := new(controllers.ImpersonationController)
+	r.Use(impersonation.Audit())
+
 	//Start PostgreSQL database
 	//Example: db.GetDB() - More info in the models folder
 	db.Init()
@@ -234,10 +246,22 @@ func main() {
 
 	}
 
+	//Notification senders (NOTIFY_EMAIL_DRIVER, NOTIFY_WEBHOOK_DRIVER), the queue is sent by the notification_delivery job
+	notify.Init()
+
+	//Attachment storage, local filesystem or S3-compatible (STORAGE_DRIVER)
+	storage.Init()
+
+	//Upstream procurement feeds, disabled unless IMPORT_SOURCE or IMPORT_SOURCE_URL is set
+	importer.Init()
+
 	//Start Redis on database 1 - it's used to store the JWT but you can use it for anythig else
 	//Example: db.GetRedis().Set(KEY, VALUE, at.Sub(now)).Err()
 	db.InitRedis(1)
 
+	//Recurring maintenance jobs (SCHEDULER_ENABLED, JOB_<NAME>_SCHEDULE), locked in Redis so one replica runs each
+	models.StartJobs()
+
 	v1 := r.Group("/v1")
 	{
 		/*** START USER ***/
@@ -249,11 +273,20 @@ func main() {
 		v1.GET("/user/logout", user.Logout)
 		v1.GET("/user/profile", TokenAuthMiddleware(), user.GetProfile)
 		v1.POST("/user/forgot-password", user.ForgotPassword)
+		v1.POST("/user/reset-password", user.ResetPassword)
 		v1.POST("/user/assign-role", TokenAuthMiddleware(), auth.HasPermission("manage_users"), user.AssignRole)
+		v1.POST("/user/assign-satker", TokenAuthMiddleware(), auth.HasPermission("manage_users"), user.AssignSatker)
+
+		/*** START Impersonation ***/
+		//Time-limited "login as" tokens, read-only unless allow_write is granted
+		v1.POST("/user/impersonate", TokenAuthMiddleware(), auth.HasPermission("impersonate_users"), impersonation.Start)
+		v1.GET("/user/impersonation-logs", TokenAuthMiddleware(), auth.HasPermission("impersonate_users"), impersonation.Logs)
 
 		/*** START AUTH ***/
 		//Refresh the token when needed to generate new access_token and refresh_token for the user
 		v1.POST("/token/refresh", auth.Refresh)
+		//Cookie session mode (POST /user/login?mode=cookie): rotates the httpOnly cookies, requires X-CSRF-Token
+		v1.POST("/token/refresh-cookie", auth.RefreshCookie)
 
 		/*** START Permission ***/
 		v1.POST("/permission/create", TokenAuthMiddleware(), auth.HasPermission("manage_users"), user.CreatePermission)
@@ -267,6 +300,38 @@ func main() {
 		v1.PUT("/article/:id", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.Update)
 		v1.DELETE("/article/:id", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.Delete)
 
+		//Publishing workflow: draft -> in_review -> scheduled/published -> archived
+		v1.GET("/articles/feed", TokenAuthMiddleware(), article.Feed)
+		v1.GET("/articles/review", TokenAuthMiddleware(), auth.HasPermission("review_article"), article.ReviewQueue)
+		v1.POST("/article/:id/submit", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.Submit)
+		v1.POST("/article/:id/approve", TokenAuthMiddleware(), auth.HasPermission("review_article"), article.Approve)
+		v1.POST("/article/:id/reject", TokenAuthMiddleware(), auth.HasPermission("review_article"), article.Reject)
+		v1.POST("/article/:id/archive", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.Archive)
+
+		//Revision history
+		v1.GET("/article/:id/revisions", TokenAuthMiddleware(), auth.HasPermission("read_article"), article.Revisions)
+		v1.GET("/article/:id/revisions/diff", TokenAuthMiddleware(), auth.HasPermission("read_article"), article.Diff)
+		v1.POST("/article/:id/revisions/:revision/restore", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.Restore)
+
+		//Attachments, downloads go through signed URLs
+		v1.POST("/article/:id/attachments", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.Upload)
+		v1.GET("/article/:id/attachments", TokenAuthMiddleware(), auth.HasPermission("read_article"), article.Attachments)
+		v1.GET("/article/:id/attachments/:attachment/url", TokenAuthMiddleware(), auth.HasPermission("read_article"), article.AttachmentURL)
+		v1.DELETE("/article/:id/attachments/:attachment", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.DeleteAttachment)
+		v1.GET("/attachments/download", article.Download)
+
+		//Categories, audiences and read receipts
+		v1.GET("/article-categories", TokenAuthMiddleware(), article.Categories)
+		v1.POST("/article-categories", TokenAuthMiddleware(), auth.HasPermission("review_article"), article.CreateCategory)
+		v1.DELETE("/article-categories/:id", TokenAuthMiddleware(), auth.HasPermission("review_article"), article.DeleteCategory)
+		v1.POST("/article/:id/read", TokenAuthMiddleware(), article.MarkRead)
+		v1.GET("/article/:id/reads", TokenAuthMiddleware(), auth.HasPermission("review_article"), article.Reads)
+
+		//Co-editors, see models/article_access.go for the ownership rules
+		v1.GET("/article/:id/editors", TokenAuthMiddleware(), auth.HasPermission("read_article"), article.Editors)
+		v1.POST("/article/:id/editors", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.AddEditor)
+		v1.DELETE("/article/:id/editors/:user", TokenAuthMiddleware(), auth.HasPermission("write_article"), article.RemoveEditor)
+
 		/*** START Sijagur ***/
 		sijagur := new(controllers.SijagurController)
 
@@ -274,10 +339,107 @@ func main() {
 		v1.GET("/realisasi-bulan", TokenAuthMiddleware(), sijagur.GetRealisasiBulan)
 		v1.GET("/realisasi-tahun", TokenAuthMiddleware(), sijagur.GetRealisasiTahun)
 		v1.GET("/realisasi-perbulan", TokenAuthMiddleware(), sijagur.GetRealisasiPerbulan)
+		v1.GET("/realisasi-perbulan/yoy", TokenAuthMiddleware(), sijagur.GetRealisasiPerbulanYoY)
+
+		// Cards of several satkers and months in one request, loaded with one query
+		v1.GET("/realisasi-batch", TokenAuthMiddleware(), sijagur.GetRealisasiBatch)
 
 		// Peringkat Kinerja (alias-based ranking, scoped by jenis_opd via ?scope=skpd|kecamatan)
 		// Uses models.SijagurData.GetPeringkatKinerja and returns models.RankingResponse
 		v1.GET("/sijagur/peringkat-kinerja", TokenAuthMiddleware(), sijagur.GetPeringkatKinerja)
+
+		// Published ranking versions, served by peringkat-kinerja unless live=true
+		rankingSnapshot := new(controllers.RankingSnapshotController)
+		v1.POST("/sijagur/peringkat-kinerja/publish", TokenAuthMiddleware(), auth.HasPermission("publish_ranking"), rankingSnapshot.Publish)
+		v1.GET("/sijagur/peringkat-kinerja/snapshots", TokenAuthMiddleware(), rankingSnapshot.All)
+		v1.GET("/sijagur/peringkat-kinerja/snapshots/:id", TokenAuthMiddleware(), rankingSnapshot.One)
+
+		// Year-end forecast, per satker and across all satkers with threshold flags
+		v1.GET("/sijagur/forecast", TokenAuthMiddleware(), sijagur.GetForecast)
+		v1.GET("/sijagur/forecast/satkers", TokenAuthMiddleware(), sijagur.GetForecastSatkers)
+
+		// Side-by-side realisasi of several satkers, loaded with one query
+		v1.GET("/sijagur/compare", TokenAuthMiddleware(), sijagur.GetCompare)
+
+		// Region computed from the satker rows, by jenis_opd and reconciled with the idsatker 0 row
+		v1.GET("/sijagur/region", TokenAuthMiddleware(), sijagur.GetRegion)
+
+		// Barjas procurement funnel of a satker or the region
+		v1.GET("/sijagur/funnel", TokenAuthMiddleware(), sijagur.GetFunnel)
+
+		// Latest update per table and satker, with months missing past the reporting deadline
+		v1.GET("/sijagur/freshness", TokenAuthMiddleware(), sijagur.GetFreshness)
+
+		/*** START Satkers ***/
+		satker := new(controllers.SatkerController)
+
+		// Master registry of satkers/OPDs, names are joined into the realisasi and ranking responses
+		v1.GET("/satkers", TokenAuthMiddleware(), satker.All)
+		v1.GET("/satkers/lookup", TokenAuthMiddleware(), satker.Lookup)
+		v1.GET("/satkers/:id", TokenAuthMiddleware(), satker.One)
+		v1.POST("/satkers", TokenAuthMiddleware(), auth.HasPermission("manage_satkers"), satker.Create)
+		v1.PUT("/satkers/:id", TokenAuthMiddleware(), auth.HasPermission("manage_satkers"), satker.Update)
+		v1.DELETE("/satkers/:id", TokenAuthMiddleware(), auth.HasPermission("manage_satkers"), satker.Delete)
+
+		/*** START Score Thresholds ***/
+		scoreStatus := new(controllers.ScoreStatusController)
+
+		// Score-status bands per year, category and jenis_opd, resolved into the peringkat-kinerja score_status
+		v1.GET("/score-thresholds", TokenAuthMiddleware(), scoreStatus.All)
+		v1.GET("/score-thresholds/resolve", TokenAuthMiddleware(), scoreStatus.Resolve)
+		v1.POST("/score-thresholds", TokenAuthMiddleware(), auth.HasPermission("manage_score_thresholds"), scoreStatus.Create)
+		v1.PUT("/score-thresholds/:id", TokenAuthMiddleware(), auth.HasPermission("manage_score_thresholds"), scoreStatus.Update)
+		v1.DELETE("/score-thresholds/:id", TokenAuthMiddleware(), auth.HasPermission("manage_score_thresholds"), scoreStatus.Delete)
+
+		/*** START Imports ***/
+		imports := new(controllers.ImportController)
+
+		// Upstream procurement feeds (IMPORT_SOURCE) loaded into de_status_paket, de_peta_detail and de_detail_barjas
+		v1.POST("/imports/run", TokenAuthMiddleware(), auth.HasPermission("manage_imports"), imports.Run)
+		v1.GET("/imports", TokenAuthMiddleware(), auth.HasPermission("manage_imports"), imports.All)
+		v1.GET("/imports/:id", TokenAuthMiddleware(), auth.HasPermission("manage_imports"), imports.One)
+
+		/*** START Jobs ***/
+		jobs := new(controllers.JobController)
+
+		// Background jobs: status, run history and manual triggers
+		v1.GET("/jobs", TokenAuthMiddleware(), auth.HasPermission("manage_jobs"), jobs.All)
+		v1.GET("/jobs/runs", TokenAuthMiddleware(), auth.HasPermission("manage_jobs"), jobs.Runs)
+		v1.GET("/jobs/runs/:id", TokenAuthMiddleware(), auth.HasPermission("manage_jobs"), jobs.OneRun)
+		v1.POST("/jobs/:name/run", TokenAuthMiddleware(), auth.HasPermission("manage_jobs"), jobs.Run)
+
+		/*** START Alerts ***/
+		alert := new(controllers.AlertController)
+
+		// Rules over de_ranking_opd / de_detail_* / de_status_paket, managed by admins
+		v1.GET("/alert-rules", TokenAuthMiddleware(), auth.HasPermission("manage_alerts"), alert.Rules)
+		v1.POST("/alert-rules", TokenAuthMiddleware(), auth.HasPermission("manage_alerts"), alert.CreateRule)
+		v1.POST("/alert-rules/evaluate", TokenAuthMiddleware(), auth.HasPermission("manage_alerts"), alert.Evaluate)
+		v1.PUT("/alert-rules/:id", TokenAuthMiddleware(), auth.HasPermission("manage_alerts"), alert.UpdateRule)
+		v1.DELETE("/alert-rules/:id", TokenAuthMiddleware(), auth.HasPermission("manage_alerts"), alert.DeleteRule)
+
+		// Alerts of the caller's satker (every satker with manage_alerts)
+		v1.GET("/alerts", TokenAuthMiddleware(), alert.All)
+		v1.POST("/alerts/:id/acknowledge", TokenAuthMiddleware(), alert.Acknowledge)
+		v1.POST("/alerts/:id/resolve", TokenAuthMiddleware(), alert.Resolve)
+
+		/*** START Validation ***/
+		validation := new(controllers.ValidationController)
+
+		// Data-quality checks over the de_* tables, also available as "go run *.go validate"
+		v1.GET("/validation/checks", TokenAuthMiddleware(), validation.Checks)
+		v1.POST("/validation/run", TokenAuthMiddleware(), auth.HasPermission("manage_validation"), validation.Run)
+		v1.POST("/validation/gate", TokenAuthMiddleware(), auth.HasPermission("manage_validation"), validation.Gate)
+
+		/*** START Notifications ***/
+		notification := new(controllers.NotificationController)
+
+		v1.GET("/notifications", TokenAuthMiddleware(), notification.All)
+		v1.GET("/notifications/unread-count", TokenAuthMiddleware(), notification.UnreadCount)
+		v1.POST("/notifications/read-all", TokenAuthMiddleware(), notification.MarkAllRead)
+		v1.POST("/notifications/:id/read", TokenAuthMiddleware(), notification.MarkRead)
+		v1.GET("/notifications/preferences", TokenAuthMiddleware(), notification.Preferences)
+		v1.PUT("/notifications/preferences", TokenAuthMiddleware(), notification.SavePreferences)
 	}
 
 	// Swagger docs
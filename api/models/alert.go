@@ -0,0 +1,252 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+)
+
+// Alert states. Alerts whose condition clears are resolved by the evaluator.
+const (
+	AlertStateOpen         = "open"
+	AlertStateAcknowledged = "acknowledged"
+	AlertStateResolved     = "resolved"
+)
+
+// ErrAlertForbidden is returned when the alert belongs to another satker
+var ErrAlertForbidden = errors.New("alert belongs to another satker")
+
+// AlertRule is a condition evaluated against the latest month of every satker
+type AlertRule struct {
+	ID          int64   `db:"id, primarykey, autoincrement" json:"id"`
+	Name        string  `db:"name" json:"name"`
+	Description string  `db:"description" json:"description"`
+	Metric      string  `db:"metric" json:"metric"`
+	Operator    string  `db:"operator" json:"operator"`
+	Threshold   float64 `db:"threshold" json:"threshold"`
+	MinBulan    int     `db:"min_bulan" json:"min_bulan"`
+	JenisOpd    string  `db:"jenis_opd" json:"jenis_opd,omitempty"`
+	Severity    string  `db:"severity" json:"severity"`
+	Enabled     bool    `db:"enabled" json:"enabled"`
+	CreatedBy   int64   `db:"created_by" json:"created_by,omitempty"`
+	UpdatedAt   int64   `db:"updated_at" json:"updated_at"`
+	CreatedAt   int64   `db:"created_at" json:"created_at"`
+}
+
+// Alert is one triggered rule for a satker and year
+type Alert struct {
+	ID             int64    `db:"id, primarykey, autoincrement" json:"id"`
+	RuleID         int64    `db:"rule_id" json:"rule_id"`
+	RuleName       string   `db:"rule_name" json:"rule_name"`
+	Severity       string   `db:"severity" json:"severity"`
+	Idsatker       int64    `db:"idsatker" json:"idsatker"`
+	NamaOpd        string   `db:"nama_opd" json:"nama_opd"`
+	Tahun          int      `db:"tahun" json:"tahun"`
+	Bulan          int      `db:"bulan" json:"bulan"`
+	Value          float64  `db:"value" json:"value"`
+	Previous       *float64 `db:"previous" json:"previous,omitempty"`
+	Message        string   `db:"message" json:"message"`
+	State          string   `db:"state" json:"state"`
+	Note           string   `db:"note" json:"note,omitempty"`
+	AcknowledgedBy *int64   `db:"acknowledged_by" json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *int64   `db:"acknowledged_at" json:"acknowledged_at,omitempty"`
+	ResolvedBy     *int64   `db:"resolved_by" json:"resolved_by,omitempty"`
+	ResolvedAt     *int64   `db:"resolved_at" json:"resolved_at,omitempty"`
+	UpdatedAt      int64    `db:"updated_at" json:"updated_at"`
+	CreatedAt      int64    `db:"created_at" json:"created_at"`
+}
+
+// AlertPage ...
+type AlertPage struct {
+	Data []Alert `json:"data"`
+	Meta Meta    `json:"meta"`
+}
+
+// AlertRuleModel ...
+type AlertRuleModel struct{}
+
+// AlertModel ...
+type AlertModel struct{}
+
+// ruleColumns ...
+const ruleColumns = `id, name, COALESCE(description, '') AS description, metric, operator, threshold, min_bulan,
+	COALESCE(jenis_opd, '') AS jenis_opd, severity, enabled, COALESCE(created_by, 0) AS created_by, updated_at, created_at`
+
+// All ...
+func (m AlertRuleModel) All() (rules []AlertRule, err error) {
+	rules = []AlertRule{}
+	_, err = db.GetDB().Select(&rules, `SELECT `+ruleColumns+` FROM public.alert_rules ORDER BY id`)
+	return rules, err
+}
+
+// One ...
+func (m AlertRuleModel) One(id int64) (rule AlertRule, err error) {
+	err = db.GetDB().SelectOne(&rule, `SELECT `+ruleColumns+` FROM public.alert_rules WHERE id=$1 LIMIT 1`, id)
+	return rule, err
+}
+
+// ruleDefaults ...
+func ruleDefaults(form forms.AlertRuleForm) (forms.AlertRuleForm, bool, error) {
+	if _, ok := alertMetrics[form.Metric]; !ok {
+		return form, false, ErrUnknownMetric
+	}
+	if form.Severity == "" {
+		form.Severity = "warning"
+	}
+	enabled := form.Enabled == nil || *form.Enabled
+	return form, enabled, nil
+}
+
+// Create ...
+func (m AlertRuleModel) Create(userID int64, form forms.AlertRuleForm) (rule AlertRule, err error) {
+	form, enabled, err := ruleDefaults(form)
+	if err != nil {
+		return rule, err
+	}
+
+	now := time.Now().Unix()
+	var id int64
+	err = db.GetDB().QueryRow(`INSERT INTO public.alert_rules
+			(name, description, metric, operator, threshold, min_bulan, jenis_opd, severity, enabled, created_by, updated_at, created_at)
+			VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $11) RETURNING id`,
+		form.Name, form.Description, form.Metric, form.Operator, form.Threshold, form.MinBulan, form.JenisOpd, form.Severity, enabled, userID, now).Scan(&id)
+	if err != nil {
+		return rule, err
+	}
+	return m.One(id)
+}
+
+// Update ...
+func (m AlertRuleModel) Update(id int64, form forms.AlertRuleForm) (rule AlertRule, err error) {
+	form, enabled, err := ruleDefaults(form)
+	if err != nil {
+		return rule, err
+	}
+
+	operation, err := db.GetDB().Exec(`UPDATE public.alert_rules SET name=$2, description=NULLIF($3, ''), metric=$4, operator=$5,
+			threshold=$6, min_bulan=$7, jenis_opd=NULLIF($8, ''), severity=$9, enabled=$10, updated_at=$11 WHERE id=$1`,
+		id, form.Name, form.Description, form.Metric, form.Operator, form.Threshold, form.MinBulan, form.JenisOpd, form.Severity, enabled, time.Now().Unix())
+	if err != nil {
+		return rule, err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return rule, sql.ErrNoRows
+	}
+	return m.One(id)
+}
+
+// Delete removes a rule together with its alerts
+func (m AlertRuleModel) Delete(id int64) error {
+	operation, err := db.GetDB().Exec(`DELETE FROM public.alert_rules WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// alertColumns ...
+const alertColumns = `al.id, al.rule_id, ar.name AS rule_name, ar.severity, al.idsatker, COALESCE(al.nama_opd, '') AS nama_opd,
+	al.tahun, al.bulan, al.value, al.previous, al.message, al.state, COALESCE(al.note, '') AS note,
+	al.acknowledged_by, al.acknowledged_at, al.resolved_by, al.resolved_at, al.updated_at, al.created_at`
+
+// All lists alerts, newest first. idsatker 0 lists every satker.
+func (m AlertModel) All(idsatker int64, form forms.AlertListForm) (page AlertPage, err error) {
+	if form.Page == 0 {
+		form.Page = 1
+	}
+	if form.Limit == 0 {
+		form.Limit = 20
+	}
+	if idsatker == 0 {
+		idsatker = form.Idsatker
+	}
+
+	where := `WHERE ($1 = 0 OR al.idsatker = $1) AND ($2 = '' OR al.state = $2) AND ($3 = 0 OR al.tahun = $3) AND ($4 = 0 OR al.rule_id = $4)`
+	args := []interface{}{idsatker, form.State, form.Tahun, form.RuleID}
+
+	total, err := db.GetDB().SelectInt(`SELECT COUNT(*) FROM public.alerts al `+where, args...)
+	if err != nil {
+		return page, err
+	}
+
+	page.Data = []Alert{}
+	_, err = db.GetDB().Select(&page.Data, `SELECT `+alertColumns+` FROM public.alerts al
+		JOIN public.alert_rules ar ON ar.id = al.rule_id `+where+`
+		ORDER BY al.updated_at DESC, al.id DESC LIMIT $5 OFFSET $6`,
+		append(args, form.Limit, (form.Page-1)*form.Limit)...)
+	if err != nil {
+		return page, err
+	}
+
+	page.Meta = Meta{Total: int(total), Page: form.Page, Limit: form.Limit}
+	return page, nil
+}
+
+// One ...
+func (m AlertModel) One(id int64) (alert Alert, err error) {
+	err = db.GetDB().SelectOne(&alert, `SELECT `+alertColumns+` FROM public.alerts al
+		JOIN public.alert_rules ar ON ar.id = al.rule_id WHERE al.id=$1 LIMIT 1`, id)
+	return alert, err
+}
+
+// transition runs a state UPDATE and maps "no rows" to ErrInvalidTransition
+func (m AlertModel) transition(query string, args ...interface{}) error {
+	operation, err := db.GetDB().Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return ErrInvalidTransition
+	}
+	return nil
+}
+
+// authorize checks the alert exists and belongs to idsatker (0 allows every satker)
+func (m AlertModel) authorize(id, idsatker int64) error {
+	var owner int64
+	if err := db.GetDB().QueryRow(`SELECT idsatker FROM public.alerts WHERE id=$1`, id).Scan(&owner); err != nil {
+		return err
+	}
+	if idsatker != 0 && owner != idsatker {
+		return ErrAlertForbidden
+	}
+	return nil
+}
+
+// Acknowledge marks an open alert as seen by the satker
+func (m AlertModel) Acknowledge(userID, idsatker, id int64, note string) (alert Alert, err error) {
+	if err = m.authorize(id, idsatker); err != nil {
+		return alert, err
+	}
+
+	now := time.Now().Unix()
+	err = m.transition(`UPDATE public.alerts SET state=$2, acknowledged_by=$3, acknowledged_at=$4,
+			note=COALESCE(NULLIF($5, ''), note), updated_at=$4
+		WHERE id=$1 AND state=$6`, id, AlertStateAcknowledged, userID, now, note, AlertStateOpen)
+	if err != nil {
+		return alert, err
+	}
+	return m.One(id)
+}
+
+// Resolve closes an open or acknowledged alert
+func (m AlertModel) Resolve(userID, idsatker, id int64, note string) (alert Alert, err error) {
+	if err = m.authorize(id, idsatker); err != nil {
+		return alert, err
+	}
+
+	now := time.Now().Unix()
+	err = m.transition(`UPDATE public.alerts SET state=$2, resolved_by=$3, resolved_at=$4,
+			note=COALESCE(NULLIF($5, ''), note), updated_at=$4
+		WHERE id=$1 AND state IN ($6, $7)`, id, AlertStateResolved, userID, now, note, AlertStateOpen, AlertStateAcknowledged)
+	if err != nil {
+		return alert, err
+	}
+	return m.One(id)
+}
@@ -0,0 +1,290 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/lib/pq"
+)
+
+// ErrUnknownMetric is returned for a rule metric outside the allowlist
+var ErrUnknownMetric = errors.New("unknown alert metric")
+
+// alertMetrics maps every metric a rule may use to an SQL expression over the de_ranking_opd row "r".
+// Rules are user input, only these expressions ever reach the query.
+var alertMetrics = map[string]string{}
+
+func init() {
+	for _, dimension := range []string{"capaian", "kumulatif", "periodik", "peringkat"} {
+		for _, category := range []string{"opd", "barjas", "fisik", "anggaran", "kinerja"} {
+			column := dimension + "_" + category
+			alertMetrics[column] = "r." + column
+		}
+	}
+
+	// de_detail_* columns, c_ (bulan), k_ (kumulatif) and p_ (periodik)
+	detail := func(table, column string) string {
+		return fmt.Sprintf("(SELECT d.%s FROM %s d WHERE d.id_ranking_opd = r.id ORDER BY d.id DESC LIMIT 1)", column, table)
+	}
+	for _, prefix := range []string{"c", "k", "p"} {
+		for _, category := range []string{"barjas", "fisik", "anggaran", "kinerja"} {
+			for _, kind := range []string{"target", "realisasi"} {
+				column := prefix + "_" + category + "_" + kind
+				alertMetrics[column] = detail("de_detail_"+category, column)
+			}
+		}
+		for _, stage := range []string{"perencanaan", "pemilihan", "pengadaan", "penyerahan"} {
+			for _, kind := range []string{"selesai", "terlambat", "target"} {
+				column := prefix + "_" + stage + "_" + kind
+				alertMetrics[column] = detail("de_detail_barjas", column)
+			}
+		}
+	}
+
+	// Packages of the satker currently overdue in a stage, from de_status_paket
+	for _, stage := range []string{"perencanaan", "pemilihan", "pengadaan", "penyerahan"} {
+		alertMetrics["overdue_"+stage] = fmt.Sprintf(`(SELECT COUNT(*) FROM de_status_paket sp
+			WHERE sp.idsatker = r.idsatker AND sp.tahun = r.tahun AND COALESCE(sp.is_removed, 0) = 0
+			AND LOWER(COALESCE(NULLIF(TRIM(sp.overdue_%s), ''), '0')) NOT IN ('0', 'f', 'false', 'tidak'))`, stage)
+	}
+}
+
+// AlertMetrics lists the metrics rules can be defined on
+func AlertMetrics() []string {
+	metrics := make([]string, 0, len(alertMetrics))
+	for metric := range alertMetrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// Matches reports whether a satker value triggers the rule. previous is the value of the month
+// before and is nil for the first loaded month, which never matches increase or decrease.
+func (r AlertRule) Matches(bulan int, value float64, previous *float64) bool {
+	if bulan < r.MinBulan {
+		return false
+	}
+	switch r.Operator {
+	case "lt":
+		return value < r.Threshold
+	case "lte":
+		return value <= r.Threshold
+	case "gt":
+		return value > r.Threshold
+	case "gte":
+		return value >= r.Threshold
+	case "eq":
+		return value == r.Threshold
+	case "any":
+		return value > 0
+	case "increase":
+		return previous != nil && value-*previous >= r.Threshold
+	case "decrease":
+		return previous != nil && *previous-value >= r.Threshold
+	}
+	return false
+}
+
+// Describe renders the alert message of a match
+func (r AlertRule) Describe(bulan int, value float64, previous *float64) string {
+	formatter := Formatter{}
+	month := GetMonthName(bulan)
+	symbols := map[string]string{"lt": "<", "lte": "<=", "gt": ">", "gte": ">=", "eq": "="}
+
+	switch r.Operator {
+	case "any":
+		return fmt.Sprintf("%s: %s in %s", r.Metric, formatter.FormatNumber(value), month)
+	case "increase", "decrease":
+		return fmt.Sprintf("%s changed from %s to %s in %s", r.Metric, formatter.FormatNumber(*previous), formatter.FormatNumber(value), month)
+	}
+	return fmt.Sprintf("%s is %s (%s %s) in %s", r.Metric, formatter.FormatNumber(value), symbols[r.Operator], formatter.FormatNumber(r.Threshold), month)
+}
+
+// AlertEvaluation summarizes one evaluation run
+type AlertEvaluation struct {
+	Tahun    int   `json:"tahun"`
+	Bulan    int   `json:"bulan,omitempty"`
+	Rules    int   `json:"rules"`
+	Matched  int   `json:"matched"`
+	Opened   int64 `json:"opened"`
+	Updated  int64 `json:"updated"`
+	Resolved int64 `json:"resolved"`
+}
+
+// alertSnapshot is the metric value of a satker's latest month
+type alertSnapshot struct {
+	Idsatker int64    `db:"idsatker"`
+	NamaOpd  string   `db:"nama_opd"`
+	Bulan    int      `db:"bulan"`
+	Value    *float64 `db:"value"`
+	Previous *float64 `db:"previous"`
+}
+
+// evaluating serializes runs of the scheduler and the manual endpoint
+var evaluating sync.Mutex
+
+// Evaluate runs every enabled rule against the latest month of each satker in tahun.
+// bulan limits the months considered (0 uses the latest loaded). A satker that matches gets
+// a new open alert, or its unresolved alert refreshed; unresolved alerts that no longer
+// match are resolved. A manually resolved alert is not reopened for the same month.
+func (m AlertModel) Evaluate(tahun, bulan int) (summary AlertEvaluation, err error) {
+	evaluating.Lock()
+	defer evaluating.Unlock()
+
+	if tahun == 0 {
+		latest, err := db.GetDB().SelectInt(`SELECT COALESCE(MAX(tahun), 0) FROM de_ranking_opd`)
+		if err != nil {
+			return summary, err
+		}
+		tahun = int(latest)
+	}
+	summary.Tahun, summary.Bulan = tahun, bulan
+
+	var rules []AlertRule
+	_, err = db.GetDB().Select(&rules, `SELECT `+ruleColumns+` FROM public.alert_rules WHERE enabled ORDER BY id`)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, rule := range rules {
+		if err := m.evaluateRule(rule, tahun, bulan, &summary); err != nil {
+			return summary, fmt.Errorf("rule %d: %v", rule.ID, err)
+		}
+		summary.Rules++
+	}
+	return summary, nil
+}
+
+// evaluateRule ...
+func (m AlertModel) evaluateRule(rule AlertRule, tahun, bulan int, summary *AlertEvaluation) error {
+	expression, ok := alertMetrics[rule.Metric]
+	if !ok {
+		return ErrUnknownMetric
+	}
+
+	var snapshots []alertSnapshot
+	_, err := db.GetDB().Select(&snapshots, fmt.Sprintf(`SELECT c.idsatker, COALESCE(c.nama_opd, '') AS nama_opd, c.bulan,
+			(SELECT (%[1]s)::float8 FROM de_ranking_opd r WHERE r.id = c.id) AS value,
+			(SELECT (%[1]s)::float8 FROM de_ranking_opd r
+				WHERE r.idsatker = c.idsatker AND r.tahun = c.tahun AND r.bulan = c.bulan - 1 ORDER BY r.id DESC LIMIT 1) AS previous
+		FROM (
+			SELECT DISTINCT ON (idsatker) id, idsatker, tahun, bulan, nama_opd, jenis_opd
+			FROM de_ranking_opd
+			WHERE tahun = $1 AND idsatker <> 0 AND ($2 = 0 OR bulan <= $2)
+			ORDER BY idsatker, bulan DESC, id DESC
+		) c
+		WHERE ($3 = '' OR c.jenis_opd = $3)`, expression), tahun, bulan, rule.JenisOpd)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	matched := []int64{}
+	var opened []Alert
+	for _, snapshot := range snapshots {
+		if snapshot.Value == nil || !rule.Matches(snapshot.Bulan, *snapshot.Value, snapshot.Previous) {
+			continue
+		}
+		matched = append(matched, snapshot.Idsatker)
+		message := rule.Describe(snapshot.Bulan, *snapshot.Value, snapshot.Previous)
+
+		operation, err := tx.Exec(`UPDATE public.alerts SET bulan=$4, value=$5, previous=$6, message=$7, nama_opd=$8, updated_at=$9
+			WHERE rule_id=$1 AND idsatker=$2 AND tahun=$3 AND state <> $10`,
+			rule.ID, snapshot.Idsatker, tahun, snapshot.Bulan, *snapshot.Value, snapshot.Previous, message, snapshot.NamaOpd, now, AlertStateResolved)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if affected, _ := operation.RowsAffected(); affected > 0 {
+			summary.Updated += affected
+			continue
+		}
+
+		var alertID int64
+		err = tx.QueryRow(`INSERT INTO public.alerts (rule_id, idsatker, nama_opd, tahun, bulan, value, previous, message, state, updated_at, created_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10
+			WHERE NOT EXISTS (SELECT 1 FROM public.alerts WHERE rule_id=$1 AND idsatker=$2 AND tahun=$4 AND bulan=$5)
+			RETURNING id`,
+			rule.ID, snapshot.Idsatker, snapshot.NamaOpd, tahun, snapshot.Bulan, *snapshot.Value, snapshot.Previous, message, AlertStateOpen, now).Scan(&alertID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		summary.Opened++
+		opened = append(opened, Alert{ID: alertID, RuleID: rule.ID, RuleName: rule.Name, Severity: rule.Severity,
+			Idsatker: snapshot.Idsatker, Tahun: tahun, Bulan: snapshot.Bulan, Message: message})
+	}
+	summary.Matched += len(matched)
+
+	operation, err := tx.Exec(`UPDATE public.alerts SET state=$3, resolved_at=$4, note=COALESCE(note, 'Condition no longer met'), updated_at=$4
+		WHERE rule_id=$1 AND tahun=$2 AND state <> $3 AND NOT (idsatker = ANY($5))`,
+		rule.ID, tahun, AlertStateResolved, now, pq.Array(matched))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	resolved, _ := operation.RowsAffected()
+	summary.Resolved += resolved
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// A failed notification must not undo the evaluation, it is only logged
+	for _, alert := range opened {
+		if err := notifyAlert(alert); err != nil {
+			log.Printf("Alert %d notification error: %v", alert.ID, err)
+		}
+	}
+	return nil
+}
+
+// notifyAlert tells the users of the alert's satker about a new alert
+func notifyAlert(alert Alert) error {
+	userIDs, err := usersOfSatker(alert.Idsatker)
+	if err != nil {
+		return err
+	}
+	return notificationModel.Send(userIDs, Notice{
+		Event: EventAlert,
+		Title: alert.RuleName,
+		Body:  alert.Message,
+		Data:  map[string]interface{}{"alert_id": alert.ID, "rule_id": alert.RuleID, "severity": alert.Severity, "tahun": alert.Tahun, "bulan": alert.Bulan},
+	})
+}
+
+// AlertEvaluateInterval is how often the scheduler looks for new data, ALERT_EVALUATE_MINUTES (default 15)
+func AlertEvaluateInterval() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ALERT_EVALUATE_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// alertDataStamp changes whenever ranking data is ingested or a rule is edited
+func alertDataStamp() (string, error) {
+	var data, rules, count int64
+	err := db.GetDB().Db.QueryRow(`SELECT
+			(SELECT COALESCE(MAX(last_update), 0) FROM de_ranking_opd),
+			(SELECT COALESCE(MAX(updated_at), 0) FROM public.alert_rules),
+			(SELECT COUNT(*) FROM public.alert_rules)`).Scan(&data, &rules, &count)
+	return fmt.Sprintf("%d/%d/%d", data, rules, count), err
+}
@@ -27,9 +27,28 @@ type ArticleResponse struct {
 	ID        int          `json:"id"`
 	Title     string       `json:"title"`
 	Content   string       `json:"content"`
+	Format    string       `json:"format"`
 	UpdatedAt int64        `json:"updated_at"`
 	CreatedAt int64        `json:"created_at"`
+	Status    string       `json:"status"`
+	PublishAt int64        `json:"publish_at,omitempty"`
+	ExpireAt  int64        `json:"expire_at,omitempty"`
 	User      UserResponse `json:"user"`
+	Category  *CategoryRef `json:"category,omitempty"`
+	Tags      []string     `json:"tags"`
+	Highlight *Highlight   `json:"highlight,omitempty"`
+}
+
+// CategoryRef is the category of a listed article
+type CategoryRef struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Highlight holds HTML-escaped search snippets, matches are wrapped in <mark>
+type Highlight struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
 }
 
 // User represents the article's author
@@ -41,7 +60,11 @@ type UserResponse struct {
 
 // Meta represents metadata for pagination or total count
 type Meta struct {
-	Total int `json:"total"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 // Article ...
@@ -50,58 +73,154 @@ type Article struct {
 	UserID    int64    `db:"user_id" json:"-"`
 	Title     string   `db:"title" json:"title"`
 	Content   string   `db:"content" json:"content"`
+	HTML      string   `db:"content_html" json:"-"`
+	Format    string   `db:"-" json:"format"`
+	Status    string   `db:"status" json:"status"`
+	PublishAt int64    `db:"publish_at" json:"publish_at,omitempty"`
+	ExpireAt  int64    `db:"expire_at" json:"expire_at,omitempty"`
 	UpdatedAt int64    `db:"updated_at" json:"updated_at"`
 	CreatedAt int64    `db:"created_at" json:"created_at"`
 	User      *JSONRaw `db:"user" json:"user"`
+	Category  *JSONRaw `db:"category" json:"category"`
+	Tags      *JSONRaw `db:"tags" json:"tags"`
+	Audiences *JSONRaw `db:"audiences" json:"audiences"`
+	Editors   *JSONRaw `db:"editors" json:"editors"`
 }
 
+// articleTargetsSelect selects the category, tags and audiences of article a as JSON
+const articleTargetsSelect = `(SELECT json_build_object('id', c.id, 'name', c.name) FROM public.article_categories c WHERE c.id = a.category_id) AS category,
+	COALESCE((SELECT json_agg(t.tag ORDER BY t.tag) FROM public.article_tags t WHERE t.article_id = a.id), '[]') AS tags,
+	COALESCE((SELECT json_agg(json_build_object('type', x.type, 'value', x.value) ORDER BY x.id) FROM public.article_audiences x WHERE x.article_id = a.id), '[]') AS audiences`
+
 // ArticleModel ...
 type ArticleModel struct{}
 
-// Create ...
+// Create stores a new article as draft
 func (m ArticleModel) Create(userID int64, form forms.CreateArticleForm) (articleID int64, err error) {
-	err = db.GetDB().QueryRow("INSERT INTO public.article(user_id, title, content) VALUES($1, $2, $3) RETURNING id", userID, form.Title, form.Content).Scan(&articleID)
-	return articleID, err
+	if err = validateSchedule(form); err != nil {
+		return 0, err
+	}
+	if err = validateTargets(form); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	contentHTML, err := RenderMarkdown(form.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow("INSERT INTO public.article(user_id, title, content, content_html, status, publish_at, expire_at) VALUES($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0)) RETURNING id", userID, form.Title, form.Content, contentHTML, ArticleStatusDraft, form.PublishAt, form.ExpireAt).Scan(&articleID)
+	if err != nil {
+		return 0, err
+	}
+	if err = saveRevision(tx, articleID, userID, form.Title, form.Content, ""); err != nil {
+		return 0, err
+	}
+	if err = saveTargets(tx, articleID, form); err != nil {
+		return 0, err
+	}
+	return articleID, tx.Commit()
 }
 
-// One ...
+// One returns an article readable by userID
 func (m ArticleModel) One(userID, id int64) (article Article, err error) {
-	err = db.GetDB().SelectOne(&article, "SELECT a.id, a.title, a.content, a.updated_at, a.created_at, json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS user FROM public.article a LEFT JOIN public.user u ON a.user_id = u.id WHERE a.user_id=$1 AND a.id=$2 LIMIT 1", userID, id)
+	err = db.GetDB().SelectOne(&article, "SELECT a.id, a.title, a.content, COALESCE(a.content_html, '') AS content_html, a.status, COALESCE(a.publish_at, 0) AS publish_at, COALESCE(a.expire_at, 0) AS expire_at, a.updated_at, a.created_at, json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS user, "+articleTargetsSelect+", COALESCE((SELECT json_agg(json_build_object('id', eu.id, 'name', eu.name, 'email', eu.email) ORDER BY e.created_at) FROM public.article_editors e JOIN public.user eu ON eu.id = e.user_id WHERE e.article_id = a.id), '[]') AS editors FROM public.article a LEFT JOIN public.user u ON a.user_id = u.id WHERE a.id=$2 AND "+articleReadable("$1")+" LIMIT 1", userID, id)
 	return article, err
 }
 
-// All ...
-func (m ArticleModel) All(userID int64) (articles []DataList, err error) {
-	_, err = db.GetDB().Select(&articles, "SELECT COALESCE(array_to_json(array_agg(row_to_json(d))), '[]') AS data, (SELECT row_to_json(n) FROM ( SELECT count(a.id) AS total FROM public.article AS a WHERE a.user_id=$1 LIMIT 1 ) n ) AS meta FROM ( SELECT a.id, a.title, a.content, a.updated_at, a.created_at, json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS user FROM public.article a LEFT JOIN public.user u ON a.user_id = u.id WHERE a.user_id=$1 ORDER BY a.id DESC) d", userID)
-	return articles, err
+// ApplyFormat replaces Content with the requested format: markdown (default, the stored
+// source), html (sanitized, attachment images signed) or text
+func (a *Article) ApplyFormat(format string) {
+	if format == "" {
+		format = FormatMarkdown
+	}
+	a.Content = formatContent(a.ID, a.Content, a.HTML, format)
+	a.Format = format
 }
 
-// Update ...
+// All returns one page of the articles the user owns or co-edits, see ArticleListForm for the supported options
+func (m ArticleModel) All(userID int64, form forms.ArticleListForm) (results []Result, err error) {
+	page, err := m.list("(a.user_id=$1 OR "+isCoEditor("$1")+")", []interface{}{userID}, form)
+	if err != nil {
+		return nil, err
+	}
+	return []Result{page}, nil
+}
+
+// Update changes an article editable by userID
 func (m ArticleModel) Update(userID int64, id int64, form forms.CreateArticleForm) (err error) {
-	//METHOD 1
-	//Check the article by ID using this way
-	// _, err = m.One(userID, id)
-	// if err != nil {
-	// 	return err
-	// }
-
-	operation, err := db.GetDB().Exec("UPDATE public.article SET title=$2, content=$3 WHERE id=$1", id, form.Title, form.Content)
+	if err = m.CanEdit(userID, id); err != nil {
+		return err
+	}
+
+	if err = validateSchedule(form); err != nil {
+		return err
+	}
+	if err = validateTargets(form); err != nil {
+		return err
+	}
+
+	// The update and its revision are stored atomically
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	contentHTML, err := RenderMarkdown(form.Content)
+	if err != nil {
+		return err
+	}
+
+	operation, err := tx.Exec("UPDATE public.article a SET title=$2, content=$3, content_html=$7, publish_at=NULLIF($4, 0), expire_at=NULLIF($5, 0) WHERE a.id=$1 AND "+articleEditable("$6"),
+		id, form.Title, form.Content, form.PublishAt, form.ExpireAt, userID, contentHTML)
 	if err != nil {
 		return err
 	}
 
 	success, _ := operation.RowsAffected()
 	if success == 0 {
-		return errors.New("updated 0 records")
+		err = errors.New("updated 0 records")
+		return err
 	}
 
-	return err
+	if err = saveRevision(tx, id, userID, form.Title, form.Content, ""); err != nil {
+		return err
+	}
+	if err = saveTargets(tx, id, form); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Delete ...
+// Delete removes an article, only the owner and edit_any_article holders may delete
 func (m ArticleModel) Delete(userID, id int64) (err error) {
+	if err = m.authorize(userID, id, articleManageable("$1")); err != nil {
+		return err
+	}
+
+	// Attachment rows are removed by the cascade, their stored objects are removed after the delete succeeds
+	var keys []string
+	if _, err = db.GetDB().Select(&keys, "SELECT storage_key FROM public.article_attachments WHERE article_id=$1", id); err != nil {
+		return err
+	}
 
-	operation, err := db.GetDB().Exec("DELETE FROM public.article WHERE id=$1", id)
+	operation, err := db.GetDB().Exec("DELETE FROM public.article a WHERE a.id=$1 AND "+articleManageable("$2"), id, userID)
 	if err != nil {
 		return err
 	}
@@ -111,5 +230,7 @@ func (m ArticleModel) Delete(userID, id int64) (err error) {
 		return errors.New("no records were deleted")
 	}
 
+	AttachmentModel{}.purgeArticle(keys)
+
 	return err
 }
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Massad/gin-boilerplate/db"
+)
+
+// Article access rules, used by every article handler:
+//   - read: editors (below) and, once published, the users the article is addressed to
+//   - edit: the owner, co-editors, and users with edit_any_article (admins included)
+//   - delete and manage co-editors: the owner and users with edit_any_article
+
+// ErrArticleForbidden is returned when the article exists but the user may not perform the action
+var ErrArticleForbidden = errors.New("not allowed to change this article")
+
+// ArticleEditor is a co-editor of an article
+type ArticleEditor struct {
+	UserID    int64  `db:"user_id" json:"user_id"`
+	Name      string `db:"name" json:"name"`
+	Email     string `db:"email" json:"email"`
+	AddedBy   int64  `db:"added_by" json:"added_by"`
+	CreatedAt int64  `db:"created_at" json:"created_at"`
+}
+
+// canEditAny is an SQL condition, true when the user userExpr is an admin or holds edit_any_article
+func canEditAny(userExpr string) string {
+	return `EXISTS (SELECT 1 FROM public.user_roles ur JOIN public.roles r ON r.id = ur.role_id
+			LEFT JOIN public.role_permissions rp ON rp.role_id = r.id
+			LEFT JOIN public.permissions p ON p.id = rp.permission_id
+			WHERE ur.user_id = ` + userExpr + ` AND (r.name = 'admin' OR p.name = 'edit_any_article'))`
+}
+
+// isCoEditor is an SQL condition, true when the user userExpr is a co-editor of article a
+func isCoEditor(userExpr string) string {
+	return `EXISTS (SELECT 1 FROM public.article_editors e WHERE e.article_id = a.id AND e.user_id = ` + userExpr + `)`
+}
+
+// articleEditable is an SQL condition, true when the user userExpr may edit article a
+func articleEditable(userExpr string) string {
+	return `(a.user_id = ` + userExpr + ` OR ` + isCoEditor(userExpr) + ` OR ` + canEditAny(userExpr) + `)`
+}
+
+// articleReadable is an SQL condition, true when the user userExpr may read article a
+func articleReadable(userExpr string) string {
+	return `(` + articleEditable(userExpr) + ` OR (a.status = '` + ArticleStatusPublished + `' AND ` + audienceMatch(userExpr) + `))`
+}
+
+// articleManageable is an SQL condition, true when the user userExpr may delete article a and manage its co-editors
+func articleManageable(userExpr string) string {
+	return `(a.user_id = ` + userExpr + ` OR ` + canEditAny(userExpr) + `)`
+}
+
+// authorize returns nil when condition holds for the article, sql.ErrNoRows when it does
+// not exist and ErrArticleForbidden otherwise. condition uses $1 for the user.
+func (m ArticleModel) authorize(userID, id int64, condition string) error {
+	var allowed sql.NullBool
+	err := db.GetDB().QueryRow(`SELECT `+condition+` FROM public.article a WHERE a.id = $2`, userID, id).Scan(&allowed)
+	if err != nil {
+		return err
+	}
+	if !allowed.Bool {
+		return ErrArticleForbidden
+	}
+	return nil
+}
+
+// CanEdit ...
+func (m ArticleModel) CanEdit(userID, id int64) error {
+	return m.authorize(userID, id, articleEditable("$1"))
+}
+
+// Editors lists the co-editors of an article readable by userID
+func (m ArticleModel) Editors(userID, id int64) (editors []ArticleEditor, err error) {
+	if _, err = m.One(userID, id); err != nil {
+		return nil, err
+	}
+	_, err = db.GetDB().Select(&editors, `SELECT e.user_id, COALESCE(u.name, '') AS name, COALESCE(u.email, '') AS email,
+			COALESCE(e.added_by, 0) AS added_by, e.created_at
+		FROM public.article_editors e JOIN public."user" u ON u.id = e.user_id
+		WHERE e.article_id = $1 ORDER BY e.created_at`, id)
+	if editors == nil {
+		editors = []ArticleEditor{}
+	}
+	return editors, err
+}
+
+// AddEditor makes editorID a co-editor, adding an existing co-editor is a no-op
+func (m ArticleModel) AddEditor(userID, id, editorID int64) error {
+	if err := m.authorize(userID, id, articleManageable("$1")); err != nil {
+		return err
+	}
+
+	var ownerID int64
+	if err := db.GetDB().QueryRow("SELECT user_id FROM public.article WHERE id=$1", id).Scan(&ownerID); err != nil {
+		return err
+	}
+	if ownerID == editorID {
+		return errors.New("the owner is already an editor")
+	}
+	if _, err := userModel.One(editorID); err != nil {
+		return errors.New("user not found")
+	}
+
+	_, err := db.GetDB().Exec(`INSERT INTO public.article_editors (article_id, user_id, added_by, created_at)
+		VALUES ($1, $2, $3, EXTRACT(EPOCH FROM NOW())) ON CONFLICT (article_id, user_id) DO NOTHING`, id, editorID, userID)
+	return err
+}
+
+// RemoveEditor removes a co-editor. Co-editors may remove themselves.
+func (m ArticleModel) RemoveEditor(userID, id, editorID int64) error {
+	if userID != editorID {
+		if err := m.authorize(userID, id, articleManageable("$1")); err != nil {
+			return err
+		}
+	}
+
+	operation, err := db.GetDB().Exec("DELETE FROM public.article_editors WHERE article_id=$1 AND user_id=$2", id, editorID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
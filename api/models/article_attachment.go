@@ -0,0 +1,213 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/storage"
+)
+
+// ErrAttachmentType is returned when the sniffed content does not match an allowed type
+var ErrAttachmentType = errors.New("file type is not allowed")
+
+// ArticleAttachment is a file uploaded to an article, the bytes live in the storage backend
+type ArticleAttachment struct {
+	ID          int64    `db:"id, primarykey, autoincrement" json:"id"`
+	ArticleID   int64    `db:"article_id" json:"article_id"`
+	UserID      int64    `db:"user_id" json:"-"`
+	Filename    string   `db:"filename" json:"filename"`
+	ContentType string   `db:"content_type" json:"content_type"`
+	Size        int64    `db:"size" json:"size"`
+	StorageKey  string   `db:"storage_key" json:"-"`
+	CreatedAt   int64    `db:"created_at" json:"created_at"`
+	User        *JSONRaw `db:"user" json:"user"`
+}
+
+// AttachmentURL is a signed, expiring download link
+type AttachmentURL struct {
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// attachmentTypes maps allowed extensions to the content types http.DetectContentType may
+// report for them and the type stored. Office files sniff as zip (xlsx, docx) or as the
+// generic OLE container (xls, doc), so the extension picks the stored type.
+var attachmentTypes = map[string]struct {
+	Sniffed []string
+	Stored  string
+}{
+	".pdf":  {[]string{"application/pdf"}, "application/pdf"},
+	".png":  {[]string{"image/png"}, "image/png"},
+	".jpg":  {[]string{"image/jpeg"}, "image/jpeg"},
+	".jpeg": {[]string{"image/jpeg"}, "image/jpeg"},
+	".gif":  {[]string{"image/gif"}, "image/gif"},
+	".webp": {[]string{"image/webp"}, "image/webp"},
+	".csv":  {[]string{"text/plain; charset=utf-8"}, "text/csv"},
+	".xlsx": {[]string{"application/zip"}, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	".docx": {[]string{"application/zip"}, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	".xls":  {[]string{"application/x-ole-storage"}, "application/vnd.ms-excel"},
+	".doc":  {[]string{"application/x-ole-storage"}, "application/msword"},
+}
+
+// oleSignature is the header of Compound File Binary documents (legacy Office)
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// DetectAttachmentType sniffs the first bytes of a file and returns the content type to
+// store, or ErrAttachmentType when the content does not match the filename's extension
+func DetectAttachmentType(filename string, head []byte) (string, error) {
+	allowed, ok := attachmentTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", ErrAttachmentType
+	}
+
+	sniffed := http.DetectContentType(head)
+	if bytes.HasPrefix(head, oleSignature) {
+		sniffed = "application/x-ole-storage"
+	}
+	for _, contentType := range allowed.Sniffed {
+		if sniffed == contentType {
+			return allowed.Stored, nil
+		}
+	}
+	return "", ErrAttachmentType
+}
+
+// AttachmentMaxBytes is the upload size limit, ATTACHMENT_MAX_MB (default 10)
+func AttachmentMaxBytes() int64 {
+	megabytes, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_MB"), 10, 64)
+	if err != nil || megabytes <= 0 {
+		megabytes = 10
+	}
+	return megabytes << 20
+}
+
+// attachmentURLTTL is the signed URL lifetime, ATTACHMENT_URL_TTL_MINUTES (default 15)
+func attachmentURLTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ATTACHMENT_URL_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// attachmentKey builds a unique, unguessable object key below the article's prefix
+func attachmentKey(articleID int64, filename string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("articles/%d/%s%s", articleID, hex.EncodeToString(random), strings.ToLower(filepath.Ext(filename))), nil
+}
+
+// AttachmentModel ...
+type AttachmentModel struct{}
+
+var articleModel = new(ArticleModel)
+
+// Create stores the file in the storage backend and records it. The article must be editable by userID.
+func (m AttachmentModel) Create(userID, articleID int64, filename, contentType string, size int64, r io.Reader) (attachment ArticleAttachment, err error) {
+	if err = articleModel.CanEdit(userID, articleID); err != nil {
+		return attachment, err
+	}
+
+	key, err := attachmentKey(articleID, filename)
+	if err != nil {
+		return attachment, err
+	}
+
+	ctx := context.Background()
+	if err = storage.GetStorage().Put(ctx, key, r, size, contentType); err != nil {
+		return attachment, err
+	}
+
+	err = db.GetDB().QueryRow(`INSERT INTO public.article_attachments (article_id, user_id, filename, content_type, size, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, EXTRACT(EPOCH FROM NOW())) RETURNING id`,
+		articleID, userID, filename, contentType, size, key).Scan(&attachment.ID)
+	if err != nil {
+		// Do not leave an orphaned object behind
+		if deleteErr := storage.GetStorage().Delete(ctx, key); deleteErr != nil {
+			log.Printf("Attachment cleanup failed for %s: %v", key, deleteErr)
+		}
+		return attachment, err
+	}
+
+	return m.One(userID, articleID, attachment.ID)
+}
+
+// All lists the attachments of an article readable by userID, oldest first
+func (m AttachmentModel) All(userID, articleID int64) (attachments []ArticleAttachment, err error) {
+	if _, err = articleModel.One(userID, articleID); err != nil {
+		return nil, err
+	}
+	_, err = db.GetDB().Select(&attachments, `SELECT f.id, f.article_id, f.filename, f.content_type, f.size, f.storage_key, f.created_at,
+			json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS user
+		FROM public.article_attachments f LEFT JOIN public.user u ON f.user_id = u.id
+		WHERE f.article_id = $1 ORDER BY f.id`, articleID)
+	return attachments, err
+}
+
+// One returns one attachment of an article readable by userID
+func (m AttachmentModel) One(userID, articleID, id int64) (attachment ArticleAttachment, err error) {
+	if _, err = articleModel.One(userID, articleID); err != nil {
+		return attachment, err
+	}
+	err = db.GetDB().SelectOne(&attachment, `SELECT f.id, f.article_id, f.filename, f.content_type, f.size, f.storage_key, f.created_at,
+			json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS user
+		FROM public.article_attachments f LEFT JOIN public.user u ON f.user_id = u.id
+		WHERE f.article_id = $1 AND f.id = $2 LIMIT 1`, articleID, id)
+	return attachment, err
+}
+
+// URL returns a signed, expiring download link for an attachment
+func (m AttachmentModel) URL(userID, articleID, id int64) (link AttachmentURL, err error) {
+	attachment, err := m.One(userID, articleID, id)
+	if err != nil {
+		return link, err
+	}
+	ttl := attachmentURLTTL()
+	signed, err := storage.GetStorage().SignedURL(context.Background(), attachment.StorageKey, attachment.Filename, ttl)
+	if err != nil {
+		return link, err
+	}
+	return AttachmentURL{URL: signed, ExpiresAt: time.Now().Add(ttl).Unix()}, nil
+}
+
+// Delete removes the record and then the stored object. The article must be editable by userID.
+func (m AttachmentModel) Delete(userID, articleID, id int64) error {
+	if err := articleModel.CanEdit(userID, articleID); err != nil {
+		return err
+	}
+	attachment, err := m.One(userID, articleID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := db.GetDB().Exec("DELETE FROM public.article_attachments WHERE id=$1", attachment.ID); err != nil {
+		return err
+	}
+	if err := storage.GetStorage().Delete(context.Background(), attachment.StorageKey); err != nil {
+		log.Printf("Attachment object %s could not be deleted: %v", attachment.StorageKey, err)
+	}
+	return nil
+}
+
+// purgeArticle deletes the stored objects of an article's attachments, the rows go with the article (ON DELETE CASCADE)
+func (m AttachmentModel) purgeArticle(keys []string) {
+	for _, key := range keys {
+		if err := storage.GetStorage().Delete(context.Background(), key); err != nil {
+			log.Printf("Attachment object %s could not be deleted: %v", key, err)
+		}
+	}
+}
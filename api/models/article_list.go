@@ -0,0 +1,290 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultArticleLimit = 20
+
+	// ts_headline markers, replaced by <mark> after the snippet has been HTML-escaped
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// articleSort describes one sort option. Every sort uses a.id as the tie-breaker,
+// so (key, id) is unique and can be used for keyset pagination.
+type articleSort struct {
+	Key     string // SQL expression of the primary key, empty when sorting by id only
+	KeyType string // SQL type the cursor value is cast to
+	Desc    bool
+}
+
+var articleSorts = map[string]articleSort{
+	"newest":    {Desc: true},
+	"oldest":    {Desc: false},
+	"updated":   {Key: "COALESCE(a.updated_at, 0)", KeyType: "bigint", Desc: true},
+	"title":     {Key: "COALESCE(a.title, '')", KeyType: "text", Desc: false},
+	"relevance": {Key: "rank", KeyType: "real", Desc: true},
+}
+
+// articleCursor is the decoded form of the opaque next/prev cursor.
+// Keyset cursors carry the sort key and id of the boundary row, relevance
+// sorting uses an offset since ts_rank is not a stable key.
+type articleCursor struct {
+	Sort   string `json:"s"`
+	Dir    string `json:"d"` // "next" | "prev"
+	Key    string `json:"k,omitempty"`
+	ID     int64  `json:"i,omitempty"`
+	Offset int    `json:"o,omitempty"`
+}
+
+func (c articleCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeArticleCursor(s string) (c articleCursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if c.Dir != "next" && c.Dir != "prev" {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// highlightHTML escapes a ts_headline snippet and turns the markers into <mark> tags
+func highlightHTML(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
+
+// list builds and runs the listing query, supporting page/limit and cursor pagination.
+// scope is the base WHERE clause (without the keyword) selecting the visible articles, its
+// placeholders are numbered from $1 and bound to scopeArgs.
+func (m ArticleModel) list(scope string, scopeArgs []interface{}, form forms.ArticleListForm) (result Result, err error) {
+	limit := form.Limit
+	if limit <= 0 {
+		limit = defaultArticleLimit
+	}
+	page := form.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	format := form.Format
+	if format == "" {
+		format = FormatMarkdown
+	}
+
+	q := strings.TrimSpace(form.Q)
+	sortName := form.Sort
+	if sortName == "" {
+		sortName = "newest"
+		if q != "" {
+			sortName = "relevance"
+		}
+	}
+	if sortName == "relevance" && q == "" {
+		sortName = "newest"
+	}
+	sort := articleSorts[sortName]
+
+	var cursor *articleCursor
+	if form.Cursor != "" {
+		c, err := decodeArticleCursor(form.Cursor)
+		if err != nil {
+			return result, err
+		}
+		if c.Sort != sortName {
+			return result, errors.New("cursor does not match the sort order")
+		}
+		cursor = &c
+	}
+
+	where := "WHERE " + scope
+	args := append([]interface{}{}, scopeArgs...)
+
+	if form.Status != "" {
+		args = append(args, form.Status)
+		where += fmt.Sprintf(" AND a.status = $%d", len(args))
+	}
+	if form.Tag != "" {
+		args = append(args, strings.ToLower(strings.TrimSpace(form.Tag)))
+		where += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM public.article_tags t WHERE t.article_id = a.id AND t.tag = $%d)", len(args))
+	}
+	if form.Category > 0 {
+		args = append(args, form.Category)
+		where += fmt.Sprintf(" AND a.category_id = $%d", len(args))
+	}
+	rankSelect := "0::real AS rank"
+	highlightSelect := "'' AS title_highlight, '' AS content_highlight"
+
+	if q != "" {
+		args = append(args, q)
+		tsQuery := fmt.Sprintf("websearch_to_tsquery('simple', $%d)", len(args))
+		where += " AND a.search_vector @@ " + tsQuery
+		rankSelect = "ts_rank(a.search_vector, " + tsQuery + ") AS rank"
+		options := fmt.Sprintf("'StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=30, MinWords=10'", highlightStart, highlightStop)
+		highlightSelect = "ts_headline('simple', COALESCE(a.title, ''), " + tsQuery + ", " + options + ") AS title_highlight, " +
+			"ts_headline('simple', COALESCE(a.content, ''), " + tsQuery + ", " + options + ") AS content_highlight"
+	}
+
+	var total int64
+	total, err = db.GetDB().SelectInt("SELECT count(a.id) FROM public.article a "+where, args...)
+	if err != nil {
+		return result, err
+	}
+
+	// Walking backwards flips both the comparison and the order, rows are reversed afterwards
+	backwards := cursor != nil && cursor.Dir == "prev"
+	desc := sort.Desc != backwards
+	direction := "ASC"
+	comparator := ">"
+	if desc {
+		direction = "DESC"
+		comparator = "<"
+	}
+
+	keyset := ""
+	offset := (page - 1) * limit
+	if cursor != nil {
+		offset = 0
+		switch {
+		case sortName == "relevance":
+			offset = cursor.Offset
+		case sort.Key == "":
+			args = append(args, cursor.ID)
+			keyset = fmt.Sprintf(" AND a.id %s $%d", comparator, len(args))
+		default:
+			args = append(args, cursor.Key, cursor.ID)
+			keyset = fmt.Sprintf(" AND (%s, a.id) %s ($%d::%s, $%d)", sort.Key, comparator, len(args)-1, sort.KeyType, len(args))
+		}
+	}
+
+	orderBy := "a.id " + direction
+	keyColumn := "''"
+	if sort.Key != "" {
+		orderBy = sort.Key + " " + direction + ", " + orderBy
+		keyColumn = sort.Key + "::text"
+	}
+
+	// Fetch one extra row to know whether there is another page in this direction
+	query := `SELECT a.id, COALESCE(a.title, ''), COALESCE(a.content, ''), COALESCE(a.content_html, ''), a.status, COALESCE(a.publish_at, 0), COALESCE(a.expire_at, 0),
+			COALESCE(a.updated_at, 0), COALESCE(a.created_at, 0), COALESCE(u.id, 0), COALESCE(u.name, ''), COALESCE(u.email, ''),
+			COALESCE(c.id, 0), COALESCE(c.name, ''), ARRAY(SELECT t.tag FROM public.article_tags t WHERE t.article_id = a.id ORDER BY t.tag), ` + keyColumn + ` AS sort_key, ` + highlightSelect + `
+		FROM (SELECT a.*, ` + rankSelect + ` FROM public.article a ` + where + `) a
+		LEFT JOIN public.user u ON a.user_id = u.id
+		LEFT JOIN public.article_categories c ON a.category_id = c.id
+		WHERE true` + keyset + `
+		ORDER BY ` + orderBy + `
+		LIMIT ` + strconv.Itoa(limit+1) + ` OFFSET ` + strconv.Itoa(offset)
+
+	rows, err := db.GetDB().Query(query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	articles := []ArticleResponse{}
+	sortKeys := []string{}
+	for rows.Next() {
+		var a ArticleResponse
+		var sortKey, titleHighlight, contentHighlight, contentHTML string
+		var category CategoryRef
+		if err := rows.Scan(&a.ID, &a.Title, &a.Content, &contentHTML, &a.Status, &a.PublishAt, &a.ExpireAt, &a.UpdatedAt, &a.CreatedAt,
+			&a.User.ID, &a.User.Name, &a.User.Email, &category.ID, &category.Name, pq.Array(&a.Tags),
+			&sortKey, &titleHighlight, &contentHighlight); err != nil {
+			return result, err
+		}
+		if category.ID > 0 {
+			a.Category = &category
+		}
+		a.Format = format
+		a.Content = formatContent(int64(a.ID), a.Content, contentHTML, format)
+		if a.Tags == nil {
+			a.Tags = []string{}
+		}
+		if q != "" {
+			a.Highlight = &Highlight{
+				Title:   highlightHTML(titleHighlight),
+				Content: highlightHTML(contentHighlight),
+			}
+		}
+		articles = append(articles, a)
+		sortKeys = append(sortKeys, sortKey)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	hasMore := len(articles) > limit
+	if hasMore {
+		articles = articles[:limit]
+		sortKeys = sortKeys[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(articles)-1; i < j; i, j = i+1, j-1 {
+			articles[i], articles[j] = articles[j], articles[i]
+			sortKeys[i], sortKeys[j] = sortKeys[j], sortKeys[i]
+		}
+	}
+
+	// hasNext/hasPrev relative to the natural sort order
+	hasNext, hasPrev := hasMore, offset > 0
+	if cursor != nil {
+		hasPrev = true
+		if sortName == "relevance" {
+			hasPrev = offset > 0
+		}
+		if backwards {
+			hasNext, hasPrev = true, hasMore
+		}
+	}
+
+	meta := Meta{Total: int(total), Limit: limit}
+	if cursor == nil {
+		meta.Page = page
+	}
+
+	if len(articles) > 0 {
+		first, last := 0, len(articles)-1
+		if hasNext {
+			next := articleCursor{Sort: sortName, Dir: "next", Key: sortKeys[last], ID: int64(articles[last].ID)}
+			if sortName == "relevance" {
+				next = articleCursor{Sort: sortName, Dir: "next", Offset: offset + len(articles)}
+			}
+			meta.NextCursor = next.encode()
+		}
+		if hasPrev {
+			prev := articleCursor{Sort: sortName, Dir: "prev", Key: sortKeys[first], ID: int64(articles[first].ID)}
+			if sortName == "relevance" {
+				prevOffset := offset - limit
+				if prevOffset < 0 {
+					prevOffset = 0
+				}
+				// Relevance cursors always walk forwards from an offset
+				prev = articleCursor{Sort: sortName, Dir: "next", Offset: prevOffset}
+			}
+			meta.PrevCursor = prev.encode()
+		}
+	}
+
+	return Result{Data: articles, Meta: meta}, nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/go-gorp/gorp"
+)
+
+// ArticleRevision is an immutable snapshot of an article, stored on every create, update and restore
+type ArticleRevision struct {
+	ID        int64    `db:"id, primarykey, autoincrement" json:"id"`
+	ArticleID int64    `db:"article_id" json:"article_id"`
+	Revision  int64    `db:"revision" json:"revision"`
+	Title     string   `db:"title" json:"title"`
+	Content   string   `db:"content" json:"content"`
+	AuthorID  int64    `db:"author_id" json:"-"`
+	Note      string   `db:"note" json:"note,omitempty"`
+	CreatedAt int64    `db:"created_at" json:"created_at"`
+	Author    *JSONRaw `db:"author" json:"author"`
+}
+
+// RevisionDiff is a line-level diff between two revisions
+type RevisionDiff struct {
+	ArticleID int64      `json:"article_id"`
+	From      int64      `json:"from"`
+	To        int64      `json:"to"`
+	Title     []DiffLine `json:"title"`
+	Content   []DiffLine `json:"content"`
+}
+
+// saveRevision appends a revision inside tx. The caller must have updated (and so locked)
+// the article row in the same transaction, which serializes the revision numbering.
+func saveRevision(tx *gorp.Transaction, articleID, authorID int64, title, content, note string) error {
+	_, err := tx.Exec(`INSERT INTO public.article_revisions (article_id, revision, title, content, author_id, note, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, NULLIF($5, ''), EXTRACT(EPOCH FROM NOW())
+		FROM public.article_revisions WHERE article_id = $1`,
+		articleID, title, content, authorID, note)
+	return err
+}
+
+// Revisions lists the revisions of an article editable by userID, newest first
+func (m ArticleModel) Revisions(userID, articleID int64) (revisions []ArticleRevision, err error) {
+	if err = m.CanEdit(userID, articleID); err != nil {
+		return nil, err
+	}
+	_, err = db.GetDB().Select(&revisions, `SELECT r.id, r.article_id, r.revision, r.title, r.content, COALESCE(r.note, '') AS note, r.created_at,
+			json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS author
+		FROM public.article_revisions r LEFT JOIN public.user u ON r.author_id = u.id
+		WHERE r.article_id = $1 ORDER BY r.revision DESC`, articleID)
+	return revisions, err
+}
+
+// Revision returns one revision of an article editable by userID
+func (m ArticleModel) Revision(userID, articleID, revision int64) (rev ArticleRevision, err error) {
+	if err = m.CanEdit(userID, articleID); err != nil {
+		return rev, err
+	}
+	err = db.GetDB().SelectOne(&rev, `SELECT r.id, r.article_id, r.revision, r.title, r.content, COALESCE(r.note, '') AS note, r.created_at,
+			json_build_object('id', u.id, 'name', u.name, 'email', u.email) AS author
+		FROM public.article_revisions r LEFT JOIN public.user u ON r.author_id = u.id
+		WHERE r.article_id = $1 AND r.revision = $2 LIMIT 1`, articleID, revision)
+	return rev, err
+}
+
+// Diff compares two revisions line by line
+func (m ArticleModel) Diff(userID, articleID, from, to int64) (diff RevisionDiff, err error) {
+	fromRev, err := m.Revision(userID, articleID, from)
+	if err != nil {
+		return diff, fmt.Errorf("revision %d not found", from)
+	}
+	toRev, err := m.Revision(userID, articleID, to)
+	if err != nil {
+		return diff, fmt.Errorf("revision %d not found", to)
+	}
+
+	return RevisionDiff{
+		ArticleID: articleID,
+		From:      from,
+		To:        to,
+		Title:     DiffLines(fromRev.Title, toRev.Title),
+		Content:   DiffLines(fromRev.Content, toRev.Content),
+	}, nil
+}
+
+// Restore copies an old revision back into the article, recorded as a new revision
+func (m ArticleModel) Restore(userID, articleID, revision int64) (err error) {
+	rev, err := m.Revision(userID, articleID, revision)
+	if err != nil {
+		return fmt.Errorf("revision %d not found", revision)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	contentHTML, err := RenderMarkdown(rev.Content)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("UPDATE public.article SET title=$2, content=$3, content_html=$4 WHERE id=$1", articleID, rev.Title, rev.Content, contentHTML); err != nil {
+		return err
+	}
+	if err = saveRevision(tx, articleID, userID, rev.Title, rev.Content, fmt.Sprintf("restored from revision %d", revision)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
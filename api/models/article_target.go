@@ -0,0 +1,255 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/go-gorp/gorp"
+)
+
+// Audience types, an article without audiences is addressed to all users
+const (
+	AudienceAll      = "all"
+	AudienceRole     = "role"
+	AudienceJenisOpd = "jenis_opd"
+	AudienceIdsatker = "idsatker"
+)
+
+// ArticleCategory ...
+type ArticleCategory struct {
+	ID        int64  `db:"id, primarykey, autoincrement" json:"id"`
+	Name      string `db:"name" json:"name"`
+	CreatedAt int64  `db:"created_at" json:"created_at"`
+}
+
+// ArticleAudience ...
+type ArticleAudience struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// ArticleRead is one read receipt
+type ArticleRead struct {
+	UserID   int64  `db:"user_id" json:"user_id"`
+	Name     string `db:"name" json:"name"`
+	Email    string `db:"email" json:"email"`
+	Idsatker int64  `db:"idsatker" json:"idsatker,omitempty"`
+	ReadAt   int64  `db:"read_at" json:"read_at"`
+}
+
+// OpdReadStatus summarizes the targeted users of one OPD
+type OpdReadStatus struct {
+	Idsatker      int64  `db:"idsatker" json:"idsatker"`
+	NamaOpd       string `db:"nama_opd" json:"nama_opd"`
+	JenisOpd      string `db:"jenis_opd" json:"jenis_opd"`
+	TargetedUsers int64  `db:"targeted_users" json:"targeted_users"`
+	ReadUsers     int64  `db:"read_users" json:"read_users"`
+}
+
+// ReadReport lists who read an article and which targeted OPDs have not
+type ReadReport struct {
+	ArticleID     int64           `json:"article_id"`
+	TargetedUsers int64           `json:"targeted_users"`
+	ReadUsers     int64           `json:"read_users"`
+	Reads         []ArticleRead   `json:"reads"`
+	Opds          []OpdReadStatus `json:"opds"`
+	UnreadOpds    []OpdReadStatus `json:"unread_opds"`
+}
+
+// ErrNotTargeted is returned when the article is not addressed to the user
+var ErrNotTargeted = errors.New("article is not addressed to this user")
+
+// audienceMatch is an SQL condition that is true when the article a is addressed to the
+// user whose id is the SQL expression userExpr (a placeholder or a column)
+func audienceMatch(userExpr string) string {
+	return `(NOT EXISTS (SELECT 1 FROM public.article_audiences x WHERE x.article_id = a.id)
+		OR EXISTS (SELECT 1 FROM public.article_audiences x, public."user" viewer
+			WHERE x.article_id = a.id AND viewer.id = ` + userExpr + ` AND (
+				x.type = '` + AudienceAll + `'
+				OR (x.type = '` + AudienceRole + `' AND x.value IN (SELECT LOWER(r.name) FROM public.user_roles ur JOIN public.roles r ON r.id = ur.role_id WHERE ur.user_id = viewer.id))
+				OR (x.type = '` + AudienceJenisOpd + `' AND x.value = viewer.jenis_opd)
+				OR (x.type = '` + AudienceIdsatker + `' AND x.value = viewer.idsatker::text))))`
+}
+
+// normalizeAudiences lowercases values and validates idsatker audiences
+func normalizeAudiences(audiences []forms.AudienceForm) ([]ArticleAudience, error) {
+	normalized := []ArticleAudience{}
+	for _, audience := range audiences {
+		value := strings.ToLower(strings.TrimSpace(audience.Value))
+		switch audience.Type {
+		case AudienceAll:
+			value = ""
+		case AudienceIdsatker:
+			if id, err := strconv.ParseInt(value, 10, 64); err != nil || id <= 0 {
+				return nil, errors.New("idsatker audience value should be a satker ID")
+			}
+		case AudienceJenisOpd:
+			if value != "skpd" && value != "kecamatan" {
+				return nil, errors.New("jenis_opd audience value should be skpd or kecamatan")
+			}
+		default:
+			if value == "" {
+				return nil, errors.New("role audience value is required")
+			}
+		}
+		normalized = append(normalized, ArticleAudience{Type: audience.Type, Value: value})
+	}
+	return normalized, nil
+}
+
+// normalizeTags trims, lowercases and de-duplicates tags
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// validateTargets checks the audiences before anything is written
+func validateTargets(form forms.CreateArticleForm) error {
+	_, err := normalizeAudiences(form.Audiences)
+	return err
+}
+
+// saveTargets replaces the category, tags and audiences of an article inside tx
+func saveTargets(tx *gorp.Transaction, articleID int64, form forms.CreateArticleForm) error {
+	audiences, err := normalizeAudiences(form.Audiences)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE public.article SET category_id=NULLIF($2, 0) WHERE id=$1", articleID, form.CategoryID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM public.article_tags WHERE article_id=$1", articleID); err != nil {
+		return err
+	}
+	for _, tag := range normalizeTags(form.Tags) {
+		if _, err := tx.Exec("INSERT INTO public.article_tags (article_id, tag) VALUES ($1, $2)", articleID, tag); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM public.article_audiences WHERE article_id=$1", articleID); err != nil {
+		return err
+	}
+	for _, audience := range audiences {
+		if _, err := tx.Exec("INSERT INTO public.article_audiences (article_id, type, value) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			articleID, audience.Type, audience.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CategoryModel ...
+type CategoryModel struct{}
+
+// All ...
+func (m CategoryModel) All() (categories []ArticleCategory, err error) {
+	_, err = db.GetDB().Select(&categories, "SELECT id, name, COALESCE(created_at, 0) AS created_at FROM public.article_categories ORDER BY name")
+	return categories, err
+}
+
+// Create ...
+func (m CategoryModel) Create(form forms.CategoryForm) (category ArticleCategory, err error) {
+	category.Name = strings.TrimSpace(form.Name)
+	err = db.GetDB().QueryRow("INSERT INTO public.article_categories (name, created_at) VALUES ($1, EXTRACT(EPOCH FROM NOW())) RETURNING id, created_at",
+		category.Name).Scan(&category.ID, &category.CreatedAt)
+	return category, err
+}
+
+// Delete removes a category, its articles become uncategorized
+func (m CategoryModel) Delete(id int64) error {
+	operation, err := db.GetDB().Exec("DELETE FROM public.article_categories WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return errors.New("no records were deleted")
+	}
+	return nil
+}
+
+// MarkRead stores a read receipt for a published article addressed to userID, reading twice keeps the first time
+func (m ArticleModel) MarkRead(userID, id int64) error {
+	var count int64
+	count, err := db.GetDB().SelectInt(`SELECT count(a.id) FROM public.article a
+		WHERE a.id = $2 AND a.status = '`+ArticleStatusPublished+`' AND `+audienceMatch("$1"), userID, id)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotTargeted
+	}
+	_, err = db.GetDB().Exec(`INSERT INTO public.article_reads (article_id, user_id, read_at)
+		VALUES ($1, $2, EXTRACT(EPOCH FROM NOW())) ON CONFLICT (article_id, user_id) DO NOTHING`, id, userID)
+	return err
+}
+
+// Reads reports the read receipts of an article against its audience, grouped per OPD.
+// Users without an idsatker are counted in the totals but not in any OPD.
+func (m ArticleModel) Reads(id int64) (report ReadReport, err error) {
+	report.ArticleID = id
+
+	var exists int64
+	if exists, err = db.GetDB().SelectInt("SELECT count(id) FROM public.article WHERE id=$1", id); err != nil {
+		return report, err
+	}
+	if exists == 0 {
+		return report, errors.New("article not found")
+	}
+
+	targeted := `SELECT u.id, u.idsatker, u.jenis_opd FROM public."user" u, public.article a
+		WHERE a.id = $1 AND u.id <> a.user_id AND ` + audienceMatch("u.id")
+
+	err = db.GetDB().QueryRow(`SELECT count(t.id), count(r.user_id) FROM (`+targeted+`) t
+		LEFT JOIN public.article_reads r ON r.article_id = $1 AND r.user_id = t.id`, id).Scan(&report.TargetedUsers, &report.ReadUsers)
+	if err != nil {
+		return report, err
+	}
+
+	_, err = db.GetDB().Select(&report.Reads, `SELECT r.user_id, COALESCE(u.name, '') AS name, COALESCE(u.email, '') AS email,
+			COALESCE(u.idsatker, 0) AS idsatker, r.read_at
+		FROM public.article_reads r JOIN public."user" u ON u.id = r.user_id
+		WHERE r.article_id = $1 ORDER BY r.read_at`, id)
+	if err != nil {
+		return report, err
+	}
+
+	_, err = db.GetDB().Select(&report.Opds, `SELECT t.idsatker, COALESCE(MAX(t.jenis_opd), '') AS jenis_opd,
+			COALESCE((SELECT d.nama_opd FROM public.de_ranking_opd d WHERE d.idsatker = t.idsatker ORDER BY d.tahun DESC, d.bulan DESC LIMIT 1), '') AS nama_opd,
+			count(t.id) AS targeted_users, count(r.user_id) AS read_users
+		FROM (`+targeted+`) t
+		LEFT JOIN public.article_reads r ON r.article_id = $1 AND r.user_id = t.id
+		WHERE t.idsatker IS NOT NULL
+		GROUP BY t.idsatker ORDER BY t.idsatker`, id)
+	if err != nil {
+		return report, err
+	}
+
+	report.UnreadOpds = []OpdReadStatus{}
+	for _, opd := range report.Opds {
+		if opd.ReadUsers == 0 {
+			report.UnreadOpds = append(report.UnreadOpds, opd)
+		}
+	}
+	if report.Reads == nil {
+		report.Reads = []ArticleRead{}
+	}
+	if report.Opds == nil {
+		report.Opds = []OpdReadStatus{}
+	}
+	return report, nil
+}
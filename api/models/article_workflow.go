@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+)
+
+// Article statuses. An approved article with a future publish_at waits as
+// "scheduled" until the scheduler publishes it.
+const (
+	ArticleStatusDraft     = "draft"
+	ArticleStatusInReview  = "in_review"
+	ArticleStatusScheduled = "scheduled"
+	ArticleStatusPublished = "published"
+	ArticleStatusArchived  = "archived"
+)
+
+// ErrInvalidTransition is returned when the article is not in a state that allows the action
+var ErrInvalidTransition = errors.New("article is not in a state that allows this action")
+
+// validateSchedule ...
+func validateSchedule(form forms.CreateArticleForm) error {
+	if form.PublishAt > 0 && form.ExpireAt > 0 && form.ExpireAt <= form.PublishAt {
+		return errors.New("expire_at must be after publish_at")
+	}
+	return nil
+}
+
+// transition runs a status UPDATE and maps "no rows" to ErrInvalidTransition
+func (m ArticleModel) transition(query string, args ...interface{}) error {
+	operation, err := db.GetDB().Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, _ := operation.RowsAffected()
+	if affected == 0 {
+		return ErrInvalidTransition
+	}
+	return nil
+}
+
+// Submit sends a draft editable by userID to review
+func (m ArticleModel) Submit(userID, id int64) error {
+	return m.transition(`UPDATE public.article a SET status=$3, review_note=NULL
+		WHERE a.id=$1 AND a.status=$4 AND `+articleEditable("$2"),
+		id, userID, ArticleStatusInReview, ArticleStatusDraft)
+}
+
+// Approve publishes an article in review, or schedules it when publish_at is in the future.
+// Owners and co-editors can not review their own article.
+func (m ArticleModel) Approve(reviewerID, id int64, note string) error {
+	now := time.Now().Unix()
+	err := m.transition(`UPDATE public.article a SET
+			status = CASE WHEN COALESCE(publish_at, 0) > $3 THEN $4 ELSE $5 END,
+			published_at = CASE WHEN COALESCE(publish_at, 0) > $3 THEN NULL ELSE $3 END,
+			reviewer_id=$2, reviewed_at=$3, review_note=NULLIF($6, '')
+		WHERE a.id=$1 AND a.status=$7 AND a.user_id <> $2 AND NOT `+isCoEditor("$2"),
+		id, reviewerID, now, ArticleStatusScheduled, ArticleStatusPublished, note, ArticleStatusInReview)
+	if err != nil {
+		return err
+	}
+
+	// Scheduled articles are announced by PublishDue once they go live
+	m.announce([]int64{id})
+	return nil
+}
+
+// Reject sends an article in review back to draft with the reviewer's note
+func (m ArticleModel) Reject(reviewerID, id int64, note string) error {
+	return m.transition(`UPDATE public.article a SET status=$3, reviewer_id=$2, reviewed_at=$4, review_note=NULLIF($5, '')
+		WHERE a.id=$1 AND a.status=$6 AND a.user_id <> $2 AND NOT `+isCoEditor("$2"),
+		id, reviewerID, ArticleStatusDraft, time.Now().Unix(), note, ArticleStatusInReview)
+}
+
+// Archive withdraws a scheduled or published article. ownerID 0 skips the editor check (reviewers).
+func (m ArticleModel) Archive(ownerID, id int64) error {
+	return m.transition(`UPDATE public.article a SET status=$3
+		WHERE a.id=$1 AND ($2 = 0 OR `+articleEditable("$2")+`) AND a.status IN ($4, $5)`,
+		id, ownerID, ArticleStatusArchived, ArticleStatusScheduled, ArticleStatusPublished)
+}
+
+// Feed lists published, unexpired articles addressed to userID, and the user's own
+func (m ArticleModel) Feed(userID int64, form forms.ArticleListForm) (results []Result, err error) {
+	form.Status = ""
+	page, err := m.list("a.status='"+ArticleStatusPublished+"' AND (a.expire_at IS NULL OR a.expire_at > EXTRACT(EPOCH FROM NOW()))"+
+		" AND (a.user_id = $1 OR "+audienceMatch("$1")+")", []interface{}{userID}, form)
+	if err != nil {
+		return nil, err
+	}
+	return []Result{page}, nil
+}
+
+// ReviewQueue lists the articles waiting for review
+func (m ArticleModel) ReviewQueue(form forms.ArticleListForm) (results []Result, err error) {
+	form.Status = ""
+	page, err := m.list("a.status='"+ArticleStatusInReview+"'", nil, form)
+	if err != nil {
+		return nil, err
+	}
+	return []Result{page}, nil
+}
+
+// PublishDue publishes scheduled articles whose publish_at has passed and archives expired ones
+func (m ArticleModel) PublishDue() (published, archived int64, err error) {
+	now := time.Now().Unix()
+
+	rows, err := db.GetDB().Query(`UPDATE public.article SET status=$1, published_at=$2
+		WHERE status=$3 AND publish_at <= $2 RETURNING id`, ArticleStatusPublished, now, ArticleStatusScheduled)
+	if err != nil {
+		return 0, 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	published = int64(len(ids))
+	m.announce(ids)
+
+	operation, err := db.GetDB().Exec(`UPDATE public.article SET status=$1
+		WHERE status=$2 AND expire_at IS NOT NULL AND expire_at <= $3`, ArticleStatusArchived, ArticleStatusPublished, now)
+	if err != nil {
+		return published, 0, err
+	}
+	archived, _ = operation.RowsAffected()
+
+	return published, archived, nil
+}
+
+// publishArticles is the article_publishing job
+func publishArticles(ctx context.Context, trigger string) (string, error) {
+	published, archived, err := ArticleModel{}.PublishDue()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Published %d, archived %d", published, archived), nil
+}
+
+// announce notifies the audience of newly published articles, the author excluded.
+// Failures are logged, publishing does not depend on them.
+func (m ArticleModel) announce(ids []int64) {
+	for _, id := range ids {
+		var article struct {
+			Title  string `db:"title"`
+			Status string `db:"status"`
+		}
+		if err := db.GetDB().SelectOne(&article, `SELECT title, status FROM public.article WHERE id=$1`, id); err != nil {
+			log.Printf("Article %d announcement error: %v", id, err)
+			continue
+		}
+		if article.Status != ArticleStatusPublished {
+			continue
+		}
+
+		rows, err := db.GetDB().Query(`SELECT u.id FROM public."user" u JOIN public.article a ON a.id = $1
+			WHERE u.id <> a.user_id AND `+audienceMatch("u.id"), id)
+		if err != nil {
+			log.Printf("Article %d announcement error: %v", id, err)
+			continue
+		}
+		var userIDs []int64
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err == nil {
+				userIDs = append(userIDs, userID)
+			}
+		}
+		rows.Close()
+
+		err = notificationModel.Send(userIDs, Notice{
+			Event: EventAnnouncement,
+			Title: article.Title,
+			Body:  "A new announcement was published: " + article.Title,
+			Data:  map[string]interface{}{"article_id": id},
+		})
+		if err != nil {
+			log.Printf("Article %d announcement error: %v", id, err)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -18,6 +22,12 @@ type AuthResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// CSRFResponse ...
+type CSRFResponse struct {
+	Message   string `json:"message"`
+	CSRFToken string `json:"csrf_token"`
+}
+
 // TokenDetails ...
 type TokenDetails struct {
 	AccessToken  string
@@ -32,6 +42,14 @@ type TokenDetails struct {
 type AccessDetails struct {
 	AccessUUID string
 	UserID     int64
+	// ActorID is the real user behind an impersonation token, 0 otherwise
+	ActorID  int64
+	ReadOnly bool
+}
+
+// IsImpersonated ...
+func (a AccessDetails) IsImpersonated() bool {
+	return a.ActorID > 0
 }
 
 // Token ...
@@ -79,6 +97,35 @@ func (m AuthModel) CreateToken(userID int64) (*TokenDetails, error) {
 	return td, nil
 }
 
+// CreateImpersonationToken issues a non-refreshable access token for subjectID on behalf of actorID.
+// Unlike CreateToken it keeps the exp claim, so the token can not outlive its ttl.
+func (m AuthModel) CreateImpersonationToken(actorID, subjectID int64, readOnly bool, ttl time.Duration) (*TokenDetails, error) {
+	td := &TokenDetails{}
+	td.AtExpires = time.Now().Add(ttl).Unix()
+	td.AccessUUID = uuid.New().String()
+
+	atClaims := jwt.MapClaims{}
+	atClaims["authorized"] = true
+	atClaims["access_uuid"] = td.AccessUUID
+	atClaims["user_id"] = subjectID
+	atClaims["actor_id"] = actorID
+	atClaims["read_only"] = readOnly
+	atClaims["exp"] = td.AtExpires
+
+	var err error
+	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
+	td.AccessToken, err = at.SignedString([]byte(os.Getenv("ACCESS_SECRET")))
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.GetRedis().Set(td.AccessUUID, strconv.Itoa(int(subjectID)), time.Until(time.Unix(td.AtExpires, 0))).Err()
+	if err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
 // CreateAuth ...
 func (m AuthModel) CreateAuth(userid int64, td *TokenDetails) error {
 	at := time.Unix(td.AtExpires, 0) //converting Unix to UTC(to Time object)
@@ -96,7 +143,16 @@ func (m AuthModel) CreateAuth(userid int64, td *TokenDetails) error {
 	return nil
 }
 
-// ExtractToken ...
+// AccessTokenCookie and RefreshTokenCookie name the httpOnly cookies used in cookie session mode
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFTokenCookie    = "csrf_token"
+	CSRFTokenHeader    = "X-CSRF-Token"
+)
+
+// ExtractToken reads the access token from the Authorization header,
+// falling back to the access_token cookie for cookie-based sessions
 func (m AuthModel) ExtractToken(r *http.Request) string {
 	bearToken := r.Header.Get("Authorization")
 	//normally Authorization the_token_xxx
@@ -104,9 +160,91 @@ func (m AuthModel) ExtractToken(r *http.Request) string {
 	if len(strArr) == 2 {
 		return strArr[1]
 	}
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil {
+		return cookie.Value
+	}
 	return ""
 }
 
+// UsesCookie reports whether the request authenticates with the access_token cookie instead of a header
+func (m AuthModel) UsesCookie(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	cookie, err := r.Cookie(AccessTokenCookie)
+	return err == nil && cookie.Value != ""
+}
+
+// ValidCSRF checks the double-submit token: the X-CSRF-Token header must match the csrf_token cookie
+func (m AuthModel) ValidCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(CSRFTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
+
+// CreateCSRFToken ...
+func (m AuthModel) CreateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ParseRefreshToken verifies a refresh token and returns its uuid and user id
+func (m AuthModel) ParseRefreshToken(refreshToken string) (refreshUUID string, userID int64, err error) {
+	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
+		//Make sure that the token method conform to "SigningMethodHMAC"
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("REFRESH_SECRET")), nil
+	})
+	//if there is an error, the token must have expired
+	if err != nil {
+		return "", 0, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims) //the token claims should conform to MapClaims
+	if !ok || !token.Valid {
+		return "", 0, errors.New("invalid refresh token")
+	}
+	refreshUUID, ok = claims["refresh_uuid"].(string) //convert the interface to string
+	if !ok {
+		return "", 0, errors.New("invalid refresh token")
+	}
+	userID, err = strconv.ParseInt(fmt.Sprintf("%.f", claims["user_id"]), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return refreshUUID, userID, nil
+}
+
+// RotateRefreshToken invalidates the given refresh token and issues a new token pair
+func (m AuthModel) RotateRefreshToken(refreshToken string) (*TokenDetails, error) {
+	refreshUUID, userID, err := m.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	//Delete the previous Refresh Token
+	deleted, delErr := m.DeleteAuth(refreshUUID)
+	if delErr != nil || deleted == 0 { //if any goes wrong
+		return nil, errors.New("refresh token already used or expired")
+	}
+	//Create new pairs of refresh and access tokens
+	ts, err := m.CreateToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	//save the tokens metadata to redis
+	if err := m.CreateAuth(userID, ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
 // VerifyToken ...
 func (m AuthModel) VerifyToken(r *http.Request) (*jwt.Token, error) {
 	tokenString := m.ExtractToken(r)
@@ -152,10 +290,20 @@ func (m AuthModel) ExtractTokenMetadata(r *http.Request) (*AccessDetails, error)
 		if err != nil {
 			return nil, err
 		}
-		return &AccessDetails{
+		details := &AccessDetails{
 			AccessUUID: accessUUID,
 			UserID:     userID,
-		}, nil
+		}
+		//Impersonation tokens carry the real actor next to the subject
+		if actor, ok := claims["actor_id"]; ok {
+			actorID, err := strconv.ParseInt(fmt.Sprintf("%.f", actor), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			details.ActorID = actorID
+			details.ReadOnly, _ = claims["read_only"].(bool)
+		}
+		return details, nil
 	}
 	return nil, err
 }
@@ -179,6 +327,38 @@ func (m AuthModel) DeleteAuth(givenUUID string) (int64, error) {
 	return deleted, nil
 }
 
+// sessionKeyPattern matches the access and refresh token keys, see CreateAuth
+const sessionKeyPattern = "????????-????-????-????-????????????"
+
+// RevokeUserSessions deletes every access and refresh token key of the user, impersonation tokens
+// for the user included. Keys only hold the user id, so the session keys are scanned.
+func (m AuthModel) RevokeUserSessions(userID int64) (revoked int, err error) {
+	client := db.GetRedis()
+	owner := strconv.FormatInt(userID, 10)
+
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, sessionKeyPattern, 500).Result()
+		if err != nil {
+			return revoked, err
+		}
+		for _, key := range keys {
+			value, err := client.Get(key).Result()
+			if err != nil || value != owner {
+				continue // expired meanwhile or another user
+			}
+			deleted, err := client.Del(key).Result()
+			if err != nil {
+				return revoked, err
+			}
+			revoked += int(deleted)
+		}
+		if cursor = next; cursor == 0 {
+			return revoked, nil
+		}
+	}
+}
+
 // RefreshAuth ...
 func (m AuthModel) RefreshAuth(accessUUID string) error {
 	// Extend the expiration by 30 minutes from now
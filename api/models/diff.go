@@ -0,0 +1,124 @@
+package models
+
+import "strings"
+
+// DiffLine is one line of a line-level diff
+type DiffLine struct {
+	Op      string `json:"op"` // "equal" | "insert" | "delete"
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+// DiffLines computes a line-level diff from a to b with the fewest inserted and deleted lines.
+// It uses Myers' linear-space algorithm, memory grows with the number of lines, not their product.
+func DiffLines(a, b string) []DiffLine {
+	d := lineDiff{old: splitLines(a), new: splitLines(b), diff: []DiffLine{}}
+	d.compare(0, len(d.old), 0, len(d.new))
+	return d.diff
+}
+
+// lineDiff accumulates the diff of old[oldLo:oldHi] and new[newLo:newHi] in order
+type lineDiff struct {
+	old, new []string
+	diff     []DiffLine
+}
+
+// compare strips the common prefix and suffix, then splits the rest around the middle snake
+func (d *lineDiff) compare(oldLo, oldHi, newLo, newHi int) {
+	for oldLo < oldHi && newLo < newHi && d.old[oldLo] == d.new[newLo] {
+		d.equal(oldLo, newLo)
+		oldLo++
+		newLo++
+	}
+	suffix := 0
+	for oldLo < oldHi-suffix && newLo < newHi-suffix && d.old[oldHi-suffix-1] == d.new[newHi-suffix-1] {
+		suffix++
+	}
+	oldHi -= suffix
+	newHi -= suffix
+
+	switch {
+	case oldLo == oldHi:
+		for j := newLo; j < newHi; j++ {
+			d.diff = append(d.diff, DiffLine{Op: "insert", NewLine: j + 1, Text: d.new[j]})
+		}
+	case newLo == newHi:
+		for i := oldLo; i < oldHi; i++ {
+			d.diff = append(d.diff, DiffLine{Op: "delete", OldLine: i + 1, Text: d.old[i]})
+		}
+	default:
+		x, y, u, v := d.middleSnake(oldLo, oldHi, newLo, newHi)
+		d.compare(oldLo, x, newLo, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.equal(x, y)
+		}
+		d.compare(u, oldHi, v, newHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.equal(oldHi+i, newHi+i)
+	}
+}
+
+// equal ...
+func (d *lineDiff) equal(i, j int) {
+	d.diff = append(d.diff, DiffLine{Op: "equal", OldLine: i + 1, NewLine: j + 1, Text: d.old[i]})
+}
+
+// middleSnake runs the forward and backward searches of Myers' algorithm until they overlap and
+// returns the snake where they meet, from (x, y) to (u, v) in old and new line indexes
+func (d *lineDiff) middleSnake(oldLo, oldHi, newLo, newHi int) (x, y, u, v int) {
+	n, m := oldHi-oldLo, newHi-newLo
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+	offset := max + 1
+	// forward[k] and backward[k] are the furthest x on diagonal k = x - y, the backward search
+	// runs on the reversed sequences
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+
+	furthest := func(v []int, k, depth int) int {
+		if k == -depth || (k != depth && v[offset+k-1] < v[offset+k+1]) {
+			return v[offset+k+1]
+		}
+		return v[offset+k-1] + 1
+	}
+
+	for depth := 0; depth <= max; depth++ {
+		for k := -depth; k <= depth; k += 2 {
+			x0 := furthest(forward, k, depth)
+			x := x0
+			for x < n && x-k < m && d.old[oldLo+x] == d.new[newLo+x-k] {
+				x++
+			}
+			forward[offset+k] = x
+			if odd && k >= delta-(depth-1) && k <= delta+(depth-1) && x+backward[offset+delta-k] >= n {
+				return oldLo + x0, newLo + x0 - k, oldLo + x, newLo + x - k
+			}
+		}
+		for k := -depth; k <= depth; k += 2 {
+			x0 := furthest(backward, k, depth)
+			x := x0
+			for x < n && x-k < m && d.old[oldHi-x-1] == d.new[newHi-x+k-1] {
+				x++
+			}
+			backward[offset+k] = x
+			if !odd && k >= delta-depth && k <= delta+depth && x+forward[offset+delta-k] >= n {
+				return oldHi - x, newHi - x + k, oldHi - x0, newHi - x0 + k
+			}
+		}
+	}
+	// Unreachable, the searches meet within max steps
+	return oldLo, newLo, oldLo, newLo
+}
+
+// splitLines splits on \n, normalizing \r\n, an empty string has no lines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+)
+
+// ImpersonationResponse ...
+type ImpersonationResponse struct {
+	Message     string `json:"message"`
+	AccessToken string `json:"access_token"`
+	ExpiresAt   int64  `json:"expires_at"`
+	ReadOnly    bool   `json:"read_only"`
+	Subject     User   `json:"subject"`
+}
+
+// ImpersonationLog is one audited event of an impersonation session
+type ImpersonationLog struct {
+	ID         int64  `db:"id, primarykey, autoincrement" json:"id"`
+	ActorID    int64  `db:"actor_id" json:"actor_id"`
+	SubjectID  int64  `db:"subject_id" json:"subject_id"`
+	AccessUUID string `db:"access_uuid" json:"access_uuid"`
+	Event      string `db:"event" json:"event"` // "start" | "request" | "blocked"
+	Method     string `db:"method" json:"method"`
+	Path       string `db:"path" json:"path"`
+	Status     int    `db:"status" json:"status"`
+	RequestID  string `db:"request_id" json:"request_id"`
+	ClientIP   string `db:"client_ip" json:"client_ip"`
+	CreatedAt  int64  `db:"created_at" json:"created_at"`
+}
+
+func (l ImpersonationLog) TableName() string {
+	return "impersonation_logs"
+}
+
+// ImpersonationModel ...
+type ImpersonationModel struct{}
+
+var userModel = new(UserModel)
+
+// impersonationTTL reads IMPERSONATION_TTL_MINUTES, defaults to 15 minutes
+func impersonationTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("IMPERSONATION_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Start issues an impersonation token for subjectID and records the start event
+func (m ImpersonationModel) Start(actorID, subjectID int64, readOnly bool, requestID, clientIP string) (resp ImpersonationResponse, err error) {
+	if actorID == subjectID {
+		return resp, errors.New("you can not impersonate yourself")
+	}
+
+	subject, err := userModel.One(subjectID)
+	if err != nil {
+		return resp, errors.New("user not found")
+	}
+
+	roles, err := userModel.GetUserRoles(subjectID)
+	if err != nil {
+		return resp, errors.New("something went wrong, please try again later")
+	}
+	for _, role := range roles {
+		if role.Name == "admin" {
+			return resp, errors.New("admin accounts can not be impersonated")
+		}
+	}
+
+	td, err := authModel.CreateImpersonationToken(actorID, subjectID, readOnly, impersonationTTL())
+	if err != nil {
+		return resp, errors.New("something went wrong, please try again later")
+	}
+
+	m.Log(ImpersonationLog{
+		ActorID:    actorID,
+		SubjectID:  subjectID,
+		AccessUUID: td.AccessUUID,
+		Event:      "start",
+		RequestID:  requestID,
+		ClientIP:   clientIP,
+	})
+
+	resp = ImpersonationResponse{
+		Message:     "Impersonation started",
+		AccessToken: td.AccessToken,
+		ExpiresAt:   td.AtExpires,
+		ReadOnly:    readOnly,
+		Subject:     subject,
+	}
+	return resp, nil
+}
+
+// Log stores an audit event and mirrors it to the application log,
+// so impersonated requests are attributable even if the insert fails
+func (m ImpersonationModel) Log(entry ImpersonationLog) error {
+	log.Printf("[impersonation] event=%s actor=%d subject=%d method=%s path=%s status=%d request_id=%s ip=%s",
+		entry.Event, entry.ActorID, entry.SubjectID, entry.Method, entry.Path, entry.Status, entry.RequestID, entry.ClientIP)
+
+	_, err := db.GetDB().Exec(`INSERT INTO public.impersonation_logs (actor_id, subject_id, access_uuid, event, method, path, status, request_id, client_ip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		entry.ActorID, entry.SubjectID, entry.AccessUUID, entry.Event, entry.Method, entry.Path, entry.Status, entry.RequestID, entry.ClientIP, time.Now().Unix())
+	if err != nil {
+		log.Printf("[impersonation] failed to store audit log: %v", err)
+	}
+	return err
+}
+
+// All returns the audit trail, newest first, optionally filtered by actor or subject
+func (m ImpersonationModel) All(actorID, subjectID int64, limit int) (logs []ImpersonationLog, err error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	_, err = db.GetDB().Select(&logs, `SELECT id, actor_id, subject_id, access_uuid, event, method, path, status, request_id, client_ip, created_at
+		FROM public.impersonation_logs
+		WHERE ($1 = 0 OR actor_id = $1) AND ($2 = 0 OR subject_id = $2)
+		ORDER BY id DESC LIMIT $3`, actorID, subjectID, limit)
+	return logs, err
+}
@@ -0,0 +1,744 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/importer"
+
+	"github.com/go-gorp/gorp"
+)
+
+// Import run states
+const (
+	ImportStatusSuccess = "success"
+	ImportStatusFailed  = "failed"
+	ImportStatusBlocked = "blocked"
+)
+
+// ErrUnknownFeed is returned when a requested feed is not in importFeeds
+var ErrUnknownFeed = errors.New("unknown import feed")
+
+// importFeed describes how the records of an upstream feed load into their table
+type importFeed struct {
+	table    string
+	keys     []string          // columns the upstream records are matched on
+	required []string          // columns a record cannot be loaded without
+	aliases  map[string]string // upstream field name -> column
+	// optionalKey is a key column that may be NULL, matched as 0. It can be filled later: a record
+	// carrying it takes over the stored row without it, as when a package's contract is awarded.
+	optionalKey string
+	conflict    string // unique index backing the keys with its predicate, for ON CONFLICT
+	row         func() interface{}
+	// resolve fills the columns that have to be looked up, after mapping
+	resolve func(tx *gorp.Transaction, record importer.Record, row interface{}) error
+}
+
+// importFeeds ...
+var importFeeds = map[string]importFeed{
+	importer.FeedStatusPaket: {
+		table:    "de_status_paket",
+		keys:     []string{"id_rup"},
+		required: []string{"id_rup", "idsatker", "tahun", "bulan"},
+		aliases:  map[string]string{"kd_rup": "id_rup", "kd_satker": "idsatker", "is_deleted": "is_removed"},
+		row:      func() interface{} { return &DeStatusPaket{} },
+	},
+	importer.FeedPetaDetail: {
+		table:    "de_peta_detail",
+		keys:     []string{"tahun", "id_rup", "id_kontrak"},
+		required: []string{"id_rup", "idsatker", "tahun"},
+		aliases: map[string]string{"kd_rup": "id_rup", "kd_satker": "idsatker", "kd_kontrak": "id_kontrak",
+			"nama_satker": "nama_opd", "progress": "progres", "is_deleted": "is_removed"},
+		optionalKey: "id_kontrak",
+		conflict:    "(tahun, id_rup, COALESCE(id_kontrak, 0)) WHERE id_rup IS NOT NULL AND tahun IS NOT NULL",
+		row:         func() interface{} { return &DePetaDetail{} },
+	},
+	importer.FeedDetailBarjas: {
+		table:   "de_detail_barjas",
+		keys:    []string{"id_ranking_opd"},
+		aliases: map[string]string{"kd_satker": "idsatker"},
+		row:     func() interface{} { return &DeDetailBarjas{} },
+		resolve: resolveRankingOpd,
+	},
+}
+
+// ImportOptions ...
+type ImportOptions struct {
+	Feeds  []string // empty for every feed
+	DryRun bool     // compare and report, nothing is written
+	Full   bool     // ignore the cursors and compare every record of the feeds
+	Limit  int      // changes and issues listed per feed, 0 for all
+	RunBy  int64
+}
+
+// ImportFieldChange is one column that differs from the stored row
+type ImportFieldChange struct {
+	Field    string      `json:"field"`
+	Previous interface{} `json:"previous"`
+	Current  interface{} `json:"current"`
+}
+
+// ImportChange is a record that inserts or updates a row
+type ImportChange struct {
+	Key    map[string]interface{} `json:"key"`
+	Action string                 `json:"action"` // "insert" or "update"
+	Fields []ImportFieldChange    `json:"fields,omitempty"`
+}
+
+// ImportIssue is a record that could not be loaded
+type ImportIssue struct {
+	Record  int    `json:"record"` // position in the feed, from 0
+	Message string `json:"message"`
+}
+
+// ImportFeedReport is the diff report of one feed
+type ImportFeedReport struct {
+	Feed      string         `json:"feed"`
+	Since     int64          `json:"since"`  // cursor the run started from, epoch seconds, 0 for a full sync
+	Cursor    int64          `json:"cursor"` // latest modification time loaded, below the earliest skipped record
+	Fetched   int            `json:"fetched"`
+	Ignored   int            `json:"ignored"` // not modified since the cursor
+	Inserted  int            `json:"inserted"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Skipped   int            `json:"skipped"`
+	Tahun     []int          `json:"tahun,omitempty"` // years of the inserted and updated records
+	Changes   []ImportChange `json:"changes"`
+	Issues    []ImportIssue  `json:"issues"`
+}
+
+// ImportRun is one run of the importer
+type ImportRun struct {
+	ID         int64  `db:"id" json:"id"`
+	Source     string `db:"source" json:"source"`
+	DryRun     bool   `db:"dry_run" json:"dry_run"`
+	Full       bool   `db:"full_sync" json:"full"`
+	Status     string `db:"status" json:"status"`
+	Message    string `db:"message" json:"message,omitempty"`
+	Fetched    int    `db:"fetched" json:"fetched"`
+	Inserted   int    `db:"inserted" json:"inserted"`
+	Updated    int    `db:"updated" json:"updated"`
+	Skipped    int    `db:"skipped" json:"skipped"`
+	RunBy      *int64 `db:"run_by" json:"run_by,omitempty"`
+	StartedAt  int64  `db:"started_at" json:"started_at"`
+	FinishedAt int64  `db:"finished_at" json:"finished_at"`
+}
+
+// ImportRunDetail is a run with the report of every feed
+type ImportRunDetail struct {
+	ImportRun
+	Feeds []ImportFeedReport `json:"feeds"`
+}
+
+// ImportCursor is the latest modification time loaded from a feed
+type ImportCursor struct {
+	Feed         string `db:"feed" json:"feed"`
+	LastModified int64  `db:"last_modified" json:"last_modified"`
+	UpdatedAt    int64  `db:"updated_at" json:"updated_at"`
+}
+
+// ImportRunPage ...
+type ImportRunPage struct {
+	Data []ImportRun `json:"data"`
+	Meta Meta        `json:"meta"`
+}
+
+// ImportModel ...
+type ImportModel struct{}
+
+// importColumn is a db-tagged field of a row
+type importColumn struct {
+	name  string
+	index int
+	auto  bool
+}
+
+// importColumns lists the columns of a row type, auto-incremented ids are flagged
+func importColumns(row interface{}) []importColumn {
+	t := reflect.TypeOf(row).Elem()
+	columns := make([]importColumn, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("db")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := strings.TrimSpace(strings.Split(tag, ",")[0])
+		columns = append(columns, importColumn{name: name, index: i, auto: strings.Contains(tag, "autoincrement")})
+	}
+	return columns
+}
+
+// normalizeRecord lower-cases the field names and renames the feed's aliases
+func normalizeRecord(feed importFeed, record importer.Record) importer.Record {
+	normalized := importer.Record{}
+	for key, value := range record {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if column, ok := feed.aliases[key]; ok {
+			key = column
+		}
+		normalized[key] = strings.TrimSpace(value)
+	}
+	return normalized
+}
+
+// setColumn parses a text value into the field
+func setColumn(field reflect.Value, name, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		// Flags such as is_removed come as JSON booleans
+		switch value {
+		case "true":
+			value = "1"
+		case "false":
+			value = "0"
+		}
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			// Some feeds send whole numbers as "12.0"
+			float, floatErr := strconv.ParseFloat(value, 64)
+			if floatErr != nil || float != float64(int64(float)) {
+				return fmt.Errorf("invalid %s %q", name, value)
+			}
+			number = int64(float)
+		}
+		field.SetInt(number)
+	case reflect.Float64:
+		float, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+		field.SetFloat(float)
+	}
+	return nil
+}
+
+// MapImportRecord maps an upstream record to a new row of the feed's table. Fields are matched on
+// the column names and the feed aliases, case-insensitively. last_update is the record's
+// modification time. Columns resolved from other tables, such as id_ranking_opd, are left zero
+// unless the record carries them.
+func MapImportRecord(feedName string, record importer.Record) (interface{}, error) {
+	feed, ok := importFeeds[feedName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFeed, feedName)
+	}
+	return mapImportRecord(feed, normalizeRecord(feed, record))
+}
+
+// mapImportRecord ...
+func mapImportRecord(feed importFeed, record importer.Record) (interface{}, error) {
+	for _, column := range feed.required {
+		if record[column] == "" {
+			return nil, fmt.Errorf("missing %s", column)
+		}
+	}
+
+	row := feed.row()
+	value := reflect.ValueOf(row).Elem()
+	for _, column := range importColumns(row) {
+		if column.auto || record[column.name] == "" {
+			continue
+		}
+		if err := setColumn(value.Field(column.index), column.name, record[column.name]); err != nil {
+			return nil, err
+		}
+	}
+
+	modified, ok, err := record.Modified()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		value.FieldByName("LastUpdate").SetInt(modified.Unix())
+	}
+	return row, nil
+}
+
+// ImportFieldChanges lists the columns of current that differ from previous, both pointers to rows
+// of the same table. Only the named columns are compared, every column when names is empty.
+// last_update and auto-incremented ids are not compared.
+func ImportFieldChanges(previous, current interface{}, names ...string) []ImportFieldChange {
+	before := reflect.ValueOf(previous).Elem()
+	after := reflect.ValueOf(current).Elem()
+
+	compared := map[string]bool{}
+	for _, name := range names {
+		compared[name] = true
+	}
+
+	changes := []ImportFieldChange{}
+	for _, column := range importColumns(current) {
+		if column.auto || column.name == "last_update" || (len(names) > 0 && !compared[column.name]) {
+			continue
+		}
+		previousValue, currentValue := before.Field(column.index).Interface(), after.Field(column.index).Interface()
+		if previousValue != currentValue {
+			changes = append(changes, ImportFieldChange{Field: column.name, Previous: previousValue, Current: currentValue})
+		}
+	}
+	return changes
+}
+
+// resolveRankingOpd links a barjas record to the de_ranking_opd row of its satker and month
+func resolveRankingOpd(tx *gorp.Transaction, record importer.Record, row interface{}) error {
+	barjas := row.(*DeDetailBarjas)
+	if barjas.IdRankingOpd != 0 {
+		return nil
+	}
+	for _, column := range []string{"idsatker", "tahun", "bulan"} {
+		if record[column] == "" {
+			return fmt.Errorf("missing %s", column)
+		}
+	}
+
+	id, err := tx.SelectNullInt(`SELECT id FROM de_ranking_opd WHERE idsatker=$1 AND tahun=$2 AND bulan=$3 ORDER BY id DESC LIMIT 1`,
+		record["idsatker"], record["tahun"], record["bulan"])
+	if err != nil {
+		return err
+	}
+	if !id.Valid {
+		return fmt.Errorf("no de_ranking_opd row for idsatker %s in %s-%s", record["idsatker"], record["tahun"], record["bulan"])
+	}
+	barjas.IdRankingOpd = id.Int64
+	return nil
+}
+
+// importedColumns are the columns the record carries: its fields, the keys and last_update when the
+// record has a modification time. Other columns are left as stored when a row is updated.
+func importedColumns(feed importFeed, record importer.Record) map[string]bool {
+	imported := map[string]bool{}
+	for field := range record {
+		imported[field] = true
+	}
+	for _, key := range feed.keys {
+		imported[key] = true
+	}
+	if _, ok, _ := record.Modified(); ok {
+		imported["last_update"] = true
+	}
+	return imported
+}
+
+// upsertImportRow inserts the row or updates the stored row with the same key. An update only sets
+// and compares the columns the record carries, the others such as koordinat may be maintained
+// locally. The change has no action when the stored row is identical.
+func upsertImportRow(tx *gorp.Transaction, feed importFeed, record importer.Record, row interface{}) (change ImportChange, err error) {
+	columns := importColumns(row)
+	value := reflect.ValueOf(row).Elem()
+	imported := importedColumns(feed, record)
+
+	var selects, names, placeholders, sets, excluded, compared []string
+	var args, setArgs []interface{}
+	byName := map[string]importColumn{}
+	for _, column := range columns {
+		byName[column.name] = column
+		if column.auto {
+			continue
+		}
+		args = append(args, value.Field(column.index).Interface())
+		names = append(names, column.name)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		if !imported[column.name] {
+			continue
+		}
+		zero := "0"
+		if value.Field(column.index).Kind() == reflect.String {
+			zero = "''"
+		}
+		selects = append(selects, fmt.Sprintf("COALESCE(%s, %s) AS %s", column.name, zero, column.name))
+		setArgs = append(setArgs, value.Field(column.index).Interface())
+		sets = append(sets, fmt.Sprintf("%s=$%d", column.name, len(setArgs)))
+		excluded = append(excluded, fmt.Sprintf("%s=EXCLUDED.%s", column.name, column.name))
+		compared = append(compared, column.name)
+	}
+
+	change.Key = map[string]interface{}{}
+	var keyArgs []interface{}
+	for _, key := range feed.keys {
+		keyValue := value.Field(byName[key].index).Interface()
+		change.Key[key] = keyValue
+		keyArgs = append(keyArgs, keyValue)
+	}
+
+	existing := feed.row()
+	query := `SELECT ` + strings.Join(selects, ", ") + ` FROM ` + feed.table + ` WHERE ` + importKeyWhere(feed, 1) + ` LIMIT 1`
+	err = tx.SelectOne(existing, query, keyArgs...)
+	if errors.Is(err, sql.ErrNoRows) && feed.optionalKey != "" && value.Field(byName[feed.optionalKey].index).Int() != 0 {
+		// The row may have been loaded before the optional key was known
+		for i, key := range feed.keys {
+			if key == feed.optionalKey {
+				keyArgs[i] = int64(0)
+			}
+		}
+		err = tx.SelectOne(existing, query, keyArgs...)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		change.Action = "insert"
+		insert := `INSERT INTO ` + feed.table + ` (` + strings.Join(names, ", ") + `) VALUES (` + strings.Join(placeholders, ", ") + `)`
+		if feed.conflict != "" {
+			insert += ` ON CONFLICT ` + feed.conflict + ` DO UPDATE SET ` + strings.Join(excluded, ", ")
+		}
+		_, err = tx.Exec(insert, args...)
+		return change, err
+	}
+	if err != nil {
+		return change, err
+	}
+
+	change.Fields = ImportFieldChanges(existing, row, compared...)
+	if len(change.Fields) == 0 {
+		return change, nil
+	}
+	change.Action = "update"
+
+	// Key placeholders follow the column values
+	_, err = tx.Exec(`UPDATE `+feed.table+` SET `+strings.Join(sets, ", ")+` WHERE `+importKeyWhere(feed, len(setArgs)+1), append(setArgs, keyArgs...)...)
+	return change, err
+}
+
+// importKeyWhere matches the feed's keys on the placeholders from $first, the optional key as 0 when NULL
+func importKeyWhere(feed importFeed, first int) string {
+	where := make([]string, len(feed.keys))
+	for i, key := range feed.keys {
+		column := key
+		if key == feed.optionalKey {
+			column = "COALESCE(" + key + ", 0)"
+		}
+		where[i] = fmt.Sprintf("%s=$%d", column, first+i)
+	}
+	return strings.Join(where, " AND ")
+}
+
+// importFetch is the records of a feed fetched ahead of the import transaction
+type importFetch struct {
+	name    string
+	since   int64
+	records []importer.Record
+}
+
+// importCursor returns the cursor of the feed, 0 when it was never synced
+func importCursor(executor gorp.SqlExecutor, name string) (int64, error) {
+	cursor, err := executor.SelectNullInt(`SELECT last_modified FROM public.import_cursors WHERE feed=$1`, name)
+	return cursor.Int64, err
+}
+
+// fetchFeed fetches the records of one feed modified since its cursor. It runs before the import
+// transaction, so a slow source does not hold the import lock.
+func fetchFeed(ctx context.Context, source importer.Source, name string, options ImportOptions) (fetch importFetch, err error) {
+	fetch.name = name
+	var since time.Time
+	if !options.Full {
+		if fetch.since, err = importCursor(db.GetDB(), name); err != nil {
+			return fetch, err
+		}
+		if fetch.since != 0 {
+			since = time.Unix(fetch.since, 0)
+		}
+	}
+	fetch.records, err = source.Fetch(ctx, name, since)
+	return fetch, err
+}
+
+// syncFeed loads the fetched records of one feed. The cursor is read again under the import lock,
+// records another run loaded meanwhile are ignored.
+func syncFeed(tx *gorp.Transaction, fetch importFetch, options ImportOptions) (report ImportFeedReport, err error) {
+	name := fetch.name
+	feed := importFeeds[name]
+	report = ImportFeedReport{Feed: name, Changes: []ImportChange{}, Issues: []ImportIssue{}}
+
+	var since time.Time
+	if !options.Full {
+		if report.Since, err = importCursor(tx, name); err != nil {
+			return report, err
+		}
+		if report.Since != 0 {
+			since = time.Unix(report.Since, 0)
+		}
+	}
+	report.Cursor = report.Since
+
+	records := fetch.records
+	report.Fetched = len(records)
+
+	years := map[int]bool{}
+
+	// The cursor stays below the earliest skipped record, so the next run fetches it again
+	var heldAt int64
+	skip := func(i int, modified time.Time, err error) {
+		if !modified.IsZero() && (heldAt == 0 || modified.Unix() < heldAt) {
+			heldAt = modified.Unix()
+		}
+		report.Skipped++
+		if options.Limit == 0 || len(report.Issues) < options.Limit {
+			report.Issues = append(report.Issues, ImportIssue{Record: i, Message: err.Error()})
+		}
+	}
+
+	for i, record := range records {
+		record = normalizeRecord(feed, record)
+		modified, hasModified, err := record.Modified()
+		if err != nil {
+			skip(i, time.Time{}, err)
+			continue
+		}
+		// Sources may ignore updated_since, records without a modification time are always compared
+		if hasModified && !since.IsZero() && !modified.After(since) {
+			report.Ignored++
+			continue
+		}
+
+		row, err := mapImportRecord(feed, record)
+		if err == nil && feed.resolve != nil {
+			err = feed.resolve(tx, record, row)
+		}
+		if err != nil {
+			skip(i, modified, err)
+			continue
+		}
+
+		change, err := upsertImportRow(tx, feed, record, row)
+		if err != nil {
+			return report, fmt.Errorf("record %d: %v", i, err)
+		}
+		switch change.Action {
+		case "insert":
+			report.Inserted++
+		case "update":
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+		if change.Action != "" && (options.Limit == 0 || len(report.Changes) < options.Limit) {
+			report.Changes = append(report.Changes, change)
+		}
+		if tahun := importTahun(record, row); change.Action != "" && tahun != 0 {
+			years[tahun] = true
+		}
+		if hasModified && modified.Unix() > report.Cursor {
+			report.Cursor = modified.Unix()
+		}
+	}
+	for tahun := range years {
+		report.Tahun = append(report.Tahun, tahun)
+	}
+	sort.Ints(report.Tahun)
+	if heldAt != 0 && report.Cursor >= heldAt {
+		report.Cursor = heldAt - 1
+	}
+
+	if report.Cursor > report.Since {
+		_, err = tx.Exec(`INSERT INTO public.import_cursors (feed, last_modified, updated_at) VALUES ($1, $2, $3)
+			ON CONFLICT (feed) DO UPDATE SET last_modified = EXCLUDED.last_modified, updated_at = EXCLUDED.updated_at`,
+			name, report.Cursor, time.Now().Unix())
+	}
+	return report, err
+}
+
+// importTahun is the year of a loaded record, from the row or, for tables without tahun such as
+// de_detail_barjas, from the record. 0 when neither has one.
+func importTahun(record importer.Record, row interface{}) int {
+	if field := reflect.ValueOf(row).Elem().FieldByName("Tahun"); field.IsValid() {
+		return int(field.Int())
+	}
+	tahun, _ := strconv.Atoi(record["tahun"])
+	return tahun
+}
+
+// importFeedNames validates the requested feeds, every feed when none is requested
+func importFeedNames(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return importer.Feeds, nil
+	}
+	for _, name := range names {
+		if _, ok := importFeeds[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFeed, name)
+		}
+	}
+	return names, nil
+}
+
+// Sync loads the feeds from the source in one transaction, a failing feed rolls back the others.
+// A dry run goes through the same steps and rolls back, its report is the diff a real run would
+// apply. When VALIDATION_BLOCK_INGESTION is set, real runs pass the validation gate over the loaded
+// years before committing and roll back when it fails. Every run is recorded, including failed and
+// blocked ones.
+func (m ImportModel) Sync(ctx context.Context, source importer.Source, options ImportOptions) (run ImportRunDetail, err error) {
+	run = ImportRunDetail{
+		ImportRun: ImportRun{Source: source.Name(), DryRun: options.DryRun, Full: options.Full, StartedAt: time.Now().Unix()},
+		Feeds:     []ImportFeedReport{},
+	}
+	if options.RunBy != 0 {
+		run.RunBy = &options.RunBy
+	}
+
+	names, err := importFeedNames(options.Feeds)
+	if err != nil {
+		return run, err
+	}
+
+	err = m.sync(ctx, source, names, options, &run)
+	run.Status = ImportStatusSuccess
+	switch {
+	case errors.Is(err, ErrIngestionBlocked):
+		run.Status, run.Message = ImportStatusBlocked, err.Error()
+	case err != nil:
+		run.Status, run.Message = ImportStatusFailed, err.Error()
+	}
+	return run, m.record(&run, err)
+}
+
+// sync fetches the feeds, then loads them in a transaction serialized with other imports
+func (m ImportModel) sync(ctx context.Context, source importer.Source, names []string, options ImportOptions, run *ImportRunDetail) error {
+	fetches := make([]importFetch, 0, len(names))
+	for _, name := range names {
+		fetch, err := fetchFeed(ctx, source, name, options)
+		if err != nil {
+			run.Feeds = append(run.Feeds, ImportFeedReport{Feed: name, Since: fetch.since, Cursor: fetch.since,
+				Changes: []ImportChange{}, Issues: []ImportIssue{}})
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		fetches = append(fetches, fetch)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('importer'))`); err != nil {
+		return err
+	}
+
+	for _, fetch := range fetches {
+		name := fetch.name
+		report, err := syncFeed(tx, fetch, options)
+		run.Feeds = append(run.Feeds, report)
+		run.Fetched += report.Fetched
+		run.Inserted += report.Inserted
+		run.Updated += report.Updated
+		run.Skipped += report.Skipped
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if options.DryRun {
+		return nil
+	}
+	if ValidationBlocksIngestion() {
+		if err := importGate(tx, run.Feeds); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// importGate runs the validation gate in the import transaction, for every year the feeds loaded
+func importGate(tx *gorp.Transaction, reports []ImportFeedReport) error {
+	years := map[int]bool{}
+	for _, report := range reports {
+		for _, tahun := range report.Tahun {
+			years[tahun] = true
+		}
+	}
+	sorted := make([]int, 0, len(years))
+	for tahun := range years {
+		sorted = append(sorted, tahun)
+	}
+	sort.Ints(sorted)
+
+	for _, tahun := range sorted {
+		if _, err := (ValidationModel{}).Gate(ValidationScope{Tahun: tahun, Tolerance: 0.01, Limit: 10, tx: tx}); err != nil {
+			if errors.Is(err, ErrIngestionBlocked) {
+				return fmt.Errorf("%w: tahun %d", err, tahun)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// record saves the run and returns runErr, or the error saving it
+func (m ImportModel) record(run *ImportRunDetail, runErr error) error {
+	run.FinishedAt = time.Now().Unix()
+	report, err := json.Marshal(run.Feeds)
+	if err != nil {
+		return err
+	}
+
+	err = db.GetDB().QueryRow(`INSERT INTO public.import_runs
+			(source, dry_run, full_sync, status, message, fetched, inserted, updated, skipped, report, run_by, started_at, finished_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`,
+		run.Source, run.DryRun, run.Full, run.Status, run.Message, run.Fetched, run.Inserted, run.Updated, run.Skipped,
+		string(report), run.RunBy, run.StartedAt, run.FinishedAt).Scan(&run.ID)
+	if runErr != nil {
+		return runErr
+	}
+	return err
+}
+
+// importRunColumns ...
+const importRunColumns = `id, source, dry_run, full_sync, status, COALESCE(message, '') AS message, fetched, inserted, updated, skipped,
+	run_by, started_at, finished_at`
+
+// All lists the runs, newest first
+func (m ImportModel) All(form forms.ImportListForm) (page ImportRunPage, err error) {
+	if form.Page == 0 {
+		form.Page = 1
+	}
+	if form.Limit == 0 {
+		form.Limit = 20
+	}
+
+	total, err := db.GetDB().SelectInt(`SELECT COUNT(*) FROM public.import_runs`)
+	if err != nil {
+		return page, err
+	}
+
+	page.Data = []ImportRun{}
+	_, err = db.GetDB().Select(&page.Data, `SELECT `+importRunColumns+` FROM public.import_runs ORDER BY id DESC LIMIT $1 OFFSET $2`,
+		form.Limit, (form.Page-1)*form.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	page.Meta = Meta{Total: int(total), Page: form.Page, Limit: form.Limit}
+	return page, nil
+}
+
+// One returns a run with its feed reports
+func (m ImportModel) One(id int64) (run ImportRunDetail, err error) {
+	err = db.GetDB().SelectOne(&run.ImportRun, `SELECT `+importRunColumns+` FROM public.import_runs WHERE id=$1`, id)
+	if err != nil {
+		return run, err
+	}
+
+	var report []byte
+	if err = db.GetDB().QueryRow(`SELECT report FROM public.import_runs WHERE id=$1`, id).Scan(&report); err != nil {
+		return run, err
+	}
+	run.Feeds = []ImportFeedReport{}
+	if len(report) > 0 {
+		err = json.Unmarshal(report, &run.Feeds)
+	}
+	return run, err
+}
+
+// Cursors returns the cursor of every feed synced so far
+func (m ImportModel) Cursors() (cursors []ImportCursor, err error) {
+	cursors = []ImportCursor{}
+	_, err = db.GetDB().Select(&cursors, `SELECT feed, last_modified, updated_at FROM public.import_cursors ORDER BY feed`)
+	return cursors, err
+}
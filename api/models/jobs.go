@@ -0,0 +1,546 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/scheduler"
+	"github.com/lib/pq"
+)
+
+// Background jobs ...
+const (
+	JobCacheWarming    = "cache_warming"
+	JobRankRecompute   = "rank_recompute"
+	JobSessionCleanup  = "session_cleanup"
+	JobAlertEvaluation = "alert_evaluation"
+	JobQualityReport   = "data_quality_report"
+	JobArticlePublish  = "article_publishing"
+	JobNotifications   = "notification_delivery"
+)
+
+// ErrSchedulerNotStarted is returned before StartJobs
+var ErrSchedulerNotStarted = errors.New("scheduler is not started")
+
+// JobRun is one execution of a job, finished_at is 0 while it runs
+type JobRun struct {
+	ID          int64  `db:"id" json:"id"`
+	Job         string `db:"job" json:"job"`
+	Trigger     string `db:"trigger" json:"trigger"` // "schedule" or "manual"
+	Status      string `db:"status" json:"status"`   // "running", "success" or "failed"
+	Output      string `db:"output" json:"output,omitempty"`
+	Error       string `db:"error" json:"error,omitempty"`
+	Instance    string `db:"instance" json:"instance"`
+	TriggeredBy *int64 `db:"triggered_by" json:"triggered_by,omitempty"`
+	StartedAt   int64  `db:"started_at" json:"started_at"`
+	FinishedAt  int64  `db:"finished_at" json:"finished_at"`
+}
+
+// JobRunPage ...
+type JobRunPage struct {
+	Data []JobRun `json:"data"`
+	Meta Meta     `json:"meta"`
+}
+
+// JobInfo is the status of a job
+type JobInfo struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Schedule    string  `json:"schedule"` // cron expression, "off" for manual runs only
+	Enabled     bool    `json:"enabled"`
+	NextRun     int64   `json:"next_run,omitempty"`
+	Running     bool    `json:"running"` // on any replica
+	LastRun     *JobRun `json:"last_run,omitempty"`
+}
+
+// JobModel ...
+type JobModel struct{}
+
+// jobScheduler is set by StartJobs
+var jobScheduler *scheduler.Scheduler
+
+// backgroundJobs are the jobs with their default schedules, JOB_<NAME>_SCHEDULE overrides one
+func backgroundJobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:        JobCacheWarming,
+			Description: "Runs the dashboard queries of the latest month so their data is in the database cache",
+			Spec:        "*/30 * * * *",
+			Timeout:     5 * time.Minute,
+			Run:         warmCaches,
+		},
+		{
+			Name:        JobRankRecompute,
+			Description: "Recomputes peringkat_* of the latest year from kumulatif_* within each jenis_opd",
+			Spec:        "off", // the upstream ranks are served as they are unless JOB_RANK_RECOMPUTE_SCHEDULE is set
+			Timeout:     5 * time.Minute,
+			Run:         recomputeRanks,
+		},
+		{
+			Name:        JobSessionCleanup,
+			Description: "Deletes sessions without expiry or of deleted users, login attempts past LOGIN_ATTEMPT_RETENTION_DAYS and job runs past JOB_RUN_RETENTION_DAYS",
+			Spec:        "30 3 * * *",
+			Timeout:     10 * time.Minute,
+			Run:         cleanupSessions,
+		},
+		{
+			Name:        JobAlertEvaluation,
+			Description: "Evaluates the alert rules when new data was ingested or the rules changed",
+			Spec:        fmt.Sprintf("@every %dm", int(AlertEvaluateInterval().Minutes())),
+			Timeout:     10 * time.Minute,
+			Run:         evaluateAlerts,
+		},
+		{
+			Name:        JobQualityReport,
+			Description: "Validation checks and reporting freshness of the latest month, kept as the run output",
+			Spec:        "0 7 * * *",
+			Timeout:     10 * time.Minute,
+			Run:         qualityReport,
+		},
+		{
+			Name:        JobArticlePublish,
+			Description: "Publishes scheduled articles whose publish_at passed and archives published ones whose expire_at passed",
+			Spec:        "* * * * *",
+			Timeout:     5 * time.Minute,
+			Run:         publishArticles,
+		},
+		{
+			Name:        JobNotifications,
+			Description: "Sends the due email and webhook deliveries of the notification queue",
+			Spec:        "* * * * *",
+			Timeout:     30 * time.Minute, // covers the lease of a batch whose sends all time out
+			Run:         deliverNotifications,
+		},
+	}
+}
+
+// SchedulerEnabled is SCHEDULER_ENABLED (default true). A replica with the scheduler disabled still
+// lists the jobs and accepts manual triggers.
+func SchedulerEnabled() bool {
+	value := strings.ToLower(os.Getenv("SCHEDULER_ENABLED"))
+	return value != "false" && value != "0"
+}
+
+// StartJobs registers the background jobs and runs them on their schedules. Replicas coordinate
+// through Redis, so a scheduled run happens on one of them only.
+func StartJobs() {
+	hostname, _ := os.Hostname()
+	jobScheduler = &scheduler.Scheduler{
+		Locker:   scheduler.RedisLocker{Client: db.GetRedis()},
+		History:  JobRunModel{},
+		Instance: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		Disabled: !SchedulerEnabled(),
+	}
+
+	for _, job := range backgroundJobs() {
+		env := "JOB_" + strings.ToUpper(job.Name) + "_SCHEDULE"
+		if spec := strings.TrimSpace(os.Getenv(env)); spec != "" {
+			job.Spec = spec
+		}
+		if err := jobScheduler.Add(job); err != nil {
+			log.Fatal("Failed to init scheduler: ", fmt.Errorf("%s: %v", env, err))
+		}
+	}
+
+	jobScheduler.Start(context.Background())
+}
+
+// Status lists every job with its last run
+func (m JobModel) Status() ([]JobInfo, error) {
+	if jobScheduler == nil {
+		return nil, ErrSchedulerNotStarted
+	}
+
+	var lastRuns []JobRun
+	_, err := db.GetDB().Select(&lastRuns, `SELECT DISTINCT ON (job) `+jobRunColumns+` FROM public.job_runs ORDER BY job, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]JobRun, len(lastRuns))
+	for _, run := range lastRuns {
+		last[run.Job] = run
+	}
+
+	statuses := jobScheduler.Status()
+	jobs := make([]JobInfo, 0, len(statuses))
+	for _, status := range statuses {
+		info := JobInfo{
+			Name:        status.Name,
+			Description: status.Description,
+			Schedule:    status.Schedule,
+			Enabled:     status.Enabled,
+			Running:     status.Running,
+		}
+		if !status.NextRun.IsZero() {
+			info.NextRun = status.NextRun.Unix()
+		}
+		if run, ok := last[status.Name]; ok {
+			info.LastRun = &run
+		}
+		jobs = append(jobs, info)
+	}
+	return jobs, nil
+}
+
+// Trigger runs a job now in the background. It returns the run as started, or
+// scheduler.ErrUnknownJob and scheduler.ErrJobRunning.
+func (m JobModel) Trigger(name string, userID int64) (run JobRun, err error) {
+	if jobScheduler == nil {
+		return run, ErrSchedulerNotStarted
+	}
+	started, err := jobScheduler.Trigger(name, userID)
+	if err != nil {
+		return run, err
+	}
+	return jobRunOf(started), nil
+}
+
+// jobRunOf ...
+func jobRunOf(run scheduler.Run) JobRun {
+	jobRun := JobRun{
+		ID:        run.ID,
+		Job:       run.Job,
+		Trigger:   run.Trigger,
+		Status:    run.Status,
+		Output:    run.Output,
+		Error:     run.Error,
+		Instance:  run.Instance,
+		StartedAt: run.StartedAt.Unix(),
+	}
+	if run.TriggeredBy > 0 {
+		jobRun.TriggeredBy = &run.TriggeredBy
+	}
+	if !run.FinishedAt.IsZero() {
+		jobRun.FinishedAt = run.FinishedAt.Unix()
+	}
+	return jobRun
+}
+
+// JobRunModel stores the run history in job_runs
+type JobRunModel struct{}
+
+// jobRunColumns ...
+const jobRunColumns = `id, job, trigger, status, COALESCE(output, '') AS output, COALESCE(error, '') AS error, instance,
+	triggered_by, started_at, COALESCE(finished_at, 0) AS finished_at`
+
+// Start ...
+func (m JobRunModel) Start(run *scheduler.Run) error {
+	var triggeredBy *int64
+	if run.TriggeredBy > 0 {
+		triggeredBy = &run.TriggeredBy
+	}
+	return db.GetDB().QueryRow(`INSERT INTO public.job_runs (job, trigger, status, instance, triggered_by, started_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		run.Job, run.Trigger, run.Status, run.Instance, triggeredBy, run.StartedAt.Unix()).Scan(&run.ID)
+}
+
+// Finish ...
+func (m JobRunModel) Finish(run *scheduler.Run) error {
+	_, err := db.GetDB().Exec(`UPDATE public.job_runs SET status=$2, output=NULLIF($3, ''), error=NULLIF($4, ''), finished_at=$5 WHERE id=$1`,
+		run.ID, run.Status, run.Output, run.Error, run.FinishedAt.Unix())
+	return err
+}
+
+// All lists the runs newest first, of one job when form.Job is set
+func (m JobRunModel) All(form forms.JobRunListForm) (page JobRunPage, err error) {
+	if form.Page == 0 {
+		form.Page = 1
+	}
+	if form.Limit == 0 {
+		form.Limit = 20
+	}
+
+	total, err := db.GetDB().SelectInt(`SELECT COUNT(*) FROM public.job_runs WHERE ($1 = '' OR job = $1) AND ($2 = '' OR status = $2)`,
+		form.Job, form.Status)
+	if err != nil {
+		return page, err
+	}
+
+	page.Data = []JobRun{}
+	_, err = db.GetDB().Select(&page.Data, `SELECT `+jobRunColumns+` FROM public.job_runs
+			WHERE ($1 = '' OR job = $1) AND ($2 = '' OR status = $2)
+			ORDER BY id DESC LIMIT $3 OFFSET $4`,
+		form.Job, form.Status, form.Limit, (form.Page-1)*form.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	page.Meta = Meta{Total: int(total), Page: form.Page, Limit: form.Limit}
+	return page, nil
+}
+
+// One ...
+func (m JobRunModel) One(id int64) (run JobRun, err error) {
+	err = db.GetDB().SelectOne(&run, `SELECT `+jobRunColumns+` FROM public.job_runs WHERE id=$1`, id)
+	return run, err
+}
+
+// latestPeriod is the latest tahun and bulan with ranking data
+func latestPeriod() (tahun, bulan int, err error) {
+	err = db.GetDB().Db.QueryRow(`SELECT tahun, bulan FROM de_ranking_opd ORDER BY tahun DESC, bulan DESC LIMIT 1`).Scan(&tahun, &bulan)
+	return tahun, bulan, err
+}
+
+// warmCaches runs the ranking of every scope and the region aggregate of the latest month.
+// The API keeps no cache of its own, this keeps the pages behind the dashboard in memory.
+func warmCaches(ctx context.Context, trigger string) (string, error) {
+	tahun, bulan, err := latestPeriod()
+	if errors.Is(err, sql.ErrNoRows) {
+		return "No ranking data", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	period, err := NewPeriod(PeriodBulan, 0, bulan)
+	if err != nil {
+		return "", err
+	}
+
+	data := SijagurData{}
+	began := time.Now()
+	for _, scope := range []string{"", "skpd", "kecamatan"} {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if _, err := data.GetPeringkatKinerja(tahun, bulan, period, false, 0, 0, "", "kumulatif", scope, "", ""); err != nil {
+			return "", err
+		}
+	}
+	for _, dataType := range []string{"bulan", "tahun"} {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if _, err := data.AggregateRegion(tahun, bulan, dataType, 0.01); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("Warmed %d/%d in %s", bulan, tahun, time.Since(began).Round(time.Millisecond)), nil
+}
+
+// rankColumns pairs each peringkat_ column with the kumulatif_ score it ranks
+var rankColumns = [][2]string{
+	{"peringkat_opd", "kumulatif_opd"},
+	{"peringkat_barjas", "kumulatif_barjas"},
+	{"peringkat_fisik", "kumulatif_fisik"},
+	{"peringkat_anggaran", "kumulatif_anggaran"},
+	{"peringkat_kinerja", "kumulatif_kinerja"},
+}
+
+// recomputeRanks ranks every month of the latest year within its jenis_opd, ties sharing a rank and
+// rows without a score ranking last. Only rows whose rank changed are written, the idsatker 0 region
+// rows are left alone.
+func recomputeRanks(ctx context.Context, trigger string) (string, error) {
+	tahun, _, err := latestPeriod()
+	if errors.Is(err, sql.ErrNoRows) {
+		return "No ranking data", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ranks := make([]string, len(rankColumns))
+	sets := make([]string, len(rankColumns))
+	changed := make([]string, len(rankColumns))
+	for i, column := range rankColumns {
+		ranks[i] = fmt.Sprintf("RANK() OVER (PARTITION BY bulan, jenis_opd ORDER BY %s DESC NULLS LAST) AS %s", column[1], column[0])
+		sets[i] = fmt.Sprintf("%[1]s = r.%[1]s", column[0])
+		changed[i] = fmt.Sprintf("dro.%[1]s IS DISTINCT FROM r.%[1]s", column[0])
+	}
+
+	result, err := db.GetDB().Db.ExecContext(ctx, `UPDATE de_ranking_opd dro SET `+strings.Join(sets, ", ")+`
+		FROM (SELECT id, `+strings.Join(ranks, ", ")+` FROM de_ranking_opd WHERE tahun = $1 AND idsatker <> 0) r
+		WHERE dro.id = r.id AND (`+strings.Join(changed, " OR ")+`)`, tahun)
+	if err != nil {
+		return "", err
+	}
+	updated, _ := result.RowsAffected()
+	return fmt.Sprintf("Recomputed the ranks of %d, %d rows changed", tahun, updated), nil
+}
+
+// LoginAttemptRetention is LOGIN_ATTEMPT_RETENTION_DAYS (default 90)
+func LoginAttemptRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("LOGIN_ATTEMPT_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 90
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// JobRunRetention is JOB_RUN_RETENTION_DAYS (default 30)
+func JobRunRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("JOB_RUN_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// cleanupSessions deletes the session keys that would never expire or whose user was deleted,
+// then the login attempts and job runs past their retention
+func cleanupSessions(ctx context.Context, trigger string) (string, error) {
+	client := db.GetRedis()
+
+	var stale []string
+	owners := map[int64][]string{}
+	var cursor uint64
+	for {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		keys, next, err := client.Scan(cursor, sessionKeyPattern, 500).Result()
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			ttl, err := client.TTL(key).Result()
+			if err != nil {
+				return "", err
+			}
+			if ttl == -1 {
+				// no expiry, -2 is a key that expired meanwhile
+				stale = append(stale, key)
+				continue
+			}
+			if userID, err := client.Get(key).Int64(); err == nil {
+				owners[userID] = append(owners[userID], key)
+			}
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	if len(owners) > 0 {
+		ids := make([]int64, 0, len(owners))
+		for id := range owners {
+			ids = append(ids, id)
+		}
+		var existing []int64
+		_, err := db.GetDB().Select(&existing, `SELECT id FROM public."user" WHERE id = ANY($1)`, pq.Array(ids))
+		if err != nil {
+			return "", err
+		}
+		for _, id := range existing {
+			delete(owners, id)
+		}
+		for _, keys := range owners {
+			stale = append(stale, keys...)
+		}
+	}
+
+	var deleted int64
+	for start := 0; start < len(stale); start += 500 {
+		end := start + 500
+		if end > len(stale) {
+			end = len(stale)
+		}
+		count, err := client.Del(stale[start:end]...).Result()
+		if err != nil {
+			return "", err
+		}
+		deleted += count
+	}
+
+	result, err := db.GetDB().Db.ExecContext(ctx, `DELETE FROM public.login_attempts WHERE attempt_time < $1`,
+		time.Now().Add(-LoginAttemptRetention()).Unix())
+	if err != nil {
+		return "", err
+	}
+	attempts, _ := result.RowsAffected()
+
+	// The minutely jobs add two runs a minute
+	result, err = db.GetDB().Db.ExecContext(ctx, `DELETE FROM public.job_runs WHERE started_at < $1`,
+		time.Now().Add(-JobRunRetention()).Unix())
+	if err != nil {
+		return "", err
+	}
+	runs, _ := result.RowsAffected()
+	return fmt.Sprintf("Deleted %d sessions, %d login attempts and %d job runs", deleted, attempts, runs), nil
+}
+
+// alertStampKey keeps the data stamp of the last evaluation, shared by the replicas
+const alertStampKey = "scheduler:alert_evaluation:stamp"
+
+// evaluateAlerts evaluates the rules when new data was ingested or the rules changed since the
+// previous run. A manual run always evaluates.
+func evaluateAlerts(ctx context.Context, trigger string) (string, error) {
+	stamp, err := alertDataStamp()
+	if err != nil {
+		return "", err
+	}
+	if trigger == scheduler.TriggerSchedule {
+		if last, err := db.GetRedis().Get(alertStampKey).Result(); err == nil && last == stamp {
+			return "No new data since the last evaluation", nil
+		}
+	}
+
+	summary, err := AlertModel{}.Evaluate(0, 0)
+	if err != nil {
+		return "", err
+	}
+	if err := db.GetRedis().Set(alertStampKey, stamp, 0).Err(); err != nil {
+		log.Printf("Scheduler %s: %v", JobAlertEvaluation, err)
+	}
+	return fmt.Sprintf("Evaluated %d rules for %d: %d matched, opened %d, updated %d, resolved %d",
+		summary.Rules, summary.Tahun, summary.Matched, summary.Opened, summary.Updated, summary.Resolved), nil
+}
+
+// QualityReport is the output of the data_quality_report job
+type QualityReport struct {
+	Tahun         int      `json:"tahun"`
+	Bulan         int      `json:"bulan"`
+	Passed        bool     `json:"passed"`
+	Errors        int      `json:"errors"`
+	Warnings      int      `json:"warnings"`
+	FailedChecks  []string `json:"failed_checks"`
+	Overdue       int      `json:"overdue"`        // satkers with months past the reporting deadline
+	MissingMonths []int    `json:"missing_months"` // months no satker reported
+}
+
+// qualityReport runs the validation checks and the freshness of the latest month
+func qualityReport(ctx context.Context, trigger string) (string, error) {
+	tahun, bulan, err := latestPeriod()
+	if errors.Is(err, sql.ErrNoRows) {
+		return "No ranking data", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	validation, err := ValidationModel{}.Run(ValidationScope{Tahun: tahun, Bulan: bulan, Tolerance: 0.01, Limit: 10}, nil)
+	if err != nil {
+		return "", err
+	}
+	freshness, err := SijagurData{}.GetFreshness(tahun, ReportDeadlineDay())
+	if err != nil {
+		return "", err
+	}
+
+	report := QualityReport{
+		Tahun:         tahun,
+		Bulan:         bulan,
+		Passed:        validation.Passed,
+		Errors:        validation.Errors,
+		Warnings:      validation.Warnings,
+		FailedChecks:  []string{},
+		Overdue:       freshness.Overdue,
+		MissingMonths: freshness.MissingMonths,
+	}
+	for _, check := range validation.Checks {
+		if !check.Passed {
+			report.FailedChecks = append(report.FailedChecks, check.Name)
+		}
+	}
+	output, err := json.Marshal(report)
+	return string(output), err
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/storage"
+	"github.com/lib/pq"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Article content formats of the format query option
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatText     = "text"
+)
+
+// Images are only allowed from the attachment store, written as ![alt](attachment:12) in
+// Markdown, links may point at attachments the same way. The stored HTML keeps the
+// attachment: reference, it is swapped for a signed URL when served because signed URLs expire.
+var attachmentImageSrc = regexp.MustCompile(`^attachment:[0-9]+$`)
+
+var attachmentRef = regexp.MustCompile(`(src|href)="attachment:([0-9]+)"`)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var articlePolicy = newArticlePolicy()
+
+var textPolicy = bluemonday.StrictPolicy()
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// newArticlePolicy allows the markup goldmark produces for GFM, links and attachment images only
+func newArticlePolicy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+	policy.AllowStandardAttributes()
+	policy.AllowStandardURLs()
+	policy.AllowURLSchemes("mailto", "attachment")
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	policy.AllowAttrs("href").OnElements("a")
+	policy.AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "p", "br", "hr", "blockquote", "pre",
+		"em", "strong", "del", "sup", "sub")
+	policy.AllowLists()
+	policy.AllowTables()
+	policy.AllowAttrs("src").Matching(attachmentImageSrc).OnElements("img")
+	policy.AllowAttrs("alt").OnElements("img")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+-]+$`)).OnElements("code")
+	policy.AllowElements("code")
+	// GFM task lists
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	return policy
+}
+
+// RenderMarkdown converts Markdown to sanitized HTML. Raw HTML in the source is dropped by
+// goldmark and the result goes through the sanitizer regardless.
+func RenderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return string(articlePolicy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// PlainText strips the markup of rendered HTML
+func PlainText(renderedHTML string) string {
+	text := html.UnescapeString(textPolicy.Sanitize(renderedHTML))
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+// resolveAttachments replaces attachment: references of articleID with signed URLs.
+// Unknown attachments, and images that are not image attachments, lose their URL.
+func resolveAttachments(renderedHTML string, articleID int64) string {
+	matches := attachmentRef.FindAllStringSubmatch(renderedHTML, -1)
+	if len(matches) == 0 {
+		return renderedHTML
+	}
+
+	ids := []int64{}
+	for _, match := range matches {
+		id, _ := strconv.ParseInt(match[2], 10, 64)
+		ids = append(ids, id)
+	}
+
+	var attachments []ArticleAttachment
+	_, err := db.GetDB().Select(&attachments, `SELECT id, article_id, filename, content_type, size, storage_key, created_at
+		FROM public.article_attachments WHERE article_id = $1 AND id = ANY($2)`, articleID, pq.Array(ids))
+	if err != nil {
+		attachments = nil
+	}
+
+	urls := map[string]string{}
+	images := map[string]bool{}
+	for _, attachment := range attachments {
+		signed, err := storage.GetStorage().SignedURL(context.Background(), attachment.StorageKey, attachment.Filename, attachmentURLTTL())
+		if err == nil {
+			id := strconv.FormatInt(attachment.ID, 10)
+			urls[id] = signed
+			images[id] = strings.HasPrefix(attachment.ContentType, "image/")
+		}
+	}
+
+	return attachmentRef.ReplaceAllStringFunc(renderedHTML, func(ref string) string {
+		match := attachmentRef.FindStringSubmatch(ref)
+		attr, id := match[1], match[2]
+		signed, ok := urls[id]
+		if !ok || (attr == "src" && !images[id]) {
+			return attr + `=""`
+		}
+		return attr + `="` + html.EscapeString(signed) + `"`
+	})
+}
+
+// formatContent returns the content of an article in the requested format, markdown by default
+func formatContent(articleID int64, source, renderedHTML, format string) string {
+	switch format {
+	case FormatHTML:
+		return resolveAttachments(renderedHTML, articleID)
+	case FormatText:
+		return PlainText(renderedHTML)
+	default:
+		return source
+	}
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/notify"
+	"github.com/lib/pq"
+)
+
+// Notification events. Users can mute alert and announcement, password_reset is always delivered.
+const (
+	EventAlert         = "alert"
+	EventAnnouncement  = "announcement"
+	EventPasswordReset = "password_reset"
+)
+
+// ErrWebhookURL is returned for a webhook URL that is not an absolute http(s) URL or does not
+// resolve to public addresses
+var ErrWebhookURL = errors.New("invalid webhook URL")
+
+// Notice is what a feature asks the pipeline to tell its users
+type Notice struct {
+	Event string
+	Title string
+	Body  string
+	Data  interface{}
+	// Required skips the user's preferences and mutes, for security messages
+	Required bool
+	// SkipInbox keeps the notice out of the in-app inbox
+	SkipInbox bool
+	// Channels limits the delivery channels, nil uses every channel the user enabled
+	Channels []string
+}
+
+// Notification is one in-app inbox entry
+type Notification struct {
+	ID        int64   `db:"id, primarykey, autoincrement" json:"id"`
+	UserID    int64   `db:"user_id" json:"-"`
+	Event     string  `db:"event" json:"event"`
+	Title     string  `db:"title" json:"title"`
+	Body      string  `db:"body" json:"body"`
+	Data      JSONRaw `db:"data" json:"data,omitempty"`
+	ReadAt    *int64  `db:"read_at" json:"read_at"`
+	CreatedAt int64   `db:"created_at" json:"created_at"`
+}
+
+// NotificationPage ...
+type NotificationPage struct {
+	Data   []Notification `json:"data"`
+	Meta   Meta           `json:"meta"`
+	Unread int64          `json:"unread"`
+}
+
+// NotificationPreferences are the channels of one user. Users without a row get the defaults.
+type NotificationPreferences struct {
+	Inbox         bool     `json:"inbox"`
+	Email         bool     `json:"email"`
+	Webhook       bool     `json:"webhook"`
+	WebhookURL    string   `json:"webhook_url"`
+	WebhookSecret string   `json:"webhook_secret,omitempty"` // HMAC key of the webhook signature
+	MutedEvents   []string `json:"muted_events"`
+}
+
+// NotificationModel ...
+type NotificationModel struct{}
+
+// notificationModel is used by the features that notify users
+var notificationModel = new(NotificationModel)
+
+// Inbox lists the user's notifications, newest first
+func (m NotificationModel) Inbox(userID int64, form forms.NotificationListForm) (page NotificationPage, err error) {
+	if form.Page == 0 {
+		form.Page = 1
+	}
+	if form.Limit == 0 {
+		form.Limit = 20
+	}
+
+	where := `WHERE user_id=$1 AND (NOT $2 OR read_at IS NULL)`
+	total, err := db.GetDB().SelectInt(`SELECT COUNT(*) FROM public.notifications `+where, userID, form.Unread)
+	if err != nil {
+		return page, err
+	}
+
+	page.Data = []Notification{}
+	_, err = db.GetDB().Select(&page.Data, `SELECT id, user_id, event, title, body, COALESCE(data, 'null'::jsonb) AS data, read_at, created_at
+		FROM public.notifications `+where+` ORDER BY id DESC LIMIT $3 OFFSET $4`,
+		userID, form.Unread, form.Limit, (form.Page-1)*form.Limit)
+	if err != nil {
+		return page, err
+	}
+
+	page.Unread, err = m.UnreadCount(userID)
+	page.Meta = Meta{Total: int(total), Page: form.Page, Limit: form.Limit}
+	return page, err
+}
+
+// UnreadCount ...
+func (m NotificationModel) UnreadCount(userID int64) (int64, error) {
+	return db.GetDB().SelectInt(`SELECT COUNT(*) FROM public.notifications WHERE user_id=$1 AND read_at IS NULL`, userID)
+}
+
+// MarkRead marks one of the user's notifications as read, reading twice is not an error
+func (m NotificationModel) MarkRead(userID, id int64) error {
+	operation, err := db.GetDB().Exec(`UPDATE public.notifications SET read_at=COALESCE(read_at, $3) WHERE id=$1 AND user_id=$2`,
+		id, userID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return errors.New("notification not found")
+	}
+	return nil
+}
+
+// MarkAllRead returns how many notifications were unread
+func (m NotificationModel) MarkAllRead(userID int64) (int64, error) {
+	operation, err := db.GetDB().Exec(`UPDATE public.notifications SET read_at=$2 WHERE user_id=$1 AND read_at IS NULL`,
+		userID, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return operation.RowsAffected()
+}
+
+// Preferences ...
+func (m NotificationModel) Preferences(userID int64) (prefs NotificationPreferences, err error) {
+	err = db.GetDB().QueryRow(`SELECT COALESCE(p.inbox, TRUE), COALESCE(p.email, TRUE), COALESCE(p.webhook, FALSE),
+			COALESCE(p.webhook_url, ''), COALESCE(p.webhook_secret, ''), COALESCE(p.muted_events, '{}')
+		FROM public."user" u LEFT JOIN public.notification_preferences p ON p.user_id = u.id WHERE u.id=$1`, userID).
+		Scan(&prefs.Inbox, &prefs.Email, &prefs.Webhook, &prefs.WebhookURL, &prefs.WebhookSecret, pq.Array(&prefs.MutedEvents))
+	return prefs, err
+}
+
+// SavePreferences applies the form on top of the current preferences. A webhook secret is
+// generated the first time a webhook is enabled, and on request.
+func (m NotificationModel) SavePreferences(userID int64, form forms.NotificationPreferencesForm) (prefs NotificationPreferences, err error) {
+	prefs, err = m.Preferences(userID)
+	if err != nil {
+		return prefs, err
+	}
+
+	if form.Inbox != nil {
+		prefs.Inbox = *form.Inbox
+	}
+	if form.Email != nil {
+		prefs.Email = *form.Email
+	}
+	if form.Webhook != nil {
+		prefs.Webhook = *form.Webhook
+	}
+	if form.WebhookURL != nil {
+		prefs.WebhookURL = *form.WebhookURL
+	}
+	if form.MutedEvents != nil {
+		prefs.MutedEvents = form.MutedEvents
+	}
+
+	if prefs.WebhookURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := notify.CheckWebhookURL(ctx, prefs.WebhookURL, notify.AllowPrivateWebhooks()); err != nil {
+			return prefs, fmt.Errorf("%w: %v", ErrWebhookURL, err)
+		}
+	}
+	if prefs.Webhook && prefs.WebhookURL == "" {
+		return prefs, ErrWebhookURL
+	}
+	if (prefs.Webhook && prefs.WebhookSecret == "") || form.RotateSecret {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return prefs, err
+		}
+		prefs.WebhookSecret = hex.EncodeToString(secret)
+	}
+
+	_, err = db.GetDB().Exec(`INSERT INTO public.notification_preferences
+			(user_id, inbox, email, webhook, webhook_url, webhook_secret, muted_events, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+		ON CONFLICT (user_id) DO UPDATE SET inbox=EXCLUDED.inbox, email=EXCLUDED.email, webhook=EXCLUDED.webhook,
+			webhook_url=EXCLUDED.webhook_url, webhook_secret=EXCLUDED.webhook_secret, muted_events=EXCLUDED.muted_events,
+			updated_at=EXCLUDED.updated_at`,
+		userID, prefs.Inbox, prefs.Email, prefs.Webhook, prefs.WebhookURL, prefs.WebhookSecret, pq.Array(prefs.MutedEvents), time.Now().Unix())
+	return prefs, err
+}
+
+// recipient is a user with the channels the pipeline needs
+type recipient struct {
+	ID    int64
+	Email string
+	Prefs NotificationPreferences
+}
+
+// Send records the notice in the inboxes of userIDs and queues one delivery per enabled channel.
+// Deliveries are sent by the notification worker, so a slow SMTP server or webhook never
+// blocks the caller.
+func (m NotificationModel) Send(userIDs []int64, notice Notice) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	rows, err := db.GetDB().Query(`SELECT u.id, COALESCE(u.email, ''), COALESCE(p.inbox, TRUE), COALESCE(p.email, TRUE),
+			COALESCE(p.webhook, FALSE), COALESCE(p.webhook_url, ''), COALESCE(p.muted_events, '{}')
+		FROM public."user" u LEFT JOIN public.notification_preferences p ON p.user_id = u.id
+		WHERE u.id = ANY($1)`, pq.Array(userIDs))
+	if err != nil {
+		return err
+	}
+	var recipients []recipient
+	for rows.Next() {
+		var r recipient
+		if err := rows.Scan(&r.ID, &r.Email, &r.Prefs.Inbox, &r.Prefs.Email, &r.Prefs.Webhook, &r.Prefs.WebhookURL, pq.Array(&r.Prefs.MutedEvents)); err != nil {
+			rows.Close()
+			return err
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+
+	data, err := json.Marshal(notice.Data)
+	if err != nil {
+		return err
+	}
+	if notice.Data == nil {
+		data = nil
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, r := range recipients {
+		if !notice.Required && contains(r.Prefs.MutedEvents, notice.Event) {
+			continue
+		}
+
+		var notificationID *int64
+		if !notice.SkipInbox && (r.Prefs.Inbox || notice.Required) {
+			var id int64
+			err := tx.QueryRow(`INSERT INTO public.notifications (user_id, event, title, body, data, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, r.ID, notice.Event, notice.Title, notice.Body, nullJSON(data), now).Scan(&id)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			notificationID = &id
+		}
+
+		for _, channel := range []string{notify.ChannelEmail, notify.ChannelWebhook} {
+			if notice.Channels != nil && !contains(notice.Channels, channel) {
+				continue
+			}
+			to := r.Email
+			enabled := r.Prefs.Email || notice.Required
+			if channel == notify.ChannelWebhook {
+				to = r.Prefs.WebhookURL
+				enabled = r.Prefs.Webhook && to != ""
+			}
+			if !enabled || to == "" {
+				continue
+			}
+			_, err := tx.Exec(`INSERT INTO public.notification_deliveries
+					(user_id, notification_id, channel, event, recipient, subject, body, data, status, attempts, next_attempt_at, updated_at, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 0, $10, $10, $10)`,
+				r.ID, notificationID, channel, notice.Event, to, notice.Title, notice.Body, nullJSON(data), DeliveryPending, now)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// nullJSON keeps empty payloads NULL in jsonb columns
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// contains ...
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// usersOfSatker lists the users linked to an OPD
+func usersOfSatker(idsatker int64) (ids []int64, err error) {
+	rows, err := db.GetDB().Query(`SELECT id FROM public."user" WHERE idsatker=$1`, idsatker)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
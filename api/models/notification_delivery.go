@@ -0,0 +1,185 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/notify"
+)
+
+// Delivery statuses of the retry queue
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// NotificationMaxAttempts is how often a delivery is tried before it fails, NOTIFY_MAX_ATTEMPTS (default 5)
+func NotificationMaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("NOTIFY_MAX_ATTEMPTS"))
+	if err != nil || attempts <= 0 {
+		attempts = 5
+	}
+	return attempts
+}
+
+// NotificationRetryDelay is the wait after the given number of failed attempts:
+// 30 seconds doubling each time, capped at 6 hours
+func NotificationRetryDelay(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
+
+// queuedDelivery ...
+type queuedDelivery struct {
+	ID        int64
+	Channel   string
+	Event     string
+	Recipient string
+	Subject   string
+	Body      string
+	Data      []byte
+	Attempts  int
+	Secret    string
+}
+
+// notificationSendTimeout bounds one send, a batch of limit deliveries is leased for limit times it
+// plus notificationLeaseMargin
+const (
+	notificationSendTimeout = 30 * time.Second
+	notificationLeaseMargin = time.Minute
+)
+
+// Deliver sends up to limit due deliveries. The rows are claimed first: locked with SKIP LOCKED,
+// leased by moving next_attempt_at past the time the batch can take, and committed, so several
+// API instances can run the worker side by side without sending twice and no lock or transaction
+// is held during the sends. A worker that dies mid-batch leaves its rows to be retried once the
+// lease expires, the claim counts as an attempt. Each outcome is then recorded on its own.
+func (m NotificationModel) Deliver(limit int) (sent, failed int, err error) {
+	now := time.Now()
+	lease := now.Add(time.Duration(limit)*notificationSendTimeout + notificationLeaseMargin)
+
+	rows, err := db.GetDB().Query(`WITH claimed AS (
+			UPDATE public.notification_deliveries SET attempts=attempts+1, next_attempt_at=$4, updated_at=$2
+			WHERE id IN (SELECT id FROM public.notification_deliveries
+				WHERE status=$1 AND next_attempt_at <= $2
+				ORDER BY next_attempt_at, id LIMIT $3
+				FOR UPDATE SKIP LOCKED)
+			RETURNING id, user_id, channel, event, recipient, subject, body, data, attempts)
+		SELECT c.id, c.channel, c.event, c.recipient, c.subject, c.body, COALESCE(c.data::text, ''), c.attempts,
+			COALESCE(p.webhook_secret, '')
+		FROM claimed c
+		LEFT JOIN public.notification_preferences p ON p.user_id = c.user_id
+		ORDER BY c.id`, DeliveryPending, now.Unix(), limit, lease.Unix())
+	if err != nil {
+		return 0, 0, err
+	}
+	var queue []queuedDelivery
+	for rows.Next() {
+		var d queuedDelivery
+		var data string
+		if err := rows.Scan(&d.ID, &d.Channel, &d.Event, &d.Recipient, &d.Subject, &d.Body, &data, &d.Attempts, &d.Secret); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		d.Data = []byte(data)
+		queue = append(queue, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	maxAttempts := NotificationMaxAttempts()
+	for _, d := range queue {
+		sendErr := m.deliver(d)
+		attempts := d.Attempts // counted when claimed
+
+		if sendErr == nil {
+			sent++
+			_, err = db.GetDB().Exec(`UPDATE public.notification_deliveries SET status=$2, attempts=$3, sent_at=$4, last_error=NULL, updated_at=$4 WHERE id=$1`,
+				d.ID, DeliverySent, attempts, time.Now().Unix())
+		} else if notify.IsPermanent(sendErr) || attempts >= maxAttempts {
+			failed++
+			log.Printf("Notification delivery %d failed: %v", d.ID, sendErr)
+			_, err = db.GetDB().Exec(`UPDATE public.notification_deliveries SET status=$2, attempts=$3, last_error=$4, updated_at=$5 WHERE id=$1`,
+				d.ID, DeliveryFailed, attempts, sendErr.Error(), time.Now().Unix())
+		} else {
+			_, err = db.GetDB().Exec(`UPDATE public.notification_deliveries SET attempts=$2, last_error=$3, next_attempt_at=$4, updated_at=$5 WHERE id=$1`,
+				d.ID, attempts, sendErr.Error(), time.Now().Add(NotificationRetryDelay(attempts)).Unix(), time.Now().Unix())
+		}
+		if err != nil {
+			// The remaining rows are retried when their lease expires
+			return sent, failed, err
+		}
+	}
+
+	return sent, failed, nil
+}
+
+// deliver hands one delivery to the sender of its channel, stamped with the time it is sent
+func (m NotificationModel) deliver(d queuedDelivery) error {
+	sender, ok := notify.GetSender(d.Channel)
+	if !ok {
+		return notify.PermanentError{Err: errUnknownChannel(d.Channel)}
+	}
+
+	if d.Event == EventPasswordReset {
+		if err := issuePasswordResetLink(&d); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationSendTimeout)
+	defer cancel()
+
+	msg := notify.Message{
+		ID:        d.ID,
+		Event:     d.Event,
+		To:        d.Recipient,
+		Secret:    d.Secret,
+		Subject:   d.Subject,
+		Body:      d.Body,
+		Timestamp: time.Now().Unix(),
+	}
+	if len(d.Data) > 0 {
+		msg.Data = json.RawMessage(d.Data)
+	}
+	return sender.Send(ctx, msg)
+}
+
+// errUnknownChannel ...
+type errUnknownChannel string
+
+func (e errUnknownChannel) Error() string {
+	return "no sender for channel " + string(e)
+}
+
+// deliverNotifications is the notification_delivery job. It drains the queue in batches so a
+// burst does not wait for the next run.
+func deliverNotifications(ctx context.Context, trigger string) (string, error) {
+	var sent, failed int
+	for ctx.Err() == nil {
+		batchSent, batchFailed, err := NotificationModel{}.Deliver(50)
+		sent, failed = sent+batchSent, failed+batchFailed
+		if err != nil {
+			return fmt.Sprintf("Sent %d, failed %d", sent, failed), err
+		}
+		if batchSent+batchFailed < 50 {
+			break
+		}
+	}
+	return fmt.Sprintf("Sent %d, failed %d", sent, failed), nil
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/notify"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrResetToken is returned for an unknown, used or expired reset token
+var ErrResetToken = errors.New("invalid or expired reset token")
+
+// passwordResetTTL is the reset link lifetime, PASSWORD_RESET_TTL_MINUTES (default 30)
+func passwordResetTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// passwordResetKey is the Redis key of a token. Only the hash is stored.
+func passwordResetKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "password_reset_" + hex.EncodeToString(sum[:])
+}
+
+// passwordResetLink stands for the reset link in a queued email. The token is only issued by the
+// notification worker when the email is sent, so it is never written to notification_deliveries.
+const passwordResetLink = "{reset_link}"
+
+// passwordResetData is the delivery data of a reset email
+type passwordResetData struct {
+	UserID int64 `json:"user_id"`
+}
+
+// RequestPasswordReset emails a reset link through the notification pipeline
+func (m UserModel) RequestPasswordReset(email string) error {
+	var user User
+	err := db.GetDB().SelectOne(&user, `SELECT id, email FROM public."user" WHERE LOWER(email)=LOWER($1) LIMIT 1`, email)
+	if err != nil {
+		return err
+	}
+
+	return notificationModel.Send([]int64{user.ID}, Notice{
+		Event: EventPasswordReset,
+		Title: "Reset your password",
+		Body: fmt.Sprintf("Open this link to choose a new password:\n\n%s\n\nThe link expires in %d minutes. If you did not ask for it, ignore this email.",
+			passwordResetLink, int(passwordResetTTL().Minutes())),
+		Data:      passwordResetData{UserID: user.ID},
+		Required:  true,
+		SkipInbox: true,
+		Channels:  []string{notify.ChannelEmail},
+	})
+}
+
+// issuePasswordResetLink stores a single-use token for the user of a reset email and puts its link
+// in the body, right before the email is sent
+func issuePasswordResetLink(d *queuedDelivery) error {
+	var data passwordResetData
+	if err := json.Unmarshal(d.Data, &data); err != nil || data.UserID == 0 {
+		return notify.PermanentError{Err: errors.New("password reset delivery without a user")}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(random)
+
+	if err := db.GetRedis().Set(passwordResetKey(token), strconv.FormatInt(data.UserID, 10), passwordResetTTL()).Err(); err != nil {
+		return err
+	}
+
+	link := os.Getenv("PASSWORD_RESET_URL")
+	if link == "" {
+		link = "https://" + os.Getenv("FRONTEND_DOMAIN") + "/reset-password"
+	}
+	d.Body = strings.Replace(d.Body, passwordResetLink, link+"?token="+token, 1)
+	return nil
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset, unlocks the account
+// and signs the user out everywhere
+func (m UserModel) ResetPassword(form forms.ResetPasswordForm) error {
+	key := passwordResetKey(form.Token)
+	userID, err := db.GetRedis().Get(key).Int64()
+	if err != nil {
+		return ErrResetToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(form.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// Single use: the token is gone before the password changes
+	if deleted, err := db.GetRedis().Del(key).Result(); err != nil || deleted == 0 {
+		return ErrResetToken
+	}
+
+	_, err = db.GetDB().Exec(`UPDATE public."user" SET password=$2, failed_attempts=0, locked_until=0 WHERE id=$1`, userID, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+
+	// Whoever knew the old password may hold tokens issued with it
+	_, err = AuthModel{}.RevokeUserSessions(userID)
+	return err
+}
@@ -0,0 +1,401 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+)
+
+// ErrSnapshotUnchanged is returned when publishing a ranking identical to its latest version
+var ErrSnapshotUnchanged = errors.New("ranking has not changed since the latest published version")
+
+// ErrSnapshotNotFound is returned when a requested snapshot version does not exist
+var ErrSnapshotNotFound = errors.New("ranking snapshot not found")
+
+// RankingSnapshot is an immutable published version of the ranking of one period
+type RankingSnapshot struct {
+	ID          int64  `db:"id, primarykey, autoincrement" json:"id"`
+	Tahun       int    `db:"tahun" json:"tahun"`
+	Period      string `db:"period" json:"period"`   // "bulan" | "triwulan" | "semester"
+	Periode     int    `db:"periode" json:"periode"` // month, triwulan or semester number
+	Version     int    `db:"version" json:"version"`
+	Note        string `db:"note" json:"note,omitempty"`
+	Rows        int    `db:"row_count" json:"rows"`
+	Changes     int    `db:"changes" json:"changes"`         // OPDs in the changelog
+	LastUpdate  int64  `db:"last_update" json:"last_update"` // of the data frozen
+	PublishedBy *int64 `db:"published_by" json:"published_by,omitempty"`
+	PublishedAt int64  `db:"published_at" json:"published_at"`
+}
+
+// RankingSnapshotDetail is a snapshot with the changes from the previous version
+type RankingSnapshotDetail struct {
+	RankingSnapshot
+	Changelog []RankingChange `json:"changelog"`
+}
+
+// SnapshotRow is one frozen row, with the columns GetPeringkatKinerja reads from its source
+type SnapshotRow struct {
+	ID                int64   `db:"id" json:"id"`
+	Idsatker          int64   `db:"idsatker" json:"idsatker"`
+	NamaOpd           string  `db:"nama_opd" json:"nama_opd"`
+	JenisOpd          string  `db:"jenis_opd" json:"jenis_opd"`
+	CapaianOpd        float64 `db:"capaian_opd" json:"capaian_opd"`
+	CapaianBarjas     float64 `db:"capaian_barjas" json:"capaian_barjas"`
+	CapaianFisik      float64 `db:"capaian_fisik" json:"capaian_fisik"`
+	CapaianAnggaran   float64 `db:"capaian_anggaran" json:"capaian_anggaran"`
+	CapaianKinerja    float64 `db:"capaian_kinerja" json:"capaian_kinerja"`
+	KumulatifOpd      float64 `db:"kumulatif_opd" json:"kumulatif_opd"`
+	KumulatifBarjas   float64 `db:"kumulatif_barjas" json:"kumulatif_barjas"`
+	KumulatifFisik    float64 `db:"kumulatif_fisik" json:"kumulatif_fisik"`
+	KumulatifAnggaran float64 `db:"kumulatif_anggaran" json:"kumulatif_anggaran"`
+	KumulatifKinerja  float64 `db:"kumulatif_kinerja" json:"kumulatif_kinerja"`
+	PeriodikOpd       float64 `db:"periodik_opd" json:"periodik_opd"`
+	PeriodikBarjas    float64 `db:"periodik_barjas" json:"periodik_barjas"`
+	PeriodikFisik     float64 `db:"periodik_fisik" json:"periodik_fisik"`
+	PeriodikAnggaran  float64 `db:"periodik_anggaran" json:"periodik_anggaran"`
+	PeriodikKinerja   float64 `db:"periodik_kinerja" json:"periodik_kinerja"`
+	PeringkatOpd      int64   `db:"peringkat_opd" json:"peringkat_opd"`
+	Tahun             int     `db:"tahun" json:"tahun"`
+	Bulan             int     `db:"bulan" json:"bulan"`
+}
+
+// RankingFieldChange is a score or rank of an OPD that differs from the previous version
+type RankingFieldChange struct {
+	Field    string  `json:"field"`
+	Previous float64 `json:"previous"`
+	Current  float64 `json:"current"`
+}
+
+// RankingChange is an OPD whose ranking differs from the previous version
+type RankingChange struct {
+	Idsatker int64                `json:"idsatker"`
+	NamaOpd  string               `json:"nama_opd"`
+	Change   string               `json:"change"` // "added" | "removed" | "changed"
+	Fields   []RankingFieldChange `json:"fields"`
+}
+
+// RankingSnapshotModel ...
+type RankingSnapshotModel struct{}
+
+// snapshotColumns are the SnapshotRow columns in insert order
+const snapshotColumns = `id, idsatker, nama_opd, jenis_opd,
+	capaian_opd, capaian_barjas, capaian_fisik, capaian_anggaran, capaian_kinerja,
+	kumulatif_opd, kumulatif_barjas, kumulatif_fisik, kumulatif_anggaran, kumulatif_kinerja,
+	periodik_opd, periodik_barjas, periodik_fisik, periodik_anggaran, periodik_kinerja,
+	peringkat_opd, tahun, bulan`
+
+// snapshotKey is the period of a ranking as stored on a snapshot. ok is false for a ranking
+// over every month of the year, which is never published.
+func snapshotKey(month int, period Period) (periodType string, periode int, ok bool) {
+	if !period.IsMonth() {
+		return period.Type, period.Number, true
+	}
+	if month > 0 {
+		return PeriodBulan, month, true
+	}
+	return "", 0, false
+}
+
+// source is the snapshot as a FROM clause with the columns of de_ranking_opd
+func (s RankingSnapshot) source() string {
+	return fmt.Sprintf("(SELECT * FROM public.ranking_snapshot_rows WHERE snapshot_id = %d) snap", s.ID)
+}
+
+// fields lists the compared values of a row, ranks included
+func (r SnapshotRow) fields(ranks map[string]int) []RankingFieldChange {
+	values := []RankingFieldChange{
+		{Field: "capaian_opd", Current: r.CapaianOpd},
+		{Field: "capaian_barjas", Current: r.CapaianBarjas},
+		{Field: "capaian_fisik", Current: r.CapaianFisik},
+		{Field: "capaian_anggaran", Current: r.CapaianAnggaran},
+		{Field: "capaian_kinerja", Current: r.CapaianKinerja},
+		{Field: "kumulatif_opd", Current: r.KumulatifOpd},
+		{Field: "kumulatif_barjas", Current: r.KumulatifBarjas},
+		{Field: "kumulatif_fisik", Current: r.KumulatifFisik},
+		{Field: "kumulatif_anggaran", Current: r.KumulatifAnggaran},
+		{Field: "kumulatif_kinerja", Current: r.KumulatifKinerja},
+		{Field: "periodik_opd", Current: r.PeriodikOpd},
+		{Field: "periodik_barjas", Current: r.PeriodikBarjas},
+		{Field: "periodik_fisik", Current: r.PeriodikFisik},
+		{Field: "periodik_anggaran", Current: r.PeriodikAnggaran},
+		{Field: "periodik_kinerja", Current: r.PeriodikKinerja},
+		{Field: "peringkat_opd", Current: float64(r.PeringkatOpd)},
+	}
+	for _, dimension := range []string{"kumulatif", "capaian", "periodik"} {
+		values = append(values, RankingFieldChange{Field: "rank_" + dimension, Current: float64(ranks[dimension])})
+	}
+	return values
+}
+
+// snapshotRanks ranks every row by the opd score of each dimension within its jenis_opd, the
+// way the scoped ranking lists them. Ties share a rank.
+func snapshotRanks(rows []SnapshotRow) []map[string]int {
+	ranks := make([]map[string]int, len(rows))
+	for i := range ranks {
+		ranks[i] = map[string]int{}
+	}
+
+	groups := map[string][]int{}
+	for i, r := range rows {
+		groups[r.JenisOpd] = append(groups[r.JenisOpd], i)
+	}
+	for _, indexes := range groups {
+		for _, dimension := range []string{"kumulatif", "capaian", "periodik"} {
+			values := make([]float64, len(indexes))
+			for k, i := range indexes {
+				switch dimension {
+				case "capaian":
+					values[k] = rows[i].CapaianOpd
+				case "periodik":
+					values[k] = rows[i].PeriodikOpd
+				default:
+					values[k] = rows[i].KumulatifOpd
+				}
+			}
+			for k, rank := range competitionRanks(values) {
+				ranks[indexes[k]][dimension] = rank
+			}
+		}
+	}
+	return ranks
+}
+
+// RankingChangelog lists the OPDs whose scores or ranks differ between two versions of a
+// ranking, ordered by idsatker. Rows are matched by idsatker.
+func RankingChangelog(previous, current []SnapshotRow) []RankingChange {
+	type entry struct {
+		row    SnapshotRow
+		fields []RankingFieldChange
+	}
+	index := func(rows []SnapshotRow) map[int64]entry {
+		ranks := snapshotRanks(rows)
+		entries := make(map[int64]entry, len(rows))
+		for i, r := range rows {
+			entries[r.Idsatker] = entry{r, r.fields(ranks[i])}
+		}
+		return entries
+	}
+	before, after := index(previous), index(current)
+
+	ids := []int64{}
+	for id := range before {
+		ids = append(ids, id)
+	}
+	for id := range after {
+		if _, ok := before[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+
+	changes := []RankingChange{}
+	for _, id := range ids {
+		old, hadOld := before[id]
+		now, hasNow := after[id]
+		switch {
+		case !hadOld:
+			changes = append(changes, RankingChange{Idsatker: id, NamaOpd: now.row.NamaOpd, Change: "added", Fields: now.fields})
+		case !hasNow:
+			fields := old.fields
+			for i := range fields {
+				fields[i].Previous, fields[i].Current = fields[i].Current, 0
+			}
+			changes = append(changes, RankingChange{Idsatker: id, NamaOpd: old.row.NamaOpd, Change: "removed", Fields: fields})
+		default:
+			var fields []RankingFieldChange
+			for i, field := range now.fields {
+				if field.Current != old.fields[i].Current {
+					fields = append(fields, RankingFieldChange{Field: field.Field, Previous: old.fields[i].Current, Current: field.Current})
+				}
+			}
+			if len(fields) > 0 {
+				changes = append(changes, RankingChange{Idsatker: id, NamaOpd: now.row.NamaOpd, Change: "changed", Fields: fields})
+			}
+		}
+	}
+	return changes
+}
+
+// liveSnapshotRows reads the live ranking rows of a period, as GetPeringkatKinerja would rank them
+func liveSnapshotRows(year int, periodType string, periode int) ([]SnapshotRow, error) {
+	var rows []SnapshotRow
+	columns := `id, idsatker, COALESCE(nama_opd, '') AS nama_opd, COALESCE(jenis_opd, '') AS jenis_opd,
+		capaian_opd, capaian_barjas, capaian_fisik, capaian_anggaran, capaian_kinerja,
+		kumulatif_opd, kumulatif_barjas, kumulatif_fisik, kumulatif_anggaran, kumulatif_kinerja,
+		periodik_opd, periodik_barjas, periodik_fisik, periodik_anggaran, periodik_kinerja,
+		peringkat_opd, tahun, bulan`
+
+	if periodType == PeriodBulan {
+		_, err := db.GetDB().Select(&rows, `SELECT `+columns+` FROM de_ranking_opd WHERE tahun = $1 AND bulan = $2 ORDER BY idsatker, id`, year, periode)
+		return rows, err
+	}
+	first, last := Period{Type: periodType, Number: periode}.Months()
+	_, err := db.GetDB().Select(&rows, `SELECT `+columns+` FROM `+periodRankingSource+` WHERE tahun = $1 ORDER BY idsatker, id`, year, first, last)
+	return rows, err
+}
+
+// Publish freezes the live ranking of a period into a new version. The changelog compares it with
+// the latest version; publishing an unchanged ranking returns ErrSnapshotUnchanged.
+func (m RankingSnapshotModel) Publish(year int, period Period, note string, userID int64) (RankingSnapshotDetail, error) {
+	periodType, periode := period.Type, period.Number
+	if periodType == "" {
+		periodType = PeriodBulan
+	}
+	if periode == 0 {
+		return RankingSnapshotDetail{}, ErrInvalidPeriod
+	}
+
+	current, err := liveSnapshotRows(year, periodType, periode)
+	if err != nil {
+		return RankingSnapshotDetail{}, err
+	}
+	first, last := Period{Type: periodType, Number: periode}.Months()
+	lastUpdate := SijagurData{}.DataLastUpdate(year, first, last, 0)
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return RankingSnapshotDetail{}, err
+	}
+
+	// Serialises publishing of the same period so versions stay sequential
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, fmt.Sprintf("ranking_snapshot:%d:%s:%d", year, periodType, periode)); err != nil {
+		tx.Rollback()
+		return RankingSnapshotDetail{}, err
+	}
+
+	var latest RankingSnapshot
+	var previous []SnapshotRow
+	err = tx.SelectOne(&latest, `SELECT id, tahun, period, periode, version, COALESCE(note, '') AS note, row_count, changes, last_update, published_by, published_at
+		FROM public.ranking_snapshots WHERE tahun = $1 AND period = $2 AND periode = $3
+		ORDER BY version DESC LIMIT 1`, year, periodType, periode)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		tx.Rollback()
+		return RankingSnapshotDetail{}, err
+	default:
+		if _, err := tx.Select(&previous, `SELECT `+snapshotColumns+` FROM public.ranking_snapshot_rows WHERE snapshot_id = $1 ORDER BY idsatker, id`, latest.ID); err != nil {
+			tx.Rollback()
+			return RankingSnapshotDetail{}, err
+		}
+	}
+
+	changelog := []RankingChange{}
+	if latest.ID != 0 {
+		changelog = RankingChangelog(previous, current)
+		if len(changelog) == 0 {
+			tx.Rollback()
+			return RankingSnapshotDetail{}, ErrSnapshotUnchanged
+		}
+	}
+	data, err := json.Marshal(changelog)
+	if err != nil {
+		tx.Rollback()
+		return RankingSnapshotDetail{}, err
+	}
+
+	snapshot := RankingSnapshot{
+		Tahun:       year,
+		Period:      periodType,
+		Periode:     periode,
+		Version:     latest.Version + 1,
+		Note:        note,
+		Rows:        len(current),
+		Changes:     len(changelog),
+		LastUpdate:  lastUpdate,
+		PublishedAt: time.Now().Unix(),
+	}
+	if userID != 0 {
+		snapshot.PublishedBy = &userID
+	}
+	err = tx.QueryRow(`INSERT INTO public.ranking_snapshots
+			(tahun, period, periode, version, note, row_count, changes, changelog, last_update, published_by, published_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11) RETURNING id`,
+		snapshot.Tahun, snapshot.Period, snapshot.Periode, snapshot.Version, snapshot.Note, snapshot.Rows,
+		snapshot.Changes, string(data), snapshot.LastUpdate, snapshot.PublishedBy, snapshot.PublishedAt).Scan(&snapshot.ID)
+	if err != nil {
+		tx.Rollback()
+		return RankingSnapshotDetail{}, err
+	}
+
+	for _, r := range current {
+		_, err := tx.Exec(`INSERT INTO public.ranking_snapshot_rows (snapshot_id, `+snapshotColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`,
+			snapshot.ID, r.ID, r.Idsatker, r.NamaOpd, r.JenisOpd,
+			r.CapaianOpd, r.CapaianBarjas, r.CapaianFisik, r.CapaianAnggaran, r.CapaianKinerja,
+			r.KumulatifOpd, r.KumulatifBarjas, r.KumulatifFisik, r.KumulatifAnggaran, r.KumulatifKinerja,
+			r.PeriodikOpd, r.PeriodikBarjas, r.PeriodikFisik, r.PeriodikAnggaran, r.PeriodikKinerja,
+			r.PeringkatOpd, r.Tahun, r.Bulan)
+		if err != nil {
+			tx.Rollback()
+			return RankingSnapshotDetail{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RankingSnapshotDetail{}, err
+	}
+	return RankingSnapshotDetail{RankingSnapshot: snapshot, Changelog: changelog}, nil
+}
+
+// All lists the published versions of a year, optionally of one period, newest first
+func (m RankingSnapshotModel) All(year int, periodType string, periode int) ([]RankingSnapshot, error) {
+	snapshots := []RankingSnapshot{}
+	_, err := db.GetDB().Select(&snapshots, `SELECT id, tahun, period, periode, version, COALESCE(note, '') AS note, row_count, changes, last_update, published_by, published_at
+		FROM public.ranking_snapshots
+		WHERE tahun = $1 AND ($2 = '' OR period = $2) AND ($3 = 0 OR periode = $3)
+		ORDER BY period, periode, version DESC`, year, periodType, periode)
+	return snapshots, err
+}
+
+// One returns a snapshot with its changelog
+func (m RankingSnapshotModel) One(id int64) (RankingSnapshotDetail, error) {
+	var detail RankingSnapshotDetail
+	var changelog []byte
+	err := db.GetDB().QueryRow(`SELECT id, tahun, period, periode, version, COALESCE(note, ''), row_count, changes, changelog, last_update, published_by, published_at
+		FROM public.ranking_snapshots WHERE id = $1`, id).Scan(
+		&detail.ID, &detail.Tahun, &detail.Period, &detail.Periode, &detail.Version, &detail.Note, &detail.Rows,
+		&detail.Changes, &changelog, &detail.LastUpdate, &detail.PublishedBy, &detail.PublishedAt)
+	if err != nil {
+		return detail, err
+	}
+	detail.Changelog = []RankingChange{}
+	if len(changelog) > 0 {
+		err = json.Unmarshal(changelog, &detail.Changelog)
+	}
+	return detail, err
+}
+
+// servedSnapshot is the snapshot a ranking is served from: the requested version, or the latest
+// one when version is 0. It is nil for the live ranking, when live is requested, the ranking spans
+// the whole year, or nothing was published for the period yet.
+func servedSnapshot(year, month int, period Period, live bool, version int) (*RankingSnapshot, error) {
+	periodType, periode, ok := snapshotKey(month, period)
+	if live || !ok {
+		if version > 0 {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, nil
+	}
+
+	var snapshot RankingSnapshot
+	err := db.GetDB().SelectOne(&snapshot, `SELECT id, tahun, period, periode, version, COALESCE(note, '') AS note, row_count, changes, last_update, published_by, published_at
+		FROM public.ranking_snapshots
+		WHERE tahun = $1 AND period = $2 AND periode = $3 AND ($4 = 0 OR version = $4)
+		ORDER BY version DESC LIMIT 1`, year, periodType, periode, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		if version > 0 {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
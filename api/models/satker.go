@@ -0,0 +1,272 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+
+	"github.com/lib/pq"
+)
+
+// Satker registry errors
+var (
+	ErrSatkerIDRequired = errors.New("idsatker is required")
+	ErrSatkerExists     = errors.New("satker already exists")
+	ErrSatkerParent     = errors.New("parent satker does not exist or would create a cycle")
+	ErrSatkerPeriod     = errors.New("active_until should not be before active_from")
+)
+
+// Satker is the master record of a satker/OPD. The de_* tables only carry copies of the
+// name and jenis_opd, which drift from month to month, the registry is the reference.
+type Satker struct {
+	Idsatker       int64  `db:"idsatker" json:"idsatker"`
+	IdSkpd         int64  `db:"id_skpd" json:"id_skpd,omitempty"`
+	Code           string `db:"code" json:"code,omitempty"`
+	Name           string `db:"name" json:"name"`
+	ShortName      string `db:"short_name" json:"short_name,omitempty"`
+	JenisOpd       string `db:"jenis_opd" json:"jenis_opd"`
+	ParentIdsatker int64  `db:"parent_idsatker" json:"parent_idsatker,omitempty"`
+	ParentName     string `db:"parent_name" json:"parent_name,omitempty"`
+	KodeGadm       string `db:"kode_gadm" json:"kode_gadm,omitempty"`
+	HeadName       string `db:"head_name" json:"head_name,omitempty"`
+	HeadNip        string `db:"head_nip" json:"head_nip,omitempty"`
+	HeadTitle      string `db:"head_title" json:"head_title,omitempty"`
+	ActiveFrom     string `db:"active_from" json:"active_from,omitempty"`   // YYYY-MM-DD
+	ActiveUntil    string `db:"active_until" json:"active_until,omitempty"` // YYYY-MM-DD, empty while active
+	UpdatedAt      int64  `db:"updated_at" json:"updated_at"`
+	CreatedAt      int64  `db:"created_at" json:"created_at"`
+}
+
+// SatkerDetail is a satker with the satkers directly below it
+type SatkerDetail struct {
+	Satker
+	Children []Satker `json:"children"`
+}
+
+// SatkerOption is one entry of the lookup used by the frontend filters
+type SatkerOption struct {
+	Idsatker  int64  `db:"idsatker" json:"idsatker"`
+	Code      string `db:"code" json:"code,omitempty"`
+	Name      string `db:"name" json:"name"`
+	ShortName string `db:"short_name" json:"short_name,omitempty"`
+	JenisOpd  string `db:"jenis_opd" json:"jenis_opd"`
+}
+
+// SatkerName is the registry name joined into realisasi and ranking responses
+type SatkerName struct {
+	Name      string `db:"name"`
+	ShortName string `db:"short_name"`
+}
+
+// SatkerPage ...
+type SatkerPage struct {
+	Data []Satker `json:"data"`
+	Meta Meta     `json:"meta"`
+}
+
+// SatkerModel ...
+type SatkerModel struct{}
+
+// satkerColumns ...
+const satkerColumns = `s.idsatker, COALESCE(s.id_skpd, 0) AS id_skpd, COALESCE(s.code, '') AS code, s.name,
+	COALESCE(s.short_name, '') AS short_name, s.jenis_opd, COALESCE(s.parent_idsatker, 0) AS parent_idsatker,
+	COALESCE(p.name, '') AS parent_name, COALESCE(s.kode_gadm, '') AS kode_gadm, COALESCE(s.head_name, '') AS head_name,
+	COALESCE(s.head_nip, '') AS head_nip, COALESCE(s.head_title, '') AS head_title,
+	COALESCE(to_char(s.active_from, 'YYYY-MM-DD'), '') AS active_from,
+	COALESCE(to_char(s.active_until, 'YYYY-MM-DD'), '') AS active_until, s.updated_at, s.created_at`
+
+// satkerFrom ...
+const satkerFrom = ` FROM public.satkers s LEFT JOIN public.satkers p ON p.idsatker = s.parent_idsatker `
+
+// satkerWhere filters on $1 search, $2 jenis_opd, $3 parent and $4 a year the satker was active in
+const satkerWhere = `WHERE ($1 = '' OR s.name ILIKE '%' || $1 || '%' OR s.short_name ILIKE '%' || $1 || '%' OR s.code ILIKE $1 || '%')
+	AND ($2 = '' OR s.jenis_opd = $2) AND ($3 = 0 OR s.parent_idsatker = $3)
+	AND ($4 = 0 OR ((s.active_from IS NULL OR EXTRACT(YEAR FROM s.active_from) <= $4)
+		AND (s.active_until IS NULL OR EXTRACT(YEAR FROM s.active_until) >= $4)))`
+
+// SatkerCreatesCycle reports whether making parent the parent of idsatker would loop the
+// hierarchy. parents maps every satker to its current parent, 0 for a root.
+func SatkerCreatesCycle(parents map[int64]int64, idsatker, parent int64) bool {
+	seen := map[int64]bool{}
+	for current := parent; current != 0; current = parents[current] {
+		if current == idsatker || seen[current] {
+			return true
+		}
+		seen[current] = true
+	}
+	return false
+}
+
+// All lists the registry ordered by name
+func (m SatkerModel) All(form forms.SatkerListForm) (page SatkerPage, err error) {
+	if form.Page == 0 {
+		form.Page = 1
+	}
+	if form.Limit == 0 {
+		form.Limit = 20
+	}
+	args := []interface{}{strings.TrimSpace(form.Q), form.JenisOpd, form.ParentIdsatker, form.Tahun}
+
+	total, err := db.GetDB().SelectInt(`SELECT COUNT(*)`+satkerFrom+satkerWhere, args...)
+	if err != nil {
+		return page, err
+	}
+
+	page.Data = []Satker{}
+	_, err = db.GetDB().Select(&page.Data, `SELECT `+satkerColumns+satkerFrom+satkerWhere+`
+		ORDER BY s.jenis_opd, s.name, s.idsatker LIMIT $5 OFFSET $6`,
+		append(args, form.Limit, (form.Page-1)*form.Limit)...)
+	if err != nil {
+		return page, err
+	}
+
+	page.Meta = Meta{Total: int(total), Page: form.Page, Limit: form.Limit}
+	return page, nil
+}
+
+// Lookup returns the options matching the filters for autocomplete, codes starting with the
+// search first
+func (m SatkerModel) Lookup(form forms.SatkerListForm) (options []SatkerOption, err error) {
+	if form.Limit == 0 {
+		form.Limit = 20
+	}
+	q := strings.TrimSpace(form.Q)
+
+	options = []SatkerOption{}
+	_, err = db.GetDB().Select(&options, `SELECT s.idsatker, COALESCE(s.code, '') AS code, s.name,
+			COALESCE(s.short_name, '') AS short_name, s.jenis_opd`+satkerFrom+satkerWhere+`
+		ORDER BY ($1 <> '' AND s.code ILIKE $1 || '%') DESC, s.name, s.idsatker LIMIT $5`,
+		q, form.JenisOpd, form.ParentIdsatker, form.Tahun, form.Limit)
+	return options, err
+}
+
+// One returns a satker with its children
+func (m SatkerModel) One(idsatker int64) (satker SatkerDetail, err error) {
+	err = db.GetDB().SelectOne(&satker.Satker, `SELECT `+satkerColumns+satkerFrom+`WHERE s.idsatker=$1 LIMIT 1`, idsatker)
+	if err != nil {
+		return satker, err
+	}
+
+	satker.Children = []Satker{}
+	_, err = db.GetDB().Select(&satker.Children, `SELECT `+satkerColumns+satkerFrom+`WHERE s.parent_idsatker=$1 ORDER BY s.name`, idsatker)
+	return satker, err
+}
+
+// checkParent rejects a parent that does not exist or sits below the satker
+func (m SatkerModel) checkParent(idsatker, parent int64) error {
+	if parent == 0 {
+		return nil
+	}
+
+	var links []struct {
+		Idsatker int64 `db:"idsatker"`
+		Parent   int64 `db:"parent_idsatker"`
+	}
+	_, err := db.GetDB().Select(&links, `SELECT idsatker, COALESCE(parent_idsatker, 0) AS parent_idsatker FROM public.satkers`)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[int64]int64, len(links))
+	for _, link := range links {
+		parents[link.Idsatker] = link.Parent
+	}
+	if _, ok := parents[parent]; !ok || SatkerCreatesCycle(parents, idsatker, parent) {
+		return ErrSatkerParent
+	}
+	return nil
+}
+
+// checkSatker validates what the form binding cannot
+func (m SatkerModel) checkSatker(idsatker int64, form forms.SatkerDataForm) error {
+	if form.ActiveFrom != "" && form.ActiveUntil != "" && form.ActiveUntil < form.ActiveFrom {
+		return ErrSatkerPeriod
+	}
+	return m.checkParent(idsatker, form.ParentIdsatker)
+}
+
+// Create ...
+func (m SatkerModel) Create(form forms.SatkerDataForm) (satker SatkerDetail, err error) {
+	if form.Idsatker == 0 {
+		return satker, ErrSatkerIDRequired
+	}
+	if err = m.checkSatker(form.Idsatker, form); err != nil {
+		return satker, err
+	}
+
+	now := time.Now().Unix()
+	operation, err := db.GetDB().Exec(`INSERT INTO public.satkers
+			(idsatker, id_skpd, code, name, short_name, jenis_opd, parent_idsatker, kode_gadm, head_name, head_nip, head_title,
+			active_from, active_until, updated_at, created_at)
+			VALUES ($1, NULLIF($2, 0), NULLIF($3, ''), $4, NULLIF($5, ''), $6, NULLIF($7, 0), NULLIF($8, ''), NULLIF($9, ''),
+			NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, '')::DATE, NULLIF($13, '')::DATE, $14, $14)
+			ON CONFLICT (idsatker) DO NOTHING`,
+		form.Idsatker, form.IdSkpd, form.Code, form.Name, form.ShortName, form.JenisOpd, form.ParentIdsatker, form.KodeGadm,
+		form.HeadName, form.HeadNip, form.HeadTitle, form.ActiveFrom, form.ActiveUntil, now)
+	if err != nil {
+		return satker, err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return satker, ErrSatkerExists
+	}
+	return m.One(form.Idsatker)
+}
+
+// Update replaces the record, the idsatker in the form is ignored
+func (m SatkerModel) Update(idsatker int64, form forms.SatkerDataForm) (satker SatkerDetail, err error) {
+	if err = m.checkSatker(idsatker, form); err != nil {
+		return satker, err
+	}
+
+	operation, err := db.GetDB().Exec(`UPDATE public.satkers SET id_skpd=NULLIF($2, 0), code=NULLIF($3, ''), name=$4,
+			short_name=NULLIF($5, ''), jenis_opd=$6, parent_idsatker=NULLIF($7, 0), kode_gadm=NULLIF($8, ''),
+			head_name=NULLIF($9, ''), head_nip=NULLIF($10, ''), head_title=NULLIF($11, ''),
+			active_from=NULLIF($12, '')::DATE, active_until=NULLIF($13, '')::DATE, updated_at=$14 WHERE idsatker=$1`,
+		idsatker, form.IdSkpd, form.Code, form.Name, form.ShortName, form.JenisOpd, form.ParentIdsatker, form.KodeGadm,
+		form.HeadName, form.HeadNip, form.HeadTitle, form.ActiveFrom, form.ActiveUntil, time.Now().Unix())
+	if err != nil {
+		return satker, err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return satker, sql.ErrNoRows
+	}
+	return m.One(idsatker)
+}
+
+// Delete removes a satker, its children become roots. The de_* rows are left untouched.
+func (m SatkerModel) Delete(idsatker int64) error {
+	operation, err := db.GetDB().Exec(`DELETE FROM public.satkers WHERE idsatker=$1`, idsatker)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SatkerNames returns the registry names of the satkers, all of them when ids is empty.
+// Responses fall back to the names copied in the de_* rows, so errors are only logged.
+func SatkerNames(ids []int64) map[int64]SatkerName {
+	var rows []struct {
+		Idsatker int64 `db:"idsatker"`
+		SatkerName
+	}
+	_, err := db.GetDB().Select(&rows, `SELECT idsatker, name, COALESCE(short_name, '') AS short_name FROM public.satkers
+		WHERE cardinality($1::BIGINT[]) = 0 OR idsatker = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		log.Printf("SatkerNames: %v", err)
+		return map[int64]SatkerName{}
+	}
+
+	names := make(map[int64]SatkerName, len(rows))
+	for _, row := range rows {
+		names[row.Idsatker] = row.SatkerName
+	}
+	return names
+}
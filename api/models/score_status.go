@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+
+	"github.com/lib/pq"
+)
+
+// ErrScoreThresholdExists ...
+var ErrScoreThresholdExists = errors.New("a threshold with this min_score already exists for the tahun_from, category and jenis_opd")
+
+// ScoreThreshold is one band of a score-status policy: scores from min_score up to the next band
+// get the label. The bands sharing tahun_from, category and jenis_opd form a set.
+type ScoreThreshold struct {
+	ID        int64   `db:"id" json:"id"`
+	TahunFrom int     `db:"tahun_from" json:"tahun_from"`
+	Category  string  `db:"category" json:"category,omitempty"`   // empty for every category
+	JenisOpd  string  `db:"jenis_opd" json:"jenis_opd,omitempty"` // empty for every jenis_opd
+	MinScore  float64 `db:"min_score" json:"min_score"`
+	Label     string  `db:"label" json:"label"`
+	Color     string  `db:"color" json:"color,omitempty"`
+	UpdatedAt int64   `db:"updated_at" json:"updated_at,omitempty"`
+	CreatedAt int64   `db:"created_at" json:"created_at,omitempty"`
+}
+
+// ScoreStatus is the band a score falls in
+type ScoreStatus struct {
+	Label string `json:"label"`
+	Color string `json:"color,omitempty"`
+}
+
+// ScoreThresholds is the whole policy, every set of every year
+type ScoreThresholds []ScoreThreshold
+
+// defaultScoreThresholds apply when no set matches, the seed of migration 18
+var defaultScoreThresholds = ScoreThresholds{
+	{MinScore: 0, Label: "Diam", Color: "#dc2626"},
+	{MinScore: 25, Label: "Berjalan", Color: "#f59e0b"},
+	{MinScore: 50, Label: "Berlari", Color: "#2563eb"},
+	{MinScore: 75, Label: "Melesat", Color: "#16a34a"},
+}
+
+// ScoreThresholdModel ...
+type ScoreThresholdModel struct{}
+
+// Bands returns the set applying to a ranking of the year, category and jenis_opd, lowest band
+// first. Only the matching sets of the latest tahun_from not after the year are candidates, so a
+// newer general set replaces older overrides. Among those the most specific set wins, category and
+// jenis_opd over category over jenis_opd over the general set. Without any, the defaults.
+func (t ScoreThresholds) Bands(tahun int, category, jenisOpd string) ScoreThresholds {
+	matches := func(threshold ScoreThreshold) bool {
+		return threshold.TahunFrom <= tahun &&
+			(threshold.Category == "" || threshold.Category == category) &&
+			(threshold.JenisOpd == "" || threshold.JenisOpd == jenisOpd)
+	}
+	specificity := func(threshold ScoreThreshold) int {
+		score := 0
+		if threshold.Category != "" {
+			score += 2
+		}
+		if threshold.JenisOpd != "" {
+			score++
+		}
+		return score
+	}
+
+	latest, found := 0, false
+	for _, threshold := range t {
+		if matches(threshold) && (!found || threshold.TahunFrom > latest) {
+			latest, found = threshold.TahunFrom, true
+		}
+	}
+	var best *ScoreThreshold
+	for i, threshold := range t {
+		if !matches(threshold) || threshold.TahunFrom != latest {
+			continue
+		}
+		if best == nil || specificity(threshold) > specificity(*best) {
+			best = &t[i]
+		}
+	}
+	if best == nil {
+		return defaultScoreThresholds
+	}
+
+	var bands ScoreThresholds
+	for _, threshold := range t {
+		if threshold.TahunFrom == best.TahunFrom && threshold.Category == best.Category && threshold.JenisOpd == best.JenisOpd {
+			bands = append(bands, threshold)
+		}
+	}
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].MinScore < bands[j].MinScore })
+	return bands
+}
+
+// Status is the band of the score in the set applying to the ranking. Scores below the lowest
+// band take the lowest band.
+func (t ScoreThresholds) Status(tahun int, category, jenisOpd string, score float64) ScoreStatus {
+	bands := t.Bands(tahun, category, jenisOpd)
+	band := bands[0]
+	for _, threshold := range bands[1:] {
+		if score >= threshold.MinScore {
+			band = threshold
+		}
+	}
+	return ScoreStatus{Label: band.Label, Color: band.Color}
+}
+
+// LoadScoreThresholds reads the policy. Rankings fall back to the defaults, so errors are only logged.
+func LoadScoreThresholds() ScoreThresholds {
+	var thresholds ScoreThresholds
+	_, err := db.GetDB().Select(&thresholds, `SELECT `+scoreThresholdColumns+` FROM public.score_status_thresholds`)
+	if err != nil {
+		log.Printf("LoadScoreThresholds: %v", err)
+		return nil
+	}
+	return thresholds
+}
+
+// scoreThresholdColumns ...
+const scoreThresholdColumns = `id, tahun_from, COALESCE(category, '') AS category, COALESCE(jenis_opd, '') AS jenis_opd,
+	min_score, label, COALESCE(color, '') AS color, updated_at, created_at`
+
+// All lists the thresholds by set and band, filtered on tahun_from, category and jenis_opd
+func (m ScoreThresholdModel) All(form forms.ScoreThresholdListForm) (thresholds []ScoreThreshold, err error) {
+	thresholds = []ScoreThreshold{}
+	_, err = db.GetDB().Select(&thresholds, `SELECT `+scoreThresholdColumns+` FROM public.score_status_thresholds
+		WHERE ($1 = 0 OR tahun_from = $1) AND ($2 = '' OR COALESCE(category, '') = $2) AND ($3 = '' OR COALESCE(jenis_opd, '') = $3)
+		ORDER BY tahun_from DESC, category NULLS FIRST, jenis_opd NULLS FIRST, min_score`,
+		form.TahunFrom, form.Category, form.JenisOpd)
+	return thresholds, err
+}
+
+// Resolve returns the bands applying to a ranking
+func (m ScoreThresholdModel) Resolve(form forms.ScoreThresholdResolveForm) ScoreThresholds {
+	return LoadScoreThresholds().Bands(form.Tahun, form.Category, form.JenisOpd)
+}
+
+// One ...
+func (m ScoreThresholdModel) One(id int64) (threshold ScoreThreshold, err error) {
+	err = db.GetDB().SelectOne(&threshold, `SELECT `+scoreThresholdColumns+` FROM public.score_status_thresholds WHERE id=$1`, id)
+	return threshold, err
+}
+
+// isUniqueViolation ...
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Create ...
+func (m ScoreThresholdModel) Create(form forms.ScoreThresholdForm) (threshold ScoreThreshold, err error) {
+	now := time.Now().Unix()
+	var id int64
+	err = db.GetDB().QueryRow(`INSERT INTO public.score_status_thresholds
+			(tahun_from, category, jenis_opd, min_score, label, color, updated_at, created_at)
+			VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7, $7) RETURNING id`,
+		form.TahunFrom, form.Category, form.JenisOpd, *form.MinScore, form.Label, form.Color, now).Scan(&id)
+	if isUniqueViolation(err) {
+		return threshold, ErrScoreThresholdExists
+	}
+	if err != nil {
+		return threshold, err
+	}
+	return m.One(id)
+}
+
+// Update ...
+func (m ScoreThresholdModel) Update(id int64, form forms.ScoreThresholdForm) (threshold ScoreThreshold, err error) {
+	operation, err := db.GetDB().Exec(`UPDATE public.score_status_thresholds SET tahun_from=$2, category=NULLIF($3, ''),
+			jenis_opd=NULLIF($4, ''), min_score=$5, label=$6, color=NULLIF($7, ''), updated_at=$8 WHERE id=$1`,
+		id, form.TahunFrom, form.Category, form.JenisOpd, *form.MinScore, form.Label, form.Color, time.Now().Unix())
+	if isUniqueViolation(err) {
+		return threshold, ErrScoreThresholdExists
+	}
+	if err != nil {
+		return threshold, err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return threshold, sql.ErrNoRows
+	}
+	return m.One(id)
+}
+
+// Delete ...
+func (m ScoreThresholdModel) Delete(id int64) error {
+	operation, err := db.GetDB().Exec(`DELETE FROM public.score_status_thresholds WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -276,6 +276,727 @@ var migrations = []Migration{
 			return nil
 		},
 	},
+	{
+		Version: 3,
+		Name:    "create_impersonation_logs_table",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.impersonation_logs (
+					id SERIAL PRIMARY KEY,
+					actor_id INTEGER NOT NULL REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					subject_id INTEGER NOT NULL REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					access_uuid TEXT NOT NULL,
+					event TEXT NOT NULL,
+					method TEXT NOT NULL DEFAULT '',
+					path TEXT NOT NULL DEFAULT '',
+					status INTEGER NOT NULL DEFAULT 0,
+					request_id TEXT NOT NULL DEFAULT '',
+					client_ip TEXT NOT NULL DEFAULT '',
+					created_at INTEGER
+				);
+				CREATE INDEX IF NOT EXISTS impersonation_logs_actor_id_idx ON public.impersonation_logs (actor_id);
+				CREATE INDEX IF NOT EXISTS impersonation_logs_subject_id_idx ON public.impersonation_logs (subject_id);
+				INSERT INTO public.permissions (name)
+				SELECT 'impersonate_users' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'impersonate_users');
+				INSERT INTO public.permissions (name)
+				SELECT 'impersonate_users_write' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'impersonate_users_write');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create impersonation_logs table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.impersonation_logs`)
+			if err != nil {
+				return fmt.Errorf("failed to drop impersonation_logs table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_article_search_vector",
+		UpFunc: func() error {
+			// 'simple' config: the content is mostly Indonesian, which has no built-in stemmer
+			_, err := db.GetDB().Db.Exec(`
+				ALTER TABLE public.article ADD COLUMN IF NOT EXISTS search_vector tsvector
+					GENERATED ALWAYS AS (
+						setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+						setweight(to_tsvector('simple', COALESCE(content, '')), 'B')
+					) STORED;
+				CREATE INDEX IF NOT EXISTS article_search_vector_idx ON public.article USING GIN (search_vector);
+				CREATE INDEX IF NOT EXISTS article_user_id_idx ON public.article (user_id, id);
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to add article search_vector: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				DROP INDEX IF EXISTS article_user_id_idx;
+				DROP INDEX IF EXISTS article_search_vector_idx;
+				ALTER TABLE public.article DROP COLUMN IF EXISTS search_vector;
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to drop article search_vector: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_article_publishing_workflow",
+		UpFunc: func() error {
+			// Existing articles become drafts, which keeps them visible to their owner only
+			_, err := db.GetDB().Db.Exec(`
+				ALTER TABLE public.article
+					ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'draft'
+						CHECK (status IN ('draft', 'in_review', 'scheduled', 'published', 'archived')),
+					ADD COLUMN IF NOT EXISTS publish_at INTEGER,
+					ADD COLUMN IF NOT EXISTS expire_at INTEGER,
+					ADD COLUMN IF NOT EXISTS published_at INTEGER,
+					ADD COLUMN IF NOT EXISTS reviewer_id INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					ADD COLUMN IF NOT EXISTS reviewed_at INTEGER,
+					ADD COLUMN IF NOT EXISTS review_note TEXT;
+				CREATE INDEX IF NOT EXISTS article_status_publish_at_idx ON public.article (status, publish_at);
+				INSERT INTO public.permissions (name)
+				SELECT 'review_article' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'review_article');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to add article workflow columns: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				DROP INDEX IF EXISTS article_status_publish_at_idx;
+				ALTER TABLE public.article
+					DROP COLUMN IF EXISTS status,
+					DROP COLUMN IF EXISTS publish_at,
+					DROP COLUMN IF EXISTS expire_at,
+					DROP COLUMN IF EXISTS published_at,
+					DROP COLUMN IF EXISTS reviewer_id,
+					DROP COLUMN IF EXISTS reviewed_at,
+					DROP COLUMN IF EXISTS review_note;
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to drop article workflow columns: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_article_revisions_table",
+		UpFunc: func() error {
+			// Revisions are append-only, the trigger rejects any UPDATE.
+			// Existing articles get their current state as revision 1.
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.article_revisions (
+					id SERIAL PRIMARY KEY,
+					article_id INTEGER NOT NULL REFERENCES public.article (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					revision INTEGER NOT NULL,
+					title TEXT NOT NULL DEFAULT '',
+					content TEXT NOT NULL DEFAULT '',
+					author_id INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					note TEXT,
+					created_at INTEGER,
+					UNIQUE (article_id, revision)
+				);
+				CREATE OR REPLACE FUNCTION article_revisions_immutable() RETURNS trigger
+					LANGUAGE plpgsql
+					AS $$
+				BEGIN
+					RAISE EXCEPTION 'article revisions are immutable';
+				END;
+				$$;
+				DROP TRIGGER IF EXISTS article_revisions_no_update ON public.article_revisions;
+				CREATE TRIGGER article_revisions_no_update BEFORE UPDATE ON public.article_revisions
+					FOR EACH ROW EXECUTE PROCEDURE article_revisions_immutable();
+				INSERT INTO public.article_revisions (article_id, revision, title, content, author_id, note, created_at)
+				SELECT a.id, 1, COALESCE(a.title, ''), COALESCE(a.content, ''), a.user_id, 'initial import', COALESCE(a.updated_at, a.created_at)
+				FROM public.article a
+				WHERE NOT EXISTS (SELECT 1 FROM public.article_revisions r WHERE r.article_id = a.id);
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create article_revisions table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				DROP TABLE IF EXISTS public.article_revisions;
+				DROP FUNCTION IF EXISTS article_revisions_immutable();
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to drop article_revisions table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create_article_attachments_table",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.article_attachments (
+					id SERIAL PRIMARY KEY,
+					article_id INTEGER NOT NULL REFERENCES public.article (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					user_id INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					filename VARCHAR(255) NOT NULL,
+					content_type VARCHAR(255) NOT NULL,
+					size BIGINT NOT NULL,
+					storage_key TEXT NOT NULL UNIQUE,
+					created_at INTEGER
+				);
+				CREATE INDEX IF NOT EXISTS article_attachments_article_id_idx ON public.article_attachments (article_id);
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create article_attachments table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.article_attachments;`)
+			if err != nil {
+				return fmt.Errorf("failed to drop article_attachments table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_article_targeting_and_read_receipts",
+		UpFunc: func() error {
+			// Users belong to at most one OPD. An article without audiences is addressed to all users.
+			_, err := db.GetDB().Db.Exec(`
+				ALTER TABLE public."user"
+					ADD COLUMN IF NOT EXISTS idsatker INTEGER,
+					ADD COLUMN IF NOT EXISTS jenis_opd VARCHAR(20);
+				CREATE TABLE IF NOT EXISTS public.article_categories (
+					id SERIAL PRIMARY KEY,
+					name VARCHAR(50) NOT NULL UNIQUE,
+					created_at INTEGER
+				);
+				ALTER TABLE public.article
+					ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES public.article_categories (id) ON UPDATE CASCADE ON DELETE SET NULL;
+				CREATE TABLE IF NOT EXISTS public.article_tags (
+					article_id INTEGER NOT NULL REFERENCES public.article (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					tag VARCHAR(50) NOT NULL,
+					PRIMARY KEY (article_id, tag)
+				);
+				CREATE INDEX IF NOT EXISTS article_tags_tag_idx ON public.article_tags (tag);
+				CREATE TABLE IF NOT EXISTS public.article_audiences (
+					id SERIAL PRIMARY KEY,
+					article_id INTEGER NOT NULL REFERENCES public.article (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					type VARCHAR(20) NOT NULL CHECK (type IN ('all', 'role', 'jenis_opd', 'idsatker')),
+					value VARCHAR(100) NOT NULL DEFAULT '',
+					UNIQUE (article_id, type, value)
+				);
+				CREATE TABLE IF NOT EXISTS public.article_reads (
+					article_id INTEGER NOT NULL REFERENCES public.article (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					user_id INTEGER NOT NULL REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					read_at INTEGER NOT NULL,
+					PRIMARY KEY (article_id, user_id)
+				);
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create article targeting tables: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				DROP TABLE IF EXISTS public.article_reads;
+				DROP TABLE IF EXISTS public.article_audiences;
+				DROP TABLE IF EXISTS public.article_tags;
+				ALTER TABLE public.article DROP COLUMN IF EXISTS category_id;
+				DROP TABLE IF EXISTS public.article_categories;
+				ALTER TABLE public."user" DROP COLUMN IF EXISTS idsatker, DROP COLUMN IF EXISTS jenis_opd;
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to drop article targeting tables: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 9,
+		Name:    "create_article_editors_table",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.article_editors (
+					article_id INTEGER NOT NULL REFERENCES public.article (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					user_id INTEGER NOT NULL REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					added_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					created_at INTEGER,
+					PRIMARY KEY (article_id, user_id)
+				);
+				CREATE INDEX IF NOT EXISTS article_editors_user_id_idx ON public.article_editors (user_id);
+				INSERT INTO public.permissions (name)
+				SELECT 'edit_any_article' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'edit_any_article');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create article_editors table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.article_editors`)
+			if err != nil {
+				return fmt.Errorf("failed to drop article_editors table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_article_content_html",
+		UpFunc: func() error {
+			// Rendering happens in Go, existing articles are rendered here once
+			if _, err := db.GetDB().Db.Exec(`ALTER TABLE public.article ADD COLUMN IF NOT EXISTS content_html TEXT`); err != nil {
+				return fmt.Errorf("failed to add content_html column: %v", err)
+			}
+
+			var articles []struct {
+				ID      int64  `db:"id"`
+				Content string `db:"content"`
+			}
+			if _, err := db.GetDB().Select(&articles, `SELECT id, COALESCE(content, '') AS content FROM public.article WHERE content_html IS NULL`); err != nil {
+				return fmt.Errorf("failed to read articles: %v", err)
+			}
+			for _, article := range articles {
+				contentHTML, err := RenderMarkdown(article.Content)
+				if err != nil {
+					return fmt.Errorf("failed to render article %d: %v", article.ID, err)
+				}
+				if _, err := db.GetDB().Exec(`UPDATE public.article SET content_html=$2 WHERE id=$1`, article.ID, contentHTML); err != nil {
+					return fmt.Errorf("failed to store article %d html: %v", article.ID, err)
+				}
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`ALTER TABLE public.article DROP COLUMN IF EXISTS content_html`)
+			if err != nil {
+				return fmt.Errorf("failed to drop content_html column: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 11,
+		Name:    "create_alert_tables",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.alert_rules (
+					id SERIAL PRIMARY KEY,
+					name TEXT NOT NULL,
+					description TEXT,
+					metric TEXT NOT NULL,
+					operator TEXT NOT NULL CHECK (operator IN ('lt', 'lte', 'gt', 'gte', 'eq', 'any', 'increase', 'decrease')),
+					threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+					min_bulan INTEGER NOT NULL DEFAULT 0,
+					jenis_opd TEXT,
+					severity TEXT NOT NULL DEFAULT 'warning' CHECK (severity IN ('info', 'warning', 'critical')),
+					enabled BOOLEAN NOT NULL DEFAULT TRUE,
+					created_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					updated_at INTEGER,
+					created_at INTEGER
+				);
+				CREATE TABLE IF NOT EXISTS public.alerts (
+					id SERIAL PRIMARY KEY,
+					rule_id INTEGER NOT NULL REFERENCES public.alert_rules (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					idsatker BIGINT NOT NULL,
+					nama_opd TEXT,
+					tahun INTEGER NOT NULL,
+					bulan INTEGER NOT NULL,
+					value DOUBLE PRECISION NOT NULL,
+					previous DOUBLE PRECISION,
+					message TEXT NOT NULL,
+					state TEXT NOT NULL DEFAULT 'open' CHECK (state IN ('open', 'acknowledged', 'resolved')),
+					note TEXT,
+					acknowledged_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					acknowledged_at INTEGER,
+					resolved_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					resolved_at INTEGER,
+					updated_at INTEGER,
+					created_at INTEGER
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS alerts_unresolved_idx ON public.alerts (rule_id, idsatker, tahun) WHERE state <> 'resolved';
+				CREATE INDEX IF NOT EXISTS alerts_idsatker_idx ON public.alerts (idsatker, state);
+				INSERT INTO public.permissions (name)
+				SELECT 'manage_alerts' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'manage_alerts');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create alert tables: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.alerts; DROP TABLE IF EXISTS public.alert_rules`)
+			if err != nil {
+				return fmt.Errorf("failed to drop alert tables: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 12,
+		Name:    "create_notification_tables",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.notifications (
+					id SERIAL PRIMARY KEY,
+					user_id INTEGER NOT NULL REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					event TEXT NOT NULL,
+					title TEXT NOT NULL,
+					body TEXT NOT NULL DEFAULT '',
+					data JSONB,
+					read_at INTEGER,
+					created_at INTEGER
+				);
+				CREATE INDEX IF NOT EXISTS notifications_user_id_idx ON public.notifications (user_id, id);
+				CREATE INDEX IF NOT EXISTS notifications_unread_idx ON public.notifications (user_id) WHERE read_at IS NULL;
+				CREATE TABLE IF NOT EXISTS public.notification_preferences (
+					user_id INTEGER PRIMARY KEY REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					inbox BOOLEAN NOT NULL DEFAULT TRUE,
+					email BOOLEAN NOT NULL DEFAULT TRUE,
+					webhook BOOLEAN NOT NULL DEFAULT FALSE,
+					webhook_url TEXT,
+					webhook_secret TEXT,
+					muted_events TEXT[] NOT NULL DEFAULT '{}',
+					updated_at INTEGER
+				);
+				CREATE TABLE IF NOT EXISTS public.notification_deliveries (
+					id SERIAL PRIMARY KEY,
+					user_id INTEGER NOT NULL REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					notification_id INTEGER REFERENCES public.notifications (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					channel TEXT NOT NULL,
+					event TEXT NOT NULL,
+					recipient TEXT NOT NULL,
+					subject TEXT NOT NULL,
+					body TEXT NOT NULL DEFAULT '',
+					data JSONB,
+					status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+					attempts INTEGER NOT NULL DEFAULT 0,
+					next_attempt_at INTEGER NOT NULL,
+					last_error TEXT,
+					sent_at INTEGER,
+					updated_at INTEGER,
+					created_at INTEGER
+				);
+				CREATE INDEX IF NOT EXISTS notification_deliveries_due_idx ON public.notification_deliveries (next_attempt_at) WHERE status = 'pending';
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create notification tables: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.notification_deliveries;
+				DROP TABLE IF EXISTS public.notification_preferences;
+				DROP TABLE IF EXISTS public.notifications`)
+			if err != nil {
+				return fmt.Errorf("failed to drop notification tables: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 13,
+		Name:    "seed_validation_permission",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				INSERT INTO public.permissions (name)
+				SELECT 'manage_validation' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'manage_validation');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to seed validation permission: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DELETE FROM public.permissions WHERE name = 'manage_validation'`)
+			if err != nil {
+				return fmt.Errorf("failed to remove validation permission: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 14,
+		Name:    "create_ranking_snapshot_tables",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.ranking_snapshots (
+					id SERIAL PRIMARY KEY,
+					tahun INTEGER NOT NULL,
+					period TEXT NOT NULL CHECK (period IN ('bulan', 'triwulan', 'semester')),
+					periode INTEGER NOT NULL,
+					version INTEGER NOT NULL,
+					note TEXT,
+					row_count INTEGER NOT NULL DEFAULT 0,
+					changes INTEGER NOT NULL DEFAULT 0,
+					changelog JSONB,
+					last_update BIGINT NOT NULL DEFAULT 0,
+					published_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					published_at INTEGER NOT NULL,
+					UNIQUE (tahun, period, periode, version)
+				);
+				CREATE TABLE IF NOT EXISTS public.ranking_snapshot_rows (
+					snapshot_id INTEGER NOT NULL REFERENCES public.ranking_snapshots (id) ON UPDATE CASCADE ON DELETE CASCADE,
+					id BIGINT NOT NULL,
+					idsatker BIGINT NOT NULL,
+					nama_opd TEXT NOT NULL DEFAULT '',
+					jenis_opd TEXT NOT NULL DEFAULT '',
+					capaian_opd DOUBLE PRECISION NOT NULL DEFAULT 0,
+					capaian_barjas DOUBLE PRECISION NOT NULL DEFAULT 0,
+					capaian_fisik DOUBLE PRECISION NOT NULL DEFAULT 0,
+					capaian_anggaran DOUBLE PRECISION NOT NULL DEFAULT 0,
+					capaian_kinerja DOUBLE PRECISION NOT NULL DEFAULT 0,
+					kumulatif_opd DOUBLE PRECISION NOT NULL DEFAULT 0,
+					kumulatif_barjas DOUBLE PRECISION NOT NULL DEFAULT 0,
+					kumulatif_fisik DOUBLE PRECISION NOT NULL DEFAULT 0,
+					kumulatif_anggaran DOUBLE PRECISION NOT NULL DEFAULT 0,
+					kumulatif_kinerja DOUBLE PRECISION NOT NULL DEFAULT 0,
+					periodik_opd DOUBLE PRECISION NOT NULL DEFAULT 0,
+					periodik_barjas DOUBLE PRECISION NOT NULL DEFAULT 0,
+					periodik_fisik DOUBLE PRECISION NOT NULL DEFAULT 0,
+					periodik_anggaran DOUBLE PRECISION NOT NULL DEFAULT 0,
+					periodik_kinerja DOUBLE PRECISION NOT NULL DEFAULT 0,
+					peringkat_opd BIGINT NOT NULL DEFAULT 0,
+					tahun INTEGER NOT NULL,
+					bulan INTEGER NOT NULL,
+					PRIMARY KEY (snapshot_id, id)
+				);
+				INSERT INTO public.permissions (name)
+				SELECT 'publish_ranking' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'publish_ranking');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create ranking snapshot tables: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.ranking_snapshot_rows; DROP TABLE IF EXISTS public.ranking_snapshots`)
+			if err != nil {
+				return fmt.Errorf("failed to drop ranking snapshot tables: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 15,
+		Name:    "create_satkers_table",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.satkers (
+					idsatker BIGINT PRIMARY KEY,
+					id_skpd BIGINT,
+					code TEXT,
+					name TEXT NOT NULL,
+					short_name TEXT,
+					jenis_opd TEXT NOT NULL CHECK (jenis_opd IN ('skpd', 'kecamatan')),
+					parent_idsatker BIGINT REFERENCES public.satkers (idsatker) ON UPDATE CASCADE ON DELETE SET NULL,
+					kode_gadm TEXT,
+					head_name TEXT,
+					head_nip TEXT,
+					head_title TEXT,
+					active_from DATE,
+					active_until DATE,
+					updated_at INTEGER NOT NULL,
+					created_at INTEGER NOT NULL,
+					CHECK (active_until IS NULL OR active_from IS NULL OR active_until >= active_from)
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS satkers_code_key ON public.satkers (code) WHERE code IS NOT NULL;
+				CREATE INDEX IF NOT EXISTS satkers_parent_idsatker_idx ON public.satkers (parent_idsatker);
+				-- Seed from the latest name each satker reported
+				INSERT INTO public.satkers (idsatker, id_skpd, name, jenis_opd, updated_at, created_at)
+				SELECT DISTINCT ON (idsatker) idsatker, NULLIF(id_skpd, 0), COALESCE(NULLIF(nama_opd, ''), 'Satker ' || idsatker),
+					jenis_opd, EXTRACT(EPOCH FROM NOW())::INTEGER, EXTRACT(EPOCH FROM NOW())::INTEGER
+				FROM de_ranking_opd
+				WHERE idsatker <> 0 AND jenis_opd IN ('skpd', 'kecamatan')
+				ORDER BY idsatker, tahun DESC, bulan DESC
+				ON CONFLICT (idsatker) DO NOTHING;
+				INSERT INTO public.permissions (name)
+				SELECT 'manage_satkers' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'manage_satkers');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create satkers table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.satkers`)
+			if err != nil {
+				return fmt.Errorf("failed to drop satkers table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 16,
+		Name:    "create_import_tables",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.import_runs (
+					id SERIAL PRIMARY KEY,
+					source TEXT NOT NULL,
+					dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+					full_sync BOOLEAN NOT NULL DEFAULT FALSE,
+					status TEXT NOT NULL CHECK (status IN ('success', 'failed', 'blocked')),
+					message TEXT,
+					fetched INTEGER NOT NULL DEFAULT 0,
+					inserted INTEGER NOT NULL DEFAULT 0,
+					updated INTEGER NOT NULL DEFAULT 0,
+					skipped INTEGER NOT NULL DEFAULT 0,
+					report JSONB,
+					run_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					started_at INTEGER NOT NULL,
+					finished_at INTEGER NOT NULL
+				);
+				CREATE TABLE IF NOT EXISTS public.import_cursors (
+					feed TEXT PRIMARY KEY,
+					last_modified BIGINT NOT NULL,
+					updated_at INTEGER NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS de_peta_detail_import_key_idx ON de_peta_detail (tahun, id_rup, id_kontrak);
+				CREATE INDEX IF NOT EXISTS de_detail_barjas_id_ranking_opd_idx ON de_detail_barjas (id_ranking_opd);
+				INSERT INTO public.permissions (name)
+				SELECT 'manage_imports' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'manage_imports');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create import tables: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.import_cursors; DROP TABLE IF EXISTS public.import_runs`)
+			if err != nil {
+				return fmt.Errorf("failed to drop import tables: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 17,
+		Name:    "create_job_runs_table",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.job_runs (
+					id SERIAL PRIMARY KEY,
+					job TEXT NOT NULL,
+					trigger TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+					status TEXT NOT NULL CHECK (status IN ('running', 'success', 'failed')),
+					output TEXT,
+					error TEXT,
+					instance TEXT NOT NULL,
+					triggered_by INTEGER REFERENCES public."user" (id) ON UPDATE CASCADE ON DELETE SET NULL,
+					started_at INTEGER NOT NULL,
+					finished_at INTEGER
+				);
+				CREATE INDEX IF NOT EXISTS job_runs_job_idx ON public.job_runs (job, id DESC);
+				INSERT INTO public.permissions (name)
+				SELECT 'manage_jobs' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'manage_jobs');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create job_runs table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.job_runs`)
+			if err != nil {
+				return fmt.Errorf("failed to drop job_runs table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 18,
+		Name:    "create_score_status_thresholds_table",
+		UpFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				CREATE TABLE IF NOT EXISTS public.score_status_thresholds (
+					id SERIAL PRIMARY KEY,
+					tahun_from INTEGER NOT NULL,
+					category TEXT CHECK (category IN ('all', 'barjas', 'fisik', 'anggaran', 'kinerja')),
+					jenis_opd TEXT CHECK (jenis_opd IN ('skpd', 'kecamatan')),
+					min_score DOUBLE PRECISION NOT NULL CHECK (min_score >= 0),
+					label TEXT NOT NULL,
+					color TEXT,
+					updated_at INTEGER NOT NULL,
+					created_at INTEGER NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS score_status_thresholds_band_idx
+					ON public.score_status_thresholds (tahun_from, COALESCE(category, ''), COALESCE(jenis_opd, ''), min_score);
+				INSERT INTO public.score_status_thresholds (tahun_from, min_score, label, color, updated_at, created_at)
+				SELECT 2000, band.min_score, band.label, band.color, EXTRACT(EPOCH FROM NOW())::INTEGER, EXTRACT(EPOCH FROM NOW())::INTEGER
+				FROM (VALUES (0, 'Diam', '#dc2626'), (25, 'Berjalan', '#f59e0b'), (50, 'Berlari', '#2563eb'), (75, 'Melesat', '#16a34a'))
+					AS band (min_score, label, color)
+				WHERE NOT EXISTS (SELECT 1 FROM public.score_status_thresholds);
+				INSERT INTO public.permissions (name)
+				SELECT 'manage_score_thresholds' WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = 'manage_score_thresholds');
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to create score_status_thresholds table: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`DROP TABLE IF EXISTS public.score_status_thresholds`)
+			if err != nil {
+				return fmt.Errorf("failed to drop score_status_thresholds table: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 19,
+		Name:    "unique_peta_detail_import_key",
+		UpFunc: func() error {
+			// Rows loaded without a year or RUP are left out of the key. Duplicated keys are left for
+			// an operator to resolve rather than deleted here.
+			var duplicates int64
+			err := db.GetDB().Db.QueryRow(`
+				SELECT COUNT(*) FROM (
+					SELECT 1 FROM de_peta_detail
+					WHERE id_rup IS NOT NULL AND tahun IS NOT NULL
+					GROUP BY tahun, id_rup, COALESCE(id_kontrak, 0)
+					HAVING COUNT(*) > 1
+				) duplicated
+			`).Scan(&duplicates)
+			if err != nil {
+				return fmt.Errorf("failed to check the de_peta_detail import keys: %v", err)
+			}
+			if duplicates > 0 {
+				return fmt.Errorf("de_peta_detail has %d duplicated (tahun, id_rup, id_kontrak) keys, resolve them before the import key can be made unique", duplicates)
+			}
+
+			_, err = db.GetDB().Db.Exec(`
+				DROP INDEX IF EXISTS de_peta_detail_import_key_idx;
+				CREATE UNIQUE INDEX IF NOT EXISTS de_peta_detail_import_key_idx ON de_peta_detail (tahun, id_rup, COALESCE(id_kontrak, 0))
+					WHERE id_rup IS NOT NULL AND tahun IS NOT NULL;
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to make the de_peta_detail import key unique: %v", err)
+			}
+			return nil
+		},
+		DownFunc: func() error {
+			_, err := db.GetDB().Db.Exec(`
+				DROP INDEX IF EXISTS de_peta_detail_import_key_idx;
+				CREATE INDEX IF NOT EXISTS de_peta_detail_import_key_idx ON de_peta_detail (tahun, id_rup, id_kontrak);
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to restore the de_peta_detail import key index: %v", err)
+			}
+			return nil
+		},
+	},
 }
 
 // RunMigrations runs all pending migrations
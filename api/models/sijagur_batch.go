@@ -0,0 +1,81 @@
+package models
+
+import "github.com/lib/pq"
+
+// RealisasiBatchKey is a satker and month of a batch request
+type RealisasiBatchKey struct {
+	Idsatker int `json:"idsatker"`
+	Month    int `json:"month"`
+}
+
+// RealisasiBatchResponse is the top-level contract of the batch endpoint, one result per satker
+// and month in the shape of realisasi-bulan or realisasi-tahun
+type RealisasiBatchResponse struct {
+	Results []SijagurResult     `json:"results"`
+	Missing []RealisasiBatchKey `json:"missing"` // requested satkers and months without data
+}
+
+// GetRealisasiBatch returns the cards of several satkers over several months of a year. The satker
+// rows come in one query with their registry names; idsatker 0 is computed from the satkers of
+// the months in a second one. Results are ordered by the requested satkers then months.
+func (m SijagurData) GetRealisasiBatch(year int, months, idsatkers []int, dataType string) (RealisasiBatchResponse, error) {
+	response := RealisasiBatchResponse{Results: []SijagurResult{}, Missing: []RealisasiBatchKey{}}
+
+	var satkerIDs []int
+	for _, idsatker := range idsatkers {
+		if idsatker != 0 {
+			satkerIDs = append(satkerIDs, idsatker)
+		}
+	}
+
+	stored := map[RealisasiBatchKey]RealisasiRow{}
+	if len(satkerIDs) > 0 {
+		rows, err := FetchRealisasiBatch(year, months, satkerIDs)
+		if err != nil {
+			return response, err
+		}
+		for _, r := range rows {
+			stored[RealisasiBatchKey{Idsatker: r.Idsatker, Month: r.Bulan}] = r
+		}
+	}
+
+	// The region is always computed from the satkers, a pre-inserted idsatker 0 row can lag behind them
+	if len(satkerIDs) < len(idsatkers) {
+		satkers, err := queryRealisasiRows(`WHERE dro.tahun = $1 AND dro.bulan = ANY($2) AND dro.idsatker <> 0`, year, pq.Array(months))
+		if err != nil {
+			return response, err
+		}
+		byMonth := map[int][]RealisasiRow{}
+		for _, r := range satkers {
+			byMonth[r.Bulan] = append(byMonth[r.Bulan], r)
+		}
+		for month, monthRows := range byMonth {
+			stored[RealisasiBatchKey{Month: month}] = AggregateRows(monthRows)
+		}
+	}
+
+	for _, idsatker := range idsatkers {
+		for _, month := range months {
+			key := RealisasiBatchKey{Idsatker: idsatker, Month: month}
+			row, ok := stored[key]
+			if !ok {
+				response.Missing = append(response.Missing, key)
+				continue
+			}
+
+			meta := RealisasiMeta{
+				Year:       year,
+				Month:      month,
+				MonthName:  GetMonthName(month),
+				Idsatker:   idsatker,
+				Type:       dataType,
+				LastUpdate: row.LastUpdate,
+			}
+			if idsatker != 0 {
+				meta.NamaOpd, meta.ShortName = row.Satker.Name, row.Satker.ShortName
+			}
+			response.Results = append(response.Results, SijagurResult{Data: row.Cards(dataType), Meta: meta})
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"sort"
+)
+
+// CompareScore is one category of a satker measured against the rest of the selection
+type CompareScore struct {
+	Category        string  `json:"category"`
+	Progress        float64 `json:"progress"`
+	Rank            int     `json:"rank"` // 1 is the best progress within the selection, ties share a rank
+	DiffFromAverage float64 `json:"diff_from_average"`
+	DiffFormatted   string  `json:"diff_formatted"`
+}
+
+// CompareSatker is the realisasi of one satker in a comparison
+type CompareSatker struct {
+	Idsatker  int             `json:"idsatker"`
+	NamaOpd   string          `json:"nama_opd"`
+	ShortName string          `json:"short_name,omitempty"`
+	JenisOpd  string          `json:"jenis_opd"`
+	Data      []RealisasiData `json:"data"`
+	Scores    []CompareScore  `json:"scores"`
+}
+
+// CompareResponse is the top-level contract of the compare endpoint
+type CompareResponse struct {
+	Status    string             `json:"status"` // "success"
+	Year      int                `json:"year"`
+	Month     int                `json:"month"`
+	MonthName string             `json:"month_name"`
+	Type      string             `json:"type"` // "bulan" or "tahun"
+	Total     int                `json:"total"`
+	Average   map[string]float64 `json:"average"`
+	Missing   []int              `json:"missing"` // requested satkers without data for the month
+	Data      []CompareSatker    `json:"data"`
+}
+
+// CompareSatkers returns the realisasi of several satkers side by side. dataType "tahun" uses the
+// kumulatif columns like GetRealisasiTahunWithParams, anything else the bulan columns.
+// Satkers are kept in the requested order, those without a ranking row are listed in Missing.
+func (m SijagurData) CompareSatkers(year, month int, idsatkers []int, dataType string) (CompareResponse, error) {
+	response := CompareResponse{
+		Status:    "success",
+		Year:      year,
+		Month:     month,
+		MonthName: GetMonthName(month),
+		Type:      dataType,
+		Missing:   []int{},
+		Data:      []CompareSatker{},
+	}
+
+	rows, err := FetchRealisasiRows(year, month, idsatkers)
+	if err != nil {
+		return response, err
+	}
+
+	ids := make([]int64, len(idsatkers))
+	for i, idsatker := range idsatkers {
+		ids[i] = int64(idsatker)
+	}
+	names := SatkerNames(ids)
+
+	for _, idsatker := range idsatkers {
+		row, ok := rows[idsatker]
+		if !ok {
+			response.Missing = append(response.Missing, idsatker)
+			continue
+		}
+		satker := CompareSatker{Idsatker: row.Idsatker, NamaOpd: row.NamaOpd, JenisOpd: row.JenisOpd, Data: row.Cards(dataType)}
+		if name, ok := names[int64(idsatker)]; ok {
+			satker.NamaOpd, satker.ShortName = name.Name, name.ShortName
+		}
+		response.Data = append(response.Data, satker)
+	}
+
+	response.Average = RankComparison(response.Data)
+	response.Total = len(response.Data)
+	return response, nil
+}
+
+// RankComparison scores every category of every satker against the selection: the rank by
+// progress (ties share a rank, 1 2 2 4) and the difference from the group average in
+// percentage points. It returns the average progress per category.
+func RankComparison(satkers []CompareSatker) map[string]float64 {
+	formatter := Formatter{}
+	averages := map[string]float64{}
+	if len(satkers) == 0 {
+		return averages
+	}
+
+	// Categories are aligned, the first satker gives the order
+	for index, card := range satkers[0].Data {
+		progress := make([]float64, len(satkers))
+		sum := 0.0
+		for i, satker := range satkers {
+			progress[i] = satker.Data[index].Progress
+			sum += progress[i]
+		}
+		average := sum / float64(len(satkers))
+		averages[card.Category] = average
+
+		ranks := competitionRanks(progress)
+		for i := range satkers {
+			diff := progress[i] - average
+			formatted := formatter.FormatProgress(diff)
+			if diff > 0 && formatted != "0" {
+				formatted = "+" + formatted
+			}
+			satkers[i].Scores = append(satkers[i].Scores, CompareScore{
+				Category:        card.Category,
+				Progress:        progress[i],
+				Rank:            ranks[i],
+				DiffFromAverage: diff,
+				DiffFormatted:   formatted,
+			})
+		}
+	}
+	return averages
+}
+
+// competitionRanks ranks values from the highest, equal values share the better rank
+func competitionRanks(values []float64) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] > values[order[b]] })
+
+	ranks := make([]int, len(values))
+	for position, i := range order {
+		if position > 0 && values[i] == values[order[position-1]] {
+			ranks[i] = ranks[order[position-1]]
+			continue
+		}
+		ranks[i] = position + 1
+	}
+	return ranks
+}
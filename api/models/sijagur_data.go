@@ -7,296 +7,32 @@ import (
 	"github.com/Massad/gin-boilerplate/db"
 )
 
-// getProgressData retrieves progress data from de_ranking_opd
-func (m SijagurData) getProgressData(year, month, idsatker int) (ProgressData, error) {
-	query := `
-		SELECT
-			capaian_opd, capaian_barjas, capaian_fisik, capaian_anggaran, capaian_kinerja,
-			kumulatif_opd, kumulatif_barjas, kumulatif_fisik, kumulatif_anggaran, kumulatif_kinerja
-		FROM de_ranking_opd
-		WHERE tahun = $1 AND bulan = $2 AND idsatker = $3
-		LIMIT 1
-	`
-
-	var progress ProgressData
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(
-		&progress.CapaianOpd, &progress.CapaianBarjas, &progress.CapaianFisik,
-		&progress.CapaianAnggaran, &progress.CapaianKinerja,
-		&progress.KumulatifOpd, &progress.KumulatifBarjas, &progress.KumulatifFisik,
-		&progress.KumulatifAnggaran, &progress.KumulatifKinerja,
-	)
-
-	if err != nil {
-		log.Printf("Error querying progress data: %v", err)
-		return ProgressData{}, err
-	}
-
-	return progress, nil
-}
-
-// getBarjasRawData retrieves raw barjas data for frontend processing
-func (m SijagurData) getBarjasRawData(year, month, idsatker int, progress float64) (RealisasiData, error) {
-	// Query to get barjas data
-	query := `
-		SELECT
-			c_perencanaan_selesai, c_perencanaan_target, c_perencanaan_terlambat,
-			c_pemilihan_selesai, c_pemilihan_target, c_pemilihan_terlambat,
-			c_pengadaan_selesai, c_pengadaan_target, c_pengadaan_terlambat,
-			c_penyerahan_selesai, c_penyerahan_target, c_penyerahan_terlambat
-		FROM de_detail_barjas ddb
-		INNER JOIN de_ranking_opd dro ON ddb.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var perencanaanSelesai, perencanaanTarget, perencanaanTerlambat int64
-	var pemilihanSelesai, pemilihanTarget, pemilihanTerlambat int64
-	var pengadaanSelesai, pengadaanTarget, pengadaanTerlambat int64
-	var penyerahanSelesai, penyerahanTarget, penyerahanTerlambat int64
-
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(
-		&perencanaanSelesai, &perencanaanTarget, &perencanaanTerlambat,
-		&pemilihanSelesai, &pemilihanTarget, &pemilihanTerlambat,
-		&pengadaanSelesai, &pengadaanTarget, &pengadaanTerlambat,
-		&penyerahanSelesai, &penyerahanTarget, &penyerahanTerlambat,
-	)
-	if err != nil {
-		log.Printf("Error querying barjas data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	// Build raw items for frontend processing
-	items := []RealisasiRawItem{
-		{Type: "perencanaan", Value: perencanaanSelesai, Detail: &RealisasiDetail{Selesai: perencanaanSelesai, Target: perencanaanTarget, Terlambat: perencanaanTerlambat}},
-		{Type: "pemilihan", Value: pemilihanSelesai, Detail: &RealisasiDetail{Selesai: pemilihanSelesai, Target: pemilihanTarget, Terlambat: pemilihanTerlambat}},
-		{Type: "pengadaan", Value: pengadaanSelesai, Detail: &RealisasiDetail{Selesai: pengadaanSelesai, Target: pengadaanTarget, Terlambat: pengadaanTerlambat}},
-		{Type: "penyerahan", Value: penyerahanSelesai, Detail: &RealisasiDetail{Selesai: penyerahanSelesai, Target: penyerahanTarget, Terlambat: penyerahanTerlambat}},
-	}
-
-	return RealisasiData{
-		Category:          "barjas",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Items:             items,
-	}, nil
-}
-
-// getFisikRawData retrieves raw fisik data for frontend processing
-func (m SijagurData) getFisikRawData(year, month, idsatker int, progress float64) (RealisasiData, error) {
-	// Query to get fisik data
-	query := `
-		SELECT
-			c_fisik_realisasi, c_fisik_target
-		FROM de_detail_fisik ddf
-		INNER JOIN de_ranking_opd dro ON ddf.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var realisasi, target float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&realisasi, &target)
-	if err != nil {
-		log.Printf("Error querying fisik data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "fisik",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: realisasi, Formatted: formatter.FormatNumber(realisasi)},
-			{Type: "target", Value: target, Formatted: formatter.FormatNumber(target)},
-		},
-	}, nil
-}
-
-// getAnggaranRawData retrieves raw anggaran data for frontend processing
-func (m SijagurData) getAnggaranRawData(year, month, idsatker int, progress float64) (RealisasiData, error) {
-	// Query to get anggaran data
-	query := `
-		SELECT
-			c_anggaran_realisasi, c_anggaran_target
-		FROM de_detail_anggaran dda
-		INNER JOIN de_ranking_opd dro ON dda.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var realisasi, target float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&realisasi, &target)
-	if err != nil {
-		log.Printf("Error querying anggaran data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "anggaran",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: realisasi, Formatted: formatter.FormatCurrency(realisasi)},
-			{Type: "target", Value: target, Formatted: formatter.FormatCurrency(target)},
-		},
-	}, nil
-}
-
-// getKinerjaRawData retrieves raw kinerja data for frontend processing
-func (m SijagurData) getKinerjaRawData(year, month, idsatker int, progress float64) (RealisasiData, error) {
-	// Query to get kinerja data
-	query := `
-		SELECT
-			c_kinerja_realisasi, c_kinerja_target
-		FROM de_detail_kinerja ddk
-		INNER JOIN de_ranking_opd dro ON ddk.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var realisasi, target float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&realisasi, &target)
-	if err != nil {
-		log.Printf("Error querying kinerja data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "kinerja",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: realisasi, Formatted: formatter.FormatNumber(realisasi)},
-			{Type: "target", Value: target, Formatted: formatter.FormatNumber(target)},
-		},
-	}, nil
-}
-
-// getBarjasRawDataTahun retrieves raw barjas tahun data for frontend processing
-func (m SijagurData) getBarjasRawDataTahun(year, month, idsatker int, progress float64, capaian float64) (RealisasiData, error) {
-	// Query to get barjas data for the year up to the specified month
-	query := `
-		SELECT
-			k_barjas_realisasi, k_barjas_target
-		FROM de_detail_barjas ddb
-		INNER JOIN de_ranking_opd dro ON ddb.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var totalRealisasi, totalTarget float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&totalRealisasi, &totalTarget)
-	if err != nil {
-		log.Printf("Error querying barjas tahun data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "barjas",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Capaian:           capaian,
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: totalRealisasi, Formatted: formatter.FormatNumber(totalRealisasi)},
-			{Type: "target", Value: totalTarget, Formatted: formatter.FormatNumber(totalTarget)},
-		},
-	}, nil
-}
-
-func (m SijagurData) getFisikRawDataTahun(year, month, idsatker int, progress float64, capaian float64) (RealisasiData, error) {
-	query := `
-		SELECT
-			k_fisik_realisasi, k_fisik_target
-		FROM de_detail_fisik ddf
-		INNER JOIN de_ranking_opd dro ON ddf.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var realisasi, target float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&realisasi, &target)
-	if err != nil {
-		log.Printf("Error querying fisik tahun data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "fisik",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Capaian:           capaian,
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: realisasi, Formatted: formatter.FormatNumber(realisasi)},
-			{Type: "target", Value: target, Formatted: formatter.FormatNumber(target)},
-		},
-	}, nil
-}
-
-func (m SijagurData) getAnggaranRawDataTahun(year, month, idsatker int, progress float64, capaian float64) (RealisasiData, error) {
-	query := `
-		SELECT
-			k_anggaran_realisasi, k_anggaran_target
-		FROM de_detail_anggaran dda
-		INNER JOIN de_ranking_opd dro ON dda.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var realisasi, target float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&realisasi, &target)
-	if err != nil {
-		log.Printf("Error querying anggaran tahun data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "anggaran",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Capaian:           capaian,
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: realisasi, Formatted: formatter.FormatCurrency(realisasi)},
-			{Type: "target", Value: target, Formatted: formatter.FormatCurrency(target)},
-		},
-	}, nil
-}
-
-func (m SijagurData) getKinerjaRawDataTahun(year, month, idsatker int, progress float64, capaian float64) (RealisasiData, error) {
-	query := `
-		SELECT
-			k_kinerja_realisasi, k_kinerja_target
-		FROM de_detail_kinerja ddk
-		INNER JOIN de_ranking_opd dro ON ddk.id_ranking_opd = dro.id
-		WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3
-	`
-
-	var realisasi, target float64
-	err := db.GetDB().QueryRow(query, year, month, idsatker).Scan(&realisasi, &target)
-	if err != nil {
-		log.Printf("Error querying kinerja tahun data: %v", err)
-		return RealisasiData{}, err
-	}
-
-	formatter := Formatter{}
-	return RealisasiData{
-		Category:          "kinerja",
-		Progress:          progress,
-		ProgressFormatted: formatter.FormatProgress(progress),
-		Capaian:           capaian,
-		Items: []RealisasiRawItem{
-			{Type: "realisasi", Value: realisasi, Formatted: formatter.FormatNumber(realisasi)},
-			{Type: "target", Value: target, Formatted: formatter.FormatNumber(target)},
-		},
-	}, nil
-}
+// rankingSource is de_ranking_opd with data_update, the latest last_update of each row and its
+// detail rows
+const rankingSource = `(
+	SELECT dro.*, GREATEST(dro.last_update,
+		(SELECT MAX(last_update) FROM de_detail_barjas WHERE id_ranking_opd = dro.id),
+		(SELECT MAX(last_update) FROM de_detail_fisik WHERE id_ranking_opd = dro.id),
+		(SELECT MAX(last_update) FROM de_detail_anggaran WHERE id_ranking_opd = dro.id),
+		(SELECT MAX(last_update) FROM de_detail_kinerja WHERE id_ranking_opd = dro.id)) AS data_update
+	FROM de_ranking_opd dro
+) r`
 
 // GetPeringkatKinerja returns rankings from de_ranking_opd with alias-based scores,
 // supporting scoped views via jenis_opd:
 // - scope = "skpd"      -> WHERE jenis_opd = 'skpd'
 // - scope = "kecamatan" -> WHERE jenis_opd = 'kecamatan'
 // - scope empty/other   -> no jenis_opd filter (all)
+// A triwulan or semester period ranks periodRankingSource instead of a single month. last_update
+// is the latest data_update of the ranked rows, so it follows the same filters.
+// A month or period that was published is served from its latest snapshot, or the given
+// version, unless live is set.
 func (m SijagurData) GetPeringkatKinerja(
 	year int,
 	month int,
+	period Period,
+	live bool,
+	version int,
 	idsatker int,
 	category string,
 	dimension string,
@@ -321,11 +57,28 @@ func (m SijagurData) GetPeringkatKinerja(
 	}
 
 	// Build WHERE clause
+	source := rankingSource
 	where := "WHERE tahun = $1"
 	args := []interface{}{year}
 	argIdx := 2
 
-	if month > 0 {
+	snapshot, err := servedSnapshot(year, month, period, live, version)
+	if err != nil {
+		return RankingResponse{}, err
+	}
+	sourceName := "live"
+
+	switch {
+	case snapshot != nil:
+		// Frozen rows already hold the month or the collapsed period
+		source = snapshot.source()
+		sourceName = "published"
+	case !period.IsMonth():
+		first, last := period.Months()
+		source = periodRankingSource
+		args = append(args, first, last)
+		argIdx = 4
+	case month > 0:
 		where += " AND bulan = $" + fmt.Sprint(argIdx)
 		args = append(args, month)
 		argIdx++
@@ -345,28 +98,42 @@ func (m SijagurData) GetPeringkatKinerja(
 		argIdx++
 	}
 
-	// Count total for this scope
-	countSQL := "SELECT COUNT(*) FROM de_ranking_opd " + where
+	// Count total and take the latest update for this scope, a snapshot carries its own
+	dataUpdate := "data_update"
+	if snapshot != nil {
+		dataUpdate = "0"
+	}
+	countSQL := "SELECT COUNT(*), COALESCE(MAX(" + dataUpdate + "), 0) FROM " + source + " " + where
 	var total int
-	if err := db.GetDB().QueryRow(countSQL, args...).Scan(&total); err != nil {
+	var lastUpdate int64
+	if err := db.GetDB().QueryRow(countSQL, args...).Scan(&total, &lastUpdate); err != nil {
 		log.Printf("GetPeringkatKinerja: count query error: %v", err)
 		return RankingResponse{}, err
 	}
+	if snapshot != nil {
+		lastUpdate = snapshot.LastUpdate
+	}
 
 	if total == 0 {
 		return RankingResponse{
-			Status:    "success",
-			Scope:     normalizedScope,
-			Category:  category,
-			Dimension: dimension,
-			Year:      year,
-			Month:     month,
-			Page:      1,
-			PageSize:  total,
-			Total:     0,
-			SortBy:    sortBy,
-			SortDir:   sortDir,
-			Data:      []RankingRow{},
+			Status:     "success",
+			Scope:      normalizedScope,
+			Category:   category,
+			Dimension:  dimension,
+			Year:       year,
+			Month:      month,
+			Period:     period.Type,
+			Periode:    period.Number,
+			Label:      period.Label(),
+			LastUpdate: lastUpdate,
+			Source:     sourceName,
+			Snapshot:   snapshot,
+			Page:       1,
+			PageSize:   total,
+			Total:      0,
+			SortBy:     sortBy,
+			SortDir:    sortDir,
+			Data:       []RankingRow{},
 		}, nil
 	}
 
@@ -429,12 +196,22 @@ func (m SijagurData) GetPeringkatKinerja(
 
 	orderClause := "ORDER BY " + sortBy + " " + sortDir
 
+	// Names come from the satker registry, the copies in the rows drift between months. A
+	// published ranking keeps the names it was frozen with.
+	nameColumns := "COALESCE(s.name, nama_opd) AS nama_opd, COALESCE(s.short_name, '') AS short_name"
+	nameJoin := "LEFT JOIN (SELECT idsatker, name, short_name FROM public.satkers) s USING (idsatker)"
+	if snapshot != nil {
+		nameColumns = "nama_opd, '' AS short_name"
+		nameJoin = ""
+	}
+
 	// Full result set for this scope (no LIMIT/OFFSET)
 	sql := `
         SELECT
             id,
             idsatker,
-            nama_opd,
+            ` + nameColumns + `,
+            COALESCE(jenis_opd, '') AS jenis_opd,
             capaian_barjas,
             capaian_fisik,
             capaian_anggaran,
@@ -452,7 +229,8 @@ func (m SijagurData) GetPeringkatKinerja(
             peringkat_opd,
             tahun,
             bulan
-        FROM de_ranking_opd
+        FROM ` + source + `
+        ` + nameJoin + `
         ` + where + `
         ` + orderClause + `
     `
@@ -465,6 +243,7 @@ func (m SijagurData) GetPeringkatKinerja(
 	defer rows.Close()
 
 	formatter := Formatter{}
+	thresholds := LoadScoreThresholds()
 	var list []RankingRow
 
 	for rows.Next() {
@@ -472,6 +251,8 @@ func (m SijagurData) GetPeringkatKinerja(
 			id                 int64
 			rowIdsatker        int64
 			namaOpd            string
+			shortName          string
+			jenisOpd           string
 			cCapaianBarjas     float64
 			cCapaianFisik      float64
 			cCapaianAnggaran   float64
@@ -495,6 +276,8 @@ func (m SijagurData) GetPeringkatKinerja(
 			&id,
 			&rowIdsatker,
 			&namaOpd,
+			&shortName,
+			&jenisOpd,
 			&cCapaianBarjas,
 			&cCapaianFisik,
 			&cCapaianAnggaran,
@@ -570,17 +353,20 @@ func (m SijagurData) GetPeringkatKinerja(
 			rankNumber = 0
 		}
 
+		status := thresholds.Status(tahunVal, category, jenisOpd, scoreTotal)
 		row := RankingRow{
 			ID:                     id,
 			Idsatker:               rowIdsatker,
 			NamaOpd:                namaOpd,
+			ShortName:              shortName,
 			RankNumber:             rankNumber,
 			ScoreTotal:             scoreTotal,
 			ScoreBarjas:            scoreBarjas,
 			ScoreFisik:             scoreFisik,
 			ScoreAnggaran:          scoreAnggaran,
 			ScoreKinerja:           scoreKinerja,
-			ScoreStatus:            scoreStatusFromTotal(scoreTotal),
+			ScoreStatus:            status.Label,
+			ScoreStatusColor:       status.Color,
 			ScoreTotalFormatted:    formatter.FormatProgress(scoreTotal),
 			ScoreBarjasFormatted:   formatter.FormatProgress(scoreBarjas),
 			ScoreFisikFormatted:    formatter.FormatProgress(scoreFisik),
@@ -594,18 +380,24 @@ func (m SijagurData) GetPeringkatKinerja(
 	}
 
 	resp := RankingResponse{
-		Status:    "success",
-		Scope:     normalizedScope,
-		Category:  category,
-		Dimension: dimension,
-		Year:      year,
-		Month:     month,
-		Page:      1,
-		PageSize:  total,
-		Total:     total,
-		SortBy:    sortBy,
-		SortDir:   sortDir,
-		Data:      list,
+		Status:     "success",
+		Scope:      normalizedScope,
+		Category:   category,
+		Dimension:  dimension,
+		Year:       year,
+		Month:      month,
+		Period:     period.Type,
+		Periode:    period.Number,
+		Label:      period.Label(),
+		LastUpdate: lastUpdate,
+		Source:     sourceName,
+		Snapshot:   snapshot,
+		Page:       1,
+		PageSize:   total,
+		Total:      total,
+		SortBy:     sortBy,
+		SortDir:    sortDir,
+		Data:       list,
 	}
 
 	return resp, nil
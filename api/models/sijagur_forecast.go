@@ -0,0 +1,397 @@
+package models
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/Massad/gin-boilerplate/db"
+)
+
+// Forecast models. Auto picks seasonal when at least one previous year has a complete series.
+const (
+	ForecastModelAuto     = "auto"
+	ForecastModelLinear   = "linear"
+	ForecastModelSeasonal = "seasonal"
+)
+
+// forecastCategories are the realisasi categories that are projected
+var forecastCategories = []string{"barjas", "fisik", "anggaran", "kinerja"}
+
+// ForecastBand is a December projection of a cumulative series with its 95% band
+type ForecastBand struct {
+	Model     string  `json:"model"`
+	Projected float64 `json:"projected"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+	Samples   int     `json:"samples"` // observed months (linear) or history years (seasonal)
+}
+
+// CategoryForecast is the year-end projection of one category, in percent of the annual target
+type CategoryForecast struct {
+	Category           string  `json:"category"`
+	Model              string  `json:"model"`
+	Realisasi          float64 `json:"realisasi"`           // cumulative realisasi up to the observed month
+	ProjectedRealisasi float64 `json:"projected_realisasi"` // cumulative realisasi expected in December
+	Target             float64 `json:"target"`              // annual target, projected when December is not loaded yet
+	Current            float64 `json:"current"`             // realisasi to date in percent of the annual target
+	Projected          float64 `json:"projected"`
+	Lower              float64 `json:"lower"`
+	Upper              float64 `json:"upper"`
+	ProjectedFormatted string  `json:"projected_formatted"`
+	BelowThreshold     bool    `json:"below_threshold"`
+}
+
+// SatkerForecast groups the category forecasts of one satker
+type SatkerForecast struct {
+	Idsatker       int                `json:"idsatker"`
+	NamaOpd        string             `json:"nama_opd,omitempty"`
+	JenisOpd       string             `json:"jenis_opd,omitempty"`
+	Month          int                `json:"month"` // last observed month
+	MonthName      string             `json:"month_name,omitempty"`
+	HistoryYears   []int              `json:"history_years"`
+	BelowThreshold bool               `json:"below_threshold"`
+	Flagged        []string           `json:"flagged"` // categories projected below the threshold
+	Categories     []CategoryForecast `json:"categories"`
+}
+
+// ForecastResponse is the top-level contract of the forecast endpoints
+type ForecastResponse struct {
+	Status    string           `json:"status"`
+	Year      int              `json:"year"`
+	Model     string           `json:"model"`
+	Threshold float64          `json:"threshold"`
+	Total     int              `json:"total"`
+	Flagged   int              `json:"flagged"`
+	Data      []SatkerForecast `json:"data"`
+}
+
+// ForecastThreshold is the default year-end percentage under which satkers are flagged,
+// FORECAST_THRESHOLD (default 80)
+func ForecastThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("FORECAST_THRESHOLD"), 64)
+	if err != nil || threshold <= 0 {
+		threshold = 80
+	}
+	return threshold
+}
+
+// tCritical95 are two-sided 95% Student t quantiles for 1 to 30 degrees of freedom
+var tCritical95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+func tQuantile(df int) float64 {
+	if df < 1 {
+		return 0
+	}
+	if df > len(tCritical95) {
+		return 1.96
+	}
+	return tCritical95[df-1]
+}
+
+// Cumulative turns monthly values into a running total of the first months entries
+func Cumulative(monthly [12]float64, months int) []float64 {
+	if months > 12 {
+		months = 12
+	}
+	series := make([]float64, 0, months)
+	total := 0.0
+	for i := 0; i < months; i++ {
+		total += monthly[i]
+		series = append(series, total)
+	}
+	return series
+}
+
+// ProjectLinear fits a least squares line on the cumulative series (month 1..n) and
+// extrapolates it to December. The band is the 95% prediction interval; with fewer
+// than three months there are no residual degrees of freedom and the band collapses.
+func ProjectLinear(cumulative []float64) ForecastBand {
+	n := len(cumulative)
+	band := ForecastBand{Model: ForecastModelLinear, Samples: n}
+	if n == 0 {
+		return band
+	}
+	last := cumulative[n-1]
+	if n == 1 {
+		// A single month only gives a run rate
+		band.Projected = last * 12
+		band.Lower, band.Upper = band.Projected, band.Projected
+		return clampBand(band, last)
+	}
+
+	var sumX, sumY float64
+	for i, y := range cumulative {
+		sumX += float64(i + 1)
+		sumY += y
+	}
+	meanX, meanY := sumX/float64(n), sumY/float64(n)
+
+	var sxx, sxy float64
+	for i, y := range cumulative {
+		dx := float64(i+1) - meanX
+		sxx += dx * dx
+		sxy += dx * (y - meanY)
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+	band.Projected = intercept + slope*12
+
+	halfWidth := 0.0
+	if n > 2 {
+		var sse float64
+		for i, y := range cumulative {
+			residual := y - (intercept + slope*float64(i+1))
+			sse += residual * residual
+		}
+		stdErr := math.Sqrt(sse / float64(n-2))
+		halfWidth = tQuantile(n-2) * stdErr * math.Sqrt(1+1/float64(n)+(12-meanX)*(12-meanX)/sxx)
+	}
+	band.Lower = band.Projected - halfWidth
+	band.Upper = band.Projected + halfWidth
+	return clampBand(band, last)
+}
+
+// ProjectSeasonal scales the current cumulative value by the share of the December total
+// that previous years had reached in the same month. Each usable history year gives one
+// estimate; the band is the 95% prediction interval over those estimates. Years without a
+// December total or without realisasi by that month are skipped.
+func ProjectSeasonal(cumulative []float64, history [][12]float64) ForecastBand {
+	n := len(cumulative)
+	band := ForecastBand{Model: ForecastModelSeasonal}
+	if n == 0 {
+		return band
+	}
+	last := cumulative[n-1]
+
+	var estimates []float64
+	for _, year := range history {
+		series := Cumulative(year, 12)
+		if series[11] <= 0 || series[n-1] <= 0 {
+			continue
+		}
+		estimates = append(estimates, last/(series[n-1]/series[11]))
+	}
+	band.Samples = len(estimates)
+	if len(estimates) == 0 {
+		return band
+	}
+
+	var sum float64
+	for _, estimate := range estimates {
+		sum += estimate
+	}
+	k := float64(len(estimates))
+	band.Projected = sum / k
+
+	halfWidth := 0.0
+	if len(estimates) > 1 {
+		var ss float64
+		for _, estimate := range estimates {
+			ss += (estimate - band.Projected) * (estimate - band.Projected)
+		}
+		halfWidth = tQuantile(len(estimates)-1) * math.Sqrt(ss/(k-1)) * math.Sqrt(1+1/k)
+	}
+	band.Lower = band.Projected - halfWidth
+	band.Upper = band.Projected + halfWidth
+	return clampBand(band, last)
+}
+
+// Project runs the requested model, falling back to linear when no history year is usable.
+// A seasonal projection from a single year borrows the width of the linear band.
+func Project(model string, cumulative []float64, history [][12]float64) ForecastBand {
+	if model == ForecastModelLinear {
+		return ProjectLinear(cumulative)
+	}
+	seasonal := ProjectSeasonal(cumulative, history)
+	if seasonal.Samples == 0 {
+		return ProjectLinear(cumulative)
+	}
+	if seasonal.Samples == 1 {
+		linear := ProjectLinear(cumulative)
+		seasonal.Lower = seasonal.Projected - (linear.Projected - linear.Lower)
+		seasonal.Upper = seasonal.Projected + (linear.Upper - linear.Projected)
+		return clampBand(seasonal, cumulative[len(cumulative)-1])
+	}
+	return seasonal
+}
+
+// clampBand keeps a cumulative projection at or above what has already been realised
+func clampBand(band ForecastBand, floor float64) ForecastBand {
+	band.Projected = math.Max(band.Projected, floor)
+	band.Lower = math.Max(band.Lower, floor)
+	band.Upper = math.Max(band.Upper, band.Projected)
+	return band
+}
+
+// forecastSeries holds the monthly realisasi and target of each category for one satker year
+type forecastSeries struct {
+	realisasi map[string]*[12]float64
+	target    map[string]*[12]float64
+	lastMonth int
+}
+
+// loadForecastSeries reads a satker year through FetchRealisasiPerbulanData, keeping months up to maxMonth
+func loadForecastSeries(year, idsatker, maxMonth int) (forecastSeries, error) {
+	series := forecastSeries{realisasi: map[string]*[12]float64{}, target: map[string]*[12]float64{}}
+	for _, category := range forecastCategories {
+		series.realisasi[category] = &[12]float64{}
+		series.target[category] = &[12]float64{}
+	}
+
+	rows, err := FetchRealisasiPerbulanData(year, idsatker)
+	if err != nil {
+		return series, err
+	}
+	for _, row := range rows {
+		if row.Month > maxMonth {
+			continue
+		}
+		i := row.Month - 1
+		series.realisasi["barjas"][i], series.target["barjas"][i] = row.RealisasiBarjas, row.TargetBarjas
+		series.realisasi["fisik"][i], series.target["fisik"][i] = row.RealisasiFisik, row.TargetFisik
+		series.realisasi["anggaran"][i], series.target["anggaran"][i] = row.RealisasiAnggaran, row.TargetAnggaran
+		series.realisasi["kinerja"][i], series.target["kinerja"][i] = row.RealisasiKinerja, row.TargetKinerja
+		if row.Month > series.lastMonth {
+			series.lastMonth = row.Month
+		}
+	}
+	return series, nil
+}
+
+// percentOf ...
+func percentOf(value, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(value/total*10000) / 100
+}
+
+// ForecastSatker projects December for every category of a satker. month limits the observed
+// months (0 uses everything loaded), history is the number of previous years used for fitting.
+func (m SijagurData) ForecastSatker(year, month, idsatker int, model string, history int, threshold float64) (SatkerForecast, error) {
+	forecast := SatkerForecast{Idsatker: idsatker, HistoryYears: []int{}, Flagged: []string{}}
+	if month <= 0 || month > 12 {
+		month = 12
+	}
+
+	current, err := loadForecastSeries(year, idsatker, month)
+	if err != nil {
+		return forecast, err
+	}
+	forecast.Month = current.lastMonth
+	forecast.MonthName = GetMonthName(current.lastMonth)
+	if current.lastMonth == 0 {
+		return forecast, nil
+	}
+
+	var past []forecastSeries
+	if model != ForecastModelLinear {
+		for y := year - 1; y >= year-history; y-- {
+			series, err := loadForecastSeries(y, idsatker, 12)
+			if err != nil {
+				return forecast, err
+			}
+			if series.lastMonth == 12 {
+				past = append(past, series)
+				forecast.HistoryYears = append(forecast.HistoryYears, y)
+			}
+		}
+	}
+
+	formatter := Formatter{}
+	for _, category := range forecastCategories {
+		var realisasiHistory, targetHistory [][12]float64
+		for _, series := range past {
+			realisasiHistory = append(realisasiHistory, *series.realisasi[category])
+			targetHistory = append(targetHistory, *series.target[category])
+		}
+
+		realised := Cumulative(*current.realisasi[category], current.lastMonth)
+		band := Project(model, realised, realisasiHistory)
+
+		// The annual target is known once December is loaded, otherwise it is projected like realisasi
+		planned := Cumulative(*current.target[category], current.lastMonth)
+		target := planned[len(planned)-1]
+		if current.lastMonth < 12 {
+			target = Project(model, planned, targetHistory).Projected
+		}
+
+		item := CategoryForecast{
+			Category:           category,
+			Model:              band.Model,
+			Realisasi:          realised[len(realised)-1],
+			ProjectedRealisasi: band.Projected,
+			Target:             target,
+			Current:            percentOf(realised[len(realised)-1], target),
+			Projected:          percentOf(band.Projected, target),
+			Lower:              percentOf(band.Lower, target),
+			Upper:              percentOf(band.Upper, target),
+		}
+		item.ProjectedFormatted = formatter.FormatProgress(item.Projected)
+		// Without a target there is nothing to miss
+		item.BelowThreshold = target > 0 && item.Projected < threshold
+		if item.BelowThreshold {
+			forecast.Flagged = append(forecast.Flagged, category)
+		}
+		forecast.Categories = append(forecast.Categories, item)
+	}
+	forecast.BelowThreshold = len(forecast.Flagged) > 0
+
+	return forecast, nil
+}
+
+// ForecastAll projects every satker reported in the year (idsatker 0, the region row, excluded),
+// optionally keeping only the satkers projected below the threshold
+func (m SijagurData) ForecastAll(year, month int, jenisOpd, model string, history int, threshold float64, flaggedOnly bool) ([]SatkerForecast, error) {
+	var satkers []struct {
+		Idsatker int    `db:"idsatker"`
+		NamaOpd  string `db:"nama_opd"`
+		JenisOpd string `db:"jenis_opd"`
+	}
+	_, err := db.GetDB().Select(&satkers, `SELECT DISTINCT ON (idsatker) idsatker, COALESCE(nama_opd, '') AS nama_opd, COALESCE(jenis_opd, '') AS jenis_opd
+		FROM de_ranking_opd
+		WHERE tahun=$1 AND idsatker <> 0 AND ($2 = '' OR jenis_opd = $2)
+		ORDER BY idsatker, bulan DESC`, year, jenisOpd)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SatkerForecast, len(satkers))
+	errs := make([]error, len(satkers))
+
+	// Every satker costs a handful of queries, so a small pool keeps the connection count bounded
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = m.ForecastSatker(year, month, satkers[i].Idsatker, model, history, threshold)
+				results[i].NamaOpd = satkers[i].NamaOpd
+				results[i].JenisOpd = satkers[i].JenisOpd
+			}
+		}()
+	}
+	for i := range satkers {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	forecasts := []SatkerForecast{}
+	for i, forecast := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if flaggedOnly && !forecast.BelowThreshold {
+			continue
+		}
+		forecasts = append(forecasts, forecast)
+	}
+	return forecasts, nil
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/lib/pq"
+)
+
+// TableFreshness is the latest update of one de_* table for the year
+type TableFreshness struct {
+	Table      string `json:"table"`
+	Rows       int64  `json:"rows"`
+	LastUpdate int64  `json:"last_update"` // epoch seconds, 0 without rows
+}
+
+// SatkerFreshness is how up to date the data of one satker is
+type SatkerFreshness struct {
+	Idsatker    int              `json:"idsatker"`
+	NamaOpd     string           `json:"nama_opd"`
+	LastUpdate  int64            `json:"last_update"`
+	LatestMonth int              `json:"latest_month"` // last bulan with a de_ranking_opd row, 0 for none
+	Tables      map[string]int64 `json:"tables"`       // latest update per table
+	Missing     []int            `json:"missing"`      // months past the deadline without a de_ranking_opd row
+	Overdue     bool             `json:"overdue"`
+}
+
+// FreshnessResponse is the top-level contract of the freshness endpoint
+type FreshnessResponse struct {
+	Status         string            `json:"status"` // "success"
+	Year           int               `json:"year"`
+	DeadlineDay    int               `json:"deadline_day"`
+	ExpectedMonths int               `json:"expected_months"` // months whose reporting deadline has passed
+	LastUpdate     int64             `json:"last_update"`
+	Tables         []TableFreshness  `json:"tables"`
+	MissingMonths  []int             `json:"missing_months"` // expected months no satker reported
+	Overdue        int               `json:"overdue"`        // satkers with missing months
+	Satkers        []SatkerFreshness `json:"satkers"`
+}
+
+// FreshnessUpdate is the latest update of a table for one satker
+type FreshnessUpdate struct {
+	Table      string
+	Idsatker   int
+	Rows       int64
+	LastUpdate int64
+}
+
+// freshnessTables are the de_* tables carrying last_update, in report order
+var freshnessTables = []string{
+	"de_ranking_opd", "de_detail_barjas", "de_detail_fisik", "de_detail_anggaran", "de_detail_kinerja",
+	"de_status_paket", "de_peta_detail",
+}
+
+// freshnessQuery is the latest update and row count of every table for tahun $1, per satker.
+// The detail tables take the satker from their ranking row.
+const freshnessQuery = `
+	SELECT 'de_ranking_opd', idsatker, COUNT(*), COALESCE(MAX(last_update), 0)
+	FROM de_ranking_opd WHERE tahun = $1 GROUP BY idsatker
+	UNION ALL
+	SELECT 'de_detail_barjas', dro.idsatker, COUNT(*), COALESCE(MAX(d.last_update), 0)
+	FROM de_detail_barjas d JOIN de_ranking_opd dro ON dro.id = d.id_ranking_opd WHERE dro.tahun = $1 GROUP BY dro.idsatker
+	UNION ALL
+	SELECT 'de_detail_fisik', dro.idsatker, COUNT(*), COALESCE(MAX(d.last_update), 0)
+	FROM de_detail_fisik d JOIN de_ranking_opd dro ON dro.id = d.id_ranking_opd WHERE dro.tahun = $1 GROUP BY dro.idsatker
+	UNION ALL
+	SELECT 'de_detail_anggaran', dro.idsatker, COUNT(*), COALESCE(MAX(d.last_update), 0)
+	FROM de_detail_anggaran d JOIN de_ranking_opd dro ON dro.id = d.id_ranking_opd WHERE dro.tahun = $1 GROUP BY dro.idsatker
+	UNION ALL
+	SELECT 'de_detail_kinerja', dro.idsatker, COUNT(*), COALESCE(MAX(d.last_update), 0)
+	FROM de_detail_kinerja d JOIN de_ranking_opd dro ON dro.id = d.id_ranking_opd WHERE dro.tahun = $1 GROUP BY dro.idsatker
+	UNION ALL
+	SELECT 'de_status_paket', idsatker, COUNT(*), COALESCE(MAX(last_update), 0)
+	FROM de_status_paket WHERE tahun = $1 AND is_removed = 0 GROUP BY idsatker
+	UNION ALL
+	SELECT 'de_peta_detail', idsatker, COUNT(*), COALESCE(MAX(last_update), 0)
+	FROM de_peta_detail WHERE tahun = $1 AND is_removed = 0 GROUP BY idsatker`
+
+// ReportDeadlineDay is the day of the following month by which a satker must have reported a
+// month, SIJAGUR_REPORT_DEADLINE_DAY (default 10)
+func ReportDeadlineDay() int {
+	day, err := strconv.Atoi(os.Getenv("SIJAGUR_REPORT_DEADLINE_DAY"))
+	if err != nil || day < 1 || day > 28 {
+		day = 10
+	}
+	return day
+}
+
+// ExpectedMonths is the number of months of year whose reporting deadline, deadlineDay of the
+// following month, has passed at now
+func ExpectedMonths(year, deadlineDay int, now time.Time) int {
+	expected := 0
+	for month := 1; month <= 12; month++ {
+		deadline := time.Date(year, time.Month(month+1), deadlineDay, 0, 0, 0, 0, now.Location())
+		if now.Before(deadline) {
+			break
+		}
+		expected = month
+	}
+	return expected
+}
+
+// missingMonths lists the months 1 to expected not in reported
+func missingMonths(reported map[int]bool, expected int) []int {
+	missing := []int{}
+	for month := 1; month <= expected; month++ {
+		if !reported[month] {
+			missing = append(missing, month)
+		}
+	}
+	return missing
+}
+
+// BuildFreshness assembles the freshness report. names lists the satkers expected to report,
+// reported the months each satker has a ranking row for. Updates of satkers not in names still
+// count for their table; idsatker 0 is the region row and never listed as a satker.
+func BuildFreshness(year, expected int, names map[int]string, updates []FreshnessUpdate, reported map[int][]int) FreshnessResponse {
+	response := FreshnessResponse{
+		Status:         "success",
+		Year:           year,
+		ExpectedMonths: expected,
+		Tables:         []TableFreshness{},
+		Satkers:        []SatkerFreshness{},
+	}
+
+	tables := make(map[string]*TableFreshness, len(freshnessTables))
+	for _, name := range freshnessTables {
+		response.Tables = append(response.Tables, TableFreshness{Table: name})
+	}
+	for i := range response.Tables {
+		tables[response.Tables[i].Table] = &response.Tables[i]
+	}
+
+	satkers := make(map[int]*SatkerFreshness, len(names))
+	ids := make([]int, 0, len(names))
+	for idsatker := range names {
+		if idsatker != 0 {
+			ids = append(ids, idsatker)
+		}
+	}
+	sort.Ints(ids)
+	for _, idsatker := range ids {
+		response.Satkers = append(response.Satkers, SatkerFreshness{Idsatker: idsatker, NamaOpd: names[idsatker], Tables: map[string]int64{}})
+	}
+	for i := range response.Satkers {
+		satkers[response.Satkers[i].Idsatker] = &response.Satkers[i]
+	}
+
+	for _, u := range updates {
+		if table, ok := tables[u.Table]; ok {
+			table.Rows += u.Rows
+			if u.LastUpdate > table.LastUpdate {
+				table.LastUpdate = u.LastUpdate
+			}
+		}
+		if u.LastUpdate > response.LastUpdate {
+			response.LastUpdate = u.LastUpdate
+		}
+		if satker, ok := satkers[u.Idsatker]; ok {
+			satker.Tables[u.Table] = u.LastUpdate
+			if u.LastUpdate > satker.LastUpdate {
+				satker.LastUpdate = u.LastUpdate
+			}
+		}
+	}
+
+	anyReported := map[int]bool{}
+	satkerReported := map[int]map[int]bool{}
+	for idsatker, months := range reported {
+		satkerReported[idsatker] = map[int]bool{}
+		for _, month := range months {
+			satkerReported[idsatker][month] = true
+			if idsatker != 0 {
+				anyReported[month] = true
+			}
+		}
+	}
+	for i := range response.Satkers {
+		satker := &response.Satkers[i]
+		for month := range satkerReported[satker.Idsatker] {
+			if month > satker.LatestMonth {
+				satker.LatestMonth = month
+			}
+		}
+		satker.Missing = missingMonths(satkerReported[satker.Idsatker], expected)
+		satker.Overdue = len(satker.Missing) > 0
+		if satker.Overdue {
+			response.Overdue++
+		}
+	}
+	response.MissingMonths = missingMonths(anyReported, expected)
+	return response
+}
+
+// GetFreshness reports the latest update per table and satker for year, with the months past
+// the reporting deadline that are missing. The satkers expected to report are those with a
+// ranking row in year or the year before.
+func (m SijagurData) GetFreshness(year, deadlineDay int) (FreshnessResponse, error) {
+	names := map[int]string{}
+	var satkers []struct {
+		Idsatker int    `db:"idsatker"`
+		NamaOpd  string `db:"nama_opd"`
+	}
+	_, err := db.GetDB().Select(&satkers, `
+		SELECT DISTINCT ON (idsatker) idsatker, COALESCE(nama_opd, '') AS nama_opd
+		FROM de_ranking_opd WHERE tahun IN ($1 - 1, $1) AND idsatker <> 0
+		ORDER BY idsatker, tahun DESC, bulan DESC, last_update DESC NULLS LAST`, year)
+	if err != nil {
+		log.Printf("Error querying freshness satkers: %v", err)
+		return FreshnessResponse{}, err
+	}
+	for _, s := range satkers {
+		names[s.Idsatker] = s.NamaOpd
+	}
+
+	rows, err := db.GetDB().Query(freshnessQuery, year)
+	if err != nil {
+		log.Printf("Error querying freshness: %v", err)
+		return FreshnessResponse{}, err
+	}
+	defer rows.Close()
+	var updates []FreshnessUpdate
+	for rows.Next() {
+		var u FreshnessUpdate
+		if err := rows.Scan(&u.Table, &u.Idsatker, &u.Rows, &u.LastUpdate); err != nil {
+			return FreshnessResponse{}, err
+		}
+		updates = append(updates, u)
+	}
+	if err := rows.Err(); err != nil {
+		return FreshnessResponse{}, err
+	}
+
+	reported := map[int][]int{}
+	var months []struct {
+		Idsatker int           `db:"idsatker"`
+		Months   pq.Int64Array `db:"months"`
+	}
+	_, err = db.GetDB().Select(&months, `
+		SELECT idsatker, array_agg(DISTINCT bulan) AS months
+		FROM de_ranking_opd WHERE tahun = $1 GROUP BY idsatker`, year)
+	if err != nil {
+		log.Printf("Error querying freshness months: %v", err)
+		return FreshnessResponse{}, err
+	}
+	for _, s := range months {
+		for _, month := range s.Months {
+			reported[s.Idsatker] = append(reported[s.Idsatker], int(month))
+		}
+	}
+
+	response := BuildFreshness(year, ExpectedMonths(year, deadlineDay, time.Now()), names, updates, reported)
+	response.DeadlineDay = deadlineDay
+	return response, nil
+}
+
+// DataLastUpdate is the latest last_update of the ranking and detail rows of months first to last
+// of year, for one satker or every satker when idsatker is 0. Errors are logged and read as 0 so
+// the timestamp never fails the response it decorates.
+func (m SijagurData) DataLastUpdate(year, first, last, idsatker int) int64 {
+	var lastUpdate int64
+	err := db.GetDB().QueryRow(`
+		SELECT COALESCE(MAX(GREATEST(dro.last_update, ddb.last_update, ddf.last_update, dda.last_update, ddk.last_update)), 0)
+		FROM de_ranking_opd dro
+		LEFT JOIN de_detail_barjas ddb ON ddb.id_ranking_opd = dro.id
+		LEFT JOIN de_detail_fisik ddf ON ddf.id_ranking_opd = dro.id
+		LEFT JOIN de_detail_anggaran dda ON dda.id_ranking_opd = dro.id
+		LEFT JOIN de_detail_kinerja ddk ON ddk.id_ranking_opd = dro.id
+		WHERE dro.tahun = $1 AND dro.bulan BETWEEN $2 AND $3 AND ($4 = 0 OR dro.idsatker = $4)`,
+		year, first, last, idsatker).Scan(&lastUpdate)
+	if err != nil {
+		log.Printf("Error querying data last update: %v", err)
+	}
+	return lastUpdate
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Funnel views, mapped to the c_, k_ and p_ stage columns of de_detail_barjas
+const (
+	FunnelViewBulan     = "bulan"
+	FunnelViewKumulatif = "kumulatif"
+	FunnelViewPeriodik  = "periodik"
+)
+
+// funnelColumns maps a view to the RealisasiRow column index
+var funnelColumns = map[string]int{
+	FunnelViewBulan:     bulanColumns,
+	FunnelViewKumulatif: tahunColumns,
+	FunnelViewPeriodik:  periodikColumns,
+}
+
+// FunnelStage is one procurement stage of the barjas funnel. Ratios are percentages and left out
+// when their denominator is zero.
+type FunnelStage struct {
+	Stage      string   `json:"stage"`
+	Selesai    int64    `json:"selesai"`
+	Target     int64    `json:"target"`
+	Terlambat  int64    `json:"terlambat"`
+	Backlog    int64    `json:"backlog"`              // target not yet selesai
+	Completion *float64 `json:"completion,omitempty"` // selesai / target
+	Lateness   *float64 `json:"lateness,omitempty"`   // terlambat / target
+	Conversion *float64 `json:"conversion,omitempty"` // selesai / selesai of the previous stage
+	Stuck      int64    `json:"stuck"`                // selesai at the previous stage but not at this one
+}
+
+// BarjasFunnel is the procurement funnel from perencanaan to penyerahan
+type BarjasFunnel struct {
+	Stages []FunnelStage `json:"stages"`
+	// Overall is penyerahan selesai over perencanaan target
+	Overall *float64 `json:"overall,omitempty"`
+	// Bottleneck is the stage with the lowest conversion from the previous one, or the
+	// first stage with a backlog when nothing reached a later stage yet
+	Bottleneck string `json:"bottleneck,omitempty"`
+}
+
+// FunnelResponse is the top-level contract of the barjas funnel endpoint
+type FunnelResponse struct {
+	Status    string       `json:"status"` // "success"
+	Year      int          `json:"year"`
+	Month     int          `json:"month"`
+	MonthName string       `json:"month_name"`
+	View      string       `json:"view"` // "bulan" | "kumulatif" | "periodik"
+	Idsatker  int          `json:"idsatker"`
+	NamaOpd   string       `json:"nama_opd,omitempty"`
+	Satkers   int          `json:"satkers,omitempty"` // satkers summed for the region
+	Funnel    BarjasFunnel `json:"funnel"`
+}
+
+// ratio is part over whole in percent, nil when whole is zero
+func ratio(part, whole int64) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	value := float64(part) / float64(whole) * 100
+	return &value
+}
+
+// BuildFunnel computes the funnel of the selesai, target and terlambat counts of the four stages
+func BuildFunnel(stages [4][3]int64) BarjasFunnel {
+	funnel := BarjasFunnel{Stages: make([]FunnelStage, 0, len(stages))}
+	var lowest *float64
+
+	for i, name := range barjasStageNames {
+		s := stages[i]
+		stage := FunnelStage{
+			Stage:      name,
+			Selesai:    s[0],
+			Target:     s[1],
+			Terlambat:  s[2],
+			Completion: ratio(s[0], s[1]),
+			Lateness:   ratio(s[2], s[1]),
+		}
+		if stage.Backlog = s[1] - s[0]; stage.Backlog < 0 {
+			stage.Backlog = 0
+		}
+
+		if i > 0 {
+			previous := stages[i-1][0]
+			stage.Conversion = ratio(s[0], previous)
+			if stage.Stuck = previous - s[0]; stage.Stuck < 0 {
+				stage.Stuck = 0
+			}
+			if stage.Conversion != nil && (lowest == nil || *stage.Conversion < *lowest) {
+				lowest = stage.Conversion
+				funnel.Bottleneck = name
+			}
+		}
+		funnel.Stages = append(funnel.Stages, stage)
+	}
+
+	if funnel.Bottleneck == "" {
+		for _, stage := range funnel.Stages {
+			if stage.Backlog > 0 {
+				funnel.Bottleneck = stage.Stage
+				break
+			}
+		}
+	}
+	funnel.Overall = ratio(stages[len(stages)-1][0], stages[0][1])
+	return funnel
+}
+
+// GetBarjasFunnel returns the procurement funnel of a satker, or of the region for idsatker 0
+// summed from every satker's stage counts
+func (m SijagurData) GetBarjasFunnel(year, month, idsatker int, view string) (FunnelResponse, error) {
+	response := FunnelResponse{
+		Status:    "success",
+		Year:      year,
+		Month:     month,
+		MonthName: GetMonthName(month),
+		View:      view,
+		Idsatker:  idsatker,
+	}
+	columns, ok := funnelColumns[view]
+	if !ok {
+		columns = bulanColumns
+		response.View = FunnelViewBulan
+	}
+
+	var row RealisasiRow
+	if idsatker == 0 {
+		rows, err := regionRows(year, month)
+		if err != nil {
+			return response, err
+		}
+		row = AggregateRows(rows)
+		response.Satkers = len(rows)
+	} else {
+		rows, err := FetchRealisasiRows(year, month, []int{idsatker})
+		if err != nil {
+			return response, err
+		}
+		if row, ok = rows[idsatker]; !ok {
+			return response, sql.ErrNoRows
+		}
+		response.NamaOpd = row.NamaOpd
+	}
+
+	response.Funnel = BuildFunnel(row.BarjasStages[columns])
+	return response, nil
+}
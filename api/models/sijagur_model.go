@@ -1,8 +1,8 @@
 package models
 
 import (
+	"database/sql"
 	"log"
-	"sync"
 )
 
 // SijagurResponse represents the response structure matching articles
@@ -65,11 +65,17 @@ type RealisasiResponse struct {
 
 // RealisasiMeta represents metadata for the realisasi response
 type RealisasiMeta struct {
-	Year      int    `json:"year"`
-	Month     int    `json:"month"`
-	MonthName string `json:"month_name,omitempty"`
-	Idsatker  int    `json:"idsatker"`
-	Type      string `json:"type"` // "bulan" or "tahun"
+	Year       int    `json:"year"`
+	Month      int    `json:"month"`
+	MonthName  string `json:"month_name,omitempty"`
+	Idsatker   int    `json:"idsatker"`
+	NamaOpd    string `json:"nama_opd,omitempty"`    // registry name of the satker, empty for the region
+	ShortName  string `json:"short_name,omitempty"`  // registry short name
+	Type       string `json:"type"`                  // "bulan" or "tahun"
+	Period     string `json:"period,omitempty"`      // "triwulan" | "semester", empty for a month
+	Periode    int    `json:"periode,omitempty"`     // number of the triwulan or semester
+	Label      string `json:"label,omitempty"`       // "Triwulan II"
+	LastUpdate int64  `json:"last_update,omitempty"` // latest last_update of the rows behind the data, epoch seconds
 }
 
 // RealisasiBulanResponse alias for backward compatibility
@@ -90,6 +96,11 @@ type ProgressData struct {
 	KumulatifFisik    float64
 	KumulatifAnggaran float64
 	KumulatifKinerja  float64
+	PeriodikOpd       float64
+	PeriodikBarjas    float64
+	PeriodikFisik     float64
+	PeriodikAnggaran  float64
+	PeriodikKinerja   float64
 }
 
 // SijagurData ...
@@ -102,16 +113,18 @@ type SijagurData struct{}
 // - *_formatted: pre-formatted strings for direct display on frontend
 // - rank_number: peringkat_opd when dimension="kumulatif", otherwise can be 0 (frontend may infer)
 type RankingRow struct {
-	ID            int64   `json:"id"`
-	Idsatker      int64   `json:"idsatker,omitempty"`
-	NamaOpd       string  `json:"nama_opd,omitempty"`
-	RankNumber    int64   `json:"rank_number"`
-	ScoreTotal    float64 `json:"score_total"`
-	ScoreBarjas   float64 `json:"score_barjas,omitempty"`
-	ScoreFisik    float64 `json:"score_fisik,omitempty"`
-	ScoreAnggaran float64 `json:"score_anggaran,omitempty"`
-	ScoreKinerja  float64 `json:"score_kinerja,omitempty"`
-	ScoreStatus   string  `json:"score_status"` // "Diam" | "Berjalan" | "Berlari" | "Melesat"
+	ID               int64   `json:"id"`
+	Idsatker         int64   `json:"idsatker,omitempty"`
+	NamaOpd          string  `json:"nama_opd,omitempty"`
+	ShortName        string  `json:"short_name,omitempty"`
+	RankNumber       int64   `json:"rank_number"`
+	ScoreTotal       float64 `json:"score_total"`
+	ScoreBarjas      float64 `json:"score_barjas,omitempty"`
+	ScoreFisik       float64 `json:"score_fisik,omitempty"`
+	ScoreAnggaran    float64 `json:"score_anggaran,omitempty"`
+	ScoreKinerja     float64 `json:"score_kinerja,omitempty"`
+	ScoreStatus      string  `json:"score_status"` // label of the score_total band, see ScoreThresholds
+	ScoreStatusColor string  `json:"score_status_color,omitempty"`
 
 	// Pre-formatted values for UI (percent-style)
 	ScoreTotalFormatted    string `json:"score_total_formatted,omitempty"`
@@ -126,134 +139,98 @@ type RankingRow struct {
 
 // RankingResponse is the top-level contract for the Peringkat Kinerja endpoint.
 type RankingResponse struct {
-	Status    string       `json:"status"` // "success"
-	Scope     string       `json:"scope"`  // "opd"
-	Category  string       `json:"category"`
-	Dimension string       `json:"dimension"` // "kumulatif" | "capaian" | "periodik"
-	Year      int          `json:"year"`
-	Month     int          `json:"month,omitempty"`
-	Page      int          `json:"page"`
-	PageSize  int          `json:"page_size"`
-	Total     int          `json:"total"`
-	SortBy    string       `json:"sort_by"`
-	SortDir   string       `json:"sort_dir"`
-	Data      []RankingRow `json:"data"`
-}
-
-// scoreStatusFromTotal maps score_total (0-100) to human-readable label.
-func scoreStatusFromTotal(score float64) string {
-	if score >= 75 {
-		return "Melesat"
-	}
-	if score >= 50 {
-		return "Berlari"
-	}
-	if score >= 25 {
-		return "Berjalan"
-	}
-	return "Diam"
+	Status     string           `json:"status"` // "success"
+	Scope      string           `json:"scope"`  // "opd"
+	Category   string           `json:"category"`
+	Dimension  string           `json:"dimension"` // "kumulatif" | "capaian" | "periodik"
+	Year       int              `json:"year"`
+	Month      int              `json:"month,omitempty"`
+	Period     string           `json:"period,omitempty"`      // "triwulan" | "semester", empty for a month
+	Periode    int              `json:"periode,omitempty"`     // number of the triwulan or semester
+	Label      string           `json:"label,omitempty"`       // "Triwulan II"
+	LastUpdate int64            `json:"last_update,omitempty"` // latest last_update of the ranked rows, epoch seconds
+	Source     string           `json:"source"`                // "published" when served from a snapshot, "live" otherwise
+	Snapshot   *RankingSnapshot `json:"snapshot,omitempty"`
+	Page       int              `json:"page"`
+	PageSize   int              `json:"page_size"`
+	Total      int              `json:"total"`
+	SortBy     string           `json:"sort_by"`
+	SortDir    string           `json:"sort_dir"`
+	Data       []RankingRow     `json:"data"`
 }
 
 // GetRealisasiBulanWithParams retrieves raw realisasi bulan data for frontend processing
 func (m SijagurData) GetRealisasiBulanWithParams(year, month, idsatker int) ([]RealisasiData, error) {
-	// First, get the progress percentages from de_ranking_opd
-	progressData, err := m.getProgressData(year, month, idsatker)
+	return m.realisasiCards(year, month, idsatker, "bulan")
+}
+
+// GetRealisasiTahunWithParams retrieves raw realisasi tahun data for frontend processing
+func (m SijagurData) GetRealisasiTahunWithParams(year, month, idsatker int) ([]RealisasiData, error) {
+	return m.realisasiCards(year, month, idsatker, "tahun")
+}
+
+// realisasiCards builds the cards of dataType from the row of the satker and month
+func (m SijagurData) realisasiCards(year, month, idsatker int, dataType string) ([]RealisasiData, error) {
+	row, err := m.realisasiRow(year, month, idsatker)
 	if err != nil {
-		log.Printf("Error getting progress data: %v", err)
+		log.Printf("Error getting realisasi %s data: %v", dataType, err)
 		return nil, err
 	}
+	return row.Cards(dataType), nil
+}
 
-	// Use goroutines to fetch data concurrently
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	data := make([]RealisasiData, 4)
-	errors := make([]error, 4)
-
-	// Helper function to fetch data concurrently
-	fetchData := func(index int, fetchFunc func() (RealisasiData, error)) {
-		defer wg.Done()
-		result, err := fetchFunc()
-		mu.Lock()
-		data[index] = result
-		errors[index] = err
-		mu.Unlock()
-	}
-
-	wg.Add(4)
-	go fetchData(0, func() (RealisasiData, error) {
-		return m.getBarjasRawData(year, month, idsatker, progressData.CapaianBarjas)
-	})
-	go fetchData(1, func() (RealisasiData, error) {
-		return m.getFisikRawData(year, month, idsatker, progressData.CapaianFisik)
-	})
-	go fetchData(2, func() (RealisasiData, error) {
-		return m.getAnggaranRawData(year, month, idsatker, progressData.CapaianAnggaran)
-	})
-	go fetchData(3, func() (RealisasiData, error) {
-		return m.getKinerjaRawData(year, month, idsatker, progressData.CapaianKinerja)
-	})
-
-	wg.Wait()
-
-	// Check for errors
-	for _, err := range errors {
+// realisasiRow loads the ranking row of the satker with its four detail rows and registry name in
+// one query. idsatker 0 is always computed from the satkers, a pre-inserted region row can lag
+// behind them.
+func (m SijagurData) realisasiRow(year, month, idsatker int) (RealisasiRow, error) {
+	if idsatker == 0 {
+		rows, err := regionRows(year, month)
 		if err != nil {
-			return nil, err
+			return RealisasiRow{}, err
 		}
+		return AggregateRows(rows), nil
 	}
 
-	return data, nil
-}
-
-// GetRealisasiTahunWithParams retrieves raw realisasi tahun data for frontend processing
-func (m SijagurData) GetRealisasiTahunWithParams(year, month, idsatker int) ([]RealisasiData, error) {
-	// First, get the progress percentages from de_ranking_opd
-	progressData, err := m.getProgressData(year, month, idsatker)
+	rows, err := FetchRealisasiRows(year, month, []int{idsatker})
 	if err != nil {
-		log.Printf("Error getting progress data tahun: %v", err)
-		return nil, err
+		return RealisasiRow{}, err
 	}
-
-	// Use goroutines to fetch data concurrently
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	data := make([]RealisasiData, 4)
-	errors := make([]error, 4)
-
-	// Helper function to fetch data concurrently
-	fetchData := func(index int, fetchFunc func() (RealisasiData, error)) {
-		defer wg.Done()
-		result, err := fetchFunc()
-		mu.Lock()
-		data[index] = result
-		errors[index] = err
-		mu.Unlock()
+	row, ok := rows[idsatker]
+	if !ok {
+		return RealisasiRow{}, sql.ErrNoRows
 	}
+	return row, nil
+}
 
-	wg.Add(4)
-	go fetchData(0, func() (RealisasiData, error) {
-		return m.getBarjasRawDataTahun(year, month, idsatker, progressData.KumulatifBarjas, progressData.CapaianBarjas)
-	})
-	go fetchData(1, func() (RealisasiData, error) {
-		return m.getFisikRawDataTahun(year, month, idsatker, progressData.KumulatifFisik, progressData.CapaianFisik)
-	})
-	go fetchData(2, func() (RealisasiData, error) {
-		return m.getAnggaranRawDataTahun(year, month, idsatker, progressData.KumulatifAnggaran, progressData.CapaianAnggaran)
-	})
-	go fetchData(3, func() (RealisasiData, error) {
-		return m.getKinerjaRawDataTahun(year, month, idsatker, progressData.KumulatifKinerja, progressData.CapaianKinerja)
-	})
-
-	wg.Wait()
-
-	// Check for errors
-	for _, err := range errors {
-		if err != nil {
-			return nil, err
-		}
+// GetRealisasiResult returns the realisasi cards of dataType ("bulan" or "tahun") for a month or a
+// period, with their meta. last_update and the registry name of the satker come with the rows
+// behind the cards, so the result costs one query.
+func (m SijagurData) GetRealisasiResult(year int, period Period, idsatker int, dataType string) (SijagurResult, error) {
+	meta := RealisasiMeta{Year: year, Idsatker: idsatker, Type: dataType}
+	cardType := dataType
+
+	var row RealisasiRow
+	var err error
+	if period.IsMonth() {
+		meta.Month = period.Number
+		row, err = m.realisasiRow(year, period.Number, idsatker)
+	} else {
+		_, meta.Month = period.Months()
+		meta.Period, meta.Periode, meta.Label = period.Type, period.Number, period.Label()
+		cardType = periodCardType(dataType)
+		row, err = m.periodRow(year, period, idsatker)
+	}
+	if err != nil {
+		log.Printf("Error getting realisasi %s data: %v", dataType, err)
+		return SijagurResult{Meta: meta}, err
 	}
 
-	return data, nil
+	meta.MonthName = GetMonthName(meta.Month)
+	meta.LastUpdate = row.LastUpdate
+	if idsatker != 0 {
+		meta.NamaOpd, meta.ShortName = row.Satker.Name, row.Satker.ShortName
+	}
+	return SijagurResult{Data: row.Cards(cardType), Meta: meta}, nil
 }
 
 // ProcessRealisasiPerbulan processes pre-fetched monthly progress data into RealisasiData format
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Reporting periods of a year. The realisasi and peringkat endpoints default to PeriodBulan.
+const (
+	PeriodBulan    = "bulan"
+	PeriodTriwulan = "triwulan"
+	PeriodSemester = "semester"
+)
+
+// ErrInvalidPeriod is returned for a period number outside of its year
+var ErrInvalidPeriod = errors.New("periode should be 1-12 for bulan, 1-4 for triwulan and 1-2 for semester")
+
+// Period is a month, a triwulan or a semester of a year
+type Period struct {
+	Type   string
+	Number int
+}
+
+// periodMonths is the number of months of each period type
+var periodMonths = map[string]int{PeriodBulan: 1, PeriodTriwulan: 3, PeriodSemester: 6}
+
+// NewPeriod builds a period of periodType. Without a number the period is the one containing month.
+func NewPeriod(periodType string, number, month int) (Period, error) {
+	if periodType == "" {
+		periodType = PeriodBulan
+	}
+	length, ok := periodMonths[periodType]
+	if !ok {
+		return Period{}, ErrInvalidPeriod
+	}
+	if number == 0 && month >= 1 && month <= 12 {
+		number = (month-1)/length + 1
+	}
+	if number < 1 || number > 12/length {
+		return Period{}, ErrInvalidPeriod
+	}
+	return Period{Type: periodType, Number: number}, nil
+}
+
+// IsMonth ...
+func (p Period) IsMonth() bool {
+	return p.Type == "" || p.Type == PeriodBulan
+}
+
+// Months returns the first and last month of the period
+func (p Period) Months() (first, last int) {
+	length := periodMonths[p.Type]
+	if length == 0 {
+		length = 1
+	}
+	last = p.Number * length
+	return last - length + 1, last
+}
+
+// Label is the localised name of the period, "Juni", "Triwulan II" or "Semester I"
+func (p Period) Label() string {
+	if p.Number == 0 {
+		return ""
+	}
+	switch p.Type {
+	case PeriodTriwulan:
+		return GetTriwulanName(p.Number)
+	case PeriodSemester:
+		return GetSemesterName(p.Number)
+	}
+	return GetMonthName(p.Number)
+}
+
+// AggregatePeriod collapses the monthly rows of one satker, ordered by bulan, into one period row.
+// Capaian and kumulatif are states, so their percentages and c_/k_ values are the ones of the
+// last reported month. Periodik values measure the month itself: p_ targets, realisasi and
+// stage counts are summed and the periodik_ percentages averaged weighted by the p_ targets.
+// LastUpdate is the latest of the months.
+func AggregatePeriod(rows []RealisasiRow) RealisasiRow {
+	if len(rows) == 0 {
+		return RealisasiRow{}
+	}
+	period := rows[len(rows)-1]
+
+	// The p_ values are rebuilt from every month, the opd and category weights as in AggregateRows
+	summed := AggregateRows(rows)
+	period.BarjasStages[periodikColumns] = summed.BarjasStages[periodikColumns]
+	period.BarjasRealisasi[periodikColumns] = summed.BarjasRealisasi[periodikColumns]
+	period.BarjasTarget[periodikColumns] = summed.BarjasTarget[periodikColumns]
+	period.FisikRealisasi[periodikColumns] = summed.FisikRealisasi[periodikColumns]
+	period.FisikTarget[periodikColumns] = summed.FisikTarget[periodikColumns]
+	period.AnggaranRealisasi[periodikColumns] = summed.AnggaranRealisasi[periodikColumns]
+	period.AnggaranTarget[periodikColumns] = summed.AnggaranTarget[periodikColumns]
+	period.KinerjaRealisasi[periodikColumns] = summed.KinerjaRealisasi[periodikColumns]
+	period.KinerjaTarget[periodikColumns] = summed.KinerjaTarget[periodikColumns]
+	period.Progress.PeriodikOpd = summed.Progress.PeriodikOpd
+	period.Progress.PeriodikBarjas = summed.Progress.PeriodikBarjas
+	period.Progress.PeriodikFisik = summed.Progress.PeriodikFisik
+	period.Progress.PeriodikAnggaran = summed.Progress.PeriodikAnggaran
+	period.Progress.PeriodikKinerja = summed.Progress.PeriodikKinerja
+	period.LastUpdate = summed.LastUpdate
+	return period
+}
+
+// GetRealisasiPeriod returns the realisasi cards of a triwulan or semester. dataType "tahun" gives
+// the kumulatif cards at the end of the period, anything else the periodik cards of the period's
+// own activity. For idsatker 0 each month of the region is computed from the satkers.
+func (m SijagurData) GetRealisasiPeriod(year int, period Period, idsatker int, dataType string) ([]RealisasiData, error) {
+	row, err := m.periodRow(year, period, idsatker)
+	if err != nil {
+		return nil, err
+	}
+	return row.Cards(periodCardType(dataType)), nil
+}
+
+// periodCardType is the card shape of a period: the kumulatif cards for "tahun", else the periodik ones
+func periodCardType(dataType string) string {
+	if dataType == "tahun" {
+		return "tahun"
+	}
+	return "periodik"
+}
+
+// periodRow collapses the months of the period into one row, see AggregatePeriod
+func (m SijagurData) periodRow(year int, period Period, idsatker int) (RealisasiRow, error) {
+	first, last := period.Months()
+	var rows []RealisasiRow
+	var err error
+	if idsatker == 0 {
+		var satkers []RealisasiRow
+		satkers, err = queryRealisasiRows(`WHERE dro.tahun = $1 AND dro.bulan BETWEEN $2 AND $3 AND dro.idsatker <> 0`, year, first, last)
+		rows = regionByMonth(satkers)
+	} else {
+		rows, err = queryRealisasiRows(`WHERE dro.tahun = $1 AND dro.bulan BETWEEN $2 AND $3 AND dro.idsatker = $4`, year, first, last, idsatker)
+	}
+	if err != nil {
+		return RealisasiRow{}, err
+	}
+	if len(rows) == 0 {
+		return RealisasiRow{}, sql.ErrNoRows
+	}
+	return AggregatePeriod(rows), nil
+}
+
+// regionByMonth aggregates satker rows into one region row per month, ordered by bulan
+func regionByMonth(rows []RealisasiRow) []RealisasiRow {
+	byMonth := map[int][]RealisasiRow{}
+	for _, r := range rows {
+		byMonth[r.Bulan] = append(byMonth[r.Bulan], r)
+	}
+
+	var region []RealisasiRow
+	for month := 1; month <= 12; month++ {
+		if len(byMonth[month]) > 0 {
+			region = append(region, AggregateRows(byMonth[month]))
+		}
+	}
+	return region
+}
+
+// periodRankingSource is de_ranking_opd collapsed over the months $2 to $3 of tahun $1, with the
+// columns read by GetPeringkatKinerja: capaian_, kumulatif_ and peringkat_opd of the last reported
+// month, periodik_ percentages averaged over the period weighted by the p_ targets (plain mean
+// when the satker has no p_ target, and always for periodik_opd), as in AggregatePeriod, and
+// data_update, the latest last_update of the period's ranking and detail rows
+var periodRankingSource = fmt.Sprintf(`(
+	SELECT l.id, l.idsatker, l.nama_opd, l.jenis_opd,
+		l.capaian_opd, l.capaian_barjas, l.capaian_fisik, l.capaian_anggaran, l.capaian_kinerja,
+		l.kumulatif_opd, l.kumulatif_barjas, l.kumulatif_fisik, l.kumulatif_anggaran, l.kumulatif_kinerja,
+		a.periodik_opd, a.periodik_barjas, a.periodik_fisik, a.periodik_anggaran, a.periodik_kinerja,
+		l.peringkat_opd, l.tahun, l.bulan, a.data_update
+	FROM (
+		SELECT DISTINCT ON (idsatker) * FROM de_ranking_opd
+		WHERE tahun = $1 AND bulan BETWEEN $2 AND $3
+		ORDER BY idsatker, bulan DESC, last_update DESC NULLS LAST, id DESC
+	) l
+	JOIN (
+		SELECT dro.idsatker,
+			AVG(dro.periodik_opd) AS periodik_opd,
+			%s AS periodik_barjas,
+			%s AS periodik_fisik,
+			%s AS periodik_anggaran,
+			%s AS periodik_kinerja,
+			MAX(GREATEST(dro.last_update, ddb.last_update, ddf.last_update, dda.last_update, ddk.last_update)) AS data_update
+		FROM de_ranking_opd dro
+		LEFT JOIN de_detail_barjas ddb ON ddb.id_ranking_opd = dro.id
+		LEFT JOIN de_detail_fisik ddf ON ddf.id_ranking_opd = dro.id
+		LEFT JOIN de_detail_anggaran dda ON dda.id_ranking_opd = dro.id
+		LEFT JOIN de_detail_kinerja ddk ON ddk.id_ranking_opd = dro.id
+		WHERE dro.tahun = $1 AND dro.bulan BETWEEN $2 AND $3
+		GROUP BY dro.idsatker
+	) a ON a.idsatker = l.idsatker
+) pr`,
+	weightedPeriodik("barjas", "ddb"), weightedPeriodik("fisik", "ddf"),
+	weightedPeriodik("anggaran", "dda"), weightedPeriodik("kinerja", "ddk"))
+
+// weightedPeriodik averages periodik_<category> weighted by the p_ target of its detail table
+func weightedPeriodik(category, alias string) string {
+	return fmt.Sprintf("COALESCE(SUM(dro.periodik_%[1]s * %[2]s.p_%[1]s_target) / NULLIF(SUM(%[2]s.p_%[1]s_target), 0), AVG(dro.periodik_%[1]s))",
+		category, alias)
+}
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+// RegionAggregate is the realisasi of a group of satkers computed from their own rows
+type RegionAggregate struct {
+	JenisOpd string          `json:"jenis_opd,omitempty"` // empty for the whole region
+	Satkers  int             `json:"satkers"`
+	Opd      float64         `json:"opd"` // capaian_opd for bulan, kumulatif_opd for tahun
+	Data     []RealisasiData `json:"data"`
+}
+
+// RegionDifference is a value of the stored idsatker 0 row that does not match the computed one
+type RegionDifference struct {
+	Category   string  `json:"category"`
+	Field      string  `json:"field"`
+	Computed   float64 `json:"computed"`
+	Stored     float64 `json:"stored"`
+	Difference float64 `json:"difference"` // stored - computed
+}
+
+// RegionReconciliation compares the computed region with the pre-inserted idsatker 0 row
+type RegionReconciliation struct {
+	Stored      bool               `json:"stored"` // false when there is no idsatker 0 row for the month
+	Tolerance   float64            `json:"tolerance"`
+	Consistent  bool               `json:"consistent"`
+	Differences []RegionDifference `json:"differences"`
+}
+
+// RegionResponse is the top-level contract of the region endpoint
+type RegionResponse struct {
+	Status         string               `json:"status"` // "success"
+	Year           int                  `json:"year"`
+	Month          int                  `json:"month"`
+	MonthName      string               `json:"month_name"`
+	Type           string               `json:"type"` // "bulan" or "tahun"
+	Region         RegionAggregate      `json:"region"`
+	JenisOpd       []RegionAggregate    `json:"jenis_opd"`
+	Reconciliation RegionReconciliation `json:"reconciliation"`
+}
+
+// weightedMean averages values by weights, falling back to the plain mean when every weight is zero
+func weightedMean(values, weights []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum, weighted, total float64
+	for i, value := range values {
+		sum += value
+		weighted += value * weights[i]
+		total += weights[i]
+	}
+	if total == 0 {
+		return sum / float64(len(values))
+	}
+	return weighted / total
+}
+
+// AggregateRows adds up satker rows of one month into one region row. Targets, realisasi and
+// barjas stage counts are summed. Each capaian_, kumulatif_ and periodik_ percentage is averaged
+// weighted by the category's c_, k_ or p_ target, so a satker with a larger budget or more
+// packages weighs more. The opd percentages have no target of their own and use the plain mean.
+// LastUpdate is the latest of the rows.
+// LastUpdate is the latest of the rows.
+func AggregateRows(rows []RealisasiRow) RealisasiRow {
+	var region RealisasiRow
+	if len(rows) == 0 {
+		return region
+	}
+	region.Bulan = rows[0].Bulan
+
+	progress := make(map[string][]float64)
+	weights := make(map[string][]float64)
+	add := func(key string, value, weight float64) {
+		progress[key] = append(progress[key], value)
+		weights[key] = append(weights[key], weight)
+	}
+
+	for _, r := range rows {
+		region.addValues(r)
+		if r.LastUpdate > region.LastUpdate {
+			region.LastUpdate = r.LastUpdate
+		}
+		if r.LastUpdate > region.LastUpdate {
+			region.LastUpdate = r.LastUpdate
+		}
+
+		p := r.Progress
+		add("capaian_opd", p.CapaianOpd, 0)
+		add("capaian_barjas", p.CapaianBarjas, r.BarjasTarget[bulanColumns])
+		add("capaian_fisik", p.CapaianFisik, r.FisikTarget[bulanColumns])
+		add("capaian_anggaran", p.CapaianAnggaran, r.AnggaranTarget[bulanColumns])
+		add("capaian_kinerja", p.CapaianKinerja, r.KinerjaTarget[bulanColumns])
+		add("kumulatif_opd", p.KumulatifOpd, 0)
+		add("kumulatif_barjas", p.KumulatifBarjas, r.BarjasTarget[tahunColumns])
+		add("kumulatif_fisik", p.KumulatifFisik, r.FisikTarget[tahunColumns])
+		add("kumulatif_anggaran", p.KumulatifAnggaran, r.AnggaranTarget[tahunColumns])
+		add("kumulatif_kinerja", p.KumulatifKinerja, r.KinerjaTarget[tahunColumns])
+		add("periodik_opd", p.PeriodikOpd, 0)
+		add("periodik_barjas", p.PeriodikBarjas, r.BarjasTarget[periodikColumns])
+		add("periodik_fisik", p.PeriodikFisik, r.FisikTarget[periodikColumns])
+		add("periodik_anggaran", p.PeriodikAnggaran, r.AnggaranTarget[periodikColumns])
+		add("periodik_kinerja", p.PeriodikKinerja, r.KinerjaTarget[periodikColumns])
+	}
+
+	mean := func(key string) float64 { return weightedMean(progress[key], weights[key]) }
+	region.Progress = ProgressData{
+		CapaianOpd:        mean("capaian_opd"),
+		CapaianBarjas:     mean("capaian_barjas"),
+		CapaianFisik:      mean("capaian_fisik"),
+		CapaianAnggaran:   mean("capaian_anggaran"),
+		CapaianKinerja:    mean("capaian_kinerja"),
+		KumulatifOpd:      mean("kumulatif_opd"),
+		KumulatifBarjas:   mean("kumulatif_barjas"),
+		KumulatifFisik:    mean("kumulatif_fisik"),
+		KumulatifAnggaran: mean("kumulatif_anggaran"),
+		KumulatifKinerja:  mean("kumulatif_kinerja"),
+		PeriodikOpd:       mean("periodik_opd"),
+		PeriodikBarjas:    mean("periodik_barjas"),
+		PeriodikFisik:     mean("periodik_fisik"),
+		PeriodikAnggaran:  mean("periodik_anggaran"),
+		PeriodikKinerja:   mean("periodik_kinerja"),
+	}
+	return region
+}
+
+// addValues adds the stage counts, targets and realisasi of r for every column prefix
+func (region *RealisasiRow) addValues(r RealisasiRow) {
+	for columns := range columnPrefixes {
+		region.addColumns(r, columns)
+	}
+}
+
+// addColumns adds the stage counts, targets and realisasi of r for one column prefix
+func (region *RealisasiRow) addColumns(r RealisasiRow, columns int) {
+	for stage := range r.BarjasStages[columns] {
+		for k := range r.BarjasStages[columns][stage] {
+			region.BarjasStages[columns][stage][k] += r.BarjasStages[columns][stage][k]
+		}
+	}
+	region.BarjasRealisasi[columns] += r.BarjasRealisasi[columns]
+	region.BarjasTarget[columns] += r.BarjasTarget[columns]
+	region.FisikRealisasi[columns] += r.FisikRealisasi[columns]
+	region.FisikTarget[columns] += r.FisikTarget[columns]
+	region.AnggaranRealisasi[columns] += r.AnggaranRealisasi[columns]
+	region.AnggaranTarget[columns] += r.AnggaranTarget[columns]
+	region.KinerjaRealisasi[columns] += r.KinerjaRealisasi[columns]
+	region.KinerjaTarget[columns] += r.KinerjaTarget[columns]
+}
+
+// regionMeasure is one reconciled value of a row
+type regionMeasure struct {
+	Category string
+	Field    string
+	Value    float64
+}
+
+// measures lists the values of a row compared by Reconcile, in a stable order
+func (r RealisasiRow) measures() []regionMeasure {
+	p := r.Progress
+	list := []regionMeasure{
+		{"opd", "capaian", p.CapaianOpd},
+		{"opd", "kumulatif", p.KumulatifOpd},
+		{"opd", "periodik", p.PeriodikOpd},
+	}
+
+	pairs := []struct {
+		category                     string
+		capaian, kumulatif, periodik float64
+		realisasi, target            [3]float64
+	}{
+		{"barjas", p.CapaianBarjas, p.KumulatifBarjas, p.PeriodikBarjas, r.BarjasRealisasi, r.BarjasTarget},
+		{"fisik", p.CapaianFisik, p.KumulatifFisik, p.PeriodikFisik, r.FisikRealisasi, r.FisikTarget},
+		{"anggaran", p.CapaianAnggaran, p.KumulatifAnggaran, p.PeriodikAnggaran, r.AnggaranRealisasi, r.AnggaranTarget},
+		{"kinerja", p.CapaianKinerja, p.KumulatifKinerja, p.PeriodikKinerja, r.KinerjaRealisasi, r.KinerjaTarget},
+	}
+	for _, c := range pairs {
+		list = append(list,
+			regionMeasure{c.category, "capaian", c.capaian},
+			regionMeasure{c.category, "kumulatif", c.kumulatif},
+			regionMeasure{c.category, "periodik", c.periodik},
+		)
+		for columns, prefix := range columnPrefixes {
+			list = append(list,
+				regionMeasure{c.category, prefix + "realisasi", c.realisasi[columns]},
+				regionMeasure{c.category, prefix + "target", c.target[columns]},
+			)
+		}
+	}
+
+	// The monthly stages keep their unprefixed names from before the k_ and p_ stages were added
+	for columns, prefix := range columnPrefixes {
+		if columns == bulanColumns {
+			prefix = ""
+		}
+		for i, stage := range barjasStageNames {
+			s := r.BarjasStages[columns][i]
+			list = append(list,
+				regionMeasure{"barjas", prefix + stage + "_selesai", float64(s[0])},
+				regionMeasure{"barjas", prefix + stage + "_target", float64(s[1])},
+				regionMeasure{"barjas", prefix + stage + "_terlambat", float64(s[2])},
+			)
+		}
+	}
+	return list
+}
+
+// Reconcile lists the values of stored that differ from computed by more than tolerance
+func Reconcile(computed, stored RealisasiRow, tolerance float64) []RegionDifference {
+	differences := []RegionDifference{}
+	storedMeasures := stored.measures()
+	for i, measure := range computed.measures() {
+		diff := storedMeasures[i].Value - measure.Value
+		if math.Abs(diff) <= tolerance {
+			continue
+		}
+		differences = append(differences, RegionDifference{
+			Category:   measure.Category,
+			Field:      measure.Field,
+			Computed:   measure.Value,
+			Stored:     storedMeasures[i].Value,
+			Difference: diff,
+		})
+	}
+	return differences
+}
+
+// regionAggregate ...
+func regionAggregate(jenisOpd string, rows []RealisasiRow, dataType string) RegionAggregate {
+	region := AggregateRows(rows)
+	opd := region.Progress.CapaianOpd
+	if dataType == "tahun" {
+		opd = region.Progress.KumulatifOpd
+	}
+	return RegionAggregate{JenisOpd: jenisOpd, Satkers: len(rows), Opd: opd, Data: region.Cards(dataType)}
+}
+
+// regionRows loads every satker row of the month, the idsatker 0 aggregate row excluded
+func regionRows(year, month int) ([]RealisasiRow, error) {
+	return queryRealisasiRows(`WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker <> 0`, year, month)
+}
+
+// AggregateRegion computes the region from the individual satker rows, broken down by jenis_opd,
+// and reconciles it against the stored idsatker 0 row when there is one
+func (m SijagurData) AggregateRegion(year, month int, dataType string, tolerance float64) (RegionResponse, error) {
+	response := RegionResponse{
+		Status:    "success",
+		Year:      year,
+		Month:     month,
+		MonthName: GetMonthName(month),
+		Type:      dataType,
+		JenisOpd:  []RegionAggregate{},
+	}
+
+	rows, err := regionRows(year, month)
+	if err != nil {
+		return response, err
+	}
+	stored, err := FetchRealisasiRows(year, month, []int{0})
+	if err != nil {
+		return response, err
+	}
+
+	response.Region = regionAggregate("", rows, dataType)
+
+	groups := map[string][]RealisasiRow{}
+	for _, r := range rows {
+		groups[r.JenisOpd] = append(groups[r.JenisOpd], r)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		response.JenisOpd = append(response.JenisOpd, regionAggregate(name, groups[name], dataType))
+	}
+
+	response.Reconciliation = RegionReconciliation{Tolerance: tolerance, Consistent: true, Differences: []RegionDifference{}}
+	if row, ok := stored[0]; ok {
+		response.Reconciliation.Stored = true
+		response.Reconciliation.Differences = Reconcile(AggregateRows(rows), row, tolerance)
+		response.Reconciliation.Consistent = len(response.Reconciliation.Differences) == 0
+	}
+	return response, nil
+}
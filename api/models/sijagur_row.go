@@ -0,0 +1,236 @@
+package models
+
+import (
+	"log"
+	"strings"
+
+	"github.com/Massad/gin-boilerplate/db"
+
+	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
+)
+
+// RealisasiRow is the de_ranking_opd row of one satker and month with its four detail rows
+type RealisasiRow struct {
+	Idsatker int
+	NamaOpd  string
+	JenisOpd string
+	Bulan    int
+	Progress ProgressData
+
+	// LastUpdate is the latest last_update of the ranking and detail rows, epoch seconds
+	LastUpdate int64
+
+	// Satker is the registry name, empty when the satker is not registered
+	Satker SatkerName
+
+	// Stage counts of de_detail_barjas by c_/k_/p_ prefix: selesai, target, terlambat for
+	// perencanaan, pemilihan, pengadaan and penyerahan
+	BarjasStages [3][4][3]int64
+
+	// c_, k_ and p_ values, indexed by bulanColumns, tahunColumns and periodikColumns
+	BarjasRealisasi, BarjasTarget     [3]float64
+	FisikRealisasi, FisikTarget       [3]float64
+	AnggaranRealisasi, AnggaranTarget [3]float64
+	KinerjaRealisasi, KinerjaTarget   [3]float64
+}
+
+// Indexes of the c_/k_/p_ values of RealisasiRow
+const (
+	bulanColumns = iota
+	tahunColumns
+	periodikColumns
+)
+
+// columnPrefixes are the detail column prefixes in the order of the indexes above
+var columnPrefixes = []string{"c_", "k_", "p_"}
+
+// barjasStageNames are the procurement stages in de_detail_barjas column order
+var barjasStageNames = []string{"perencanaan", "pemilihan", "pengadaan", "penyerahan"}
+
+// realisasiRowQuery joins the four detail tables and the satker registry to the ranking row so a
+// satker costs no extra round trip. Missing detail rows read as zero. The WHERE clause is appended
+// by the caller.
+var realisasiRowQuery = buildRealisasiRowQuery()
+
+// buildRealisasiRowQuery lists the detail columns in the order queryRealisasiRows scans them
+func buildRealisasiRowQuery() string {
+	var columns []string
+	for _, prefix := range columnPrefixes {
+		for _, stage := range barjasStageNames {
+			for _, count := range []string{"selesai", "target", "terlambat"} {
+				columns = append(columns, "COALESCE(ddb."+prefix+stage+"_"+count+", 0)")
+			}
+		}
+	}
+	for _, prefix := range columnPrefixes {
+		for _, detail := range []struct{ alias, category string }{{"ddb", "barjas"}, {"ddf", "fisik"}, {"dda", "anggaran"}, {"ddk", "kinerja"}} {
+			columns = append(columns,
+				"COALESCE("+detail.alias+"."+prefix+detail.category+"_realisasi, 0)",
+				"COALESCE("+detail.alias+"."+prefix+detail.category+"_target, 0)")
+		}
+	}
+
+	return `
+	SELECT DISTINCT ON (dro.idsatker, dro.bulan)
+		dro.idsatker, COALESCE(dro.nama_opd, ''), COALESCE(dro.jenis_opd, ''), dro.bulan,
+		COALESCE(GREATEST(dro.last_update, ddb.last_update, ddf.last_update, dda.last_update, ddk.last_update), 0),
+		dro.capaian_opd, dro.capaian_barjas, dro.capaian_fisik, dro.capaian_anggaran, dro.capaian_kinerja,
+		dro.kumulatif_opd, dro.kumulatif_barjas, dro.kumulatif_fisik, dro.kumulatif_anggaran, dro.kumulatif_kinerja,
+		dro.periodik_opd, dro.periodik_barjas, dro.periodik_fisik, dro.periodik_anggaran, dro.periodik_kinerja,
+		` + strings.Join(columns, ",\n\t\t") + `,
+		COALESCE(s.name, ''), COALESCE(s.short_name, '')
+	FROM de_ranking_opd dro
+	LEFT JOIN de_detail_barjas ddb ON ddb.id_ranking_opd = dro.id
+	LEFT JOIN de_detail_fisik ddf ON ddf.id_ranking_opd = dro.id
+	LEFT JOIN de_detail_anggaran dda ON dda.id_ranking_opd = dro.id
+	LEFT JOIN de_detail_kinerja ddk ON ddk.id_ranking_opd = dro.id
+	LEFT JOIN public.satkers s ON s.idsatker = dro.idsatker
+	`
+}
+
+// queryRealisasiRows runs realisasiRowQuery with the given WHERE clause, one row per satker and month
+// ordered by idsatker then bulan. When a satker has several ranking rows for a month the latest update wins.
+func queryRealisasiRows(where string, args ...interface{}) ([]RealisasiRow, error) {
+	return queryRealisasiRowsOn(db.GetDB(), where, args...)
+}
+
+// queryRealisasiRowsOn is queryRealisasiRows on the given connection or transaction
+func queryRealisasiRowsOn(executor gorp.SqlExecutor, where string, args ...interface{}) ([]RealisasiRow, error) {
+	rows, err := executor.Query(realisasiRowQuery+where+` ORDER BY dro.idsatker, dro.bulan, dro.last_update DESC NULLS LAST, dro.id DESC`, args...)
+	if err != nil {
+		log.Printf("Error querying realisasi rows: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RealisasiRow
+	for rows.Next() {
+		var r RealisasiRow
+		p := &r.Progress
+		dest := []interface{}{
+			&r.Idsatker, &r.NamaOpd, &r.JenisOpd, &r.Bulan, &r.LastUpdate,
+			&p.CapaianOpd, &p.CapaianBarjas, &p.CapaianFisik, &p.CapaianAnggaran, &p.CapaianKinerja,
+			&p.KumulatifOpd, &p.KumulatifBarjas, &p.KumulatifFisik, &p.KumulatifAnggaran, &p.KumulatifKinerja,
+			&p.PeriodikOpd, &p.PeriodikBarjas, &p.PeriodikFisik, &p.PeriodikAnggaran, &p.PeriodikKinerja,
+		}
+		for columns := range columnPrefixes {
+			for stage := range barjasStageNames {
+				s := &r.BarjasStages[columns][stage]
+				dest = append(dest, &s[0], &s[1], &s[2])
+			}
+		}
+		for columns := range columnPrefixes {
+			dest = append(dest,
+				&r.BarjasRealisasi[columns], &r.BarjasTarget[columns],
+				&r.FisikRealisasi[columns], &r.FisikTarget[columns],
+				&r.AnggaranRealisasi[columns], &r.AnggaranTarget[columns],
+				&r.KinerjaRealisasi[columns], &r.KinerjaTarget[columns])
+		}
+		dest = append(dest, &r.Satker.Name, &r.Satker.ShortName)
+
+		if err := rows.Scan(dest...); err != nil {
+			log.Printf("Error scanning realisasi row: %v", err)
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// FetchRealisasiRows loads the realisasi of several satkers for one month in a single query, keyed by idsatker
+func FetchRealisasiRows(year, month int, idsatkers []int) (map[int]RealisasiRow, error) {
+	rows, err := queryRealisasiRows(`WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = ANY($3)`, year, month, pq.Array(idsatkers))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]RealisasiRow, len(rows))
+	for _, r := range rows {
+		result[r.Idsatker] = r
+	}
+	return result, nil
+}
+
+// FetchRealisasiBatch loads the realisasi of several satkers over several months of a year in a
+// single query, ordered by idsatker then bulan. Satkers or months without a ranking row are absent.
+func FetchRealisasiBatch(year int, months, idsatkers []int) ([]RealisasiRow, error) {
+	return queryRealisasiRows(`WHERE dro.tahun = $1 AND dro.bulan = ANY($2) AND dro.idsatker = ANY($3)`,
+		year, pq.Array(months), pq.Array(idsatkers))
+}
+
+// realisasiCard builds a realisasi/target card the way the single-satker getters do
+func realisasiCard(category string, progress, capaian, realisasi, target float64, format func(float64) string) RealisasiData {
+	formatter := Formatter{}
+	return RealisasiData{
+		Category:          category,
+		Progress:          progress,
+		ProgressFormatted: formatter.FormatProgress(progress),
+		Capaian:           capaian,
+		Items: []RealisasiRawItem{
+			{Type: "realisasi", Value: realisasi, Formatted: format(realisasi)},
+			{Type: "target", Value: target, Formatted: format(target)},
+		},
+	}
+}
+
+// Cards returns the four realisasi cards of the row. dataType "tahun" has the shape of
+// GetRealisasiTahunWithParams, "periodik" the p_ values, anything else the shape of
+// GetRealisasiBulanWithParams.
+func (r RealisasiRow) Cards(dataType string) []RealisasiData {
+	switch dataType {
+	case "tahun":
+		return r.tahunData()
+	case "periodik":
+		return r.periodikData()
+	}
+	return r.bulanData()
+}
+
+// bulanData ...
+func (r RealisasiRow) bulanData() []RealisasiData {
+	formatter := Formatter{}
+	p := r.Progress
+
+	return []RealisasiData{
+		{Category: "barjas", Progress: p.CapaianBarjas, ProgressFormatted: formatter.FormatProgress(p.CapaianBarjas), Items: r.stageItems(bulanColumns)},
+		realisasiCard("fisik", p.CapaianFisik, 0, r.FisikRealisasi[bulanColumns], r.FisikTarget[bulanColumns], formatter.FormatNumber),
+		realisasiCard("anggaran", p.CapaianAnggaran, 0, r.AnggaranRealisasi[bulanColumns], r.AnggaranTarget[bulanColumns], formatter.FormatCurrency),
+		realisasiCard("kinerja", p.CapaianKinerja, 0, r.KinerjaRealisasi[bulanColumns], r.KinerjaTarget[bulanColumns], formatter.FormatNumber),
+	}
+}
+
+// tahunData ...
+func (r RealisasiRow) tahunData() []RealisasiData {
+	formatter := Formatter{}
+	p := r.Progress
+	return []RealisasiData{
+		realisasiCard("barjas", p.KumulatifBarjas, p.CapaianBarjas, r.BarjasRealisasi[tahunColumns], r.BarjasTarget[tahunColumns], formatter.FormatNumber),
+		realisasiCard("fisik", p.KumulatifFisik, p.CapaianFisik, r.FisikRealisasi[tahunColumns], r.FisikTarget[tahunColumns], formatter.FormatNumber),
+		realisasiCard("anggaran", p.KumulatifAnggaran, p.CapaianAnggaran, r.AnggaranRealisasi[tahunColumns], r.AnggaranTarget[tahunColumns], formatter.FormatCurrency),
+		realisasiCard("kinerja", p.KumulatifKinerja, p.CapaianKinerja, r.KinerjaRealisasi[tahunColumns], r.KinerjaTarget[tahunColumns], formatter.FormatNumber),
+	}
+}
+
+// periodikData has the shape of bulanData for the activity of a period: the p_ values with the
+// periodik percentages, capaian is the one at the end of the period
+func (r RealisasiRow) periodikData() []RealisasiData {
+	formatter := Formatter{}
+	p := r.Progress
+	return []RealisasiData{
+		{Category: "barjas", Progress: p.PeriodikBarjas, ProgressFormatted: formatter.FormatProgress(p.PeriodikBarjas), Capaian: p.CapaianBarjas, Items: r.stageItems(periodikColumns)},
+		realisasiCard("fisik", p.PeriodikFisik, p.CapaianFisik, r.FisikRealisasi[periodikColumns], r.FisikTarget[periodikColumns], formatter.FormatNumber),
+		realisasiCard("anggaran", p.PeriodikAnggaran, p.CapaianAnggaran, r.AnggaranRealisasi[periodikColumns], r.AnggaranTarget[periodikColumns], formatter.FormatCurrency),
+		realisasiCard("kinerja", p.PeriodikKinerja, p.CapaianKinerja, r.KinerjaRealisasi[periodikColumns], r.KinerjaTarget[periodikColumns], formatter.FormatNumber),
+	}
+}
+
+// stageItems are the barjas stage items of one column prefix
+func (r RealisasiRow) stageItems(columns int) []RealisasiRawItem {
+	items := make([]RealisasiRawItem, 0, len(barjasStageNames))
+	for i, stage := range barjasStageNames {
+		s := r.BarjasStages[columns][i]
+		items = append(items, RealisasiRawItem{Type: stage, Value: s[0], Detail: &RealisasiDetail{Selesai: s[0], Target: s[1], Terlambat: s[2]}})
+	}
+	return items
+}
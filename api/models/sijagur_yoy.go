@@ -0,0 +1,134 @@
+package models
+
+// YearSeries is the perbulan series of one year
+type YearSeries struct {
+	Year     int             `json:"year"`
+	Reported [12]bool        `json:"reported"` // months with a de_ranking_opd row
+	Data     []RealisasiData `json:"data"`
+}
+
+// YoYMonth compares one month of a category between the base year and an earlier year. Value is the
+// periodik percentage, realisasi the p_ realisasi of the month and cumulative its running total,
+// the absorption curve. Growth is relative to the compare year and left out when it was zero.
+type YoYMonth struct {
+	Month             string   `json:"month"`
+	Complete          bool     `json:"complete"` // both years reported the month
+	Value             float64  `json:"value"`
+	CompareValue      float64  `json:"compare_value"`
+	ValueDelta        float64  `json:"value_delta"` // percentage points
+	Realisasi         float64  `json:"realisasi"`
+	CompareRealisasi  float64  `json:"compare_realisasi"`
+	RealisasiDelta    float64  `json:"realisasi_delta"`
+	RealisasiGrowth   *float64 `json:"realisasi_growth,omitempty"` // percent
+	Cumulative        float64  `json:"cumulative"`
+	CompareCumulative float64  `json:"compare_cumulative"`
+	CumulativeDelta   float64  `json:"cumulative_delta"`
+	CumulativeGrowth  *float64 `json:"cumulative_growth,omitempty"` // percent
+	Ahead             bool     `json:"ahead"`                       // cumulative realisasi above the compare year
+}
+
+// YoYCategory is the month by month comparison of a category with one earlier year
+type YoYCategory struct {
+	Category    string     `json:"category"`
+	Year        int        `json:"year"`
+	CompareYear int        `json:"compare_year"`
+	LatestMonth string     `json:"latest_month,omitempty"` // last month reported by the base year
+	Ahead       bool       `json:"ahead"`                  // at the latest month
+	Months      []YoYMonth `json:"months"`
+}
+
+// YoYResponse is the top-level contract of the year-over-year endpoint
+type YoYResponse struct {
+	Status      string        `json:"status"` // "success"
+	Idsatker    int           `json:"idsatker"`
+	Years       []int         `json:"years"` // base year first
+	Series      []YearSeries  `json:"series"`
+	Comparisons []YoYCategory `json:"comparisons"`
+}
+
+// growth is the relative change from previous in percent, nil when previous is zero
+func growth(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	value := (current - previous) / previous * 100
+	return &value
+}
+
+// CompareYearSeries compares every category of base month by month with compare
+func CompareYearSeries(base, compare YearSeries) []YoYCategory {
+	categories := make([]YoYCategory, 0, len(base.Data))
+	for index, card := range base.Data {
+		if index >= len(compare.Data) {
+			break
+		}
+		other := compare.Data[index]
+
+		category := YoYCategory{Category: card.Category, Year: base.Year, CompareYear: compare.Year, Months: []YoYMonth{}}
+		var cumulative, compareCumulative float64
+		for i, item := range card.Monthly {
+			if i >= len(other.Monthly) {
+				break
+			}
+			previous := other.Monthly[i]
+			cumulative += item.Realisasi
+			compareCumulative += previous.Realisasi
+
+			category.Months = append(category.Months, YoYMonth{
+				Month:             item.Month,
+				Complete:          i < 12 && base.Reported[i] && compare.Reported[i],
+				Value:             item.Value,
+				CompareValue:      previous.Value,
+				ValueDelta:        item.Value - previous.Value,
+				Realisasi:         item.Realisasi,
+				CompareRealisasi:  previous.Realisasi,
+				RealisasiDelta:    item.Realisasi - previous.Realisasi,
+				RealisasiGrowth:   growth(item.Realisasi, previous.Realisasi),
+				Cumulative:        cumulative,
+				CompareCumulative: compareCumulative,
+				CumulativeDelta:   cumulative - compareCumulative,
+				CumulativeGrowth:  growth(cumulative, compareCumulative),
+				Ahead:             cumulative > compareCumulative,
+			})
+			if i < 12 && base.Reported[i] {
+				category.LatestMonth = item.Month
+				category.Ahead = cumulative > compareCumulative
+			}
+		}
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+// GetRealisasiPerbulanYears returns the perbulan series of several years side by side, the first
+// year being compared month by month with each of the others
+func (m SijagurData) GetRealisasiPerbulanYears(years []int, idsatker int) (YoYResponse, error) {
+	response := YoYResponse{
+		Status:      "success",
+		Idsatker:    idsatker,
+		Years:       years,
+		Series:      []YearSeries{},
+		Comparisons: []YoYCategory{},
+	}
+
+	for _, year := range years {
+		rawData, err := FetchRealisasiPerbulanData(year, idsatker)
+		if err != nil {
+			return response, err
+		}
+
+		series := YearSeries{Year: year, Data: m.ProcessRealisasiPerbulan(rawData)}
+		for _, p := range rawData {
+			series.Reported[p.Month-1] = true
+		}
+		response.Series = append(response.Series, series)
+	}
+
+	if len(response.Series) < 2 {
+		return response, nil
+	}
+	for _, compare := range response.Series[1:] {
+		response.Comparisons = append(response.Comparisons, CompareYearSeries(response.Series[0], compare)...)
+	}
+	return response, nil
+}
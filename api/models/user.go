@@ -29,6 +29,8 @@ type User struct {
 	Name           string `db:"name" json:"name"`
 	FailedAttempts int64  `db:"failed_attempts" json:"-"`
 	LockedUntil    int64  `db:"locked_until" json:"-"`
+	Idsatker       int64  `db:"idsatker" json:"idsatker,omitempty"`
+	JenisOpd       string `db:"jenis_opd" json:"jenis_opd,omitempty"`
 	UpdatedAt      int64  `db:"updated_at" json:"-"`
 	CreatedAt      int64  `db:"created_at" json:"-"`
 }
@@ -202,11 +204,23 @@ func (m UserModel) Register(form forms.RegisterForm) (user User, err error) {
 
 // One ...
 func (m UserModel) One(userID int64) (user User, err error) {
-	row := db.GetDB().Db.QueryRow(`SELECT id, email, username, name, failed_attempts, locked_until FROM public."user" WHERE id=$1 LIMIT 1`, userID)
-	err = row.Scan(&user.ID, &user.Email, &user.Username, &user.Name, &user.FailedAttempts, &user.LockedUntil)
+	row := db.GetDB().Db.QueryRow(`SELECT id, email, username, name, failed_attempts, locked_until, COALESCE(idsatker, 0), COALESCE(jenis_opd, '') FROM public."user" WHERE id=$1 LIMIT 1`, userID)
+	err = row.Scan(&user.ID, &user.Email, &user.Username, &user.Name, &user.FailedAttempts, &user.LockedUntil, &user.Idsatker, &user.JenisOpd)
 	return user, err
 }
 
+// AssignSatker links a user to an OPD for audience targeting and read receipts
+func (m UserModel) AssignSatker(form forms.AssignSatkerForm) error {
+	operation, err := db.GetDB().Exec(`UPDATE public."user" SET idsatker=$2, jenis_opd=$3 WHERE id=$1`, form.UserID, form.Idsatker, form.JenisOpd)
+	if err != nil {
+		return err
+	}
+	if affected, _ := operation.RowsAffected(); affected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
 // LogLoginAttempt ...
 func (m UserModel) LogLoginAttempt(userID int64, success bool) error {
 	_, err := db.GetDB().Exec(`INSERT INTO public.login_attempts (user_id, success, attempt_time) VALUES ($1, $2, $3)`, userID, success, time.Now().Unix())
@@ -222,8 +236,7 @@ func (m UserModel) GetUserRoles(userID int64) ([]Role, error) {
 
 // HasPermission ...
 func (m UserModel) HasPermission(userID int64, permName string) (bool, error) {
-	var count int
-	_, err := db.GetDB().Get(&count, `SELECT COUNT(*) FROM public.permissions p
+	count, err := db.GetDB().SelectInt(`SELECT COUNT(*) FROM public.permissions p
 		JOIN public.role_permissions rp ON p.id = rp.permission_id
 		JOIN public.user_roles ur ON rp.role_id = ur.role_id
 		WHERE ur.user_id = $1 AND p.name = $2`, userID, permName)
@@ -108,3 +108,21 @@ func GetMonthName(month int) string {
 	}
 	return "Unknown"
 }
+
+var romanNumerals = []string{"", "I", "II", "III", "IV"}
+
+// GetTriwulanName returns the label of a quarter, "Triwulan I" to "Triwulan IV"
+func GetTriwulanName(triwulan int) string {
+	if triwulan >= 1 && triwulan <= 4 {
+		return "Triwulan " + romanNumerals[triwulan]
+	}
+	return "Unknown"
+}
+
+// GetSemesterName returns the label of a half year, "Semester I" or "Semester II"
+func GetSemesterName(semester int) string {
+	if semester >= 1 && semester <= 2 {
+		return "Semester " + romanNumerals[semester]
+	}
+	return "Unknown"
+}
@@ -0,0 +1,462 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+
+	"github.com/go-gorp/gorp"
+)
+
+// Validation severities. Only errors fail a report and block ingestion.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ErrUnknownCheck is returned when a requested check is not in the catalogue
+var ErrUnknownCheck = errors.New("unknown validation check")
+
+// ErrIngestionBlocked is returned by Gate when blocking is enabled and the data has errors
+var ErrIngestionBlocked = errors.New("ingestion blocked by validation errors")
+
+// ValidationScope is the data a run looks at. Bulan 0 checks the whole year. Tolerance is the
+// largest absolute difference still accepted when comparing sums, Limit caps the issues listed
+// per check.
+type ValidationScope struct {
+	Tahun     int
+	Bulan     int
+	Tolerance float64
+	Limit     int
+	tx        gorp.SqlExecutor // an open transaction whose changes are checked, the pool when nil
+}
+
+// executor is the transaction of the scope, or the pool
+func (scope ValidationScope) executor() gorp.SqlExecutor {
+	if scope.tx != nil {
+		return scope.tx
+	}
+	return db.GetDB()
+}
+
+// ValidationIssue is one offending row or value
+type ValidationIssue struct {
+	Table    string  `json:"table"`
+	ID       int64   `json:"id,omitempty"`
+	Idsatker int     `json:"idsatker,omitempty"`
+	Tahun    int     `json:"tahun,omitempty"`
+	Bulan    int     `json:"bulan,omitempty"`
+	Field    string  `json:"field,omitempty"`
+	Value    float64 `json:"value,omitempty"`
+	Expected float64 `json:"expected,omitempty"`
+	Message  string  `json:"message"`
+}
+
+// ValidationCheck is a named check of the catalogue
+type ValidationCheck struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	run         func(scope ValidationScope) ([]ValidationIssue, error)
+}
+
+// ValidationResult is the outcome of one check
+type ValidationResult struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Severity    string            `json:"severity"`
+	Passed      bool              `json:"passed"`
+	Count       int               `json:"count"`     // issues found
+	Truncated   bool              `json:"truncated"` // more issues than the limit
+	Issues      []ValidationIssue `json:"issues"`
+}
+
+// ValidationReport is the outcome of a run
+type ValidationReport struct {
+	Status   string             `json:"status"` // "success"
+	Tahun    int                `json:"tahun"`
+	Bulan    int                `json:"bulan,omitempty"`
+	RanAt    int64              `json:"ran_at"`
+	Passed   bool               `json:"passed"` // no check of error severity found issues
+	Errors   int                `json:"errors"`
+	Warnings int                `json:"warnings"`
+	Blocked  bool               `json:"blocked"` // ingestion is held back, see Gate
+	Checks   []ValidationResult `json:"checks"`
+}
+
+// ValidationModel ...
+type ValidationModel struct{}
+
+// validationChecks is the catalogue, in report order
+var validationChecks = []ValidationCheck{
+	{
+		Name:        "duplicate_ranking_rows",
+		Description: "More than one de_ranking_opd row for the same tahun, bulan and idsatker",
+		Severity:    SeverityError,
+		run:         checkDuplicateRankingRows,
+	},
+	{
+		Name:        "orphan_detail_rows",
+		Description: "de_detail_* rows whose id_ranking_opd has no de_ranking_opd row, regardless of the scope",
+		Severity:    SeverityError,
+		run:         checkOrphanDetailRows,
+	},
+	{
+		Name:        "duplicate_detail_rows",
+		Description: "More than one row in a de_detail_* table for the same ranking row",
+		Severity:    SeverityWarning,
+		run:         checkDuplicateDetailRows,
+	},
+	{
+		Name:        "periodik_kumulatif_mismatch",
+		Description: "p_ realisasi and targets of January to the month that do not add up to the k_ values of the month",
+		Severity:    SeverityError,
+		run:         checkPeriodikKumulatif,
+	},
+	{
+		Name:        "realisasi_over_target",
+		Description: "c_, k_ or p_ realisasi, or barjas stages selesai, above their target",
+		Severity:    SeverityWarning,
+		run:         checkRealisasiOverTarget,
+	},
+}
+
+// detailTables are the de_detail_* tables hanging off de_ranking_opd
+var detailTables = []string{"de_detail_barjas", "de_detail_fisik", "de_detail_anggaran", "de_detail_kinerja"}
+
+// ValidationChecks lists the catalogue
+func ValidationChecks() []ValidationCheck {
+	return validationChecks
+}
+
+// ValidationBlocksIngestion is VALIDATION_BLOCK_INGESTION, whether Gate holds back ingestion on errors
+func ValidationBlocksIngestion() bool {
+	value := strings.ToLower(os.Getenv("VALIDATION_BLOCK_INGESTION"))
+	return value == "true" || value == "1"
+}
+
+// rowValue is one realisasi or target of a RealisasiRow with the name of its column
+type rowValue struct {
+	category  string
+	realisasi [3]float64
+	target    [3]float64
+}
+
+// values lists the realisasi and target columns of every detail table
+func (r RealisasiRow) values() []rowValue {
+	return []rowValue{
+		{"barjas", r.BarjasRealisasi, r.BarjasTarget},
+		{"fisik", r.FisikRealisasi, r.FisikTarget},
+		{"anggaran", r.AnggaranRealisasi, r.AnggaranTarget},
+		{"kinerja", r.KinerjaRealisasi, r.KinerjaTarget},
+	}
+}
+
+// RealisasiOverTarget lists the values of rows whose realisasi, or barjas stage selesai, is above a
+// non-zero target by more than tolerance. Rows of other months than bulan are skipped unless bulan is 0.
+func RealisasiOverTarget(rows []RealisasiRow, tahun, bulan int, tolerance float64) []ValidationIssue {
+	issues := []ValidationIssue{}
+	for _, r := range rows {
+		if bulan != 0 && r.Bulan != bulan {
+			continue
+		}
+		for _, v := range r.values() {
+			for columns, prefix := range columnPrefixes {
+				if v.target[columns] <= 0 || v.realisasi[columns]-v.target[columns] <= tolerance {
+					continue
+				}
+				issues = append(issues, ValidationIssue{
+					Table:    "de_detail_" + v.category,
+					Idsatker: r.Idsatker,
+					Tahun:    tahun,
+					Bulan:    r.Bulan,
+					Field:    prefix + v.category + "_realisasi",
+					Value:    v.realisasi[columns],
+					Expected: v.target[columns],
+					Message:  fmt.Sprintf("%s%s_realisasi is above %s%s_target", prefix, v.category, prefix, v.category),
+				})
+			}
+		}
+		for columns, prefix := range columnPrefixes {
+			for i, stage := range barjasStageNames {
+				s := r.BarjasStages[columns][i]
+				if s[1] <= 0 || float64(s[0]-s[1]) <= tolerance {
+					continue
+				}
+				issues = append(issues, ValidationIssue{
+					Table:    "de_detail_barjas",
+					Idsatker: r.Idsatker,
+					Tahun:    tahun,
+					Bulan:    r.Bulan,
+					Field:    prefix + stage + "_selesai",
+					Value:    float64(s[0]),
+					Expected: float64(s[1]),
+					Message:  fmt.Sprintf("%s%s_selesai is above %s%s_target", prefix, stage, prefix, stage),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// PeriodikMismatches adds up the p_ realisasi and targets of every satker month by month and lists
+// the months where the running total differs from the k_ value by more than tolerance. rows must be
+// ordered by idsatker then bulan, as queryRealisasiRows returns them. A month missing in between
+// leaves its periodik values out of the total and shows as a mismatch from then on.
+func PeriodikMismatches(rows []RealisasiRow, tahun, bulan int, tolerance float64) []ValidationIssue {
+	issues := []ValidationIssue{}
+	var sums [4][2]float64
+	for i, r := range rows {
+		if i == 0 || rows[i-1].Idsatker != r.Idsatker {
+			sums = [4][2]float64{}
+		}
+		for c, v := range r.values() {
+			sums[c][0] += v.realisasi[periodikColumns]
+			sums[c][1] += v.target[periodikColumns]
+			if bulan != 0 && r.Bulan != bulan {
+				continue
+			}
+
+			for k, field := range []string{"realisasi", "target"} {
+				kumulatif := v.realisasi[tahunColumns]
+				if k == 1 {
+					kumulatif = v.target[tahunColumns]
+				}
+				if math.Abs(sums[c][k]-kumulatif) <= tolerance {
+					continue
+				}
+				issues = append(issues, ValidationIssue{
+					Table:    "de_detail_" + v.category,
+					Idsatker: r.Idsatker,
+					Tahun:    tahun,
+					Bulan:    r.Bulan,
+					Field:    "k_" + v.category + "_" + field,
+					Value:    kumulatif,
+					Expected: sums[c][k],
+					Message:  fmt.Sprintf("k_%s_%s does not match the sum of p_%s_%s since January", v.category, field, v.category, field),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// validationRows loads the rows of the year up to the scope's month, the idsatker 0 region row included
+func validationRows(scope ValidationScope) ([]RealisasiRow, error) {
+	last := scope.Bulan
+	if last == 0 {
+		last = 12
+	}
+	return queryRealisasiRowsOn(scope.executor(), `WHERE dro.tahun = $1 AND dro.bulan <= $2`, scope.Tahun, last)
+}
+
+// checkRealisasiOverTarget ...
+func checkRealisasiOverTarget(scope ValidationScope) ([]ValidationIssue, error) {
+	rows, err := validationRows(scope)
+	if err != nil {
+		return nil, err
+	}
+	return RealisasiOverTarget(rows, scope.Tahun, scope.Bulan, scope.Tolerance), nil
+}
+
+// checkPeriodikKumulatif ...
+func checkPeriodikKumulatif(scope ValidationScope) ([]ValidationIssue, error) {
+	rows, err := validationRows(scope)
+	if err != nil {
+		return nil, err
+	}
+	return PeriodikMismatches(rows, scope.Tahun, scope.Bulan, scope.Tolerance), nil
+}
+
+// checkDuplicateRankingRows ...
+func checkDuplicateRankingRows(scope ValidationScope) ([]ValidationIssue, error) {
+	var duplicates []struct {
+		Tahun    int    `db:"tahun"`
+		Bulan    int    `db:"bulan"`
+		Idsatker int    `db:"idsatker"`
+		Count    int    `db:"count"`
+		IDs      string `db:"ids"`
+	}
+	_, err := scope.executor().Select(&duplicates, `
+		SELECT tahun, bulan, idsatker, COUNT(*) AS count, string_agg(id::text, ', ' ORDER BY id) AS ids
+		FROM de_ranking_opd
+		WHERE tahun = $1 AND ($2 = 0 OR bulan = $2)
+		GROUP BY tahun, bulan, idsatker
+		HAVING COUNT(*) > 1
+		ORDER BY bulan, idsatker`, scope.Tahun, scope.Bulan)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []ValidationIssue{}
+	for _, d := range duplicates {
+		issues = append(issues, ValidationIssue{
+			Table:    "de_ranking_opd",
+			Idsatker: d.Idsatker,
+			Tahun:    d.Tahun,
+			Bulan:    d.Bulan,
+			Value:    float64(d.Count),
+			Message:  fmt.Sprintf("%d rows for the month: id %s", d.Count, d.IDs),
+		})
+	}
+	return issues, nil
+}
+
+// checkOrphanDetailRows ...
+func checkOrphanDetailRows(scope ValidationScope) ([]ValidationIssue, error) {
+	issues := []ValidationIssue{}
+	for _, table := range detailTables {
+		var orphans []struct {
+			ID           int64 `db:"id"`
+			IdRankingOpd int64 `db:"id_ranking_opd"`
+		}
+		_, err := scope.executor().Select(&orphans, `
+			SELECT d.id, d.id_ranking_opd FROM `+table+` d
+			WHERE NOT EXISTS (SELECT 1 FROM de_ranking_opd dro WHERE dro.id = d.id_ranking_opd)
+			ORDER BY d.id`)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range orphans {
+			issues = append(issues, ValidationIssue{
+				Table:   table,
+				ID:      o.ID,
+				Field:   "id_ranking_opd",
+				Value:   float64(o.IdRankingOpd),
+				Message: fmt.Sprintf("de_ranking_opd row %d does not exist", o.IdRankingOpd),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// checkDuplicateDetailRows ...
+func checkDuplicateDetailRows(scope ValidationScope) ([]ValidationIssue, error) {
+	issues := []ValidationIssue{}
+	for _, table := range detailTables {
+		var duplicates []struct {
+			IdRankingOpd int64 `db:"id_ranking_opd"`
+			Idsatker     int   `db:"idsatker"`
+			Bulan        int   `db:"bulan"`
+			Count        int   `db:"count"`
+		}
+		_, err := scope.executor().Select(&duplicates, `
+			SELECT d.id_ranking_opd, dro.idsatker, dro.bulan, COUNT(*) AS count
+			FROM `+table+` d
+			JOIN de_ranking_opd dro ON dro.id = d.id_ranking_opd
+			WHERE dro.tahun = $1 AND ($2 = 0 OR dro.bulan = $2)
+			GROUP BY d.id_ranking_opd, dro.idsatker, dro.bulan
+			HAVING COUNT(*) > 1
+			ORDER BY dro.bulan, dro.idsatker`, scope.Tahun, scope.Bulan)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range duplicates {
+			issues = append(issues, ValidationIssue{
+				Table:    table,
+				Idsatker: d.Idsatker,
+				Tahun:    scope.Tahun,
+				Bulan:    d.Bulan,
+				Field:    "id_ranking_opd",
+				Value:    float64(d.IdRankingOpd),
+				Message:  fmt.Sprintf("%d detail rows for ranking row %d", d.Count, d.IdRankingOpd),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// NewValidationResult wraps the issues of a check, keeping at most limit of them (0 keeps all)
+func NewValidationResult(check ValidationCheck, issues []ValidationIssue, limit int) ValidationResult {
+	result := ValidationResult{
+		Name:        check.Name,
+		Description: check.Description,
+		Severity:    check.Severity,
+		Passed:      len(issues) == 0,
+		Count:       len(issues),
+		Issues:      issues,
+	}
+	if result.Issues == nil {
+		result.Issues = []ValidationIssue{}
+	}
+	if limit > 0 && len(result.Issues) > limit {
+		result.Issues = result.Issues[:limit]
+		result.Truncated = true
+	}
+	return result
+}
+
+// Summarize counts the failed checks of the report by severity
+func (report *ValidationReport) Summarize() {
+	report.Errors, report.Warnings = 0, 0
+	for _, result := range report.Checks {
+		if result.Passed {
+			continue
+		}
+		if result.Severity == SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+	report.Passed = report.Errors == 0
+}
+
+// Run runs the named checks, every check of the catalogue when names is empty
+func (m ValidationModel) Run(scope ValidationScope, names []string) (ValidationReport, error) {
+	checks := validationChecks
+	if len(names) > 0 {
+		checks = nil
+		for _, name := range names {
+			found := false
+			for _, check := range validationChecks {
+				if check.Name == name {
+					checks = append(checks, check)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return ValidationReport{}, fmt.Errorf("%w: %s", ErrUnknownCheck, name)
+			}
+		}
+	}
+
+	report := ValidationReport{
+		Status: "success",
+		Tahun:  scope.Tahun,
+		Bulan:  scope.Bulan,
+		RanAt:  time.Now().Unix(),
+		Checks: []ValidationResult{},
+	}
+	for _, check := range checks {
+		issues, err := check.run(scope)
+		if err != nil {
+			log.Printf("Validation check %s failed: %v", check.Name, err)
+			return report, fmt.Errorf("check %s: %v", check.Name, err)
+		}
+		report.Checks = append(report.Checks, NewValidationResult(check, issues, scope.Limit))
+	}
+	report.Summarize()
+	return report, nil
+}
+
+// Gate runs every check for an ingestion job. When VALIDATION_BLOCK_INGESTION is set and a check of
+// error severity fails, the report is marked blocked and ErrIngestionBlocked is returned with it.
+func (m ValidationModel) Gate(scope ValidationScope) (ValidationReport, error) {
+	report, err := m.Run(scope, nil)
+	if err != nil {
+		return report, err
+	}
+	if !report.Passed && ValidationBlocksIngestion() {
+		report.Blocked = true
+		return report, ErrIngestionBlocked
+	}
+	return report, nil
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Delivery channels. The in-app inbox is written directly by the models and is not a Sender.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Message is one delivery handed to a Sender
+type Message struct {
+	ID        int64           `json:"id"` // delivery id, receivers can use it to drop duplicates
+	Event     string          `json:"event"`
+	To        string          `json:"-"` // email address or webhook URL
+	Secret    string          `json:"-"` // webhook signing secret
+	Subject   string          `json:"subject"`
+	Body      string          `json:"body"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// Sender delivers messages over one channel
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// PermanentError marks a failure that retrying will not fix, such as a rejected recipient
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent ...
+func IsPermanent(err error) bool {
+	var permanent PermanentError
+	return errors.As(err, &permanent)
+}
+
+var senders = map[string]Sender{}
+
+// Init selects the senders from NOTIFY_EMAIL_DRIVER ("log" by default or "smtp")
+// and NOTIFY_WEBHOOK_DRIVER ("http" by default or "log")
+func Init() {
+	switch os.Getenv("NOTIFY_EMAIL_DRIVER") {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+		senders[ChannelEmail] = SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+	case "", "log":
+		senders[ChannelEmail] = LogSender{Channel: ChannelEmail}
+	default:
+		log.Fatal("Failed to init notifications: ", fmt.Errorf("unknown NOTIFY_EMAIL_DRIVER %q", os.Getenv("NOTIFY_EMAIL_DRIVER")))
+	}
+
+	switch os.Getenv("NOTIFY_WEBHOOK_DRIVER") {
+	case "", "http":
+		senders[ChannelWebhook] = NewWebhookSender(10*time.Second, AllowPrivateWebhooks())
+	case "log":
+		senders[ChannelWebhook] = LogSender{Channel: ChannelWebhook}
+	default:
+		log.Fatal("Failed to init notifications: ", fmt.Errorf("unknown NOTIFY_WEBHOOK_DRIVER %q", os.Getenv("NOTIFY_WEBHOOK_DRIVER")))
+	}
+}
+
+// GetSender ...
+func GetSender(channel string) (Sender, bool) {
+	sender, ok := senders[channel]
+	return sender, ok
+}
+
+// SetSender replaces the sender of a channel, used by tests
+func SetSender(channel string, sender Sender) {
+	senders[channel] = sender
+}
+
+// LogSender writes messages to the log, for development
+type LogSender struct {
+	Channel string
+}
+
+// Send ...
+func (s LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("Notification [%s] #%d %s to %s: %s", s.Channel, msg.ID, msg.Event, msg.To, msg.Subject)
+	return nil
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends plain text email. STARTTLS is used when the server offers it.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send ...
+func (s SMTPSender) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return PermanentError{fmt.Errorf("no email address")}
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(s.Host, strconv.Itoa(s.Port)), auth, s.From, []string{msg.To}, s.build(msg))
+	}()
+
+	select {
+	case err := <-done:
+		// 5xx replies are permanent, a bad address will not start working on retry
+		if err != nil && strings.HasPrefix(err.Error(), "5") {
+			return PermanentError{err}
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// build renders the RFC 5322 message
+func (s SMTPSender) build(msg Message) []byte {
+	headers := []string{
+		"From: " + s.From,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Unix(msg.Timestamp, 0).Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(body, "\n", "\r\n") + "\r\n")
+}
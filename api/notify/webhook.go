@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Webhook request headers. The signature covers "<timestamp>.<body>".
+const (
+	HeaderEvent     = "X-Notification-Event"
+	HeaderDelivery  = "X-Notification-Delivery"
+	HeaderTimestamp = "X-Notification-Timestamp"
+	HeaderSignature = "X-Notification-Signature"
+)
+
+// ErrPrivateAddress is returned for a webhook host on a loopback, private, link-local or otherwise
+// non-public address, such as the cloud metadata endpoint
+var ErrPrivateAddress = errors.New("webhook URL should resolve to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether ip is a public unicast address webhooks may be sent to
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// AllowPrivateWebhooks reads NOTIFY_WEBHOOK_ALLOW_PRIVATE, set it to true to reach local receivers in development
+func AllowPrivateWebhooks() bool {
+	allow, _ := strconv.ParseBool(os.Getenv("NOTIFY_WEBHOOK_ALLOW_PRIVATE"))
+	return allow
+}
+
+// CheckWebhookURL accepts an absolute http(s) URL whose host resolves to public addresses only.
+// The sender checks the address again when it connects, DNS can change in between.
+func CheckWebhookURL(ctx context.Context, raw string, allowPrivate bool) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("webhook URL should be an absolute http or https URL")
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("webhook host %q could not be resolved", parsed.Hostname())
+	}
+	for _, address := range addresses {
+		if !PublicIP(address.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// WebhookSender posts messages as JSON, signed with the subscriber's secret
+type WebhookSender struct {
+	Client *http.Client
+}
+
+// NewWebhookSender refuses to connect to non-public addresses unless allowPrivate is set. The check
+// runs on the address actually dialed, so it also covers redirects and DNS answers changed after
+// the URL was saved.
+func NewWebhookSender(timeout time.Duration, allowPrivate bool) WebhookSender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would dial on our behalf and bypass the address check
+	transport.DialContext = dialer.DialContext
+	return WebhookSender{Client: &http.Client{Timeout: timeout, Transport: transport}}
+}
+
+// Sign returns the "sha256=<hex>" HMAC of the timestamp and body, receivers recompute it to verify
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send ...
+func (s WebhookSender) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return PermanentError{fmt.Errorf("no webhook URL")}
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return PermanentError{err}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(body))
+	if err != nil {
+		return PermanentError{err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, msg.Event)
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(msg.ID, 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(msg.Timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(msg.Secret, msg.Timestamp, body))
+
+	response, err := s.Client.Do(request)
+	if errors.Is(err, ErrPrivateAddress) {
+		return PermanentError{ErrPrivateAddress}
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded %d", response.StatusCode)
+	// Client errors other than timeouts and rate limits will not succeed on retry
+	if response.StatusCode >= 400 && response.StatusCode < 500 &&
+		response.StatusCode != http.StatusRequestTimeout && response.StatusCode != http.StatusTooManyRequests {
+		return PermanentError{err}
+	}
+	return err
+}
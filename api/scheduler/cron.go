@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression or a fixed interval
+type Schedule struct {
+	spec   string
+	fields [5]uint64 // minute, hour, day of month, month, day of week bitsets
+	domAll bool      // day of month was "*", see Next
+	dowAll bool
+	every  time.Duration
+}
+
+// cronBounds are the ranges of the five fields
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// cronMacros ...
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse reads a five-field cron expression ("*/15 * * * *", "0 2 * * 1-5", "30 6 1,15 * *"),
+// a macro such as @daily, or "@every <duration>" with a duration of at least a minute
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	schedule := Schedule{spec: spec}
+
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || every < time.Minute {
+			return schedule, fmt.Errorf("invalid interval in %q, it should be a duration of at least 1m", spec)
+		}
+		schedule.every = every
+		return schedule, nil
+	}
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return schedule, fmt.Errorf("invalid cron expression %q, expected 5 fields", spec)
+	}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronBounds[i][0], cronBounds[i][1], i == 4)
+		if err != nil {
+			return schedule, fmt.Errorf("invalid cron expression %q: %v", spec, err)
+		}
+		schedule.fields[i] = bits
+	}
+	schedule.domAll = parts[2] == "*"
+	schedule.dowAll = parts[4] == "*"
+	return schedule, nil
+}
+
+// parseCronField reads a comma separated list of "*", "n", "a-b", each with an optional "/step".
+// Day of week accepts 7 for Sunday.
+func parseCronField(field string, min, max int, weekday bool) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rangePart = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+		}
+
+		low, high := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var errLow, errHigh error
+			low, errLow = strconv.Atoi(bounds[0])
+			high, errHigh = strconv.Atoi(bounds[1])
+			if errLow != nil || errHigh != nil {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rangePart)
+			}
+			low, high = value, value
+			if step > 1 {
+				high = max
+			}
+		}
+
+		limit := max
+		if weekday {
+			limit = 7 // 7 is Sunday too
+		}
+		if low < min || high > limit || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for value := low; value <= high; value += step {
+			if weekday && value == 7 {
+				bits |= 1
+				break
+			}
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// String ...
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first run after t, in t's location. Intervals are aligned on multiples of the
+// duration so every replica computes the same runs. As in cron, when both the day of month and
+// the day of week are restricted a day matching either runs.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Truncate(s.every).Add(s.every)
+	}
+
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	// Five years covers every valid expression, 30 February never matches
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		switch {
+		case !s.has(3, int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !s.has(1, next.Hour()):
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !s.has(0, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// has ...
+func (s Schedule) has(field, value int) bool {
+	return s.fields[field]&(1<<uint(value)) != 0
+}
+
+// dayMatches ...
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.has(2, t.Day()), s.has(4, int(t.Weekday()))
+	if s.domAll || s.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+)
+
+// Locker hands out expiring locks shared by every replica
+type Locker interface {
+	// Lock takes key for ttl, ok is false when someone else holds it. release frees the lock early
+	// and never frees a lock that expired and was taken by another holder.
+	Lock(key string, ttl time.Duration) (release func(), ok bool, err error)
+	// Held reports whether key is currently locked
+	Held(key string) (bool, error)
+}
+
+// releaseScript deletes the key only while it still holds our token
+var releaseScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
+
+// RedisLocker locks with SET NX PX, so only one replica runs a job
+type RedisLocker struct {
+	Client *redis.Client
+}
+
+// Lock ...
+func (l RedisLocker) Lock(key string, ttl time.Duration) (func(), bool, error) {
+	token := uuid.New().String()
+	ok, err := l.Client.SetNX(key, token, ttl).Result()
+	if err != nil || !ok {
+		return func() {}, false, err
+	}
+	return func() {
+		releaseScript.Run(l.Client, []string{key}, token)
+	}, true, nil
+}
+
+// Held ...
+func (l RedisLocker) Held(key string) (bool, error) {
+	count, err := l.Client.Exists(key).Result()
+	return count > 0, err
+}
+
+// MemoryLocker is a Locker for a single process, used without Redis and in tests
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+// memoryLock ...
+type memoryLock struct {
+	token   int64
+	expires time.Time
+}
+
+// Lock ...
+func (l *MemoryLocker) Lock(key string, ttl time.Duration) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks == nil {
+		l.locks = make(map[string]memoryLock)
+	}
+	now := time.Now()
+	if held, ok := l.locks[key]; ok && now.Before(held.expires) {
+		return func() {}, false, nil
+	}
+	token := now.UnixNano()
+	l.locks[key] = memoryLock{token: token, expires: now.Add(ttl)}
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if held, ok := l.locks[key]; ok && held.token == token {
+			delete(l.locks, key)
+		}
+	}, true, nil
+}
+
+// Held ...
+func (l *MemoryLocker) Held(key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	held, ok := l.locks[key]
+	return ok && time.Now().Before(held.expires), nil
+}
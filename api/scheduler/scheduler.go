@@ -0,0 +1,312 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Triggers ...
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run statuses ...
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// ErrUnknownJob ...
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrJobRunning is returned when the job is already running on any replica
+var ErrJobRunning = errors.New("job is already running")
+
+// Job is a recurring task. Run returns a short report kept in the run history.
+type Job struct {
+	Name        string
+	Description string
+	Spec        string
+	Timeout     time.Duration
+	Run         func(ctx context.Context, trigger string) (string, error)
+}
+
+// Run is one execution of a job
+type Run struct {
+	ID          int64
+	Job         string
+	Trigger     string
+	Status      string
+	Output      string
+	Error       string
+	Instance    string
+	TriggeredBy int64
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
+
+// History stores the runs, Start may set the run ID
+type History interface {
+	Start(run *Run) error
+	Finish(run *Run) error
+}
+
+// JobStatus ...
+type JobStatus struct {
+	Name        string
+	Description string
+	Schedule    string
+	Enabled     bool
+	NextRun     time.Time
+	Running     bool
+}
+
+// entry ...
+type entry struct {
+	job      Job
+	schedule Schedule
+	enabled  bool
+	next     time.Time
+}
+
+// Scheduler runs the jobs on their schedules. Every replica runs a Scheduler, the Locker makes
+// sure a scheduled slot runs once and that a job never overlaps itself.
+type Scheduler struct {
+	Locker   Locker
+	History  History
+	Instance string
+	// Disabled keeps the status and manual triggers but never runs a job on its schedule
+	Disabled bool
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// Add registers a job, an empty or "off" spec registers it for manual triggers only. A spec that
+// never matches, such as 30 February, is rejected.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a run function")
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = 10 * time.Minute
+	}
+
+	e := &entry{job: job}
+	if job.Spec != "" && job.Spec != "off" {
+		schedule, err := Parse(job.Spec)
+		if err != nil {
+			return fmt.Errorf("job %s: %v", job.Name, err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return fmt.Errorf("job %s: %q never runs", job.Name, job.Spec)
+		}
+		e.schedule, e.enabled = schedule, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]*entry)
+	}
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries[job.Name] = e
+	return nil
+}
+
+// Start runs the scheduled jobs in the background until ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	if s.Disabled {
+		return
+	}
+	go func() {
+		for {
+			s.mu.Lock()
+			now := time.Now()
+			wake := now.Add(time.Minute)
+			for _, e := range s.entries {
+				if !e.enabled {
+					continue
+				}
+				if e.next.IsZero() {
+					e.next = e.schedule.Next(now)
+				} else if !e.next.After(now) {
+					go s.runSlot(e.job, e.next)
+					e.next = e.schedule.Next(now)
+				}
+				if e.next.IsZero() {
+					// The schedule has no run left
+					log.Printf("Scheduler %s: %q has no next run, disabled", e.job.Name, e.job.Spec)
+					e.enabled = false
+					continue
+				}
+				if e.next.Before(wake) {
+					wake = e.next
+				}
+			}
+			s.mu.Unlock()
+
+			timer := time.NewTimer(time.Until(wake))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+// runSlot runs a scheduled slot unless another replica already claimed it. The slot lock is
+// left to expire so a replica whose clock lags cannot run the slot again.
+func (s *Scheduler) runSlot(job Job, slot time.Time) {
+	_, ok, err := s.Locker.Lock(fmt.Sprintf("scheduler:%s:%d", job.Name, slot.Unix()), job.Timeout+time.Hour)
+	if err != nil {
+		log.Printf("Scheduler %s: %v", job.Name, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	_, err = s.startWith(job, TriggerSchedule, 0, nil)
+	switch {
+	case errors.Is(err, ErrJobRunning):
+		log.Printf("Scheduler %s: skipped, the previous run is still going", job.Name)
+	case err != nil:
+		log.Printf("Scheduler %s: %v", job.Name, err)
+	}
+}
+
+// Trigger starts the job now in the background and returns the run as recorded at its start
+func (s *Scheduler) Trigger(name string, triggeredBy int64) (Run, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return Run{}, ErrUnknownJob
+	}
+
+	run := make(chan Run, 1)
+	_, err := s.startWith(e.job, TriggerManual, triggeredBy, run)
+	if err != nil {
+		return Run{}, err
+	}
+	return <-run, nil
+}
+
+// startWith takes the running lock, records the start and runs the job in a goroutine. started
+// receives the run once recorded, the returned channel once finished.
+func (s *Scheduler) startWith(job Job, trigger string, triggeredBy int64, started chan<- Run) (<-chan Run, error) {
+	release, ok, err := s.Locker.Lock(runningKey(job.Name), job.Timeout+time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobRunning
+	}
+
+	run := &Run{
+		Job:         job.Name,
+		Trigger:     trigger,
+		Status:      StatusRunning,
+		Instance:    s.Instance,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+	}
+	if s.History != nil {
+		if err := s.History.Start(run); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	if started != nil {
+		started <- *run
+	}
+
+	done := make(chan Run, 1)
+	go func() {
+		defer release()
+		s.execute(job, run)
+		done <- *run
+	}()
+	return done, nil
+}
+
+// execute runs the job with its timeout, recovering from panics
+func (s *Scheduler) execute(job Job, run *Run) {
+	ctx, cancel := context.WithTimeout(context.Background(), job.Timeout)
+	defer cancel()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				run.Status, run.Error = StatusFailed, fmt.Sprintf("panic: %v", r)
+			}
+		}()
+		output, err := job.Run(ctx, run.Trigger)
+		run.Output = output
+		if err != nil {
+			run.Status, run.Error = StatusFailed, err.Error()
+			return
+		}
+		run.Status = StatusSuccess
+	}()
+	run.FinishedAt = time.Now()
+
+	if run.Status == StatusFailed {
+		log.Printf("Scheduler %s failed: %s", job.Name, run.Error)
+	}
+	if s.History != nil {
+		if err := s.History.Finish(run); err != nil {
+			log.Printf("Scheduler %s: could not record the run: %v", job.Name, err)
+		}
+	}
+}
+
+// Status lists the jobs by name with their next run and whether any replica is running them
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	statuses := make([]JobStatus, 0, len(s.entries))
+	now := time.Now()
+	for _, e := range s.entries {
+		status := JobStatus{
+			Name:        e.job.Name,
+			Description: e.job.Description,
+			Schedule:    e.job.Spec,
+			Enabled:     e.enabled && !s.Disabled,
+		}
+		if status.Enabled {
+			status.NextRun = e.next
+			if status.NextRun.IsZero() {
+				status.NextRun = e.schedule.Next(now)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	s.mu.Unlock()
+
+	for i := range statuses {
+		running, err := s.Locker.Held(runningKey(statuses[i].Name))
+		if err != nil {
+			log.Printf("Scheduler %s: %v", statuses[i].Name, err)
+		}
+		statuses[i].Running = running
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// runningKey is held while the job runs on any replica
+func runningKey(name string) string {
+	return "scheduler:" + name + ":running"
+}
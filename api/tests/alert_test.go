@@ -0,0 +1,37 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestAlertRuleMatches
+* "kumulatif_anggaran < 30 at bulan >= 6", "any overdue_pemilihan" and "rank dropped by 5+"
+ */
+func TestAlertRuleMatches(t *testing.T) {
+	lowBudget := models.AlertRule{Metric: "kumulatif_anggaran", Operator: "lt", Threshold: 30, MinBulan: 6}
+	assert.False(t, lowBudget.Matches(5, 12, nil))
+	assert.True(t, lowBudget.Matches(6, 12, nil))
+	assert.False(t, lowBudget.Matches(7, 30, nil))
+
+	overdue := models.AlertRule{Metric: "overdue_pemilihan", Operator: "any"}
+	assert.False(t, overdue.Matches(3, 0, nil))
+	assert.True(t, overdue.Matches(3, 2, nil))
+
+	// A worse rank is a higher peringkat number
+	rankDrop := models.AlertRule{Metric: "peringkat_opd", Operator: "increase", Threshold: 5}
+	previous := 4.0
+	assert.False(t, rankDrop.Matches(6, 11, nil))
+	assert.True(t, rankDrop.Matches(6, 9, &previous))
+	assert.False(t, rankDrop.Matches(6, 8, &previous))
+	assert.Contains(t, rankDrop.Describe(6, 9, &previous), "peringkat_opd changed from 4 to 9")
+
+	assert.Contains(t, models.AlertMetrics(), "overdue_pemilihan")
+	assert.Contains(t, models.AlertMetrics(), "k_pemilihan_terlambat")
+}
@@ -0,0 +1,205 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/go-gorp/gorp"
+	"github.com/stretchr/testify/assert"
+)
+
+// articleSchema is an article table with the co-editors and audiences the access rules read
+var articleSchema = append(append([]string{}, userSchema...),
+	`CREATE TABLE public.article (id INTEGER PRIMARY KEY, user_id INTEGER, title TEXT, content TEXT, content_html TEXT, status TEXT,
+		publish_at INTEGER, expire_at INTEGER, published_at INTEGER, reviewer_id INTEGER, reviewed_at INTEGER, review_note TEXT,
+		category_id INTEGER, updated_at INTEGER, created_at INTEGER)`,
+	`CREATE TABLE public.article_editors (article_id INTEGER, user_id INTEGER, added_by INTEGER, created_at INTEGER,
+		PRIMARY KEY (article_id, user_id))`,
+	`CREATE TABLE public.article_audiences (id INTEGER PRIMARY KEY, article_id INTEGER, type TEXT, value TEXT DEFAULT '')`,
+	`CREATE TABLE public.article_categories (id INTEGER PRIMARY KEY, name TEXT)`,
+	`CREATE TABLE public.article_tags (article_id INTEGER, tag TEXT)`)
+
+// Users of the access tables, article 1 belongs to accessOwner
+const (
+	accessOwner = iota + 1
+	accessCoEditor
+	accessReviewer
+	accessReviewingCoEditor
+	accessOther
+	accessEditAny
+)
+
+// accessUsers names the users in failure messages
+var accessUsers = map[int64]string{
+	accessOwner:             "owner",
+	accessCoEditor:          "co-editor",
+	accessReviewer:          "reviewer",
+	accessReviewingCoEditor: "reviewer who is a co-editor",
+	accessOther:             "other user",
+	accessEditAny:           "edit_any_article",
+}
+
+// accessDB stores article 1 in status with its co-editors and audiences
+func accessDB(t *testing.T, status string, audiences ...string) *gorp.DbMap {
+	dbmap := sqliteDB(t, articleSchema...)
+	addUser(t, dbmap, accessOwner, "user")
+	addUser(t, dbmap, accessCoEditor, "user")
+	addUser(t, dbmap, accessReviewer, "user", "review_article")
+	addUser(t, dbmap, accessReviewingCoEditor, "user", "review_article")
+	addUser(t, dbmap, accessOther, "user")
+	addUser(t, dbmap, accessEditAny, "user", "edit_any_article")
+
+	_, err := dbmap.Exec(`INSERT INTO public.article (id, user_id, title, content, status, publish_at, updated_at, created_at) VALUES (1, 1, 'Title', 'Body', $1, $2, 0, 0)`,
+		status, time.Now().Add(time.Hour).Unix())
+	if err == nil {
+		_, err = dbmap.Exec(`INSERT INTO public.article_editors (article_id, user_id, added_by, created_at) VALUES (1, 2, 1, 0), (1, 4, 1, 0)`)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, idsatker := range audiences {
+		if _, err := dbmap.Exec(`INSERT INTO public.article_audiences (article_id, type, value) VALUES (1, 'idsatker', $1)`, idsatker); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dbmap
+}
+
+// allowed maps an access check to true, false for ErrArticleForbidden and sql.ErrNoRows
+func allowed(t *testing.T, userID int64, err error) bool {
+	if err != nil && err != models.ErrArticleForbidden && err != sql.ErrNoRows {
+		t.Fatalf("%s: %v", accessUsers[userID], err)
+	}
+	return err == nil
+}
+
+/**
+* TestArticleEditable
+* Owners, co-editors and edit_any_article may edit, reviewers may not for being reviewers
+ */
+func TestArticleEditable(t *testing.T) {
+	accessDB(t, models.ArticleStatusInReview)
+
+	for userID, expected := range map[int64]bool{
+		accessOwner:             true,
+		accessCoEditor:          true,
+		accessReviewer:          false,
+		accessReviewingCoEditor: true,
+		accessOther:             false,
+		accessEditAny:           true,
+	} {
+		assert.Equal(t, expected, allowed(t, userID, models.ArticleModel{}.CanEdit(userID, 1)), accessUsers[userID])
+	}
+	assert.Equal(t, sql.ErrNoRows, models.ArticleModel{}.CanEdit(accessOwner, 99))
+}
+
+/**
+* TestArticleReadable
+* Editors read the article in any status, everybody else once it is published to them
+ */
+func TestArticleReadable(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		status    string
+		audiences []string
+		readers   []int64
+	}{
+		{"in review", models.ArticleStatusInReview, nil, []int64{accessOwner, accessCoEditor, accessReviewingCoEditor, accessEditAny}},
+		{"published", models.ArticleStatusPublished, nil, []int64{accessOwner, accessCoEditor, accessReviewer, accessReviewingCoEditor, accessOther, accessEditAny}},
+		{"published to a satker", models.ArticleStatusPublished, []string{"99"}, []int64{accessOwner, accessCoEditor, accessReviewingCoEditor, accessEditAny}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			accessDB(t, test.status, test.audiences...)
+			for userID := range accessUsers {
+				_, err := models.ArticleModel{}.One(userID, 1)
+				assert.Equal(t, contains(test.readers, userID), allowed(t, userID, err), accessUsers[userID])
+			}
+		})
+	}
+}
+
+/**
+* TestArticleManageable
+* Only the owner and edit_any_article manage the co-editors, a co-editor may still leave
+ */
+func TestArticleManageable(t *testing.T) {
+	dbmap := accessDB(t, models.ArticleStatusDraft)
+
+	for userID, expected := range map[int64]bool{
+		accessOwner:             true,
+		accessCoEditor:          false,
+		accessReviewer:          false,
+		accessReviewingCoEditor: false,
+		accessOther:             false,
+		accessEditAny:           true,
+	} {
+		// Removing a user who is not a co-editor changes nothing once allowed
+		err := models.ArticleModel{}.RemoveEditor(userID, 1, 99)
+		assert.Equal(t, expected, err == sql.ErrNoRows, accessUsers[userID])
+		if !expected {
+			assert.Equal(t, models.ErrArticleForbidden, err, accessUsers[userID])
+		}
+	}
+
+	assert.Equal(t, models.ErrArticleForbidden, models.ArticleModel{}.AddEditor(accessCoEditor, 1, accessOther))
+	assert.NoError(t, models.ArticleModel{}.AddEditor(accessOwner, 1, accessOther))
+	assert.Error(t, models.ArticleModel{}.AddEditor(accessEditAny, 1, accessOwner))
+
+	assert.NoError(t, models.ArticleModel{}.RemoveEditor(accessCoEditor, 1, accessCoEditor))
+	count, _ := dbmap.SelectInt(`SELECT count(*) FROM public.article_editors WHERE article_id = 1`)
+	assert.Equal(t, int64(2), count)
+}
+
+/**
+* TestArticleReview
+* Owners and co-editors can not approve or reject their own article, whatever permissions they hold
+ */
+func TestArticleReview(t *testing.T) {
+	dbmap := accessDB(t, models.ArticleStatusInReview)
+
+	for _, review := range []struct {
+		name   string
+		run    func(reviewerID int64) error
+		status string
+	}{
+		{"approve", func(reviewerID int64) error { return models.ArticleModel{}.Approve(reviewerID, 1, "") }, models.ArticleStatusScheduled},
+		{"reject", func(reviewerID int64) error { return models.ArticleModel{}.Reject(reviewerID, 1, "no") }, models.ArticleStatusDraft},
+	} {
+		for userID, expected := range map[int64]error{
+			accessOwner:             models.ErrInvalidTransition,
+			accessCoEditor:          models.ErrInvalidTransition,
+			accessReviewer:          nil,
+			accessReviewingCoEditor: models.ErrInvalidTransition,
+		} {
+			if _, err := dbmap.Exec(`UPDATE public.article SET status = $1, reviewer_id = NULL WHERE id = 1`, models.ArticleStatusInReview); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, expected, review.run(userID), review.name+" by "+accessUsers[userID])
+
+			status, _ := dbmap.SelectStr(`SELECT status FROM public.article WHERE id = 1`)
+			reviewerID, _ := dbmap.SelectNullInt(`SELECT reviewer_id FROM public.article WHERE id = 1`)
+			if expected == nil {
+				assert.Equal(t, review.status, status, review.name)
+				assert.Equal(t, int64(userID), reviewerID.Int64, review.name)
+			} else {
+				assert.Equal(t, models.ArticleStatusInReview, status, review.name+" by "+accessUsers[userID])
+				assert.False(t, reviewerID.Valid, review.name+" by "+accessUsers[userID])
+			}
+		}
+	}
+}
+
+// contains ...
+func contains(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
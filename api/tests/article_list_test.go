@@ -0,0 +1,282 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/go-gorp/gorp"
+	"github.com/stretchr/testify/assert"
+)
+
+// listingDriver answers the article listing: the count query with total and the page query with
+// rows, recording the page queries and their arguments
+type listingDriver struct {
+	total   int64
+	rows    [][]driver.Value
+	queries []string
+	args    [][]driver.Value
+}
+
+// listingDB points the models at a fresh listingDriver until the test ends
+func listingDB(t *testing.T) *listingDriver {
+	d := &listingDriver{}
+	conn := sql.OpenDB(d)
+	previous := db.GetDB()
+	db.SetDB(&gorp.DbMap{Db: conn, Dialect: gorp.PostgresDialect{}})
+	t.Cleanup(func() {
+		db.SetDB(previous)
+		conn.Close()
+	})
+	return d
+}
+
+// Connect implements driver.Connector
+func (d *listingDriver) Connect(context.Context) (driver.Conn, error) { return listingConn{d}, nil }
+
+// Driver implements driver.Connector
+func (d *listingDriver) Driver() driver.Driver { return d }
+
+// Open implements driver.Driver
+func (d *listingDriver) Open(string) (driver.Conn, error) { return listingConn{d}, nil }
+
+// last is the latest page query with its arguments
+func (d *listingDriver) last() (string, []driver.Value) {
+	if len(d.queries) == 0 {
+		return "", nil
+	}
+	return d.queries[len(d.queries)-1], d.args[len(d.args)-1]
+}
+
+type listingConn struct{ d *listingDriver }
+
+func (c listingConn) Prepare(query string) (driver.Stmt, error) { return listingStmt{c.d, query}, nil }
+func (c listingConn) Close() error                              { return nil }
+func (c listingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type listingStmt struct {
+	d     *listingDriver
+	query string
+}
+
+func (s listingStmt) Close() error  { return nil }
+func (s listingStmt) NumInput() int { return -1 }
+func (s listingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (s listingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "SELECT count(") {
+		return &listingRows{columns: []string{"count"}, values: [][]driver.Value{{s.d.total}}}, nil
+	}
+	s.d.queries = append(s.d.queries, s.query)
+	s.d.args = append(s.d.args, args)
+	columns := make([]string, 18)
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	return &listingRows{columns: columns, values: s.d.rows}, nil
+}
+
+type listingRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *listingRows) Columns() []string { return r.columns }
+func (r *listingRows) Close() error      { return nil }
+func (r *listingRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// listedArticle is a row of the page query, in its column order
+func listedArticle(id int64, title, content, sortKey, titleHighlight, contentHighlight string) []driver.Value {
+	return []driver.Value{id, title, content, "", models.ArticleStatusInReview, int64(0), int64(0), int64(0), int64(0),
+		int64(1), "User 1", "user1@example.com", int64(0), "", []byte("{}"), sortKey, titleHighlight, contentHighlight}
+}
+
+// cursorFields decodes an opaque listing cursor
+func cursorFields(t *testing.T, cursor string) map[string]interface{} {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+// encodeCursor builds a cursor the way the listing does
+func encodeCursor(fields map[string]interface{}) string {
+	b, _ := json.Marshal(fields)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// reviewPage lists one page of the review queue
+func reviewPage(t *testing.T, form forms.ArticleListForm) models.Result {
+	results, err := models.ArticleModel{}.ReviewQueue(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results[0]
+}
+
+/**
+* TestArticleListHighlight
+* Highlights are HTML-escaped before the ts_headline markers become <mark>, so markup in the
+* article, <mark> included, comes back as text
+ */
+func TestArticleListHighlight(t *testing.T) {
+	d := listingDB(t)
+	d.total = 1
+	d.rows = [][]driver.Value{listedArticle(1, "<script>alert(1)</script>", "body", "",
+		"\x02<script>\x03alert(1)</script>",
+		"<img src=x onerror=\"alert(1)\"> <mark>fake</mark> \x02script\x03 & more")}
+
+	page := reviewPage(t, forms.ArticleListForm{Q: "script"})
+	if !assert.Len(t, page.Data, 1) || !assert.NotNil(t, page.Data[0].Highlight) {
+		return
+	}
+	highlight := page.Data[0].Highlight
+	assert.Equal(t, "<mark>&lt;script&gt;</mark>alert(1)&lt;/script&gt;", highlight.Title)
+	assert.Equal(t, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; &lt;mark&gt;fake&lt;/mark&gt; <mark>script</mark> &amp; more", highlight.Content)
+
+	tags := regexp.MustCompile(`<[^>]*>`)
+	for _, snippet := range []string{highlight.Title, highlight.Content} {
+		for _, tag := range tags.FindAllString(snippet, -1) {
+			assert.Contains(t, []string{"<mark>", "</mark>"}, tag, snippet)
+		}
+	}
+
+	// Without a search there is nothing to highlight
+	page = reviewPage(t, forms.ArticleListForm{})
+	assert.Nil(t, page.Data[0].Highlight)
+}
+
+/**
+* TestArticleListKeysetCursor
+* Sorted listings page on (sort key, id) after the cursor's boundary row, walking backwards
+* flips the comparison and the order and reverses the rows
+ */
+func TestArticleListKeysetCursor(t *testing.T) {
+	d := listingDB(t)
+	d.total = 5
+	d.rows = [][]driver.Value{
+		listedArticle(3, "Alpha", "", "Alpha", "", ""),
+		listedArticle(1, "Beta", "", "Beta", "", ""),
+		listedArticle(2, "Gamma", "", "Gamma", "", ""),
+	}
+
+	page := reviewPage(t, forms.ArticleListForm{Sort: "title", Limit: 2})
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, 1, page.Meta.Page)
+	assert.Empty(t, page.Meta.PrevCursor)
+	next := cursorFields(t, page.Meta.NextCursor)
+	assert.Equal(t, map[string]interface{}{"s": "title", "d": "next", "k": "Beta", "i": float64(1)}, next)
+
+	page = reviewPage(t, forms.ArticleListForm{Sort: "title", Limit: 2, Cursor: page.Meta.NextCursor})
+	query, args := d.last()
+	assert.Contains(t, query, "AND (COALESCE(a.title, ''), a.id) > ($1::text, $2)")
+	assert.Contains(t, query, "ORDER BY COALESCE(a.title, '') ASC, a.id ASC")
+	assert.Contains(t, query, "LIMIT 3 OFFSET 0")
+	assert.Equal(t, []driver.Value{"Beta", int64(1)}, args)
+	assert.Equal(t, 0, page.Meta.Page)
+	assert.Equal(t, map[string]interface{}{"s": "title", "d": "prev", "k": "Alpha", "i": float64(3)}, cursorFields(t, page.Meta.PrevCursor))
+
+	// The previous page comes back in descending order and is shown ascending
+	d.rows = [][]driver.Value{
+		listedArticle(1, "Beta", "", "Beta", "", ""),
+		listedArticle(3, "Alpha", "", "Alpha", "", ""),
+	}
+	page = reviewPage(t, forms.ArticleListForm{Sort: "title", Limit: 2, Cursor: page.Meta.PrevCursor})
+	query, args = d.last()
+	assert.Contains(t, query, "AND (COALESCE(a.title, ''), a.id) < ($1::text, $2)")
+	assert.Contains(t, query, "ORDER BY COALESCE(a.title, '') DESC, a.id DESC")
+	assert.Equal(t, []driver.Value{"Alpha", int64(3)}, args)
+	if assert.Len(t, page.Data, 2) {
+		assert.Equal(t, []int{3, 1}, []int{page.Data[0].ID, page.Data[1].ID})
+	}
+	assert.Empty(t, page.Meta.PrevCursor)
+	assert.NotEmpty(t, page.Meta.NextCursor)
+
+	// Sorting by id only compares the id
+	reviewPage(t, forms.ArticleListForm{Limit: 2, Cursor: encodeCursor(map[string]interface{}{"s": "newest", "d": "next", "i": 7})})
+	query, args = d.last()
+	assert.Contains(t, query, "AND a.id < $1")
+	assert.Contains(t, query, "ORDER BY a.id DESC")
+	assert.Equal(t, []driver.Value{int64(7)}, args)
+}
+
+/**
+* TestArticleListInvalidCursor
+* Cursors that do not decode, walk in an unknown direction or belong to another sort are refused
+ */
+func TestArticleListInvalidCursor(t *testing.T) {
+	listingDB(t)
+	for _, form := range []forms.ArticleListForm{
+		{Cursor: "not a cursor"},
+		{Cursor: base64.RawURLEncoding.EncodeToString([]byte("{"))},
+		{Cursor: encodeCursor(map[string]interface{}{"s": "newest", "d": "sideways"})},
+		{Sort: "title", Cursor: encodeCursor(map[string]interface{}{"s": "newest", "d": "next", "i": 1})},
+	} {
+		_, err := models.ArticleModel{}.ReviewQueue(form)
+		assert.Error(t, err, form.Cursor)
+	}
+}
+
+/**
+* TestArticleListRelevanceOffset
+* ts_rank is not a stable key, so relevance cursors carry an offset and both of them walk forwards
+ */
+func TestArticleListRelevanceOffset(t *testing.T) {
+	d := listingDB(t)
+	d.total = 5
+	d.rows = [][]driver.Value{
+		listedArticle(4, "Budget", "", "0.5", "", ""),
+		listedArticle(2, "Budget plan", "", "0.4", "", ""),
+		listedArticle(9, "Budget cut", "", "0.3", "", ""),
+	}
+
+	// A search sorts by relevance unless told otherwise
+	page := reviewPage(t, forms.ArticleListForm{Q: "budget", Limit: 2})
+	query, _ := d.last()
+	assert.Contains(t, query, "ORDER BY rank DESC, a.id DESC")
+	assert.Equal(t, map[string]interface{}{"s": "relevance", "d": "next", "o": float64(2)}, cursorFields(t, page.Meta.NextCursor))
+
+	page = reviewPage(t, forms.ArticleListForm{Q: "budget", Limit: 2, Cursor: page.Meta.NextCursor})
+	query, args := d.last()
+	assert.Contains(t, query, "LIMIT 3 OFFSET 2")
+	assert.NotContains(t, query, "(rank, a.id)")
+	assert.Equal(t, []driver.Value{"budget"}, args)
+	assert.Equal(t, map[string]interface{}{"s": "relevance", "d": "next", "o": float64(4)}, cursorFields(t, page.Meta.NextCursor))
+	assert.Equal(t, map[string]interface{}{"s": "relevance", "d": "next"}, cursorFields(t, page.Meta.PrevCursor))
+
+	// Without a search there is no rank to sort by
+	reviewPage(t, forms.ArticleListForm{Sort: "relevance", Limit: 2})
+	query, _ = d.last()
+	assert.Contains(t, query, "ORDER BY a.id DESC")
+	assert.NotContains(t, query, "ts_rank")
+}
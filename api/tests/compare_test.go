@@ -0,0 +1,58 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// compareSatker ...
+func compareSatker(idsatker int, barjas, fisik float64) models.CompareSatker {
+	return models.CompareSatker{Idsatker: idsatker, Data: []models.RealisasiData{
+		{Category: "barjas", Progress: barjas},
+		{Category: "fisik", Progress: fisik},
+	}}
+}
+
+/**
+* TestRankComparison
+* Ranks are per category, ties share the better rank and differences are from the group average
+ */
+func TestRankComparison(t *testing.T) {
+	satkers := []models.CompareSatker{
+		compareSatker(1, 80, 40),
+		compareSatker(2, 60, 70),
+		compareSatker(3, 80, 10),
+		compareSatker(4, 20, 40),
+	}
+
+	averages := models.RankComparison(satkers)
+	assert.InDelta(t, 60, averages["barjas"], 1e-9)
+	assert.InDelta(t, 40, averages["fisik"], 1e-9)
+
+	barjas := []int{satkers[0].Scores[0].Rank, satkers[1].Scores[0].Rank, satkers[2].Scores[0].Rank, satkers[3].Scores[0].Rank}
+	assert.Equal(t, []int{1, 3, 1, 4}, barjas)
+	fisik := []int{satkers[0].Scores[1].Rank, satkers[1].Scores[1].Rank, satkers[2].Scores[1].Rank, satkers[3].Scores[1].Rank}
+	assert.Equal(t, []int{2, 1, 4, 2}, fisik)
+
+	assert.InDelta(t, 20, satkers[0].Scores[0].DiffFromAverage, 1e-9)
+	assert.Equal(t, "+20", satkers[0].Scores[0].DiffFormatted)
+	assert.Equal(t, "-40", satkers[3].Scores[0].DiffFormatted)
+	assert.Equal(t, "0", satkers[0].Scores[1].DiffFormatted)
+
+	assert.Empty(t, models.RankComparison(nil))
+}
+
+/**
+* TestCompareSatkerList
+* The idsatker list keeps the requested order and drops duplicates
+ */
+func TestCompareSatkerList(t *testing.T) {
+	form := forms.CompareQueryForm{Idsatker: "12, 3,12,7"}
+	assert.Equal(t, []int{12, 3, 7}, form.Satkers())
+}
@@ -0,0 +1,92 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// lcsLength is the reference longest common subsequence of two line lists
+func lcsLength(a, b []string) int {
+	previous := make([]int, len(b)+1)
+	for i := range a {
+		current := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				current[j+1] = previous[j] + 1
+			case previous[j+1] >= current[j]:
+				current[j+1] = previous[j+1]
+			default:
+				current[j+1] = current[j]
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// randomLines ...
+func randomLines(r *rand.Rand, count int) []string {
+	lines := make([]string, count)
+	for i := range lines {
+		lines[i] = string(rune('a' + r.Intn(4)))
+	}
+	return lines
+}
+
+/**
+* TestDiffLines
+* Replaying the diff gives both sides back in order and keeps a longest common subsequence
+ */
+func TestDiffLines(t *testing.T) {
+	assert.Equal(t, []models.DiffLine{
+		{Op: "equal", OldLine: 1, NewLine: 1, Text: "a"},
+		{Op: "delete", OldLine: 2, Text: "b"},
+		{Op: "insert", NewLine: 2, Text: "x"},
+		{Op: "equal", OldLine: 3, NewLine: 3, Text: "c"},
+	}, models.DiffLines("a\nb\nc\n", "a\nx\nc"))
+	assert.Empty(t, models.DiffLines("", ""))
+
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 500; round++ {
+		a, b := randomLines(r, r.Intn(30)), randomLines(r, r.Intn(30))
+		diff := models.DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		var oldSide, newSide []string
+		equal := 0
+		for _, line := range diff {
+			if line.Op != "insert" {
+				assert.Equal(t, len(oldSide)+1, line.OldLine)
+				oldSide = append(oldSide, line.Text)
+			}
+			if line.Op != "delete" {
+				assert.Equal(t, len(newSide)+1, line.NewLine)
+				newSide = append(newSide, line.Text)
+			}
+			if line.Op == "equal" {
+				equal++
+			}
+		}
+		assert.Equal(t, strings.Join(a, "\n"), strings.Join(oldSide, "\n"))
+		assert.Equal(t, strings.Join(b, "\n"), strings.Join(newSide, "\n"))
+		assert.Equal(t, lcsLength(a, b), equal, "%v %v", a, b)
+	}
+}
+
+/**
+* TestDiffLinesLarge
+* Ten thousand one-character lines on each side no longer need a table of their product
+ */
+func TestDiffLinesLarge(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	a, b := randomLines(r, 10000), randomLines(r, 10000)
+	diff := models.DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	assert.NotEmpty(t, diff)
+}
@@ -0,0 +1,44 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestProjectLinear
+* A perfectly linear series projects exactly with a collapsed band
+ */
+func TestProjectLinear(t *testing.T) {
+	band := models.ProjectLinear([]float64{10, 20, 30, 40, 50, 60})
+	assert.InDelta(t, 120, band.Projected, 1e-9)
+	assert.InDelta(t, 120, band.Lower, 1e-9)
+	assert.InDelta(t, 120, band.Upper, 1e-9)
+
+	noisy := models.ProjectLinear([]float64{8, 22, 29, 43, 48, 62})
+	assert.Less(t, noisy.Lower, noisy.Projected)
+	assert.Greater(t, noisy.Upper, noisy.Projected)
+	assert.GreaterOrEqual(t, noisy.Lower, 62.0)
+}
+
+/**
+* TestProjectSeasonal
+* Previous years that reached half of December by June double the current June value
+ */
+func TestProjectSeasonal(t *testing.T) {
+	year := [12]float64{5, 5, 5, 5, 5, 25, 10, 10, 10, 10, 10, 0}
+	current := models.Cumulative([12]float64{10, 10, 10, 10, 10, 50}, 6)
+
+	band := models.ProjectSeasonal(current, [][12]float64{year, year})
+	assert.Equal(t, 2, band.Samples)
+	assert.InDelta(t, 200, band.Projected, 1e-9)
+	assert.InDelta(t, 200, band.Upper, 1e-9)
+
+	fallback := models.Project(models.ForecastModelAuto, current, nil)
+	assert.Equal(t, models.ForecastModelLinear, fallback.Model)
+}
@@ -0,0 +1,69 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestExpectedMonths
+* A month is expected once the deadline day of the following month has passed
+ */
+func TestExpectedMonths(t *testing.T) {
+	assert.Equal(t, 4, models.ExpectedMonths(2025, 10, time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 3, models.ExpectedMonths(2025, 10, time.Date(2025, 5, 9, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 12, models.ExpectedMonths(2024, 10, time.Date(2025, 5, 9, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 11, models.ExpectedMonths(2024, 10, time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0, models.ExpectedMonths(2026, 10, time.Date(2025, 5, 9, 0, 0, 0, 0, time.UTC)))
+}
+
+/**
+* TestBuildFreshness
+* Tables take the latest update of every satker, satkers are overdue on missing expected months
+ */
+func TestBuildFreshness(t *testing.T) {
+	names := map[int]string{101: "Dinas A", 102: "Dinas B", 103: "Kecamatan C"}
+	updates := []models.FreshnessUpdate{
+		{Table: "de_ranking_opd", Idsatker: 0, Rows: 3, LastUpdate: 500},
+		{Table: "de_ranking_opd", Idsatker: 101, Rows: 3, LastUpdate: 300},
+		{Table: "de_ranking_opd", Idsatker: 102, Rows: 2, LastUpdate: 200},
+		{Table: "de_status_paket", Idsatker: 101, Rows: 40, LastUpdate: 350},
+	}
+	reported := map[int][]int{0: {1, 2, 3}, 101: {1, 2, 3}, 102: {1, 3}}
+
+	freshness := models.BuildFreshness(2025, 4, names, updates, reported)
+	assert.Equal(t, int64(500), freshness.LastUpdate)
+	if assert.Len(t, freshness.Tables, 7) {
+		assert.Equal(t, models.TableFreshness{Table: "de_ranking_opd", Rows: 8, LastUpdate: 500}, freshness.Tables[0])
+		assert.Equal(t, int64(0), freshness.Tables[1].LastUpdate)
+	}
+	assert.Equal(t, []int{4}, freshness.MissingMonths)
+	assert.Equal(t, 3, freshness.Overdue)
+
+	if assert.Len(t, freshness.Satkers, 3) {
+		a := freshness.Satkers[0]
+		assert.Equal(t, 101, a.Idsatker)
+		assert.Equal(t, int64(350), a.LastUpdate)
+		assert.Equal(t, 3, a.LatestMonth)
+		assert.Equal(t, map[string]int64{"de_ranking_opd": 300, "de_status_paket": 350}, a.Tables)
+		assert.Equal(t, []int{4}, a.Missing)
+
+		assert.Equal(t, []int{2, 4}, freshness.Satkers[1].Missing)
+
+		c := freshness.Satkers[2]
+		assert.Equal(t, 0, c.LatestMonth)
+		assert.Equal(t, []int{1, 2, 3, 4}, c.Missing)
+		assert.True(t, c.Overdue)
+	}
+
+	current := models.BuildFreshness(2025, 3, names, updates, map[int][]int{101: {1, 2, 3}})
+	assert.False(t, current.Satkers[0].Overdue)
+	assert.Equal(t, 2, current.Overdue)
+	assert.Empty(t, current.MissingMonths)
+}
@@ -0,0 +1,58 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestBuildFunnel
+* Conversion is relative to the previous stage, the bottleneck is the stage with the lowest one
+ */
+func TestBuildFunnel(t *testing.T) {
+	funnel := models.BuildFunnel([4][3]int64{
+		{20, 25, 2}, // perencanaan
+		{16, 25, 4}, // pemilihan
+		{6, 25, 5},  // pengadaan
+		{5, 25, 0},  // penyerahan
+	})
+
+	if assert.Len(t, funnel.Stages, 4) {
+		perencanaan := funnel.Stages[0]
+		assert.Equal(t, "perencanaan", perencanaan.Stage)
+		assert.Equal(t, int64(5), perencanaan.Backlog)
+		assert.InDelta(t, 80, *perencanaan.Completion, 1e-9)
+		assert.InDelta(t, 8, *perencanaan.Lateness, 1e-9)
+		assert.Nil(t, perencanaan.Conversion)
+		assert.Equal(t, int64(0), perencanaan.Stuck)
+
+		pengadaan := funnel.Stages[2]
+		assert.InDelta(t, 37.5, *pengadaan.Conversion, 1e-9)
+		assert.Equal(t, int64(10), pengadaan.Stuck)
+		assert.Equal(t, int64(19), pengadaan.Backlog)
+	}
+	assert.Equal(t, "pengadaan", funnel.Bottleneck)
+	assert.InDelta(t, 20, *funnel.Overall, 1e-9)
+}
+
+/**
+* TestBuildFunnelEmpty
+* Ratios without a denominator are left out, a funnel stuck at the first stage points there
+ */
+func TestBuildFunnelEmpty(t *testing.T) {
+	empty := models.BuildFunnel([4][3]int64{})
+	assert.Nil(t, empty.Overall)
+	assert.Nil(t, empty.Stages[0].Completion)
+	assert.Nil(t, empty.Stages[1].Conversion)
+	assert.Equal(t, "", empty.Bottleneck)
+
+	stalled := models.BuildFunnel([4][3]int64{{0, 10, 3}, {0, 10, 0}, {0, 10, 0}, {0, 10, 0}})
+	assert.Equal(t, "perencanaan", stalled.Bottleneck)
+	assert.InDelta(t, 30, *stalled.Stages[0].Lateness, 1e-9)
+	assert.InDelta(t, 0, *stalled.Overall, 1e-9)
+}
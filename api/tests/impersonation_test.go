@@ -0,0 +1,199 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/controllers"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-gorp/gorp"
+	"github.com/stretchr/testify/assert"
+)
+
+// impersonationSchema ...
+var impersonationSchema = append(append([]string{}, userSchema...),
+	`CREATE TABLE public.impersonation_logs (id INTEGER PRIMARY KEY, actor_id INTEGER, subject_id INTEGER, access_uuid TEXT,
+		event TEXT, method TEXT, path TEXT, status INTEGER, request_id TEXT, client_ip TEXT, created_at INTEGER)`)
+
+// impersonationRouter audits every request like main.go and serves the impersonation start, the
+// profile and a write endpoint
+func impersonationRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	impersonation := new(controllers.ImpersonationController)
+	r.Use(impersonation.Audit())
+
+	auth := new(controllers.AuthController)
+	user := new(controllers.UserController)
+	v1 := r.Group("/v1")
+	v1.POST("/user/impersonate", TokenAuthMiddleware(), auth.HasPermission("impersonate_users"), impersonation.Start)
+	v1.GET("/user/profile", TokenAuthMiddleware(), user.GetProfile)
+	v1.POST("/write", TokenAuthMiddleware(), func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "written"}) })
+	return r
+}
+
+// loginToken issues a session for the user the way Login does
+func loginToken(t *testing.T, userID int64) string {
+	td, err := models.AuthModel{}.CreateToken(userID)
+	if err == nil {
+		err = models.AuthModel{}.CreateAuth(userID, td)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return td.AccessToken
+}
+
+// impersonate calls /user/impersonate and returns the status with the decoded body
+func impersonate(r *gin.Engine, token string, userID int64, allowWrite bool) (int, models.ImpersonationResponse) {
+	body, _ := json.Marshal(gin.H{"user_id": userID, "allow_write": allowWrite})
+	req := httptest.NewRequest(http.MethodPost, "/v1/user/impersonate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	var result models.ImpersonationResponse
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	return resp.Code, result
+}
+
+// bearerRequest ...
+func bearerRequest(r *gin.Engine, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+// impersonationEvents lists the stored audit events in order
+func impersonationEvents(t *testing.T, dbmap *gorp.DbMap) []models.ImpersonationLog {
+	var logs []models.ImpersonationLog
+	_, err := dbmap.Select(&logs, `SELECT id, actor_id, subject_id, access_uuid, event, method, path, status, request_id, client_ip, created_at
+		FROM public.impersonation_logs ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+/**
+* TestImpersonationStart
+* Admins and the caller themselves can not be impersonated, other users get a token for the subject
+ */
+func TestImpersonationStart(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "access-secret")
+	redisDB(t)
+	dbmap := sqliteDB(t, impersonationSchema...)
+	addUser(t, dbmap, 1, "admin")
+	addUser(t, dbmap, 2, "user")
+	addUser(t, dbmap, 3, "admin")
+	r := impersonationRouter()
+	admin := loginToken(t, 1)
+
+	status, _ := impersonate(r, admin, 3, false)
+	assert.Equal(t, http.StatusNotAcceptable, status)
+	status, _ = impersonate(r, admin, 1, false)
+	assert.Equal(t, http.StatusNotAcceptable, status)
+	status, _ = impersonate(r, admin, 99, false)
+	assert.Equal(t, http.StatusNotAcceptable, status)
+
+	status, started := impersonate(r, admin, 2, false)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, started.ReadOnly)
+	assert.Equal(t, int64(2), started.Subject.ID)
+
+	events := impersonationEvents(t, dbmap)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "start", events[0].Event)
+		assert.Equal(t, int64(1), events[0].ActorID)
+		assert.Equal(t, int64(2), events[0].SubjectID)
+	}
+}
+
+/**
+* TestImpersonationReadOnly
+* A read-only token reads as the subject and is refused writes, a write token may write. Every
+* request is audited under the real actor.
+ */
+func TestImpersonationReadOnly(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "access-secret")
+	redisDB(t)
+	dbmap := sqliteDB(t, impersonationSchema...)
+	addUser(t, dbmap, 1, "admin")
+	addUser(t, dbmap, 2, "user")
+	r := impersonationRouter()
+
+	_, readOnly := impersonate(r, loginToken(t, 1), 2, false)
+	resp := bearerRequest(r, http.MethodGet, "/v1/user/profile", readOnly.AccessToken)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "user2@example.com")
+
+	resp = bearerRequest(r, http.MethodPost, "/v1/write", readOnly.AccessToken)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	_, writable := impersonate(r, loginToken(t, 1), 2, true)
+	assert.False(t, writable.ReadOnly)
+	resp = bearerRequest(r, http.MethodPost, "/v1/write", writable.AccessToken)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// A regular session is not audited
+	resp = bearerRequest(r, http.MethodPost, "/v1/write", loginToken(t, 2))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var requests []models.ImpersonationLog
+	for _, event := range impersonationEvents(t, dbmap) {
+		if event.Event != "start" {
+			requests = append(requests, event)
+		}
+	}
+	if assert.Len(t, requests, 3) {
+		assert.Equal(t, []string{"request", "blocked", "request"}, []string{requests[0].Event, requests[1].Event, requests[2].Event})
+		assert.Equal(t, http.StatusForbidden, requests[1].Status)
+		assert.Equal(t, "/v1/write", requests[1].Path)
+		for _, request := range requests {
+			assert.Equal(t, int64(1), request.ActorID)
+			assert.Equal(t, int64(2), request.SubjectID)
+		}
+		assert.NotEmpty(t, requests[0].AccessUUID)
+	}
+}
+
+/**
+* TestImpersonationNested
+* An impersonation token can not start another impersonation, even for a subject allowed to
+ */
+func TestImpersonationNested(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "access-secret")
+	redisDB(t)
+	dbmap := sqliteDB(t, impersonationSchema...)
+	addUser(t, dbmap, 1, "admin")
+	addUser(t, dbmap, 2, "user")
+	addUser(t, dbmap, 4, "user", "impersonate_users")
+	r := impersonationRouter()
+
+	// The subject may impersonate on its own, read-only without impersonate_users_write
+	status, _ := impersonate(r, loginToken(t, 4), 2, false)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = impersonate(r, loginToken(t, 4), 2, true)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	_, writable := impersonate(r, loginToken(t, 1), 4, true)
+	status, _ = impersonate(r, writable.AccessToken, 2, false)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	events := impersonationEvents(t, dbmap)
+	last := events[len(events)-1]
+	assert.Equal(t, "request", last.Event)
+	assert.Equal(t, int64(1), last.ActorID)
+	assert.Equal(t, int64(4), last.SubjectID)
+	assert.Equal(t, http.StatusForbidden, last.Status)
+}
@@ -0,0 +1,171 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/importer"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestHTTPSourceFetch
+* JSON and CSV feeds decode to the same records, the cursor is sent as updated_since
+ */
+func TestHTTPSourceFetch(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer rahasia", r.Header.Get("Authorization"))
+		query = r.URL.Query().Get("updated_since")
+		switch r.URL.Path {
+		case "/feeds/status_paket":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data": [
+				{"kd_rup": 3301, "kd_satker": 1021, "tahun": 2025, "bulan": 6, "skor": 87.5, "status_pemilihan": "Selesai", "last_modified": "2025-06-20T08:00:00Z"},
+				{"kd_rup": 3302, "kd_satker": 1021, "tahun": 2025, "bulan": 6, "skor": null, "is_deleted": true}
+			]}`))
+		case "/feeds/peta_detail":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Write([]byte("kd_rup;kd_satker;tahun;nama_paket;progress;tgl_update\n3301;1021;2025;\"Rehab; gedung\";45,5;2025-06-21 10:00:00\n"))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	source := importer.HTTPSource{BaseURL: server.URL + "/feeds/", Token: "rahasia", Client: server.Client()}
+	since := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	records, err := source.Fetch(context.Background(), importer.FeedStatusPaket, since)
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-06-01T00:00:00Z", query)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "3301", records[0]["kd_rup"])
+		assert.Equal(t, "87.5", records[0]["skor"])
+		assert.Equal(t, "", records[1]["skor"])
+		assert.Equal(t, "true", records[1]["is_deleted"])
+	}
+
+	records, err = source.Fetch(context.Background(), importer.FeedPetaDetail, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", query)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "Rehab; gedung", records[0]["nama_paket"])
+		assert.Equal(t, "45,5", records[0]["progress"])
+	}
+
+	_, err = source.Fetch(context.Background(), importer.FeedDetailBarjas, since)
+	assert.Error(t, err)
+}
+
+/**
+* TestFileSourceFetch
+* A feed file is read as JSON first, then as CSV
+ */
+func TestFileSourceFetch(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "detail_barjas.csv"), []byte("idsatker,tahun,bulan,k_pemilihan_selesai\n1021,2025,6,4\n"), 0o644))
+
+	source := importer.FileSource{Dir: dir}
+	records, err := source.Fetch(context.Background(), importer.FeedDetailBarjas, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "4", records[0]["k_pemilihan_selesai"])
+	}
+
+	_, err = source.Fetch(context.Background(), importer.FeedStatusPaket, time.Time{})
+	assert.Error(t, err)
+}
+
+/**
+* TestRecordModified
+* Epoch seconds and the common date formats are accepted, records without one report ok=false
+ */
+func TestRecordModified(t *testing.T) {
+	modified, ok, err := importer.Record{"last_modified": "1750406400"}.Modified()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1750406400), modified.Unix())
+
+	modified, ok, err = importer.Record{"updated_at": "2025-06-20T08:00:00Z"}.Modified()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2025, 6, 20, 8, 0, 0, 0, time.UTC).Unix(), modified.Unix())
+
+	_, ok, err = importer.Record{"nama_paket": "Rehab gedung"}.Modified()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = importer.Record{"tgl_update": "kemarin"}.Modified()
+	assert.Error(t, err)
+}
+
+/**
+* TestMapImportRecord
+* Upstream aliases map to the columns, required keys and malformed numbers are rejected
+ */
+func TestMapImportRecord(t *testing.T) {
+	row, err := models.MapImportRecord(importer.FeedStatusPaket, importer.Record{
+		"KD_RUP": "3301", "kd_satker": "1021", "tahun": "2025", "bulan": "6.0", "skor": "87.5",
+		"status_pemilihan": " Selesai ", "last_modified": "1750406400",
+	})
+	assert.NoError(t, err)
+	paket := row.(*models.DeStatusPaket)
+	assert.Equal(t, int64(3301), paket.IdRup)
+	assert.Equal(t, int64(1021), paket.Idsatker)
+	assert.Equal(t, 6, paket.Bulan)
+	assert.Equal(t, 87.5, paket.Skor)
+	assert.Equal(t, "Selesai", paket.StatusPemilihan)
+	assert.Equal(t, int64(1750406400), paket.LastUpdate)
+
+	row, err = models.MapImportRecord(importer.FeedStatusPaket, importer.Record{"kd_rup": "3302", "kd_satker": "1021", "tahun": "2025", "bulan": "6", "is_deleted": "true"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), row.(*models.DeStatusPaket).IsRemoved)
+
+	_, err = models.MapImportRecord(importer.FeedStatusPaket, importer.Record{"kd_rup": "3301", "tahun": "2025", "bulan": "6"})
+	assert.EqualError(t, err, "missing idsatker")
+
+	_, err = models.MapImportRecord(importer.FeedPetaDetail, importer.Record{"kd_rup": "3301", "kd_satker": "1021", "tahun": "2025", "progress": "45,5"})
+	assert.EqualError(t, err, `invalid progres "45,5"`)
+
+	row, err = models.MapImportRecord(importer.FeedDetailBarjas, importer.Record{"idsatker": "1021", "tahun": "2025", "bulan": "6", "k_pemilihan_selesai": "4"})
+	assert.NoError(t, err)
+	barjas := row.(*models.DeDetailBarjas)
+	assert.Equal(t, int64(4), barjas.KPemilihanSelesai)
+	assert.Equal(t, int64(0), barjas.IdRankingOpd) // resolved from de_ranking_opd when syncing
+
+	_, err = models.MapImportRecord("kontrak", importer.Record{})
+	assert.ErrorIs(t, err, models.ErrUnknownFeed)
+}
+
+/**
+* TestImportFieldChanges
+* Only the columns that differ are reported, last_update is not compared. Named columns limit the
+* comparison to the ones a record carries.
+ */
+func TestImportFieldChanges(t *testing.T) {
+	previous := &models.DeStatusPaket{IdRup: 3301, Idsatker: 1021, Tahun: 2025, Bulan: 5, StatusPemilihan: "Proses", LastUpdate: 1}
+	current := &models.DeStatusPaket{IdRup: 3301, Idsatker: 1021, Tahun: 2025, Bulan: 5, StatusPemilihan: "Proses", LastUpdate: 2}
+	assert.Empty(t, models.ImportFieldChanges(previous, current))
+
+	current.Bulan, current.StatusPemilihan = 6, "Selesai"
+	changes := models.ImportFieldChanges(previous, current)
+	assert.Equal(t, []models.ImportFieldChange{
+		{Field: "bulan", Previous: 5, Current: 6},
+		{Field: "status_pemilihan", Previous: "Proses", Current: "Selesai"},
+	}, changes)
+
+	// A record without bulan leaves the stored month alone
+	assert.Equal(t, []models.ImportFieldChange{
+		{Field: "status_pemilihan", Previous: "Proses", Current: "Selesai"},
+	}, models.ImportFieldChanges(previous, current, "id_rup", "status_pemilihan", "last_update"))
+}
@@ -0,0 +1,29 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestRenderMarkdown
+* Scripts, event handlers and external images are removed, attachment images are kept
+ */
+func TestRenderMarkdown(t *testing.T) {
+	rendered, err := models.RenderMarkdown("**Edaran** <script>x()</script>\n\n![grafik](attachment:12) ![luar](http://example.com/a.png)\n\n<img src=x onerror=alert(1)>\n\n[tautan](javascript:alert(1))")
+	assert.NoError(t, err)
+
+	assert.Contains(t, rendered, "<strong>Edaran</strong>")
+	assert.Contains(t, rendered, `<img src="attachment:12" alt="grafik">`)
+	assert.NotContains(t, rendered, "<script")
+	assert.NotContains(t, rendered, "onerror")
+	assert.NotContains(t, rendered, "example.com")
+	assert.NotContains(t, rendered, "javascript:")
+
+	assert.Equal(t, "Edaran", models.PlainText("<p><strong>Edaran</strong></p>"))
+}
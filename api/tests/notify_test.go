@@ -0,0 +1,89 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/Massad/gin-boilerplate/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestWebhookSender
+* The receiver can verify the signature; 4xx replies are permanent, 5xx are retried
+ */
+func TestWebhookSender(t *testing.T) {
+	status := http.StatusOK
+	var received notify.Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(notify.HeaderTimestamp), 10, 64)
+		assert.Equal(t, notify.Sign("rahasia", timestamp, body), r.Header.Get(notify.HeaderSignature))
+		assert.Equal(t, "alert", r.Header.Get(notify.HeaderEvent))
+		json.Unmarshal(body, &received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sender := notify.NewWebhookSender(5*time.Second, true)
+	msg := notify.Message{ID: 9, Event: "alert", To: server.URL, Secret: "rahasia", Subject: "Anggaran tertinggal", Timestamp: time.Now().Unix()}
+
+	assert.NoError(t, sender.Send(context.Background(), msg))
+	assert.Equal(t, int64(9), received.ID)
+	assert.Equal(t, "Anggaran tertinggal", received.Subject)
+
+	status = http.StatusGone
+	err := sender.Send(context.Background(), msg)
+	assert.Error(t, err)
+	assert.True(t, notify.IsPermanent(err))
+
+	status = http.StatusBadGateway
+	err = sender.Send(context.Background(), msg)
+	assert.Error(t, err)
+	assert.False(t, notify.IsPermanent(err))
+}
+
+/**
+* TestWebhookPrivateAddress
+* Loopback, private, link-local and metadata addresses are refused when saving and when dialing
+ */
+func TestWebhookPrivateAddress(t *testing.T) {
+	for _, raw := range []string{
+		"http://127.0.0.1/hook", "http://10.1.2.3/hook", "http://192.168.0.10:8080/hook", "http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook", "http://[fd00::1]/hook", "http://100.64.0.1/hook", "http://0.0.0.0/hook",
+	} {
+		assert.ErrorIs(t, notify.CheckWebhookURL(context.Background(), raw, false), notify.ErrPrivateAddress, raw)
+		assert.NoError(t, notify.CheckWebhookURL(context.Background(), raw, true), raw)
+	}
+	assert.NoError(t, notify.CheckWebhookURL(context.Background(), "https://203.0.113.7/hook", false))
+	assert.Error(t, notify.CheckWebhookURL(context.Background(), "ftp://203.0.113.7/hook", false))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the private receiver should not be reached")
+	}))
+	defer server.Close()
+
+	err := notify.NewWebhookSender(5*time.Second, false).Send(context.Background(), notify.Message{ID: 1, To: server.URL, Timestamp: time.Now().Unix()})
+	assert.ErrorIs(t, err, notify.ErrPrivateAddress)
+	assert.True(t, notify.IsPermanent(err))
+}
+
+/**
+* TestNotificationRetryDelay
+* Exponential backoff capped at 6 hours
+ */
+func TestNotificationRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, models.NotificationRetryDelay(1))
+	assert.Equal(t, 2*time.Minute, models.NotificationRetryDelay(3))
+	assert.Equal(t, 6*time.Hour, models.NotificationRetryDelay(20))
+}
@@ -0,0 +1,79 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestNewPeriod
+* Periods default to the one containing the month and are labelled in Indonesian
+ */
+func TestNewPeriod(t *testing.T) {
+	period, err := models.NewPeriod(models.PeriodTriwulan, 0, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, period.Number)
+	assert.Equal(t, "Triwulan II", period.Label())
+	first, last := period.Months()
+	assert.Equal(t, []int{4, 6}, []int{first, last})
+
+	period, err = models.NewPeriod(models.PeriodSemester, 2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Semester II", period.Label())
+	first, last = period.Months()
+	assert.Equal(t, []int{7, 12}, []int{first, last})
+
+	period, err = models.NewPeriod("", 0, 6)
+	assert.NoError(t, err)
+	assert.True(t, period.IsMonth())
+	assert.Equal(t, "Juni", period.Label())
+
+	_, err = models.NewPeriod(models.PeriodTriwulan, 5, 0)
+	assert.ErrorIs(t, err, models.ErrInvalidPeriod)
+	_, err = models.NewPeriod(models.PeriodSemester, 0, 0)
+	assert.ErrorIs(t, err, models.ErrInvalidPeriod)
+	_, err = models.NewPeriod("tahun", 1, 1)
+	assert.ErrorIs(t, err, models.ErrInvalidPeriod)
+}
+
+// periodRow ...
+func periodRow(bulan int, kumulatif, periodik, pTarget, pRealisasi float64) models.RealisasiRow {
+	row := models.RealisasiRow{Bulan: bulan}
+	row.Progress.KumulatifAnggaran = kumulatif
+	row.Progress.PeriodikAnggaran = periodik
+	row.AnggaranTarget = [3]float64{0, kumulatif * 10, pTarget}
+	row.AnggaranRealisasi = [3]float64{0, 0, pRealisasi}
+	row.BarjasStages[2][1] = [3]int64{1, 2, 0}
+	return row
+}
+
+/**
+* TestAggregatePeriod
+* Kumulatif values come from the last month, periodik values add up over the months
+ */
+func TestAggregatePeriod(t *testing.T) {
+	period := models.AggregatePeriod([]models.RealisasiRow{
+		periodRow(4, 20, 50, 100, 50),
+		periodRow(5, 30, 100, 300, 300),
+		periodRow(6, 45, 0, 0, 0),
+	})
+
+	assert.Equal(t, 6, period.Bulan)
+	assert.InDelta(t, 45, period.Progress.KumulatifAnggaran, 1e-9)
+	assert.InDelta(t, 450, period.AnggaranTarget[1], 1e-9)
+	assert.InDelta(t, 400, period.AnggaranTarget[2], 1e-9)
+	assert.InDelta(t, 350, period.AnggaranRealisasi[2], 1e-9)
+	// 50% of 100 and 100% of 300, the month without target weighs nothing
+	assert.InDelta(t, 87.5, period.Progress.PeriodikAnggaran, 1e-9)
+	assert.Equal(t, [3]int64{3, 6, 0}, period.BarjasStages[2][1])
+
+	cards := period.Cards("periodik")
+	assert.Equal(t, "anggaran", cards[2].Category)
+	assert.InDelta(t, 87.5, cards[2].Progress, 1e-9)
+	assert.Equal(t, "pemilihan", cards[0].Items[1].Type)
+}
@@ -0,0 +1,63 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// snapshotRow ...
+func snapshotRow(idsatker int64, jenisOpd string, kumulatif float64, peringkat int64) models.SnapshotRow {
+	return models.SnapshotRow{Idsatker: idsatker, NamaOpd: "OPD", JenisOpd: jenisOpd, KumulatifOpd: kumulatif, PeringkatOpd: peringkat, Tahun: 2025, Bulan: 6}
+}
+
+/**
+* TestRankingChangelog
+* Only OPDs whose scores or ranks moved are listed, ranks are computed within jenis_opd
+ */
+func TestRankingChangelog(t *testing.T) {
+	previous := []models.SnapshotRow{
+		snapshotRow(101, "skpd", 80, 1),
+		snapshotRow(102, "skpd", 70, 2),
+		snapshotRow(103, "skpd", 60, 3),
+		snapshotRow(201, "kecamatan", 50, 1),
+		snapshotRow(202, "kecamatan", 40, 2),
+	}
+	assert.Empty(t, models.RankingChangelog(previous, previous))
+
+	// 103 is corrected above 102, 202 disappears and 301 is new
+	current := []models.SnapshotRow{
+		snapshotRow(101, "skpd", 80, 1),
+		snapshotRow(102, "skpd", 70, 3),
+		snapshotRow(103, "skpd", 75, 2),
+		snapshotRow(201, "kecamatan", 50, 1),
+		snapshotRow(301, "kecamatan", 45, 2),
+	}
+	changes := models.RankingChangelog(previous, current)
+	if !assert.Len(t, changes, 4) {
+		return
+	}
+
+	assert.Equal(t, int64(102), changes[0].Idsatker)
+	assert.Equal(t, "changed", changes[0].Change)
+	assert.Equal(t, []models.RankingFieldChange{
+		{Field: "peringkat_opd", Previous: 2, Current: 3},
+		{Field: "rank_kumulatif", Previous: 2, Current: 3},
+	}, changes[0].Fields)
+
+	assert.Equal(t, int64(103), changes[1].Idsatker)
+	assert.Equal(t, []models.RankingFieldChange{
+		{Field: "kumulatif_opd", Previous: 60, Current: 75},
+		{Field: "peringkat_opd", Previous: 3, Current: 2},
+		{Field: "rank_kumulatif", Previous: 3, Current: 2},
+	}, changes[1].Fields)
+
+	assert.Equal(t, int64(202), changes[2].Idsatker)
+	assert.Equal(t, "removed", changes[2].Change)
+	assert.Equal(t, int64(301), changes[3].Idsatker)
+	assert.Equal(t, "added", changes[3].Change)
+}
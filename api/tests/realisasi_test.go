@@ -0,0 +1,559 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/controllers"
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-gorp/gorp"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTrip is the simulated latency of one query in the benchmarks
+const roundTrip = time.Millisecond
+
+// countingDriver is a database/sql driver answering every query with rows of ones after a delay.
+// It counts the queries and the peak of queries in flight, each holding a pool connection.
+// Realisasi rows are returned for the idsatker and bulan keys matching the query arguments.
+type countingDriver struct {
+	latency time.Duration
+	keys    [][2]int64
+	// updates are the last_update of several ranking rows of a key in id order, nil for NULL. The
+	// row answered is picked the way Postgres orders the query's DISTINCT ON.
+	updates  map[[2]int64][]driver.Value
+	queries  int64
+	inflight int64
+	peak     int64
+}
+
+// countingDB points the models at a fresh countingDriver until the test ends
+func countingDB(tb testing.TB, latency time.Duration, keys ...[2]int64) *countingDriver {
+	d := &countingDriver{latency: latency, keys: keys}
+	conn := sql.OpenDB(d)
+	conn.SetMaxIdleConns(64)
+	previous := db.GetDB()
+	db.SetDB(&gorp.DbMap{Db: conn, Dialect: gorp.PostgresDialect{}})
+	tb.Cleanup(func() {
+		db.SetDB(previous)
+		conn.Close()
+	})
+	return d
+}
+
+// reset clears the counters between measured runs
+func (d *countingDriver) reset() {
+	atomic.StoreInt64(&d.queries, 0)
+	atomic.StoreInt64(&d.peak, 0)
+}
+
+// report adds the queries and the peak of connections in use per operation to a benchmark
+func (d *countingDriver) report(b *testing.B) {
+	b.ReportMetric(float64(atomic.LoadInt64(&d.queries))/float64(b.N), "queries/op")
+	b.ReportMetric(float64(atomic.LoadInt64(&d.peak)), "peak-conns")
+}
+
+// Connect implements driver.Connector
+func (d *countingDriver) Connect(context.Context) (driver.Conn, error) {
+	return countingConn{d}, nil
+}
+
+// Driver implements driver.Connector
+func (d *countingDriver) Driver() driver.Driver { return d }
+
+// Open implements driver.Driver
+func (d *countingDriver) Open(string) (driver.Conn, error) { return countingConn{d}, nil }
+
+type countingConn struct{ d *countingDriver }
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	return countingStmt{c.d, query}, nil
+}
+func (c countingConn) Close() error { return nil }
+func (c countingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type countingStmt struct {
+	d     *countingDriver
+	query string
+}
+
+func (s countingStmt) Close() error  { return nil }
+func (s countingStmt) NumInput() int { return -1 }
+func (s countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+// Query waits the round trip and answers with one row of ones, or the matching realisasi rows
+func (s countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.d
+	atomic.AddInt64(&d.queries, 1)
+	inflight := atomic.AddInt64(&d.inflight, 1)
+	for {
+		peak := atomic.LoadInt64(&d.peak)
+		if inflight <= peak || atomic.CompareAndSwapInt64(&d.peak, peak, inflight) {
+			break
+		}
+	}
+	time.Sleep(d.latency)
+
+	columns := selectColumns(s.query)
+	ones := func() []driver.Value {
+		row := make([]driver.Value, len(columns))
+		for i := range row {
+			row[i] = int64(1)
+		}
+		return row
+	}
+
+	var values [][]driver.Value
+	if !strings.Contains(s.query, "LEFT JOIN de_detail_barjas") {
+		values = append(values, ones())
+	} else {
+		// idsatker and bulan are the first and fourth column of a realisasi row
+		months := argSet(args, 1)
+		for _, key := range d.keys {
+			if !months[key[1]] {
+				continue
+			}
+			if len(args) > 2 && !argSet(args, 2)[key[0]] || len(args) == 2 && key[0] == 0 {
+				continue
+			}
+			row := ones()
+			row[0], row[3] = key[0], key[1]
+			if updates, ok := d.updates[key]; ok {
+				row[4] = latestUpdate(s.query, updates)
+			}
+			values = append(values, row)
+		}
+	}
+	return &countingRows{d: d, columns: columns, values: values}, nil
+}
+
+// latestUpdate is the last_update of the first ranking row under the query's
+// "last_update DESC[ NULLS LAST], id DESC" order, 0 for NULL. Postgres sorts NULL first in a
+// descending order unless told otherwise.
+func latestUpdate(query string, updates []driver.Value) driver.Value {
+	nullsLast := strings.Contains(query, "dro.last_update DESC NULLS LAST")
+	before := func(a, b driver.Value) bool {
+		switch {
+		case a == nil || b == nil:
+			return b != nil && !nullsLast || a != nil && nullsLast
+		default:
+			return a.(int64) > b.(int64)
+		}
+	}
+
+	// From the highest id, a later row only wins on a greater last_update
+	best := len(updates) - 1
+	for i := best - 1; i >= 0; i-- {
+		if before(updates[i], updates[best]) {
+			best = i
+		}
+	}
+	if updates[best] == nil {
+		return int64(0)
+	}
+	return updates[best]
+}
+
+// argSet reads an integer or a postgres array argument
+func argSet(args []driver.Value, i int) map[int64]bool {
+	set := map[int64]bool{}
+	switch value := args[i].(type) {
+	case int64:
+		set[value] = true
+	case string:
+		for _, part := range strings.Split(strings.Trim(value, "{}"), ",") {
+			if n, err := strconv.ParseInt(part, 10, 64); err == nil {
+				set[n] = true
+			}
+		}
+	}
+	return set
+}
+
+// selectColumns names the columns of the select list, by alias or the part after the table alias
+func selectColumns(query string) []string {
+	query = query[strings.Index(query, "SELECT")+len("SELECT"):]
+	var columns []string
+	depth, start := 0, 0
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '(':
+			depth++
+		case query[i] == ')':
+			depth--
+		case depth == 0 && query[i] == ',':
+			columns = append(columns, columnName(query[start:i]))
+			start = i + 1
+		case depth == 0 && strings.HasPrefix(query[i:], "FROM"):
+			return append(columns, columnName(query[start:i]))
+		}
+	}
+	return columns
+}
+
+// columnName ...
+func columnName(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if i := strings.LastIndex(expression, " AS "); i >= 0 {
+		return strings.TrimSpace(expression[i+4:])
+	}
+	return expression[strings.LastIndex(expression, ".")+1:]
+}
+
+type countingRows struct {
+	d       *countingDriver
+	columns []string
+	values  [][]driver.Value
+	closed  bool
+}
+
+func (r *countingRows) Columns() []string { return r.columns }
+
+func (r *countingRows) Close() error {
+	if !r.closed {
+		r.closed = true
+		atomic.AddInt64(&r.d.inflight, -1)
+	}
+	return nil
+}
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// legacyRealisasiQueries are the ranking row query and the four detail queries the cards used to
+// run, the detail ones concurrently once the ranking row was read
+var legacyRealisasiQueries = []string{
+	`SELECT capaian_opd, capaian_barjas, capaian_fisik, capaian_anggaran, capaian_kinerja,
+		kumulatif_opd, kumulatif_barjas, kumulatif_fisik, kumulatif_anggaran, kumulatif_kinerja
+	FROM de_ranking_opd WHERE tahun = $1 AND bulan = $2 AND idsatker = $3 LIMIT 1`,
+	`SELECT c_perencanaan_selesai, c_perencanaan_target, c_perencanaan_terlambat,
+		c_pemilihan_selesai, c_pemilihan_target, c_pemilihan_terlambat,
+		c_pengadaan_selesai, c_pengadaan_target, c_pengadaan_terlambat,
+		c_penyerahan_selesai, c_penyerahan_target, c_penyerahan_terlambat
+	FROM de_detail_barjas ddb INNER JOIN de_ranking_opd dro ON ddb.id_ranking_opd = dro.id
+	WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3`,
+	`SELECT c_fisik_realisasi, c_fisik_target FROM de_detail_fisik ddf INNER JOIN de_ranking_opd dro ON ddf.id_ranking_opd = dro.id
+	WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3`,
+	`SELECT c_anggaran_realisasi, c_anggaran_target FROM de_detail_anggaran dda INNER JOIN de_ranking_opd dro ON dda.id_ranking_opd = dro.id
+	WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3`,
+	`SELECT c_kinerja_realisasi, c_kinerja_target FROM de_detail_kinerja ddk INNER JOIN de_ranking_opd dro ON ddk.id_ranking_opd = dro.id
+	WHERE dro.tahun = $1 AND dro.bulan = $2 AND dro.idsatker = $3`,
+}
+
+// legacyRealisasiCard replays the access pattern of the former card getters
+func legacyRealisasiCard(year, month, idsatker int) error {
+	query := func(sqlQuery string) error {
+		rows, err := db.GetDB().Query(sqlQuery, year, month, idsatker)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+		}
+		return rows.Err()
+	}
+
+	if err := query(legacyRealisasiQueries[0]); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i, detail := range legacyRealisasiQueries[1:] {
+		wg.Add(1)
+		go func(i int, detail string) {
+			defer wg.Done()
+			errs[i] = query(detail)
+		}(i, detail)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+* TestRealisasiCardsSingleQuery
+* A card set costs one query, a satker without a ranking row is not found
+ */
+func TestRealisasiCardsSingleQuery(t *testing.T) {
+	d := countingDB(t, 0, [2]int64{12, 3})
+	sijagur := models.SijagurData{}
+
+	cards, err := sijagur.GetRealisasiBulanWithParams(2025, 3, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), d.queries)
+	if assert.Len(t, cards, 4) {
+		assert.Equal(t, "barjas", cards[0].Category)
+		assert.Len(t, cards[0].Items, 4)
+		assert.Equal(t, "kinerja", cards[3].Category)
+	}
+
+	cards, err = sijagur.GetRealisasiTahunWithParams(2025, 3, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), d.queries)
+	if assert.Len(t, cards, 4) {
+		assert.Equal(t, 1.0, cards[1].Capaian)
+	}
+
+	_, err = sijagur.GetRealisasiBulanWithParams(2025, 3, 13)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+/**
+* TestRealisasiResult
+* The cards, last_update and the registry name of a satker come from one query
+ */
+func TestRealisasiResult(t *testing.T) {
+	d := countingDB(t, 0, [2]int64{12, 3})
+	sijagur := models.SijagurData{}
+
+	result, err := sijagur.GetRealisasiResult(2025, models.Period{Type: models.PeriodBulan, Number: 3}, 12, "bulan")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), d.queries)
+	assert.Len(t, result.Data, 4)
+	assert.Equal(t, models.RealisasiMeta{Year: 2025, Month: 3, MonthName: "Maret", Idsatker: 12, NamaOpd: "1", ShortName: "1",
+		Type: "bulan", LastUpdate: 1}, result.Meta)
+
+	// The region has no registry name, its last_update is the latest of its satkers
+	result, err = sijagur.GetRealisasiResult(2025, models.Period{Type: models.PeriodBulan, Number: 3}, 0, "tahun")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), d.queries)
+	assert.Equal(t, "", result.Meta.NamaOpd)
+	assert.Equal(t, int64(1), result.Meta.LastUpdate)
+
+	_, err = sijagur.GetRealisasiResult(2025, models.Period{Type: models.PeriodBulan, Number: 3}, 13, "bulan")
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+/**
+* TestRealisasiLatestUpdate
+* A duplicate ranking row without a last_update does not win over the latest one
+ */
+func TestRealisasiLatestUpdate(t *testing.T) {
+	d := countingDB(t, 0, [2]int64{12, 3})
+	d.updates = map[[2]int64][]driver.Value{{12, 3}: {int64(1700000000), nil}}
+
+	result, err := models.SijagurData{}.GetRealisasiResult(2025, models.Period{Type: models.PeriodBulan, Number: 3}, 12, "bulan")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000), result.Meta.LastUpdate)
+
+	d.updates = map[[2]int64][]driver.Value{{12, 3}: {int64(1700000000), int64(1700000000), int64(1600000000)}}
+	result, err = models.SijagurData{}.GetRealisasiResult(2025, models.Period{Type: models.PeriodBulan, Number: 3}, 12, "bulan")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000), result.Meta.LastUpdate)
+}
+
+/**
+* TestRealisasiBatch
+* Stored rows with their names and the computed region cost a query each, missing pairs are listed
+ */
+func TestRealisasiBatch(t *testing.T) {
+	d := countingDB(t, 0, [2]int64{12, 3}, [2]int64{12, 4}, [2]int64{15, 3})
+
+	response, err := models.SijagurData{}.GetRealisasiBatch(2025, []int{3, 4}, []int{0, 12, 15}, "tahun")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), d.queries)
+	assert.Equal(t, []models.RealisasiBatchKey{{Idsatker: 15, Month: 4}}, response.Missing)
+
+	var keys []models.RealisasiBatchKey
+	for _, result := range response.Results {
+		keys = append(keys, models.RealisasiBatchKey{Idsatker: result.Meta.Idsatker, Month: result.Meta.Month})
+		assert.Len(t, result.Data, 4)
+		assert.Equal(t, "tahun", result.Meta.Type)
+	}
+	assert.Equal(t, []models.RealisasiBatchKey{
+		{Idsatker: 0, Month: 3}, {Idsatker: 0, Month: 4}, {Idsatker: 12, Month: 3}, {Idsatker: 12, Month: 4}, {Idsatker: 15, Month: 3},
+	}, keys)
+}
+
+// dashboardSatkers and dashboardMonths are the cards of a typical dashboard
+var (
+	dashboardSatkers = []int{12, 15, 18}
+	dashboardMonths  = []int{3, 4}
+)
+
+// dashboardKeys ...
+func dashboardKeys() [][2]int64 {
+	var keys [][2]int64
+	for _, idsatker := range dashboardSatkers {
+		for _, month := range dashboardMonths {
+			keys = append(keys, [2]int64{int64(idsatker), int64(month)})
+		}
+	}
+	return keys
+}
+
+/**
+* BenchmarkRealisasiCard
+* One card set: five round trips, two of them sequential, against a single query
+ */
+func BenchmarkRealisasiCard(b *testing.B) {
+	sijagur := models.SijagurData{}
+	for _, bench := range []struct {
+		name string
+		card func() error
+	}{
+		{"legacy", func() error { return legacyRealisasiCard(2025, 3, 12) }},
+		{"single", func() error { _, err := sijagur.GetRealisasiBulanWithParams(2025, 3, 12); return err }},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			d := countingDB(b, roundTrip, [2]int64{12, 3})
+			d.reset()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := bench.card(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			d.report(b)
+		})
+	}
+}
+
+// previousRealisasiHandler replays the realisasi-bulan controller before its meta came with the
+// rows: the cards, then the last_update of the data and the satker name in two more queries
+func previousRealisasiHandler(c *gin.Context) {
+	sijagur := models.SijagurData{}
+	data, err := sijagur.GetRealisasiBulanWithParams(2025, 3, 12)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	meta := models.RealisasiMeta{Year: 2025, Month: 3, MonthName: models.GetMonthName(3), Idsatker: 12, Type: "bulan"}
+	meta.LastUpdate = sijagur.DataLastUpdate(2025, 3, 3, 12)
+	if name, ok := models.SatkerNames([]int64{12})[12]; ok {
+		meta.NamaOpd, meta.ShortName = name.Name, name.ShortName
+	}
+	c.JSON(http.StatusOK, models.SijagurResponse{Results: []models.SijagurResult{{Data: data, Meta: meta}}})
+}
+
+/**
+* BenchmarkRealisasiEndpoint
+* One /realisasi-bulan request through the controller: the cards, last_update and satker name in
+* three queries against one
+ */
+func BenchmarkRealisasiEndpoint(b *testing.B) {
+	// The replayed last_update query finds no row on the counting driver and would log every run
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	gin.SetMode(gin.TestMode)
+	sijagur := new(controllers.SijagurController)
+	for _, bench := range []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{"previous", previousRealisasiHandler},
+		{"single", sijagur.GetRealisasiBulan},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			router := gin.New()
+			router.GET("/realisasi-bulan", func(c *gin.Context) { c.Set("userID", int64(1)) }, bench.handler)
+
+			d := countingDB(b, roundTrip, [2]int64{12, 3})
+			d.reset()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/realisasi-bulan?tahun=2025&bulan=3&idsatker=12", nil))
+				if resp.Code != http.StatusOK {
+					b.Fatal(resp.Body.String())
+				}
+			}
+			b.StopTimer()
+			d.report(b)
+		})
+	}
+}
+
+/**
+* BenchmarkRealisasiDashboard
+* Six cards requested at once as the frontend does, against one batch request
+ */
+func BenchmarkRealisasiDashboard(b *testing.B) {
+	sijagur := models.SijagurData{}
+	concurrently := func(card func(idsatker, month int) error) error {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var first error
+		for _, idsatker := range dashboardSatkers {
+			for _, month := range dashboardMonths {
+				wg.Add(1)
+				go func(idsatker, month int) {
+					defer wg.Done()
+					if err := card(idsatker, month); err != nil {
+						mu.Lock()
+						first = err
+						mu.Unlock()
+					}
+				}(idsatker, month)
+			}
+		}
+		wg.Wait()
+		return first
+	}
+
+	for _, bench := range []struct {
+		name      string
+		dashboard func() error
+	}{
+		{"legacy", func() error {
+			return concurrently(func(idsatker, month int) error { return legacyRealisasiCard(2025, month, idsatker) })
+		}},
+		{"single", func() error {
+			return concurrently(func(idsatker, month int) error {
+				_, err := sijagur.GetRealisasiBulanWithParams(2025, month, idsatker)
+				return err
+			})
+		}},
+		{"batch", func() error {
+			_, err := sijagur.GetRealisasiBatch(2025, dashboardMonths, dashboardSatkers, "bulan")
+			return err
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			d := countingDB(b, roundTrip, dashboardKeys()...)
+			d.reset()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := bench.dashboard(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			d.report(b)
+		})
+	}
+}
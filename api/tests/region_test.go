@@ -0,0 +1,72 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// regionRow ...
+func regionRow(jenisOpd string, capaianFisik, fisikTarget, capaianOpd float64) models.RealisasiRow {
+	row := models.RealisasiRow{JenisOpd: jenisOpd}
+	row.Progress.CapaianFisik = capaianFisik
+	row.Progress.CapaianOpd = capaianOpd
+	row.FisikTarget = [3]float64{fisikTarget, fisikTarget * 2, 0}
+	row.FisikRealisasi = [3]float64{fisikTarget * capaianFisik / 100, 0, 0}
+	row.BarjasStages[0][0] = [3]int64{2, 4, 1}
+	return row
+}
+
+/**
+* TestAggregateRows
+* Sums are plain sums, percentages are weighted by target and fall back to the mean without targets
+ */
+func TestAggregateRows(t *testing.T) {
+	rows := []models.RealisasiRow{
+		regionRow("skpd", 50, 300, 40),
+		regionRow("kecamatan", 100, 100, 80),
+	}
+
+	region := models.AggregateRows(rows)
+	assert.InDelta(t, 400, region.FisikTarget[0], 1e-9)
+	assert.InDelta(t, 800, region.FisikTarget[1], 1e-9)
+	assert.InDelta(t, 250, region.FisikRealisasi[0], 1e-9)
+	assert.Equal(t, [3]int64{4, 8, 2}, region.BarjasStages[0][0])
+
+	// 50% of 300 and 100% of 100 is 62.5% of 400
+	assert.InDelta(t, 62.5, region.Progress.CapaianFisik, 1e-9)
+	assert.InDelta(t, 60, region.Progress.CapaianOpd, 1e-9)
+	assert.InDelta(t, 0, region.Progress.CapaianAnggaran, 1e-9)
+
+	cards := region.Cards("bulan")
+	assert.Equal(t, "fisik", cards[1].Category)
+	assert.InDelta(t, 62.5, cards[1].Progress, 1e-9)
+	assert.Equal(t, "62.5", cards[1].ProgressFormatted)
+
+	assert.Equal(t, models.RealisasiRow{}, models.AggregateRows(nil))
+}
+
+/**
+* TestReconcile
+* Only values beyond the tolerance are reported, with the stored minus computed difference
+ */
+func TestReconcile(t *testing.T) {
+	computed := models.AggregateRows([]models.RealisasiRow{regionRow("skpd", 50, 300, 40)})
+	stored := computed
+	stored.Progress.CapaianFisik = 50.005
+	assert.Empty(t, models.Reconcile(computed, stored, 0.01))
+
+	stored.FisikTarget[0] = 280
+	stored.BarjasStages[0][0][2] = 3
+	differences := models.Reconcile(computed, stored, 0.01)
+	if assert.Len(t, differences, 2) {
+		assert.Equal(t, "fisik", differences[0].Category)
+		assert.Equal(t, "c_target", differences[0].Field)
+		assert.InDelta(t, -20, differences[0].Difference, 1e-9)
+		assert.Equal(t, "perencanaan_terlambat", differences[1].Field)
+	}
+}
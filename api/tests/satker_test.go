@@ -0,0 +1,31 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestSatkerCreatesCycle
+* A satker cannot be moved below itself or one of its descendants
+ */
+func TestSatkerCreatesCycle(t *testing.T) {
+	// 1 is the root, 2 and 3 sit below it, 4 below 2
+	parents := map[int64]int64{1: 0, 2: 1, 3: 1, 4: 2}
+
+	assert.False(t, models.SatkerCreatesCycle(parents, 3, 2))
+	assert.False(t, models.SatkerCreatesCycle(parents, 4, 1))
+	assert.False(t, models.SatkerCreatesCycle(parents, 2, 0))
+	assert.True(t, models.SatkerCreatesCycle(parents, 2, 2))
+	assert.True(t, models.SatkerCreatesCycle(parents, 1, 4))
+	assert.True(t, models.SatkerCreatesCycle(parents, 2, 4))
+
+	// An already broken hierarchy does not loop forever
+	broken := map[int64]int64{5: 6, 6: 5}
+	assert.True(t, models.SatkerCreatesCycle(broken, 7, 5))
+}
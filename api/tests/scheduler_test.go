@@ -0,0 +1,169 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestCronNext
+* Steps, ranges, lists, macros and aligned intervals give the next run after a time
+ */
+func TestCronNext(t *testing.T) {
+	base := time.Date(2025, 6, 20, 10, 7, 30, 0, time.UTC) // a Friday
+
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 6, 20, 10, 15, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2025, 6, 21, 2, 0, 0, 0, time.UTC)},
+		{"30 6 1,15 * *", time.Date(2025, 7, 1, 6, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2025, 6, 20, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 6, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2025, 6, 27, 0, 0, 0, 0, time.UTC)}, // day of month or Friday
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 6, 20, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 15m", time.Date(2025, 6, 20, 10, 15, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := scheduler.Parse(c.spec)
+		if assert.NoError(t, err, c.spec) {
+			assert.Equal(t, c.next, schedule.Next(base), c.spec)
+		}
+	}
+
+	schedule, _ := scheduler.Parse("0 0 30 2 *")
+	assert.True(t, schedule.Next(base).IsZero())
+}
+
+/**
+* TestCronParseErrors
+* Malformed expressions are rejected
+ */
+func TestCronParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 30s", "@every soon"} {
+		_, err := scheduler.Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+/**
+* TestMemoryLocker
+* A held lock is refused until released or expired, a stale release does not free a new holder
+ */
+func TestMemoryLocker(t *testing.T) {
+	locker := &scheduler.MemoryLocker{}
+
+	release, ok, err := locker.Lock("job", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, _ = locker.Lock("job", time.Minute)
+	assert.False(t, ok)
+	held, _ := locker.Held("job")
+	assert.True(t, held)
+
+	release()
+	held, _ = locker.Held("job")
+	assert.False(t, held)
+
+	stale, ok, _ := locker.Lock("short", time.Millisecond)
+	assert.True(t, ok)
+	time.Sleep(5 * time.Millisecond)
+	_, ok, _ = locker.Lock("short", time.Minute)
+	assert.True(t, ok)
+	stale()
+	held, _ = locker.Held("short")
+	assert.True(t, held)
+}
+
+// memoryHistory ...
+type memoryHistory struct {
+	mu   sync.Mutex
+	runs []scheduler.Run
+}
+
+func (h *memoryHistory) Start(run *scheduler.Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	run.ID = int64(len(h.runs) + 1)
+	h.runs = append(h.runs, *run)
+	return nil
+}
+
+func (h *memoryHistory) Finish(run *scheduler.Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs[run.ID-1] = *run
+	return nil
+}
+
+/**
+* TestSchedulerTrigger
+* A manual run is recorded with its output, a second trigger while it runs is refused
+ */
+func TestSchedulerTrigger(t *testing.T) {
+	history := &memoryHistory{}
+	s := &scheduler.Scheduler{Locker: &scheduler.MemoryLocker{}, History: history, Instance: "test"}
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	assert.NoError(t, s.Add(scheduler.Job{Name: "report", Spec: "0 7 * * *", Run: func(ctx context.Context, trigger string) (string, error) {
+		<-release
+		return "done by " + trigger, nil
+	}}))
+	assert.NoError(t, s.Add(scheduler.Job{Name: "broken", Spec: "off", Run: func(ctx context.Context, trigger string) (string, error) {
+		defer close(done)
+		return "", errors.New("no data")
+	}}))
+	assert.Error(t, s.Add(scheduler.Job{Name: "report", Run: func(ctx context.Context, trigger string) (string, error) { return "", nil }}))
+	assert.Error(t, s.Add(scheduler.Job{Name: "invalid", Spec: "every day", Run: func(ctx context.Context, trigger string) (string, error) { return "", nil }}))
+	assert.Error(t, s.Add(scheduler.Job{Name: "never", Spec: "0 0 30 2 *", Run: func(ctx context.Context, trigger string) (string, error) { return "", nil }}))
+
+	run, err := s.Trigger("report", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), run.ID)
+	assert.Equal(t, scheduler.StatusRunning, run.Status)
+	assert.Equal(t, int64(7), run.TriggeredBy)
+
+	_, err = s.Trigger("report", 7)
+	assert.ErrorIs(t, err, scheduler.ErrJobRunning)
+	_, err = s.Trigger("missing", 7)
+	assert.ErrorIs(t, err, scheduler.ErrUnknownJob)
+
+	statuses := s.Status()
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, "broken", statuses[0].Name)
+		assert.False(t, statuses[0].Enabled)
+		assert.Equal(t, "report", statuses[1].Name)
+		assert.True(t, statuses[1].Running)
+		assert.Equal(t, 7, statuses[1].NextRun.Hour())
+	}
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		history.mu.Lock()
+		defer history.mu.Unlock()
+		return history.runs[0].Status == scheduler.StatusSuccess
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "done by manual", history.runs[0].Output)
+
+	_, err = s.Trigger("broken", 0)
+	assert.NoError(t, err)
+	<-done
+	assert.Eventually(t, func() bool {
+		history.mu.Lock()
+		defer history.mu.Unlock()
+		return history.runs[1].Status == scheduler.StatusFailed && history.runs[1].Error == "no data"
+	}, time.Second, 5*time.Millisecond)
+}
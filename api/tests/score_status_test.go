@@ -0,0 +1,60 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestScoreStatusDefaults
+* Without thresholds the 25/50/75 bands apply, scores below the lowest band take it
+ */
+func TestScoreStatusDefaults(t *testing.T) {
+	var none models.ScoreThresholds
+	assert.Equal(t, "Diam", none.Status(2025, "all", "skpd", -3).Label)
+	assert.Equal(t, "Diam", none.Status(2025, "all", "skpd", 24.99).Label)
+	assert.Equal(t, "Berjalan", none.Status(2025, "all", "skpd", 25).Label)
+	assert.Equal(t, "Berlari", none.Status(2025, "all", "skpd", 74.9).Label)
+	assert.Equal(t, models.ScoreStatus{Label: "Melesat", Color: "#16a34a"}, none.Status(2025, "all", "skpd", 75))
+}
+
+/**
+* TestScoreStatusResolution
+* The latest tahun_from not after the year wins, then the most specific set of that year
+ */
+func TestScoreStatusResolution(t *testing.T) {
+	thresholds := models.ScoreThresholds{
+		{TahunFrom: 2000, MinScore: 0, Label: "Diam"},
+		{TahunFrom: 2000, MinScore: 50, Label: "Berlari"},
+		{TahunFrom: 2026, MinScore: 60, Label: "Baik"},
+		{TahunFrom: 2026, MinScore: 0, Label: "Kurang"},
+		{TahunFrom: 2025, JenisOpd: "kecamatan", MinScore: 0, Label: "Kec rendah"},
+		{TahunFrom: 2025, JenisOpd: "kecamatan", MinScore: 40, Label: "Kec tinggi"},
+		{TahunFrom: 2025, Category: "barjas", MinScore: 0, Label: "Barjas rendah"},
+		{TahunFrom: 2025, Category: "barjas", MinScore: 80, Label: "Barjas tinggi"},
+		{TahunFrom: 2025, Category: "barjas", JenisOpd: "kecamatan", MinScore: 10, Label: "Barjas kec"},
+	}
+
+	assert.Equal(t, "Berlari", thresholds.Status(2025, "all", "skpd", 55).Label)
+	assert.Equal(t, "Kurang", thresholds.Status(2026, "all", "skpd", 55).Label)
+	assert.Equal(t, "Baik", thresholds.Status(2027, "all", "skpd", 60).Label)
+	assert.Equal(t, "Berlari", thresholds.Status(2024, "all", "kecamatan", 55).Label)
+	assert.Equal(t, "Kec tinggi", thresholds.Status(2025, "all", "kecamatan", 55).Label)
+	assert.Equal(t, "Barjas rendah", thresholds.Status(2025, "barjas", "skpd", 79).Label)
+	assert.Equal(t, "Barjas kec", thresholds.Status(2025, "barjas", "kecamatan", 5).Label)
+	// A newer general set replaces older overrides
+	assert.Equal(t, "Kurang", thresholds.Status(2026, "all", "kecamatan", 55).Label)
+	assert.Equal(t, "Kurang", thresholds.Status(2026, "barjas", "kecamatan", 55).Label)
+
+	bands := thresholds.Bands(2026, "all", "skpd")
+	if assert.Len(t, bands, 2) {
+		assert.Equal(t, "Kurang", bands[0].Label)
+		assert.Equal(t, "Baik", bands[1].Label)
+	}
+	assert.Len(t, thresholds.Bands(1999, "all", "skpd"), 4) // the defaults
+}
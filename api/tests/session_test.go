@@ -0,0 +1,162 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/controllers"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionRouter serves the cookie refresh, logout and a write endpoint
+func sessionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	auth := new(controllers.AuthController)
+	user := new(controllers.UserController)
+	v1 := r.Group("/v1")
+	v1.POST("/token/refresh-cookie", auth.RefreshCookie)
+	v1.GET("/user/logout", user.Logout)
+	v1.POST("/write", TokenAuthMiddleware(), func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "written"}) })
+	v1.GET("/read", TokenAuthMiddleware(), func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "read"}) })
+	return r
+}
+
+// cookieSession logs the user in and returns the token pair with a CSRF token, as the login cookies carry them
+func cookieSession(t *testing.T, userID int64) (*models.TokenDetails, string) {
+	td, err := models.AuthModel{}.CreateToken(userID)
+	if err == nil {
+		err = models.AuthModel{}.CreateAuth(userID, td)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return td, "csrf-" + td.AccessUUID
+}
+
+// cookieRequest sends the session cookies, and csrfHeader in X-CSRF-Token when set
+func cookieRequest(r *gin.Engine, method, target string, td *models.TokenDetails, csrf, csrfHeader string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.AddCookie(&http.Cookie{Name: models.AccessTokenCookie, Value: td.AccessToken})
+	req.AddCookie(&http.Cookie{Name: models.RefreshTokenCookie, Value: td.RefreshToken})
+	req.AddCookie(&http.Cookie{Name: models.CSRFTokenCookie, Value: csrf})
+	if csrfHeader != "" {
+		req.Header.Set(models.CSRFTokenHeader, csrfHeader)
+	}
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+// responseCookies indexes the cookies set by a response by name
+func responseCookies(resp *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range resp.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+/**
+* TestSessionCSRF
+* Cookie-authenticated writes and cookie refreshes need the X-CSRF-Token header to match the
+* csrf_token cookie. Reads and bearer tokens do not.
+ */
+func TestSessionCSRF(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "access-secret")
+	t.Setenv("REFRESH_SECRET", "refresh-secret")
+	store := redisDB(t)
+	dbmap := sqliteDB(t, userSchema...)
+	addUser(t, dbmap, 1, "user")
+	r := sessionRouter()
+	td, csrf := cookieSession(t, 1)
+
+	assert.Equal(t, http.StatusForbidden, cookieRequest(r, http.MethodPost, "/v1/write", td, csrf, "").Code)
+	assert.Equal(t, http.StatusForbidden, cookieRequest(r, http.MethodPost, "/v1/write", td, csrf, "another-token").Code)
+	assert.Equal(t, http.StatusForbidden, cookieRequest(r, http.MethodPost, "/v1/write", td, "", "").Code)
+	assert.Equal(t, http.StatusOK, cookieRequest(r, http.MethodPost, "/v1/write", td, csrf, csrf).Code)
+	assert.Equal(t, http.StatusOK, cookieRequest(r, http.MethodGet, "/v1/read", td, csrf, "").Code)
+	assert.Equal(t, http.StatusOK, bearerRequest(r, http.MethodPost, "/v1/write", td.AccessToken).Code)
+
+	assert.Equal(t, http.StatusForbidden, cookieRequest(r, http.MethodPost, "/v1/token/refresh-cookie", td, csrf, "").Code)
+	assert.Equal(t, http.StatusForbidden, cookieRequest(r, http.MethodPost, "/v1/token/refresh-cookie", td, csrf, "another-token").Code)
+	// Refused refreshes leave the refresh token usable
+	_, ok := store.get(td.RefreshUUID)
+	assert.True(t, ok)
+}
+
+/**
+* TestSessionCookieFlags
+* A cookie refresh rotates the pair into HttpOnly, SameSite=Strict and Secure cookies, the CSRF
+* cookie readable by scripts. COOKIE_SECURE=FALSE drops Secure for plain http development.
+ */
+func TestSessionCookieFlags(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "access-secret")
+	t.Setenv("REFRESH_SECRET", "refresh-secret")
+	store := redisDB(t)
+	r := sessionRouter()
+
+	for _, secure := range []bool{true, false} {
+		if !secure {
+			t.Setenv("COOKIE_SECURE", "FALSE")
+		}
+		td, csrf := cookieSession(t, 1)
+
+		resp := cookieRequest(r, http.MethodPost, "/v1/token/refresh-cookie", td, csrf, csrf)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		_, ok := store.get(td.RefreshUUID)
+		assert.False(t, ok, "the refresh token is rotated")
+
+		cookies := responseCookies(resp)
+		for _, name := range []string{models.AccessTokenCookie, models.RefreshTokenCookie, models.CSRFTokenCookie} {
+			cookie, ok := cookies[name]
+			if !assert.True(t, ok, name) {
+				continue
+			}
+			assert.NotEmpty(t, cookie.Value, name)
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite, name)
+			assert.Equal(t, secure, cookie.Secure, name)
+			assert.Equal(t, name != models.CSRFTokenCookie, cookie.HttpOnly, name)
+		}
+		assert.Equal(t, "/v1", cookies[models.RefreshTokenCookie].Path)
+		assert.Contains(t, resp.Body.String(), cookies[models.CSRFTokenCookie].Value)
+	}
+}
+
+/**
+* TestSessionCookieLogout
+* Logging out of a cookie session revokes the refresh token the browser holds and clears the cookies
+ */
+func TestSessionCookieLogout(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "access-secret")
+	t.Setenv("REFRESH_SECRET", "refresh-secret")
+	store := redisDB(t)
+	r := sessionRouter()
+	td, csrf := cookieSession(t, 1)
+
+	resp := cookieRequest(r, http.MethodGet, "/v1/user/logout", td, csrf, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	_, ok := store.get(td.AccessUUID)
+	assert.False(t, ok, "access token revoked")
+	_, ok = store.get(td.RefreshUUID)
+	assert.False(t, ok, "refresh token revoked")
+	for name, cookie := range responseCookies(resp) {
+		assert.Equal(t, -1, cookie.MaxAge, name)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, cookieRequest(r, http.MethodPost, "/v1/token/refresh-cookie", td, csrf, csrf).Code)
+
+	// A bearer logout leaves the refresh token to the client
+	td, _ = cookieSession(t, 1)
+	resp = bearerRequest(r, http.MethodGet, "/v1/user/logout", td.AccessToken)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	_, ok = store.get(td.RefreshUUID)
+	assert.True(t, ok)
+}
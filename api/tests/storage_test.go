@@ -0,0 +1,151 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/Massad/gin-boilerplate/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* TestDetectAttachmentType
+* Content must match the extension, the extension picks the stored type
+ */
+func TestDetectAttachmentType(t *testing.T) {
+	contentType, err := models.DetectAttachmentType("circular.pdf", []byte("%PDF-1.7\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", contentType)
+
+	contentType, err = models.DetectAttachmentType("data.xlsx", []byte("PK\x03\x04rest"))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", contentType)
+
+	contentType, err = models.DetectAttachmentType("legacy.xls", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.ms-excel", contentType)
+
+	// An executable renamed to .pdf is rejected
+	_, err = models.DetectAttachmentType("circular.pdf", []byte("MZ\x90\x00"))
+	assert.ErrorIs(t, err, models.ErrAttachmentType)
+
+	_, err = models.DetectAttachmentType("script.sh", []byte("#!/bin/sh\n"))
+	assert.ErrorIs(t, err, models.ErrAttachmentType)
+}
+
+// exerciseStorage runs the same put/get/delete cycle against any backend
+func exerciseStorage(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	key := "articles/0/storage-test.txt"
+	body := []byte("attachment body")
+
+	assert.NoError(t, s.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "text/plain"))
+
+	reader, err := s.Get(ctx, key)
+	assert.NoError(t, err)
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, body, stored)
+
+	assert.NoError(t, s.Delete(ctx, key))
+	_, err = s.Get(ctx, key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Deleting twice is not an error
+	assert.NoError(t, s.Delete(ctx, key))
+}
+
+/**
+* TestLocalStorage
+* Put/Get/Delete and signed URL verification on the filesystem driver
+ */
+func TestLocalStorage(t *testing.T) {
+	local, err := storage.NewLocalStorage(t.TempDir(), "/v1/attachments/download", []byte("secret"))
+	assert.NoError(t, err)
+
+	exerciseStorage(t, local)
+
+	ctx := context.Background()
+	body := []byte("signed")
+	assert.NoError(t, local.Put(ctx, "articles/1/a.pdf", bytes.NewReader(body), int64(len(body)), "application/pdf"))
+
+	signed, err := local.SignedURL(ctx, "articles/1/a.pdf", "circular.pdf", time.Minute)
+	assert.NoError(t, err)
+	parsed, _ := url.Parse(signed)
+
+	reader, filename, err := local.Open(ctx, parsed.Query())
+	assert.NoError(t, err)
+	assert.Equal(t, "circular.pdf", filename)
+	reader.Close()
+
+	// Tampering with the filename breaks the signature
+	tampered := parsed.Query()
+	tampered.Set("filename", "other.pdf")
+	_, _, err = local.Open(ctx, tampered)
+	assert.ErrorIs(t, err, storage.ErrInvalidSignature)
+
+	// Expired URLs are rejected
+	expired, _ := local.SignedURL(ctx, "articles/1/a.pdf", "circular.pdf", -time.Minute)
+	parsed, _ = url.Parse(expired)
+	_, _, err = local.Open(ctx, parsed.Query())
+	assert.ErrorIs(t, err, storage.ErrInvalidSignature)
+
+	// Keys can not escape the root
+	_, err = local.Get(ctx, "../../etc/passwd")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+/**
+* TestS3Storage
+* Runs against a local MinIO, e.g.
+* docker run -p 9002:9000 minio/minio server /data
+* with S3_ENDPOINT=127.0.0.1:9002 S3_ACCESS_KEY=minioadmin S3_SECRET_KEY=minioadmin
+ */
+func TestS3Storage(t *testing.T) {
+	if os.Getenv("S3_ENDPOINT") == "" {
+		t.Skip("S3_ENDPOINT is not set")
+	}
+
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		bucket = "gin-boilerplate-test"
+	}
+
+	s3, err := storage.NewS3Storage(storage.S3Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		UseSSL:    os.Getenv("S3_USE_SSL") == "TRUE",
+	})
+	assert.NoError(t, err)
+
+	exerciseStorage(t, s3)
+
+	ctx := context.Background()
+	body := []byte("presigned")
+	assert.NoError(t, s3.Put(ctx, "articles/1/b.pdf", bytes.NewReader(body), int64(len(body)), "application/pdf"))
+	defer s3.Delete(ctx, "articles/1/b.pdf")
+
+	signed, err := s3.SignedURL(ctx, "articles/1/b.pdf", "circular.pdf", time.Minute)
+	assert.NoError(t, err)
+
+	resp, err := http.Get(signed)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	downloaded, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, body, downloaded)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "circular.pdf")
+}
@@ -0,0 +1,352 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Massad/gin-boilerplate/db"
+	"github.com/go-gorp/gorp"
+	_redis "github.com/go-redis/redis/v7"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// memoryRedis is an in-process Redis answering the commands the models use: GET, SET with EX, PX
+// and NX, DEL, EXISTS, EXPIRE, PEXPIRE, TTL and SCAN
+type memoryRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+// redisDB points db.GetRedis at a fresh memoryRedis until the test ends
+func redisDB(t *testing.T) *memoryRedis {
+	r := &memoryRedis{values: map[string]string{}, expires: map[string]time.Time{}}
+	previous := db.RedisClient
+	db.RedisClient = _redis.NewClient(&_redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go r.serve(server)
+			return client, nil
+		},
+	})
+	t.Cleanup(func() {
+		db.RedisClient.Close()
+		db.RedisClient = previous
+	})
+	return r
+}
+
+// get reads a key the way GET does
+func (r *memoryRedis) get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookup(key)
+}
+
+// lookup drops the key when it expired, the caller holds mu
+func (r *memoryRedis) lookup(key string) (string, bool) {
+	if expires, ok := r.expires[key]; ok && !time.Now().Before(expires) {
+		delete(r.values, key)
+		delete(r.expires, key)
+	}
+	value, ok := r.values[key]
+	return value, ok
+}
+
+// serve reads RESP commands from conn until it is closed
+func (r *memoryRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, r.execute(args)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// execute runs one command and returns its RESP reply
+func (r *memoryRedis) execute(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	integer := func(n int) string { return fmt.Sprintf(":%d\r\n", n) }
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+	switch strings.ToLower(args[0]) {
+	case "ping":
+		return "+PONG\r\n"
+	case "get":
+		if value, ok := r.lookup(args[1]); ok {
+			return bulk(value)
+		}
+		return "$-1\r\n"
+	case "set":
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "ex", "px":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Second
+				if strings.ToLower(args[i]) == "px" {
+					ttl = time.Duration(n) * time.Millisecond
+				}
+				i++
+			case "nx":
+				if _, ok := r.lookup(args[1]); ok {
+					return "$-1\r\n"
+				}
+			}
+		}
+		r.values[args[1]] = args[2]
+		delete(r.expires, args[1])
+		if ttl > 0 {
+			r.expires[args[1]] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "del", "exists":
+		count := 0
+		for _, key := range args[1:] {
+			if _, ok := r.lookup(key); ok {
+				count++
+				if strings.ToLower(args[0]) == "del" {
+					delete(r.values, key)
+					delete(r.expires, key)
+				}
+			}
+		}
+		return integer(count)
+	case "expire", "pexpire":
+		if _, ok := r.lookup(args[1]); !ok {
+			return integer(0)
+		}
+		n, _ := strconv.Atoi(args[2])
+		unit := time.Second
+		if strings.ToLower(args[0]) == "pexpire" {
+			unit = time.Millisecond
+		}
+		r.expires[args[1]] = time.Now().Add(time.Duration(n) * unit)
+		return integer(1)
+	case "ttl":
+		if _, ok := r.lookup(args[1]); !ok {
+			return integer(-2)
+		}
+		expires, ok := r.expires[args[1]]
+		if !ok {
+			return integer(-1)
+		}
+		return integer(int(time.Until(expires).Seconds()))
+	case "scan":
+		// One page with every match, cursor 0 ends the scan
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.ToLower(args[i]) == "match" {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range r.values {
+			if _, ok := r.lookup(key); !ok {
+				continue
+			}
+			if matched, _ := path.Match(pattern, key); matched {
+				keys = append(keys, bulk(key))
+			}
+		}
+		return fmt.Sprintf("*2\r\n%s*%d\r\n%s", bulk("0"), len(keys), strings.Join(keys, ""))
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// postgresRewrites turn the Postgres syntax of the models into SQLite: numbered placeholders,
+// UPDATE aliases without AS, casts, the JSON builders and the current epoch
+var postgresRewrites = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`\$(\d+)`), "?$1"},
+	{regexp.MustCompile(`(?i)\bUPDATE\s+(\S+)\s+(\w+)\s+SET\b`), "UPDATE $1 AS $2 SET"},
+	{regexp.MustCompile(`::\w+`), ""},
+	{regexp.MustCompile(`\bjson_build_object\(`), "json_object("},
+	{regexp.MustCompile(`\bjson_agg\(`), "json_group_array("},
+	{regexp.MustCompile(`(?i)EXTRACT\(EPOCH FROM NOW\(\)\)`), "unixepoch()"},
+}
+
+// rewritePostgres ...
+func rewritePostgres(query string) string {
+	for _, rewrite := range postgresRewrites {
+		query = rewrite.pattern.ReplaceAllString(query, rewrite.replace)
+	}
+	return query
+}
+
+// postgresDriver opens in-memory SQLite databases with a "public" schema and runs the models'
+// queries through rewritePostgres
+type postgresDriver struct{ sqlite *sqlite3.SQLiteDriver }
+
+// Open implements driver.Driver
+func (d postgresDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.sqlite.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConn{conn}, nil
+}
+
+type postgresConn struct{ driver.Conn }
+
+// Prepare rewrites the query, database/sql falls back to it for every Exec and Query
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(rewritePostgres(query))
+	if err != nil {
+		return nil, err
+	}
+	return postgresStmt{stmt}, nil
+}
+
+type postgresStmt struct{ driver.Stmt }
+
+// Query answers text as []byte like lib/pq, JSONRaw only scans bytes
+func (s postgresStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.Stmt.Query(args)
+	if err != nil {
+		return nil, err
+	}
+	return postgresRows{rows}, nil
+}
+
+type postgresRows struct{ driver.Rows }
+
+func (r postgresRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, value := range dest {
+		if text, ok := value.(string); ok {
+			dest[i] = []byte(text)
+		}
+	}
+	return nil
+}
+
+var registerSQLite sync.Once
+
+// sqliteDB points db.GetDB at an in-memory SQLite database created with schema until the test ends
+func sqliteDB(t *testing.T, schema ...string) *gorp.DbMap {
+	registerSQLite.Do(func() {
+		sql.Register("sqlite3_postgres", postgresDriver{&sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				_, err := conn.Exec(`ATTACH DATABASE ':memory:' AS public`, nil)
+				return err
+			},
+		}})
+	})
+
+	conn, err := sql.Open("sqlite3_postgres", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection is a database of its own
+	conn.SetMaxOpenConns(1)
+	for _, statement := range schema {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("%v: %s", err, statement)
+		}
+	}
+
+	previous := db.GetDB()
+	dbmap := &gorp.DbMap{Db: conn, Dialect: gorp.PostgresDialect{}}
+	db.SetDB(dbmap)
+	t.Cleanup(func() {
+		db.SetDB(previous)
+		conn.Close()
+	})
+	return dbmap
+}
+
+// userSchema are the users with their roles and permissions
+var userSchema = []string{
+	`CREATE TABLE public."user" (id INTEGER PRIMARY KEY, email TEXT, username TEXT, name TEXT, password TEXT,
+		failed_attempts INTEGER DEFAULT 0, locked_until INTEGER DEFAULT 0, idsatker INTEGER, jenis_opd TEXT)`,
+	`CREATE TABLE public.roles (id INTEGER PRIMARY KEY, name TEXT, updated_at INTEGER DEFAULT 0, created_at INTEGER DEFAULT 0)`,
+	`CREATE TABLE public.user_roles (user_id INTEGER, role_id INTEGER)`,
+	`CREATE TABLE public.permissions (id INTEGER PRIMARY KEY, name TEXT)`,
+	`CREATE TABLE public.role_permissions (role_id INTEGER, permission_id INTEGER)`,
+	`INSERT INTO public.roles (id, name) VALUES (1, 'admin'), (2, 'user')`,
+}
+
+// addUser inserts a user with a role and the permissions given to a role of its own
+func addUser(t *testing.T, dbmap *gorp.DbMap, id int64, role string, permissions ...string) {
+	_, err := dbmap.Exec(`INSERT INTO public."user" (id, email, username, name) VALUES ($1, $2, $3, $4)`,
+		id, fmt.Sprintf("user%d@example.com", id), fmt.Sprintf("user%d", id), fmt.Sprintf("User %d", id))
+	if err == nil {
+		_, err = dbmap.Exec(`INSERT INTO public.user_roles (user_id, role_id) SELECT $1, id FROM public.roles WHERE name = $2`, id, role)
+	}
+	for _, permission := range permissions {
+		if err != nil {
+			break
+		}
+		_, err = dbmap.Exec(`INSERT INTO public.roles (name) VALUES ($1)`, fmt.Sprintf("user%d_%s", id, permission))
+		if err == nil {
+			_, err = dbmap.Exec(`INSERT INTO public.permissions (name) SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM public.permissions WHERE name = $1)`, permission)
+		}
+		if err == nil {
+			_, err = dbmap.Exec(`INSERT INTO public.role_permissions (role_id, permission_id)
+				SELECT r.id, p.id FROM public.roles r, public.permissions p WHERE r.name = $1 AND p.name = $2`,
+				fmt.Sprintf("user%d_%s", id, permission), permission)
+		}
+		if err == nil {
+			_, err = dbmap.Exec(`INSERT INTO public.user_roles (user_id, role_id) SELECT $1, id FROM public.roles WHERE name = $2`,
+				id, fmt.Sprintf("user%d_%s", id, permission))
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
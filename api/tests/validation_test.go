@@ -0,0 +1,101 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// validationRow ...
+func validationRow(idsatker, bulan int, pRealisasi, kRealisasi float64) models.RealisasiRow {
+	row := models.RealisasiRow{Idsatker: idsatker, Bulan: bulan}
+	row.FisikRealisasi = [3]float64{pRealisasi, kRealisasi, pRealisasi}
+	row.FisikTarget = [3]float64{10, 10 * float64(bulan), 10}
+	return row
+}
+
+/**
+* TestPeriodikMismatches
+* p_ values add up per satker since January and must match the k_ values of each month
+ */
+func TestPeriodikMismatches(t *testing.T) {
+	rows := []models.RealisasiRow{
+		validationRow(101, 1, 4, 4),
+		validationRow(101, 2, 6, 10),
+		validationRow(101, 3, 5, 16), // one more than 4+6+5
+		validationRow(102, 1, 3, 3),
+		validationRow(102, 2, 3, 6.005),
+	}
+
+	issues := models.PeriodikMismatches(rows, 2025, 0, 0.01)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, 101, issues[0].Idsatker)
+		assert.Equal(t, 3, issues[0].Bulan)
+		assert.Equal(t, "de_detail_fisik", issues[0].Table)
+		assert.Equal(t, "k_fisik_realisasi", issues[0].Field)
+		assert.InDelta(t, 16, issues[0].Value, 1e-9)
+		assert.InDelta(t, 15, issues[0].Expected, 1e-9)
+	}
+
+	// Earlier months still add up when only one month is reported
+	assert.Empty(t, models.PeriodikMismatches(rows, 2025, 2, 0.01))
+	assert.Len(t, models.PeriodikMismatches(rows, 2025, 3, 0.01), 1)
+}
+
+/**
+* TestRealisasiOverTarget
+* Realisasi and stage selesai above a non-zero target are reported
+ */
+func TestRealisasiOverTarget(t *testing.T) {
+	row := validationRow(101, 1, 12, 12)
+	row.AnggaranRealisasi = [3]float64{5, 0, 0} // no target, not reported
+	row.BarjasStages[1][2] = [3]int64{7, 5, 0}
+
+	issues := models.RealisasiOverTarget([]models.RealisasiRow{row}, 2025, 0, 0.01)
+	fields := []string{}
+	for _, issue := range issues {
+		fields = append(fields, issue.Field)
+	}
+	assert.Equal(t, []string{"c_fisik_realisasi", "k_fisik_realisasi", "p_fisik_realisasi", "k_pengadaan_selesai"}, fields)
+	assert.Empty(t, models.RealisasiOverTarget([]models.RealisasiRow{row}, 2025, 2, 0.01))
+}
+
+/**
+* TestValidationReport
+* Only failed checks of error severity fail the report, issues are capped by the limit
+ */
+func TestValidationReport(t *testing.T) {
+	checks := models.ValidationChecks()
+	names := map[string]models.ValidationCheck{}
+	for _, check := range checks {
+		names[check.Name] = check
+	}
+	for _, name := range []string{"duplicate_ranking_rows", "orphan_detail_rows", "periodik_kumulatif_mismatch", "realisasi_over_target"} {
+		assert.Contains(t, names, name)
+	}
+
+	issues := []models.ValidationIssue{{Table: "de_detail_fisik"}, {Table: "de_detail_fisik"}, {Table: "de_detail_fisik"}}
+	warning := models.NewValidationResult(names["realisasi_over_target"], issues, 2)
+	assert.False(t, warning.Passed)
+	assert.Equal(t, 3, warning.Count)
+	assert.Len(t, warning.Issues, 2)
+	assert.True(t, warning.Truncated)
+
+	report := models.ValidationReport{Checks: []models.ValidationResult{
+		warning,
+		models.NewValidationResult(names["duplicate_ranking_rows"], nil, 2),
+	}}
+	report.Summarize()
+	assert.True(t, report.Passed)
+	assert.Equal(t, 1, report.Warnings)
+	assert.NotNil(t, report.Checks[1].Issues)
+
+	report.Checks = append(report.Checks, models.NewValidationResult(names["orphan_detail_rows"], issues[:1], 0))
+	report.Summarize()
+	assert.False(t, report.Passed)
+	assert.Equal(t, 1, report.Errors)
+}
@@ -0,0 +1,68 @@
+//go:build all
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/Massad/gin-boilerplate/forms"
+	"github.com/Massad/gin-boilerplate/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// yearSeries builds an anggaran series from monthly realisasi, months with realisasi are reported
+func yearSeries(year int, realisasi ...float64) models.YearSeries {
+	series := models.YearSeries{Year: year}
+	card := models.RealisasiData{Category: "anggaran"}
+	for i, value := range realisasi {
+		card.Monthly = append(card.Monthly, models.RealisasiMonthlyItem{Month: models.GetMonthName(i + 1), Value: value / 10, Realisasi: value})
+		series.Reported[i] = value > 0
+	}
+	series.Data = []models.RealisasiData{card}
+	return series
+}
+
+/**
+* TestCompareYearSeries
+* Monthly and cumulative deltas with growth, ahead is read at the latest reported month
+ */
+func TestCompareYearSeries(t *testing.T) {
+	categories := models.CompareYearSeries(yearSeries(2025, 100, 300, 0), yearSeries(2024, 200, 100, 400))
+	if !assert.Len(t, categories, 1) {
+		return
+	}
+	anggaran := categories[0]
+	assert.Equal(t, 2024, anggaran.CompareYear)
+	assert.Equal(t, "Februari", anggaran.LatestMonth)
+	assert.True(t, anggaran.Ahead)
+
+	january := anggaran.Months[0]
+	assert.InDelta(t, -100, january.RealisasiDelta, 1e-9)
+	assert.InDelta(t, -50, *january.RealisasiGrowth, 1e-9)
+	assert.InDelta(t, -10, january.ValueDelta, 1e-9)
+	assert.False(t, january.Ahead)
+
+	february := anggaran.Months[1]
+	assert.InDelta(t, 400, february.Cumulative, 1e-9)
+	assert.InDelta(t, 300, february.CompareCumulative, 1e-9)
+	assert.InDelta(t, 100.0/3, *february.CumulativeGrowth, 1e-9)
+	assert.True(t, february.Complete)
+
+	march := anggaran.Months[2]
+	assert.False(t, march.Complete)
+	assert.False(t, march.Ahead)
+
+	zero := models.CompareYearSeries(yearSeries(2025, 100), yearSeries(2024, 0))
+	assert.Nil(t, zero[0].Months[0].RealisasiGrowth)
+}
+
+/**
+* TestYoYYears
+* The tahun list keeps its order, the default compares the current year with the previous one
+ */
+func TestYoYYears(t *testing.T) {
+	assert.Equal(t, []int{2025, 2023, 2024}, forms.YoYQueryForm{Tahun: "2025, 2023,2024,2023"}.Years())
+	years := forms.YoYQueryForm{}.Years()
+	assert.Equal(t, years[0]-1, years[1])
+}